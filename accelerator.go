@@ -0,0 +1,109 @@
+package izapple2
+
+import (
+	"fmt"
+	"strconv"
+)
+
+/*
+Accelerators run the CPU faster than the board clock. They go back to the
+board speed for a while on the accesses that need the original timing,
+like the Disk II, the speaker or the paddles.
+
+The emulated devices run on the board clock, a.cycles. Accelerated, each
+CPU cycle is a fraction of a board cycle and the fractions are accumulated.
+The video, the speaker, the disks and the real time pacing see the CPU
+running faster. The slowdowns run the CPU at the board speed again, for
+the software loops timing the hardware.
+*/
+
+type accelerator interface {
+	// ioAccess is called on every access to the $C0xx page
+	ioAccess(address uint8)
+}
+
+// Durations of the slowdowns, in cycles of the board clock
+const (
+	acceleratorSlowdownDisk    = uint64(50 * 1023) // 50ms
+	acceleratorSlowdownSpeaker = uint64(5 * 1023)  // 5ms
+	acceleratorSlowdownPaddles = uint64(5 * 1023)  // 5ms
+)
+
+func (a *Apple2) setAccelerator(card accelerator) {
+	a.accelerator = card
+}
+
+// setAcceleratorSpeed sets the speed of the CPU in MHz, 0 to use the board speed
+func (a *Apple2) setAcceleratorSpeed(mhz float64) {
+	a.acceleratorMhz = mhz
+}
+
+// slowDownAccelerator runs at the board speed for some cycles
+func (a *Apple2) slowDownAccelerator(cycles uint64) {
+	until := a.cycles + cycles
+	if until > a.acceleratorSlowUntil {
+		a.acceleratorSlowUntil = until
+	}
+}
+
+func (a *Apple2) isAcceleratorSlowedDown() bool {
+	return a.cycles < a.acceleratorSlowUntil
+}
+
+// boardCycles converts the cycles of a CPU instruction to cycles of the board clock
+func (a *Apple2) boardCycles(cpuCycles uint64) uint64 {
+	if a.acceleratorMhz == 0 || a.isAcceleratorSlowedDown() {
+		return cpuCycles
+	}
+	a.acceleratorCycles += float64(cpuCycles) * a.timing.ClockMhz / a.acceleratorMhz
+	cycles := uint64(a.acceleratorCycles)
+	a.acceleratorCycles -= float64(cycles)
+	return cycles
+}
+
+// acceleratorSlowdown returns the slowdown needed for an access to a softswitch
+// that needs the board timing. The slot accesses are on the slots mask.
+func acceleratorSlowdown(address uint8, speaker bool, paddles bool, slots uint8) uint64 {
+	switch {
+	case address == 0x30:
+		if speaker {
+			return acceleratorSlowdownSpeaker
+		}
+	case address >= 0x64 && address <= 0x67, address == 0x70:
+		if paddles {
+			return acceleratorSlowdownPaddles
+		}
+	case address >= 0x90:
+		slot := (address - 0x80) >> 4
+		if slots&(1<<slot) != 0 {
+			return acceleratorSlowdownDisk
+		}
+	}
+	return 0
+}
+
+// paramsGetSpeed returns a speed in MHz from a card parameter
+func paramsGetSpeed(params map[string]string, name string) (float64, error) {
+	value := paramsGetString(params, name)
+	mhz, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid speed '%s' for %s", value, name)
+	}
+	if mhz < CPUClockMhz {
+		return 0, fmt.Errorf("the speed of %s must be at least %v MHz", name, CPUClockMhz)
+	}
+	return mhz, nil
+}
+
+// paramsGetSlots returns a mask with a bit for each slot digit on the parameter, like "56"
+func paramsGetSlots(params map[string]string, name string) (uint8, error) {
+	value := paramsGetString(params, name)
+	mask := uint8(0)
+	for _, r := range value {
+		if r < '1' || r > '7' {
+			return 0, fmt.Errorf("invalid slot '%c' for %s", r, name)
+		}
+		mask |= 1 << (r - '0')
+	}
+	return mask, nil
+}
@@ -9,14 +9,17 @@ import (
 
 // Apple2 represents all the components and state of the emulated machine
 type Apple2 struct {
-	Name    string
-	cpu     *iz6502.State
-	mmu     *memoryManager
-	io      *ioC0Page
-	video   screen.VideoSource
-	cg      *CharacterGenerator
-	cards   [8]Card
-	tracers []executionTracer
+	Name        string
+	cpu         *iz6502.State
+	mmu         *memoryManager
+	io          *ioC0Page
+	video       screen.VideoSource
+	timing      screen.VideoTiming
+	palette     *screen.Palette
+	shrPalettes shrPaletteTracker
+	cg          *CharacterGenerator
+	cards       [8]Card
+	tracers     []executionTracer
 
 	softVideoSwitch softVideoSwitch
 	board           string
@@ -25,20 +28,33 @@ type Apple2 struct {
 	isFourColors    bool // An Apple II without the 6 color mod
 	usesMouse       bool
 	commandChannel  chan command
+	commandQueued   chan struct{} // Wakes up the paused emulator
 
 	dmaActive bool
 	dmaSlot   int
 
 	cycles               uint64
-	cycleDurationNs      float64 // Current speed. Inverse of the cpu clock in Ghz
+	publishedCycles      atomic.Uint64 // Copy of cycles for the other goroutines
+	cycleDurationNs      float64       // Current speed. Inverse of the cpu clock in Ghz
 	fastRequestsCounter  int32
+	accelerator          accelerator
+	acceleratorMhz       float64 // Speed set by an accelerator, 0 if not accelerated
+	acceleratorSlowUntil uint64  // Cycle when the accelerator can run fast again
+	acceleratorCycles    float64 // Fraction of board cycle pending while accelerated
 	cycleBreakpoint      uint64
+	pcBreakpoint         uint16
+	pcBreakpointActive   bool
 	breakPoint           bool
 	profile              bool
-	paused               bool
+	paused               atomic.Bool // IsPaused() is called from other goroutines
+	runDone              chan error  // Pending completion of RunCycles
 	cpuTrace             bool
 	forceCaps            bool
+	diskSoundsOn         bool
+	driveSounds          DriveSoundsProvider
 	removableMediaDrives []drive
+	recording            *recording
+	recordingActive      atomic.Bool // IsRecording() is called from other goroutines
 
 	currentFreqMHz float64
 }
@@ -58,6 +74,11 @@ func (a *Apple2) SetSpeakerProvider(s SpeakerProvider) {
 	a.io.setSpeakerProvider(s)
 }
 
+// SetDriveSoundsProvider attaches an external provider for the disk drives sounds
+func (a *Apple2) SetDriveSoundsProvider(s DriveSoundsProvider) {
+	a.driveSounds = s
+}
+
 // SetJoysticksProvider attaches an external joysticks provider
 func (a *Apple2) SetJoysticksProvider(j JoysticksProvider) {
 	a.io.setJoysticksProvider(j)
@@ -75,13 +96,41 @@ func (a *Apple2) UsesMouse() bool {
 
 // IsPaused returns true when emulator is paused
 func (a *Apple2) IsPaused() bool {
-	return a.paused
+	return a.paused.Load()
 }
 
 func (a *Apple2) GetCycles() uint64 {
 	return a.cycles
 }
 
+// GetPublishedCycles returns the cycles as updated by the emulation loop every
+// few instructions. Unlike GetCycles, it can be called from other goroutines.
+func (a *Apple2) GetPublishedCycles() uint64 {
+	return a.publishedCycles.Load()
+}
+
+// GetPC returns the program counter of the 6502. Use it with the emulator paused
+func (a *Apple2) GetPC() uint16 {
+	pc, _ := a.cpu.GetPCAndSP()
+	return pc
+}
+
+// Peek returns the byte the 6502 would read on the address. Use it with the
+// emulator paused, reading the I/O area can toggle soft switches.
+func (a *Apple2) Peek(address uint16) uint8 {
+	return a.mmu.Peek(address)
+}
+
+// Poke writes a byte as the 6502 would. Use it with the emulator paused
+func (a *Apple2) Poke(address uint16, value uint8) {
+	a.mmu.Poke(address, value)
+}
+
+// SetPC moves the program counter of the 6502. Use it with the emulator paused
+func (a *Apple2) SetPC(pc uint16) {
+	a.cpu.SetPC(pc)
+}
+
 func (a *Apple2) GetCurrentFreqMHz() float64 {
 	return a.currentFreqMHz
 }
@@ -92,6 +141,18 @@ func (a *Apple2) SetCycleBreakpoint(cycle uint64) {
 	a.breakPoint = false
 }
 
+// SetPCBreakpoint sets an address to pause the emulator when the 6502 is about to execute it
+func (a *Apple2) SetPCBreakpoint(pc uint16) {
+	a.pcBreakpoint = pc
+	a.pcBreakpointActive = true
+	a.breakPoint = false
+}
+
+// ClearPCBreakpoint disables the breakpoint set with SetPCBreakpoint
+func (a *Apple2) ClearPCBreakpoint() {
+	a.pcBreakpointActive = false
+}
+
 func (a *Apple2) BreakPoint() bool {
 	return a.breakPoint
 }
@@ -106,10 +167,20 @@ func (a *Apple2) IsForceCaps() bool {
 	return a.forceCaps
 }
 
+// IsDiskSounds returns true when the disk drives sounds are enabled
+func (a *Apple2) IsDiskSounds() bool {
+	return a.diskSoundsOn
+}
+
 func (a *Apple2) GetCgPageInfo() (int, int) {
 	return a.cg.getPage(), a.cg.getPages()
 }
 
+// GetVideoTiming returns the frame timing of the video standard, NTSC or PAL
+func (a *Apple2) GetVideoTiming() screen.VideoTiming {
+	return a.timing
+}
+
 func (a *Apple2) RequestFastMode() {
 	// Note: if the fastMode is shorter than maxWaitDuration, there won't be any gain.
 	atomic.AddInt32(&a.fastRequestsCounter, 1)
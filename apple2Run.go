@@ -1,7 +1,9 @@
 package izapple2
 
 import (
+	"errors"
 	"fmt"
+	"sync/atomic"
 	"time"
 )
 
@@ -12,8 +14,9 @@ const (
 )
 
 const (
-	maxWaitDuration = 100 * time.Millisecond
-	cpuSpinLoops    = 100
+	maxWaitDuration     = 100 * time.Millisecond
+	cpuSpinLoops        = 100
+	runUntilCheckCycles = uint64(100_000) // How often the condition of RunUntil is checked
 )
 
 // Run starts the Apple2 emulation
@@ -26,16 +29,20 @@ func (a *Apple2) Start(paused bool) {
 	// Start the processor
 	a.cpu.Reset()
 	a.cycles = a.cpu.GetCycles()
+	a.publishedCycles.Store(a.cycles)
 
 	referenceTime := time.Now()
 	speedReferenceTime := referenceTime
 	speedReferenceCycles := uint64(0)
+	simulatedNs := float64(0)
+	lastCycles := a.cycles
+	executedCycles := uint64(0) // CPU cycles, faster than the board cycles when accelerated
 
-	a.paused = paused
+	a.paused.Store(paused)
 
 	for {
 		// Run cpu steps
-		if !a.paused {
+		if !a.paused.Load() {
 			if !a.dmaActive {
 				// 6502 is running
 				for i := 0; i < cpuSpinLoops && !a.dmaActive; i++ {
@@ -44,11 +51,19 @@ func (a *Apple2) Start(paused bool) {
 					// a.cpu.SetTrace(pc >= 0xc700 && pc < 0xc800)
 
 					// Execution
-					startCycles := a.cpu.GetCycles()
-					a.cpu.ExecuteInstruction()
-					a.cycles += a.cpu.GetCycles() - startCycles
+					executedCycles += a.executeInstruction()
 
 					a.executionTrace()
+
+					if a.pcBreakpointActive {
+						pc, _ := a.cpu.GetPCAndSP()
+						if pc == a.pcBreakpoint {
+							a.breakPoint = true
+							a.pcBreakpointActive = false
+							a.pause()
+							break
+						}
+					}
 				}
 			} else {
 				// a card, like the Z80 Softcard, is running
@@ -56,18 +71,32 @@ func (a *Apple2) Start(paused bool) {
 				for i := 0; i < cpuSpinLoops && a.dmaActive; i++ {
 					card.runDMACycle()
 					a.cycles++
+					executedCycles++
 
 					a.executionTrace()
 				}
 			}
 
+			a.publishedCycles.Store(a.cycles)
+
+			if a.recording != nil {
+				err := a.recording.update(a)
+				if err != nil {
+					fmt.Printf("Error recording video: %v\n", err)
+					a.stopRecording()
+				}
+			}
+
 			if a.cycleBreakpoint != 0 && a.cycles >= a.cycleBreakpoint {
 				a.breakPoint = true
 				a.cycleBreakpoint = 0
-				a.paused = true
+				a.pause()
 			}
 		} else {
-			time.Sleep(200 * time.Millisecond)
+			select {
+			case <-a.commandQueued:
+			case <-time.After(200 * time.Millisecond):
+			}
 		}
 
 		// Execute meta commands
@@ -77,19 +106,28 @@ func (a *Apple2) Start(paused bool) {
 			case command := <-a.commandChannel:
 				switch command.getId() {
 				case CommandKill:
+					if a.recording != nil {
+						a.stopRecording()
+					}
+					if a.runDone != nil {
+						a.runDone <- errors.New("emulation stopped")
+						a.runDone = nil
+					}
 					return
 				case CommandPause:
-					if !a.paused {
-						a.paused = true
-					}
+					a.pause()
 				case CommandStart:
-					if a.paused {
-						a.paused = false
+					if a.paused.Load() {
+						a.paused.Store(false)
 						referenceTime = time.Now()
 						speedReferenceTime = referenceTime
 					}
 				case CommandPauseUnpause:
-					a.paused = !a.paused
+					if a.paused.Load() {
+						a.paused.Store(false)
+					} else {
+						a.pause()
+					}
 					referenceTime = time.Now()
 					speedReferenceTime = referenceTime
 				default:
@@ -101,10 +139,11 @@ func (a *Apple2) Start(paused bool) {
 			}
 		}
 
-		if a.cycleDurationNs != 0 && a.fastRequestsCounter <= 0 {
-			// Wait until next 6502 step has to run
+		if a.cycleDurationNs != 0 && atomic.LoadInt32(&a.fastRequestsCounter) <= 0 {
+			// Wait until next 6502 step has to run. The accelerators slow down the board cycles.
+			simulatedNs += float64(a.cycles-lastCycles) * a.cycleDurationNs
 			clockDuration := time.Since(referenceTime)
-			simulatedDuration := time.Duration(float64(a.cycles) * a.cycleDurationNs)
+			simulatedDuration := time.Duration(simulatedNs)
 			waitDuration := simulatedDuration - clockDuration
 			if waitDuration > maxWaitDuration || -waitDuration > maxWaitDuration {
 				// We have to wait too long or are too much behind. Let's fast forward
@@ -115,18 +154,51 @@ func (a *Apple2) Start(paused bool) {
 				time.Sleep(waitDuration)
 			}
 		}
+		lastCycles = a.cycles
 
-		if a.cycles-speedReferenceCycles > 1000000 {
+		if executedCycles-speedReferenceCycles > 1000000 {
 			// Calculate speed in MHz every million cycles
 			newTime := time.Now()
-			elapsedCycles := float64(a.cycles - speedReferenceCycles)
+			elapsedCycles := float64(executedCycles - speedReferenceCycles)
 			a.currentFreqMHz = 1000.0 * elapsedCycles / float64(newTime.Sub(speedReferenceTime).Nanoseconds())
 			speedReferenceTime = newTime
-			speedReferenceCycles = a.cycles
+			speedReferenceCycles = executedCycles
 		}
 	}
 }
 
+// executeInstruction runs an instruction of the 6502 and returns its CPU cycles
+func (a *Apple2) executeInstruction() uint64 {
+	startCycles := a.cpu.GetCycles()
+	a.cpu.ExecuteInstruction()
+	cpuCycles := a.cpu.GetCycles() - startCycles
+	a.cycles += a.boardCycles(cpuCycles)
+	return cpuCycles
+}
+
+// pause stops the emulation and completes the pending RunCycles
+func (a *Apple2) pause() {
+	a.paused.Store(true)
+	if a.runDone != nil {
+		a.cycleBreakpoint = 0
+		a.runDone <- nil
+		a.runDone = nil
+	}
+}
+
+// startRun resumes the paused emulation until the cycles are run or a
+// breakpoint is hit, done receives the result
+func (a *Apple2) startRun(cycles uint64, done chan error) {
+	if !a.paused.Load() || a.runDone != nil {
+		done <- errors.New("emulation is already running")
+		return
+	}
+	a.cycleBreakpoint = a.cycles + cycles
+	a.breakPoint = false
+	a.runDone = done
+	a.paused.Store(false)
+}
+
 func (a *Apple2) reset() {
 	a.cpu.Reset()
 	a.mmu.reset()
@@ -143,6 +215,40 @@ func (a *Apple2) executionTrace() {
 	}
 }
 
+/*
+RunCycles runs the paused emulator at full speed for some cycles or until
+a breakpoint is hit. Start has to be running on another goroutine, it
+hands back the paused emulator when the run completes.
+*/
+func (a *Apple2) RunCycles(cycles uint64) error {
+	a.RequestFastMode()
+	defer a.ReleaseFastMode()
+	var c commandRun
+	c.cycles = cycles
+	c.done = make(chan error, 1)
+	a.queueCommand(&c)
+	return <-c.done
+}
+
+/*
+RunUntil runs the paused emulator until the condition is true, it is
+checked every 100.000 cycles. Returns false if maxCycles are run before.
+*/
+func (a *Apple2) RunUntil(condition func() bool, maxCycles uint64) (bool, error) {
+	limit := a.GetCycles() + maxCycles
+	for !condition() {
+		cycles := a.GetCycles()
+		if cycles >= limit {
+			return false, nil
+		}
+		err := a.RunCycles(min(runUntilCheckCycles, limit-cycles))
+		if err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
 func (a *Apple2) dumpDebugInfo() {
 	// See "Apple II Monitors Peeled"
 	pageZeroSymbols := map[uint16]string{
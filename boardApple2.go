@@ -123,6 +123,9 @@ func buildSpeakerSoftSwitch(io *ioC0Page) softSwitchR {
 		if io.speaker != nil {
 			io.speaker.Click(io.apple2.GetCycles())
 		}
+		if io.apple2.recording != nil {
+			io.apple2.recording.click(io.apple2.GetCycles())
+		}
 		return 0
 	}
 }
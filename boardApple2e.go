@@ -10,11 +10,6 @@ const (
 	ioFlag80Col   uint8 = 0x1F
 )
 
-const (
-	screenDrawCycles         = uint64(12480 + 4550)
-	screenVertBlankingCycles = uint64(4550)
-)
-
 func addApple2ESoftSwitches(io *ioC0Page) {
 	// New MMU read softswithes
 	mmu := io.apple2.mmu
@@ -50,11 +45,8 @@ func addApple2ESoftSwitches(io *ioC0Page) {
 		//      12480 cycles drawing lines, VERTBLANK = $00
 		//       4550 cycles doing the return to position (0,0), VERTBLANK = $80
 		// Vert blank takes 12480 cycles every page redraw
-		cycles := io.apple2.GetCycles() % screenDrawCycles
-		if cycles <= screenVertBlankingCycles {
-			return ssOn
-		}
-		return ssOff
+		// The PAL frame has 50 more lines of vertical blanking
+		return ssFromBool(io.apple2.timing.IsVertBlank(io.apple2.GetCycles()))
 	}, "VERTBLANK")
 
 	// io.softSwitchesData[ioFlagAltChar] = ssOn // Not sure about this.
@@ -41,14 +41,17 @@ func getCardFactory() map[string]*cardBuilder {
 	cardFactory = make(map[string]*cardBuilder)
 	cardFactory["brainboard"] = newCardBrainBoardBuilder()
 	cardFactory["brainboard2"] = newCardBrainBoardIIBuilder()
+	cardFactory["cffa"] = newCardCffaBuilder()
 	cardFactory["dan2sd"] = newCardDan2ControllerBuilder()
 	cardFactory["diskii"] = newCardDisk2Builder()
 	cardFactory["diskiiseq"] = newCardDisk2SequencerBuilder()
 	cardFactory["fastchip"] = newCardFastChipBuilder()
 	cardFactory["fujinet"] = newCardSmartPortFujinetBuilder()
 	cardFactory["inout"] = newCardInOutBuilder()
+	cardFactory["iwm"] = newCardIwmBuilder()
 	cardFactory["language"] = newCardLanguageBuilder()
 	cardFactory["softswitchlogger"] = newCardLoggerBuilder()
+	cardFactory["ssc"] = newCardSuperSerialBuilder()
 	cardFactory["memexp"] = newCardMemoryExpansionBuilder()
 	cardFactory["mouse"] = newCardMouseBuilder()
 	cardFactory["multirom"] = newMultiRomCardBuilder()
@@ -57,13 +60,17 @@ func getCardFactory() map[string]*cardBuilder {
 	cardFactory["prodosromcard3"] = newCardProDOSRomCard3Builder()
 	// cardFactory["prodosnvramdrive"] = newCardProDOSNVRAMDriveBuilder()
 	cardFactory["saturn"] = newCardSaturnBuilder()
+	cardFactory["scsi"] = newCardScsiBuilder()
 	cardFactory["smartport"] = newCardSmartPortStorageBuilder()
 	cardFactory["swyftcard"] = newCardSwyftBuilder()
 	cardFactory["thunderclock"] = newCardThunderClockPlusBuilder()
+	cardFactory["transwarp"] = newCardTransWarpBuilder()
+	cardFactory["uthernet2"] = newCardUthernet2Builder()
 	cardFactory["videx"] = newCardVidexVideotermBuilder()
 	cardFactory["videxultraterm"] = newCardVidexUltratermBuilder()
 	cardFactory["vidhd"] = newCardVidHDBuilder()
 	cardFactory["z80softcard"] = newCardZ80SoftCardBuilder()
+	cardFactory["zipchip"] = newCardZipChipBuilder()
 	return cardFactory
 }
 
@@ -0,0 +1,235 @@
+package izapple2
+
+import (
+	"fmt"
+	"strconv"
+)
+
+/*
+CFFA, the CompactFlash interface card by Rich Dreher, versions 1 and 2.
+
+See:
+	http://dreher.net/?s=projects/CFforAppleII&c=projects/CFforAppleII/main.php
+	http://dreher.net/?s=projects/CFforAppleII&c=projects/CFforAppleII/downloads1.php
+
+The card has two ATA devices on an IDE bus, the CompactFlash master and
+the IDE slave. The ATA registers are on $C0n8 to $C0nF, with the latch of
+the high byte of the 16 bits data register on $C0n0.
+
+The CFFA firmware splits each device in up to four ProDOS partitions of
+32MB. Here the devices are built from the block images: image1 to image4
+are the partitions on the first device and image5 to image8 on the second
+one. Partition n starts on the sector n*65536.
+
+The original CFFA 2.0 firmware, a 4KB EPROM covering $C000 to $CFFF, can
+be loaded with the rom parameter. Without it, a built-in firmware with a
+ProDOS block driver and a SmartPort interface for the eight partitions is
+used, see cardCffaFirmware.go.
+
+The CFFA3000 is not emulated, the configurations with model=3000 are
+rejected. Its register interface and its firmware with the USB and CF
+menus are not available.
+*/
+
+// CardCffa represents a CFFA card
+type CardCffa struct {
+	cardBase
+	devices [cffaDevices]*ataDevice
+
+	// Registers shared by the devices on the bus
+	features     uint8
+	sectorCount  uint8
+	lba          [4]uint8 // Sector, cylinder low, cylinder high and head
+	dataHigh     uint8    // Latch for the high byte of the data register
+	interruptOff bool
+}
+
+const (
+	cffaDevices          = 2
+	cffaPartitions       = 4
+	cffaPartitionSectors = uint32(65536)
+)
+
+func newCardCffaBuilder() *cardBuilder {
+	return &cardBuilder{
+		name:        "CFFA",
+		description: "CompactFlash interface card with two ATA devices and eight ProDOS partitions",
+		defaultParams: &[]paramSpec{
+			{"image1", "Disk image for partition 1 of the CF device", ""},
+			{"image2", "Disk image for partition 2 of the CF device", ""},
+			{"image3", "Disk image for partition 3 of the CF device", ""},
+			{"image4", "Disk image for partition 4 of the CF device", ""},
+			{"image5", "Disk image for partition 1 of the IDE device", ""},
+			{"image6", "Disk image for partition 2 of the IDE device", ""},
+			{"image7", "Disk image for partition 3 of the IDE device", ""},
+			{"image8", "Disk image for partition 4 of the IDE device", ""},
+			{"rom", "CFFA 2.0 firmware, empty for a built-in ProDOS and SmartPort driver", ""},
+			{"model", "Card version, 2 for the CFFA 1 and 2. The CFFA3000 is not emulated", "2"},
+			{"tracehd", "Trace the ATA commands", "false"},
+		},
+		buildFunc: func(params map[string]string) (Card, error) {
+			var c CardCffa
+			model := paramsGetString(params, "model")
+			if model == "3000" {
+				return nil, fmt.Errorf("the CFFA3000 is not emulated, its USB and CF menus are not available")
+			} else if model != "2" {
+				return nil, fmt.Errorf("invalid CFFA model '%s', must be 2", model)
+			}
+
+			rom := paramsGetPath(params, "rom")
+			trace := paramsGetBool(params, "tracehd")
+			for i := 0; i < cffaDevices*cffaPartitions; i++ {
+				image := paramsGetPath(params, "image"+strconv.Itoa(i+1))
+				if image == "" {
+					continue
+				}
+				hd, err := LoadBlockDisk(image)
+				if err != nil {
+					return nil, err
+				}
+				device := i / cffaPartitions
+				if c.devices[device] == nil {
+					c.devices[device] = newAtaDevice(device)
+					c.devices[device].trace = trace
+				}
+				c.devices[device].partitions[i%cffaPartitions] = hd
+			}
+
+			if rom != "" {
+				data, _, err := LoadResource(rom)
+				if err != nil {
+					return nil, err
+				}
+				err = c.loadRom(data, cardRomFull)
+				if err != nil {
+					return nil, fmt.Errorf("invalid CFFA firmware: %w", err)
+				}
+			}
+			return &c, nil
+		},
+	}
+}
+
+func (c *CardCffa) assign(a *Apple2, slot int) {
+	if c.romCxxx == nil {
+		c.loadRom(buildCffaRom(slot), cardRomSimple)
+		rom := make([]uint8, 0x800)
+		copy(rom, cffaFirmware)
+		c.romC8xx = newMemoryRangeROM(0xc800, rom, "Slot C8 ROM")
+	}
+
+	c.addCardSoftSwitchR(0, func() uint8 {
+		return c.dataHigh
+	}, "CFFADATAHIGHR")
+	c.addCardSoftSwitchW(0, func(value uint8) {
+		c.dataHigh = value
+	}, "CFFADATAHIGHW")
+
+	// The chip select mask avoids the false reads of the 6502 on the
+	// ATA registers, there are no false reads to avoid here.
+	c.addCardSoftSwitchRW(1, func() uint8 {
+		return 0
+	}, "CFFASETCSMASK")
+	c.addCardSoftSwitchRW(2, func() uint8 {
+		return 0
+	}, "CFFACLEARCSMASK")
+
+	c.addCardSoftSwitchR(6, func() uint8 {
+		return c.status()
+	}, "CFFAALTSTATUSR")
+	c.addCardSoftSwitchW(6, func(value uint8) {
+		c.interruptOff = value&0x02 != 0
+		if value&0x04 != 0 {
+			// Software reset
+			c.reset()
+		}
+	}, "CFFADEVCTRLW")
+
+	c.addCardSoftSwitchR(8, func() uint8 {
+		var value uint8
+		if d := c.selected(); d != nil {
+			value, c.dataHigh = d.readData()
+		}
+		return value
+	}, "CFFADATALOWR")
+	c.addCardSoftSwitchW(8, func(value uint8) {
+		if d := c.selected(); d != nil {
+			d.writeData(value, c.dataHigh)
+		}
+	}, "CFFADATALOWW")
+
+	c.addCardSoftSwitchR(9, func() uint8 {
+		if d := c.selected(); d != nil {
+			return d.error
+		}
+		return 0
+	}, "CFFAERRORR")
+	c.addCardSoftSwitchW(9, func(value uint8) {
+		c.features = value
+	}, "CFFAFEATURESW")
+
+	c.addCardSoftSwitchR(0xa, func() uint8 {
+		return c.sectorCount
+	}, "CFFASECTORCOUNTR")
+	c.addCardSoftSwitchW(0xa, func(value uint8) {
+		c.sectorCount = value
+	}, "CFFASECTORCOUNTW")
+
+	lbaNames := []string{"SECTOR", "CYLINDERLOW", "CYLINDERHIGH", "HEAD"}
+	for i := 0; i < 4; i++ {
+		index := i
+		c.addCardSoftSwitchR(uint8(0xb+i), func() uint8 {
+			return c.lba[index]
+		}, "CFFA"+lbaNames[i]+"R")
+		c.addCardSoftSwitchW(uint8(0xb+i), func(value uint8) {
+			c.lba[index] = value
+		}, "CFFA"+lbaNames[i]+"W")
+	}
+
+	c.addCardSoftSwitchR(0xf, func() uint8 {
+		return c.status()
+	}, "CFFASTATUSR")
+	c.addCardSoftSwitchW(0xf, func(value uint8) {
+		c.command(value)
+	}, "CFFACOMMANDW")
+
+	c.cardBase.assign(a, slot)
+}
+
+func (c *CardCffa) reset() {
+	for _, d := range c.devices {
+		if d != nil {
+			d.reset()
+		}
+	}
+	c.features = 0
+	c.sectorCount = 1
+	c.lba = [4]uint8{1, 0, 0, 0}
+}
+
+// selected returns the device selected with the bit 4 of the head register
+func (c *CardCffa) selected() *ataDevice {
+	return c.devices[(c.lba[3]>>4)&1]
+}
+
+func (c *CardCffa) status() uint8 {
+	if d := c.selected(); d != nil {
+		return d.status
+	}
+	return 0 // No device, not busy and not ready
+}
+
+func (c *CardCffa) command(command uint8) {
+	d := c.selected()
+	if d == nil {
+		return
+	}
+	sectors := uint32(c.sectorCount)
+	if sectors == 0 {
+		sectors = 256
+	}
+	if command == ataCommandCheckPower {
+		c.sectorCount = 0xff // Active
+	}
+	d.command(command, c.features, sectors, c.lba)
+}
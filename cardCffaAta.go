@@ -0,0 +1,278 @@
+package izapple2
+
+import (
+	"fmt"
+
+	"github.com/ivanizag/izapple2/storage"
+)
+
+/*
+ATA device of the CFFA card, built with up to four partitions.
+
+See:
+	ATA/ATAPI-4 specification, T13/1153D. Sections 7 and 8.
+
+Only the PIO commands used by the Apple II drivers are supported. There
+are no delays, the commands complete immediately.
+*/
+
+type ataDevice struct {
+	index      int
+	partitions [cffaPartitions]storage.BlockDisk
+	trace      bool
+
+	status uint8
+	error  uint8
+
+	buffer    [storage.ProDosBlockSize]uint8
+	position  uint32
+	writing   bool
+	lba       uint32
+	remaining uint32
+}
+
+const (
+	ataStatusBusy  = uint8(0x80)
+	ataStatusReady = uint8(0x40)
+	ataStatusSeek  = uint8(0x10) // Seek complete
+	ataStatusDrq   = uint8(0x08) // Data request
+	ataStatusError = uint8(0x01)
+
+	ataErrorIdNotFound = uint8(0x10)
+	ataErrorAbort      = uint8(0x04)
+
+	ataHeadLba = uint8(0x40)
+
+	ataCommandRecalibrate    = 0x10
+	ataCommandReadSectors    = 0x20
+	ataCommandReadSectorsNR  = 0x21
+	ataCommandWriteSectors   = 0x30
+	ataCommandWriteSectorsNR = 0x31
+	ataCommandVerifySectors  = 0x40
+	ataCommandInitParameters = 0x91
+	ataCommandStandbyNow     = 0xe0
+	ataCommandIdleNow        = 0xe1
+	ataCommandCheckPower     = 0xe5
+	ataCommandIdentify       = 0xec
+	ataCommandSetFeatures    = 0xef
+	ataHeads                 = 16
+	ataSectorsPerTrack       = 63
+	ataMaxCylinders          = 16383
+)
+
+func newAtaDevice(index int) *ataDevice {
+	var d ataDevice
+	d.index = index
+	d.reset()
+	return &d
+}
+
+func (d *ataDevice) reset() {
+	d.status = ataStatusReady | ataStatusSeek
+	d.error = 0x01 // Diagnostic passed
+	d.remaining = 0
+}
+
+// sectors returns the size of the device, up to the end of the last partition
+func (d *ataDevice) sectors() uint32 {
+	for i := cffaPartitions - 1; i >= 0; i-- {
+		if d.partitions[i] != nil {
+			return uint32(i)*cffaPartitionSectors + d.partitions[i].GetSizeInBlocks()
+		}
+	}
+	return 0
+}
+
+func (d *ataDevice) command(command uint8, features uint8, sectors uint32, registers [4]uint8) {
+	if d.trace {
+		fmt.Printf("[CFFA] Device %v, command $%02x, features $%02x, %v sectors on $%02x%02x%02x%02x.\n",
+			d.index, command, features, sectors, registers[3], registers[2], registers[1], registers[0])
+	}
+
+	d.error = 0
+	d.status = ataStatusReady | ataStatusSeek
+	d.remaining = 0
+
+	switch command {
+	case ataCommandReadSectors, ataCommandReadSectorsNR:
+		d.lba = d.address(registers)
+		d.remaining = sectors
+		d.writing = false
+		d.nextSector()
+
+	case ataCommandWriteSectors, ataCommandWriteSectorsNR:
+		d.lba = d.address(registers)
+		d.remaining = sectors
+		d.writing = true
+		d.position = 0
+		d.status |= ataStatusDrq
+
+	case ataCommandIdentify:
+		d.identify()
+		d.position = 0
+		d.remaining = 1
+		d.writing = false
+		d.status |= ataStatusDrq
+
+	case ataCommandVerifySectors:
+		d.lba = d.address(registers)
+		for i := uint32(0); i < sectors; i++ {
+			if disk, _ := d.block(); disk == nil {
+				d.fail(ataErrorIdNotFound)
+				break
+			}
+			d.lba++
+		}
+
+	case ataCommandRecalibrate, ataCommandInitParameters,
+		ataCommandStandbyNow, ataCommandIdleNow, ataCommandCheckPower, ataCommandSetFeatures:
+		// Nothing to do
+
+	default:
+		d.fail(ataErrorAbort)
+	}
+}
+
+// address returns the LBA of the registers, on LBA or on CHS mode
+func (d *ataDevice) address(registers [4]uint8) uint32 {
+	if registers[3]&ataHeadLba != 0 {
+		return uint32(registers[0]) |
+			uint32(registers[1])<<8 |
+			uint32(registers[2])<<16 |
+			uint32(registers[3]&0x0f)<<24
+	}
+	cylinder := uint32(registers[1]) | uint32(registers[2])<<8
+	head := uint32(registers[3] & 0x0f)
+	return (cylinder*ataHeads+head)*ataSectorsPerTrack + uint32(registers[0]) - 1
+}
+
+func (d *ataDevice) fail(err uint8) {
+	d.error = err
+	d.status = ataStatusReady | ataStatusSeek | ataStatusError
+	d.remaining = 0
+}
+
+// block returns the partition and block of the current LBA
+func (d *ataDevice) block() (storage.BlockDisk, uint32) {
+	partition := d.lba / cffaPartitionSectors
+	block := d.lba % cffaPartitionSectors
+	if partition >= cffaPartitions || d.partitions[partition] == nil ||
+		block >= d.partitions[partition].GetSizeInBlocks() {
+		return nil, 0
+	}
+	return d.partitions[partition], block
+}
+
+// nextSector loads the next sector to read or ends the transfer
+func (d *ataDevice) nextSector() {
+	if d.remaining == 0 {
+		d.status &^= ataStatusDrq
+		return
+	}
+	disk, block := d.block()
+	if disk == nil {
+		d.fail(ataErrorIdNotFound)
+		return
+	}
+	data, err := disk.Read(block)
+	if err != nil {
+		d.fail(ataErrorAbort)
+		return
+	}
+	copy(d.buffer[:], data)
+	d.position = 0
+	d.status |= ataStatusDrq
+}
+
+func (d *ataDevice) readData() (uint8, uint8) {
+	if d.writing || d.remaining == 0 {
+		return 0, 0
+	}
+	low := d.buffer[d.position]
+	high := d.buffer[d.position+1]
+	d.position += 2
+	if d.position == storage.ProDosBlockSize {
+		d.remaining--
+		d.lba++
+		d.nextSector()
+	}
+	return low, high
+}
+
+func (d *ataDevice) writeData(low uint8, high uint8) {
+	if !d.writing || d.remaining == 0 {
+		return
+	}
+	d.buffer[d.position] = low
+	d.buffer[d.position+1] = high
+	d.position += 2
+	if d.position < storage.ProDosBlockSize {
+		return
+	}
+
+	disk, block := d.block()
+	if disk == nil {
+		d.fail(ataErrorIdNotFound)
+		return
+	}
+	if disk.IsReadOnly() {
+		d.fail(ataErrorAbort)
+		return
+	}
+	err := disk.Write(block, d.buffer[:])
+	if err != nil {
+		d.fail(ataErrorAbort)
+		return
+	}
+	d.remaining--
+	d.lba++
+	d.position = 0
+	if d.remaining == 0 {
+		d.status &^= ataStatusDrq
+	}
+}
+
+// identify fills the buffer with the IDENTIFY DEVICE data
+func (d *ataDevice) identify() {
+	for i := range d.buffer {
+		d.buffer[i] = 0
+	}
+	setWord := func(word int, value uint16) {
+		d.buffer[word*2] = uint8(value)
+		d.buffer[word*2+1] = uint8(value >> 8)
+	}
+	setString := func(word int, length int, s string) {
+		// Two characters per word, the first on the high byte, padded with spaces
+		for i := 0; i < length*2; i++ {
+			ch := uint8(' ')
+			if i < len(s) {
+				ch = s[i]
+			}
+			d.buffer[(word*2+i)^1] = ch
+		}
+	}
+
+	sectors := d.sectors()
+	cylinders := sectors / (ataHeads * ataSectorsPerTrack)
+	if cylinders > ataMaxCylinders {
+		cylinders = ataMaxCylinders
+	}
+
+	setWord(0, 0x0040) // Fixed device
+	setWord(1, uint16(cylinders))
+	setWord(3, ataHeads)
+	setWord(6, ataSectorsPerTrack)
+	setString(10, 10, fmt.Sprintf("IZAPPLE2-%v", d.index))
+	setString(23, 4, "1.0")
+	setString(27, 20, "izapple2 virtual disk")
+	setWord(49, 0x0200) // LBA supported
+	setWord(53, 0x0001) // Words 54 to 58 are valid
+	setWord(54, uint16(cylinders))
+	setWord(55, ataHeads)
+	setWord(56, ataSectorsPerTrack)
+	chs := cylinders * ataHeads * ataSectorsPerTrack
+	setWord(57, uint16(chs))
+	setWord(58, uint16(chs>>16))
+	setWord(60, uint16(sectors))
+	setWord(61, uint16(sectors>>16))
+}
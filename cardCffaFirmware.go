@@ -0,0 +1,433 @@
+package izapple2
+
+/*
+Built-in firmware of the CFFA card, used when the original ROM is not
+provided. It has a ProDOS block driver and a SmartPort interface.
+
+The slot page has the SmartPort signature and the entry points. They set
+$07F8 to $Cn, as the code on the $C800 area gets the slot from there,
+and jump to $C800. There is no RAM on the card, the SmartPort calls save
+the zero page on the screen holes of the slot.
+
+The ProDOS driver has the drives 1 and 2 on the first two partitions. The
+SmartPort units 1 to 8 are the eight partitions, the units 3 to 8 are
+reached by ProDOS 2 remapping the SmartPort devices and by the programs
+calling the SmartPort entry. The STATUS of a unit verifies the first
+block of the partition to report it online.
+
+The code on $C800 is assembled from doc/firmware/cffa.asm, with
+"go run . cffa.asm ../../cardCffaFirmware.go cffaFirmware" on that folder.
+*/
+
+const (
+	cffaFirmwareBoot      = 0xc800 // Boot code
+	cffaFirmwareEntry     = 0xc823 // ProDOS driver entry
+	cffaFirmwareSmartPort = 0xc8ef // SmartPort entry
+	cffaFirmwareMslot     = 0x07f8 // $Cn of the card using the $C800 area
+)
+
+func buildCffaRom(slot int) []uint8 {
+	data := make([]uint8, 256)
+	s := 0xc0 + uint8(slot) // High byte of the ROM addresses
+
+	copy(data, []uint8{
+		// Preamble bytes to comply with the expectation in $Cn01, 3, 5 and 7
+		0xa2, 0x20, // LDX #$20
+		0xa0, 0x00, // LDY #$00
+		0xa2, 0x03, // LDX #$03
+		0xa2, 0x00, // LDX #$00 ; SmartPort
+		0xf0, 0x14, // BEQ boot ; $Cn1E
+	})
+
+	copy(data[0x0a:], []uint8{
+		// ProDOS driver entry point, it has to be in $Cn0a
+		0x18,       // CLC
+		0x90, 0x01, // BCC common
+		// SmartPort entry point, 3 bytes after the ProDOS entry
+		0x38, // SEC
+		// common: $Cn0E
+		0xa9, s, // LDA #$Cn
+		0x8d, cffaFirmwareMslot & 0xff, cffaFirmwareMslot >> 8, // STA $07F8
+		0x2c, 0xff, 0xcf, // BIT $CFFF ; Release the $C800 area of other cards
+		0xb0, 0x03, // BCS smartPort
+		0x4c, cffaFirmwareEntry & 0xff, cffaFirmwareEntry >> 8, // JMP entry
+		// smartPort: $Cn1B
+		0x4c, cffaFirmwareSmartPort & 0xff, cffaFirmwareSmartPort >> 8, // JMP spEntry
+	})
+
+	copy(data[0x1e:], []uint8{
+		// Boot code, read block 0 in $0800 and jump there
+		0xa9, s, // LDA #$Cn
+		0x8d, cffaFirmwareMslot & 0xff, cffaFirmwareMslot >> 8, // STA $07F8
+		0x2c, 0xff, 0xcf, // BIT $CFFF
+		0x4c, cffaFirmwareBoot & 0xff, cffaFirmwareBoot >> 8, // JMP boot
+	})
+
+	data[0xfc] = 0
+	data[0xfd] = 0    // Use status to get the number of blocks
+	data[0xfe] = 0x17 // Two volumes. Status, read, write and format
+	data[0xff] = 0x0a // Driver entry point
+
+	return data
+}
+
+// cffaFirmware is the code on $C800. It uses cmd, unit, bufLo, bufHi, blkLo
+// and blkHi on $42 to $47 as set by ProDOS and the ATA registers with the
+// slot * 16 on X.
+var cffaFirmware = []uint8{
+	// Boot: read block 0 of the first partition on $0800
+	// boot: $C800
+	0xa9, 0x01, // LDA #$01
+	0x85, 0x42, // STA cmd ; READ
+	0x20, 0xe6, 0xc8, // JSR getSlot
+	0x86, 0x43, // STX unit ; Drive 1
+	0xa9, 0x00, // LDA #$00
+	0x85, 0x44, // STA bufLo
+	0x85, 0x46, // STA blkLo
+	0x85, 0x47, // STA blkHi
+	0xa9, 0x08, // LDA #$08
+	0x85, 0x45, // STA bufHi ; Buffer on $0800
+	0x20, 0x23, 0xc8, // JSR entry
+	0xb0, 0x06, // BCS bootFail
+	0x20, 0xe6, 0xc8, // JSR getSlot ; The boot code gets the slot * 16 on X
+	0x4c, 0x01, 0x08, // JMP $0801
+	// bootFail: $C820
+	0x4c, 0x00, 0xe0, // JMP $E000 ; No disk, go to BASIC
+	// ProDOS driver entry, the drives 1 and 2 are the first two partitions
+	// entry: $C823
+	0x20, 0xe6, 0xc8, // JSR getSlot
+	0xa5, 0x43, // LDA unit
+	0x0a,       // ASL ; Drive bit to carry
+	0xa9, 0x00, // LDA #$00
+	0x2a,       // ROL
+	0xa8,       // TAY ; Partition
+	0xa5, 0x42, // LDA cmd
+	0xd0, 0x06, // BNE notStatus
+	0xa2, 0xff, // LDX #$FF ; 65535 blocks
+	0xa0, 0xff, // LDY #$FF
+	0x18, // CLC
+	0x60, // RTS
+	// notStatus: $C837
+	0xc9, 0x03, // CMP #$03
+	0x90, 0x0a, // BCC block ; READ or WRITE
+	0xf0, 0x04, // BEQ success ; FORMAT, nothing to do
+	// badCmd: $C83D
+	0xa9, 0x01, // LDA #$01
+	0x38, // SEC
+	0x60, // RTS
+	// success: $C841
+	0xa9, 0x00, // LDA #$00
+	0x18, // CLC
+	0x60, // RTS
+	// Read or write the block blkLo, blkHi of the partition Y, 0 to 7, with
+	// the buffer on bufLo, bufHi. A is 1 to read and 2 to write, X is the
+	// slot * 16. Returns the error on A and carry.
+	// block: $C845
+	0x48,             // PHA
+	0x20, 0xb5, 0xc8, // JSR setup
+	0x68,       // PLA
+	0x4a,       // LSR ; Carry set to read
+	0x90, 0x2f, // BCC write
+	0xa9, 0x20, // LDA #$20 ; READ SECTORS
+	0x9d, 0x8f, 0xc0, // STA command,X
+	0x20, 0xda, 0xc8, // JSR wait
+	0xb0, 0x5a, // BCS ioError
+	0xa0, 0x00, // LDY #$00
+	// read1: $C859
+	0xbd, 0x88, 0xc0, // LDA dataLow,X ; Latches the high byte
+	0x91, 0x44, // STA (bufLo),Y
+	0xc8,             // INY
+	0xbd, 0x80, 0xc0, // LDA dataHigh,X
+	0x91, 0x44, // STA (bufLo),Y
+	0xc8,       // INY
+	0xd0, 0xf2, // BNE read1
+	0xe6, 0x45, // INC bufHi
+	// read2: $C869
+	0xbd, 0x88, 0xc0, // LDA dataLow,X
+	0x91, 0x44, // STA (bufLo),Y
+	0xc8,             // INY
+	0xbd, 0x80, 0xc0, // LDA dataHigh,X
+	0x91, 0x44, // STA (bufLo),Y
+	0xc8,       // INY
+	0xd0, 0xf2, // BNE read2
+	0xc6, 0x45, // DEC bufHi ; Restore the buffer address
+	0x4c, 0x41, 0xc8, // JMP success
+	// write: $C87C
+	0xa9, 0x30, // LDA #$30 ; WRITE SECTORS
+	0x9d, 0x8f, 0xc0, // STA command,X
+	0x20, 0xda, 0xc8, // JSR wait
+	0xb0, 0x2b, // BCS ioError
+	0xa0, 0x00, // LDY #$00
+	// write1: $C888
+	0xc8,       // INY
+	0xb1, 0x44, // LDA (bufLo),Y
+	0x9d, 0x80, 0xc0, // STA dataHigh,X ; Latch for the high byte
+	0x88,       // DEY
+	0xb1, 0x44, // LDA (bufLo),Y
+	0x9d, 0x88, 0xc0, // STA dataLow,X ; Writes the word
+	0xc8,       // INY
+	0xc8,       // INY
+	0xd0, 0xf0, // BNE write1
+	0xe6, 0x45, // INC bufHi
+	// write2: $C89A
+	0xc8,       // INY
+	0xb1, 0x44, // LDA (bufLo),Y
+	0x9d, 0x80, 0xc0, // STA dataHigh,X
+	0x88,       // DEY
+	0xb1, 0x44, // LDA (bufLo),Y
+	0x9d, 0x88, 0xc0, // STA dataLow,X
+	0xc8,       // INY
+	0xc8,       // INY
+	0xd0, 0xf0, // BNE write2
+	0xc6, 0x45, // DEC bufHi ; Restore the buffer address
+	0x20, 0xda, 0xc8, // JSR wait
+	0x90, 0x90, // BCC success
+	// ioError: $C8B1
+	0xa9, 0x27, // LDA #$27 ; I/O error
+	0x38, // SEC
+	0x60, // RTS
+	// Set the registers for one sector, the block blkLo, blkHi of the
+	// partition Y. The partitions 4 to 7 are on the second device.
+	// setup: $C8B5
+	0xbd, 0x8f, 0xc0, // LDA status,X
+	0x30, 0xfb, // BMI setup ; Wait while busy
+	0xa9, 0x01, // LDA #$01
+	0x9d, 0x8a, 0xc0, // STA count,X
+	0xa5, 0x46, // LDA blkLo
+	0x9d, 0x8b, 0xc0, // STA lba0,X
+	0xa5, 0x47, // LDA blkHi
+	0x9d, 0x8c, 0xc0, // STA lba8,X
+	0x98,       // TYA
+	0x29, 0x03, // AND #$03
+	0x9d, 0x8d, 0xc0, // STA lba16,X ; Partition on the device
+	0x98,       // TYA
+	0x29, 0x04, // AND #$04
+	0x0a,       // ASL
+	0x0a,       // ASL ; Device on bit 4
+	0x09, 0xe0, // ORA #$E0 ; LBA mode
+	0x9d, 0x8e, 0xc0, // STA head,X
+	0x60, // RTS
+	// Wait while busy, carry set when the device is not ready or has an error
+	// wait: $C8DA
+	0xbd, 0x8f, 0xc0, // LDA status,X
+	0x30, 0xfb, // BMI wait
+	0x29, 0x41, // AND #$41 ; Ready and error bits
+	0x49, 0x40, // EOR #$40
+	0xc9, 0x01, // CMP #$01
+	0x60, // RTS
+	// Return the slot * 16 on X
+	// getSlot: $C8E6
+	0xad, 0xf8, 0x07, // LDA mslot
+	0x0a, // ASL
+	0x0a, // ASL
+	0x0a, // ASL
+	0x0a, // ASL
+	0xaa, // TAX
+	0x60, // RTS
+	// SmartPort entry, the command and the parameter list pointer follow the
+	// JSR. Units 1 to 8 are the partitions.
+	// spEntry: $C8EF
+	0xad, 0xf8, 0x07, // LDA mslot
+	0x29, 0x07, // AND #$07
+	0xa8,       // TAY ; Slot for the screen holes
+	0xa5, 0x42, // LDA cmd
+	0x99, 0x78, 0x04, // STA hole0,Y
+	0xa5, 0x43, // LDA unit
+	0x99, 0xf8, 0x04, // STA hole1,Y
+	0xa5, 0x44, // LDA bufLo
+	0x99, 0x78, 0x05, // STA hole2,Y
+	0xa5, 0x45, // LDA bufHi
+	0x99, 0xf8, 0x05, // STA hole3,Y
+	0xa5, 0x46, // LDA blkLo
+	0x99, 0x78, 0x06, // STA hole4,Y
+	0xa5, 0x47, // LDA blkHi
+	0x99, 0xf8, 0x06, // STA hole5,Y
+	0x68,       // PLA ; Return address, the last byte of the JSR
+	0x85, 0x42, // STA cmd
+	0x68,       // PLA
+	0x85, 0x43, // STA unit
+	0x18,       // CLC
+	0xa5, 0x42, // LDA cmd
+	0x69, 0x03, // ADC #$03
+	0xaa,       // TAX
+	0xa5, 0x43, // LDA unit
+	0x69, 0x00, // ADC #$00
+	0x48,       // PHA ; Return past the command and the list pointer
+	0x8a,       // TXA
+	0x48,       // PHA
+	0x08,       // PHP
+	0x78,       // SEI
+	0xd8,       // CLD
+	0xa0, 0x01, // LDY #$01
+	0xb1, 0x42, // LDA (cmd),Y
+	0x48,       // PHA ; Command
+	0xc8,       // INY
+	0xb1, 0x42, // LDA (cmd),Y
+	0xaa,       // TAX
+	0xc8,       // INY
+	0xb1, 0x42, // LDA (cmd),Y
+	0x85, 0x43, // STA unit
+	0x86, 0x42, // STX cmd ; Parameter list
+	0xa0, 0x06, // LDY #$06
+	0xb1, 0x42, // LDA (cmd),Y
+	0x48,       // PHA ; Block bits 16 to 23
+	0xa0, 0x05, // LDY #$05
+	// spCopy: $C940
+	0xb1, 0x42, // LDA (cmd),Y
+	0x99, 0x42, 0x00, // STA cmd,Y ; Unit, buffer and block or status code
+	0x88,       // DEY
+	0xd0, 0xf8, // BNE spCopy
+	0x68,             // PLA
+	0xa8,             // TAY
+	0x68,             // PLA
+	0x20, 0x80, 0xc9, // JSR spCommand
+	0x48,             // PHA
+	0x8a,             // TXA
+	0x48,             // PHA
+	0x98,             // TYA
+	0x48,             // PHA
+	0xad, 0xf8, 0x07, // LDA mslot
+	0x29, 0x07, // AND #$07
+	0xa8,             // TAY
+	0xb9, 0x78, 0x04, // LDA hole0,Y ; Restore the zero page
+	0x85, 0x42, // STA cmd
+	0xb9, 0xf8, 0x04, // LDA hole1,Y
+	0x85, 0x43, // STA unit
+	0xb9, 0x78, 0x05, // LDA hole2,Y
+	0x85, 0x44, // STA bufLo
+	0xb9, 0xf8, 0x05, // LDA hole3,Y
+	0x85, 0x45, // STA bufHi
+	0xb9, 0x78, 0x06, // LDA hole4,Y
+	0x85, 0x46, // STA blkLo
+	0xb9, 0xf8, 0x06, // LDA hole5,Y
+	0x85, 0x47, // STA blkHi
+	0x68,       // PLA
+	0xa8,       // TAY
+	0x68,       // PLA
+	0xaa,       // TAX
+	0x68,       // PLA
+	0x28,       // PLP
+	0xc9, 0x01, // CMP #$01 ; Carry set on errors
+	0x60, // RTS
+	// Execute the SmartPort command on A, Y has the block bits 16 to 23.
+	// Returns the error on A and the bytes transferred on X and Y.
+	// spCommand: $C980
+	0x85, 0x42, // STA cmd
+	0x20, 0xe6, 0xc8, // JSR getSlot
+	0xa5, 0x43, // LDA unit
+	0xd0, 0x1d, // BNE spUnit
+	0xa5, 0x42, // LDA cmd ; The host has only STATUS
+	0xd0, 0x43, // BNE spBadCmd
+	0xa5, 0x46, // LDA blkLo
+	0xd0, 0x12, // BNE badCode
+	0xa0, 0x07, // LDY #$07
+	0xa9, 0x00, // LDA #$00
+	// spHost: $C995
+	0x91, 0x44, // STA (bufLo),Y
+	0x88,       // DEY
+	0xd0, 0xfb, // BNE spHost
+	0xa9, 0x08, // LDA #$08 ; Devices
+	0x91, 0x44, // STA (bufLo),Y
+	0xa2, 0x08, // LDX #$08
+	0xa9, 0x00, // LDA #$00
+	0x60, // RTS
+	// badCode: $C9A3
+	0xa9, 0x21, // LDA #$21 ; Bad status code
+	0x60, // RTS
+	// spUnit: $C9A6
+	0xc9, 0x09, // CMP #$09
+	0x90, 0x03, // BCC spValid
+	0xa9, 0x28, // LDA #$28 ; No device
+	0x60, // RTS
+	// spValid: $C9AD
+	0xa5, 0x42, // LDA cmd
+	0xf0, 0x22, // BEQ spStatus
+	0xc9, 0x03, // CMP #$03
+	0xb0, 0x12, // BCS spOther
+	0xc0, 0x00, // CPY #$00
+	0xd0, 0x0b, // BNE badBlock
+	0xa4, 0x43, // LDY unit
+	0x88,             // DEY ; Partition
+	0x20, 0x45, 0xc8, // JSR block
+	0xa2, 0x00, // LDX #$00 ; 512 bytes
+	0xa0, 0x02, // LDY #$02
+	0x60, // RTS
+	// badBlock: $C9C4
+	0xa9, 0x2d, // LDA #$2D ; Bad block number
+	0x60, // RTS
+	// spOther: $C9C7
+	0xc9, 0x06, // CMP #$06
+	0xb0, 0x05, // BCS spBadCmd ; Only FORMAT, CONTROL and INIT
+	0xa9, 0x00, // LDA #$00
+	0xaa, // TAX
+	0xa8, // TAY
+	0x60, // RTS
+	// spBadCmd: $C9D0
+	0xa9, 0x01, // LDA #$01 ; Bad command
+	0x60, // RTS
+	// spStatus: $C9D3
+	0xa5, 0x46, // LDA blkLo
+	0xf0, 0x1a, // BEQ spStatus0
+	0xc9, 0x03, // CMP #$03
+	0xd0, 0xc8, // BNE badCode
+	0xa0, 0x18, // LDY #$18
+	// spDib: $C9DD
+	0xb9, 0x2f, 0xca, // LDA dib-4,Y
+	0x91, 0x44, // STA (bufLo),Y
+	0x88,       // DEY
+	0xc0, 0x04, // CPY #$04
+	0xb0, 0xf6, // BCS spDib
+	0x20, 0xfb, 0xc9, // JSR unitStatus
+	0xa2, 0x19, // LDX #$19 ; 25 bytes
+	0xa0, 0x00, // LDY #$00
+	0xa9, 0x00, // LDA #$00
+	0x60, // RTS
+	// spStatus0: $C9F1
+	0x20, 0xfb, 0xc9, // JSR unitStatus
+	0xa2, 0x04, // LDX #$04 ; 4 bytes
+	0xa0, 0x00, // LDY #$00
+	0xa9, 0x00, // LDA #$00
+	0x60, // RTS
+	// Write the status and the blocks of the unit, it is offline when the
+	// first block of the partition can't be verified
+	// unitStatus: $C9FB
+	0xa4, 0x43, // LDY unit
+	0x88,       // DEY
+	0xa9, 0x00, // LDA #$00
+	0x85, 0x46, // STA blkLo
+	0x85, 0x47, // STA blkHi
+	0x20, 0xb5, 0xc8, // JSR setup
+	0xa9, 0x40, // LDA #$40 ; VERIFY SECTORS
+	0x9d, 0x8f, 0xc0, // STA command,X
+	0x20, 0xda, 0xc8, // JSR wait
+	0xa0, 0x00, // LDY #$00
+	0xb0, 0x0e, // BCS offline
+	0xa9, 0xf8, // LDA #$F8 ; Block device, write, read, online and format
+	0x91, 0x44, // STA (bufLo),Y
+	0xa9, 0xff, // LDA #$FF ; 65535 blocks
+	0xc8,       // INY
+	0x91, 0x44, // STA (bufLo),Y
+	0xc8,       // INY
+	0x91, 0x44, // STA (bufLo),Y
+	0xd0, 0x0c, // BNE statusEnd
+	// offline: $CA21
+	0xa9, 0xe8, // LDA #$E8
+	0x91, 0x44, // STA (bufLo),Y
+	0xa9, 0x00, // LDA #$00
+	0xc8,       // INY
+	0x91, 0x44, // STA (bufLo),Y
+	0xc8,       // INY
+	0x91, 0x44, // STA (bufLo),Y
+	// statusEnd: $CA2D
+	0xa9, 0x00, // LDA #$00
+	0xc8,       // INY
+	0x91, 0x44, // STA (bufLo),Y
+	0x60, // RTS
+	// Device information block from the name length
+	// dib: $CA33
+	0x04,
+	0x43, 0x46, 0x46, 0x41, // CFFA
+	0x20, 0x20, 0x20, 0x20, 0x20, 0x20, 0x20, 0x20, 0x20, 0x20, 0x20, 0x20,
+	0x02, 0x00, // Hard disk
+	0x00, 0x01, // Version
+}
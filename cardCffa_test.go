@@ -0,0 +1,208 @@
+package izapple2
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCffaBoots(t *testing.T) {
+	overrides := newConfiguration()
+	overrides.set(confS7, "cffa,image1=<internal>/ProDOS_2_4_3.po")
+	testBoots(t, "2enh", "", overrides, 100_000_000, "BITSY  BYE", "NEW VOL", testTextMode40)
+}
+
+func TestCffaRegisters(t *testing.T) {
+	data, _, err := LoadResource("<internal>/ProDOS_2_4_3.po")
+	if err != nil {
+		t.Fatal(err)
+	}
+	image := filepath.Join(t.TempDir(), "prodos.po")
+	err = os.WriteFile(image, data, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	overrides := newConfiguration()
+	overrides.set(confS7, "cffa,image2=\""+image+"\"")
+	at, err := makeApple2Tester("2enh", overrides)
+	if err != nil {
+		t.Fatal(err)
+	}
+	io := at.a.io
+	command := func(command uint8, lba uint32) {
+		io.poke(0xc0fa, 1)
+		io.poke(0xc0fb, uint8(lba))
+		io.poke(0xc0fc, uint8(lba>>8))
+		io.poke(0xc0fd, uint8(lba>>16))
+		io.poke(0xc0fe, 0xe0)
+		io.poke(0xc0ff, command)
+	}
+	readSector := func() []uint8 {
+		data := make([]uint8, 512)
+		for i := 0; i < 512; i += 2 {
+			data[i] = io.peek(0xc0f8)
+			data[i+1] = io.peek(0xc0f0)
+		}
+		return data
+	}
+
+	// The model name is on words 27 to 46 with the bytes swapped
+	command(ataCommandIdentify, 0)
+	identify := readSector()
+	model := make([]uint8, 40)
+	for i := range model {
+		model[i] = identify[(54+i)^1]
+	}
+	if !strings.HasPrefix(string(model), "izapple2 virtual disk") {
+		t.Errorf("Unexpected model '%s'", model)
+	}
+	sectors := uint32(identify[120]) | uint32(identify[121])<<8 | uint32(identify[122])<<16
+	if sectors != cffaPartitionSectors+280 {
+		t.Errorf("Unexpected size of %v sectors", sectors)
+	}
+
+	// The first partition is empty
+	command(ataCommandReadSectors, 2)
+	if io.peek(0xc0ff)&ataStatusError == 0 || io.peek(0xc0f9) != ataErrorIdNotFound {
+		t.Error("Error expected reading a missing partition")
+	}
+
+	// Write a block on the second partition and read it back
+	command(ataCommandWriteSectors, cffaPartitionSectors+3)
+	for i := 0; i < 256; i++ {
+		io.poke(0xc0f0, uint8(i))
+		io.poke(0xc0f8, 0xaa)
+	}
+	if io.peek(0xc0ff) != ataStatusReady|ataStatusSeek {
+		t.Errorf("Unexpected status $%02x after write", io.peek(0xc0ff))
+	}
+	command(ataCommandReadSectors, cffaPartitionSectors+3)
+	data = readSector()
+	if data[0] != 0xaa || data[1] != 0 || data[511] != 0xff {
+		t.Errorf("Unexpected data read %v", data[:8])
+	}
+}
+
+func TestCffaSmartPort(t *testing.T) {
+	data, _, err := LoadResource("<internal>/ProDOS_2_4_3.po")
+	if err != nil {
+		t.Fatal(err)
+	}
+	image := filepath.Join(t.TempDir(), "prodos.po")
+	err = os.WriteFile(image, data, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	overrides := newConfiguration()
+	overrides.set(confS7, "cffa,image1=<internal>/ProDOS_2_4_3.po,image8=\""+image+"\"")
+	at, err := makeApple2Tester("2enh", overrides)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := at.a
+
+	if a.mmu.Peek(0xc707) != 0x00 || a.mmu.Peek(0xc7ff) != 0x0a {
+		t.Fatal("SmartPort signature expected")
+	}
+
+	// Call the SmartPort entry with the parameter list on $0380
+	call := func(command uint8, params []uint8) (uint8, uint16) {
+		program := []uint8{
+			0x20, 0x0d, 0xc7, // JSR $C70D
+			command,
+			0x80, 0x03, // Parameter list on $0380
+		}
+		for i, value := range program {
+			a.mmu.Poke(0x300+uint16(i), value)
+		}
+		for i, value := range params {
+			a.mmu.Poke(0x380+uint16(i), value)
+		}
+		a.mmu.Poke(0x42, 0x5a) // ProDOS zero page to be preserved
+
+		a.cpu.SetPC(0x300)
+		end := 0x300 + uint16(len(program))
+		for pc, _ := a.cpu.GetPCAndSP(); pc != end; pc, _ = a.cpu.GetPCAndSP() {
+			a.executeInstruction()
+		}
+		if a.mmu.Peek(0x42) != 0x5a {
+			t.Error("Zero page not restored")
+		}
+		regA, regX, regY, regP := a.cpu.GetAXYP()
+		if (regP&1 == 1) != (regA != 0) {
+			t.Errorf("Carry expected only on errors, A is $%02x", regA)
+		}
+		return regA, uint16(regX) | uint16(regY)<<8
+	}
+
+	errCode, count := call(0x00, []uint8{3, 0, 0x00, 0x20, 0})
+	if errCode != 0 || count != 8 || a.mmu.Peek(0x2000) != 8 {
+		t.Errorf("Host status failed with $%02x, %v devices", errCode, a.mmu.Peek(0x2000))
+	}
+
+	// The units without image are offline
+	for unit, online := range map[uint8]bool{1: true, 2: false, 5: false, 8: true} {
+		errCode, count = call(0x00, []uint8{3, unit, 0x00, 0x20, 0})
+		if errCode != 0 || count != 4 || (a.mmu.Peek(0x2000)&0x10 != 0) != online {
+			t.Errorf("Unexpected status $%02x of unit %v", a.mmu.Peek(0x2000), unit)
+		}
+	}
+	errCode, count = call(0x00, []uint8{3, 8, 0x00, 0x20, 3})
+	if name := string(peekRange(a, 0x2005, int(a.mmu.Peek(0x2004)))); errCode != 0 || count != 25 || name != "CFFA" {
+		t.Errorf("DIB failed with $%02x, %v bytes and name '%s'", errCode, count, name)
+	}
+
+	// Write and read on the last partition, on the second device
+	for i := uint16(0); i < 512; i++ {
+		a.mmu.Poke(0x2000+i, uint8(i*3))
+	}
+	errCode, _ = call(0x02, []uint8{3, 8, 0x00, 0x20, 3, 0, 0})
+	if errCode != 0 {
+		t.Fatalf("WRITEBLOCK failed with $%02x", errCode)
+	}
+	for _, block := range []uint8{2, 3} {
+		errCode, count = call(0x01, []uint8{3, 8, 0x00, 0x20, block, 0, 0})
+		if errCode != 0 || count != 512 {
+			t.Fatalf("READBLOCK %v failed with $%02x", block, errCode)
+		}
+		expected := data[int(block)*512 : int(block+1)*512]
+		if block == 3 {
+			for i := range expected {
+				expected[i] = uint8(i * 3)
+			}
+		}
+		for i, want := range expected {
+			if got := a.mmu.Peek(0x2000 + uint16(i)); got != want {
+				t.Fatalf("Mismatch on byte %v of block %v: $%02x instead of $%02x", i, block, got, want)
+			}
+		}
+	}
+
+	errors := []struct {
+		command uint8
+		params  []uint8
+		errCode uint8
+	}{
+		{0x01, []uint8{3, 2, 0x00, 0x20, 2, 0, 0}, 0x27}, // No image
+		{0x01, []uint8{3, 9, 0x00, 0x20, 2, 0, 0}, 0x28}, // No unit
+		{0x01, []uint8{3, 1, 0x00, 0x20, 2, 0, 1}, 0x2d}, // Block out of range
+		{0x00, []uint8{3, 1, 0x00, 0x20, 5}, 0x21},       // Bad status code
+	}
+	for _, e := range errors {
+		if errCode, _ = call(e.command, e.params); errCode != e.errCode {
+			t.Errorf("Error $%02x expected on %v, got $%02x", e.errCode, e.params, errCode)
+		}
+	}
+}
+
+func TestCffaRejectsUnsupported(t *testing.T) {
+	overrides := newConfiguration()
+	overrides.set(confS7, "cffa,model=3000")
+	_, err := makeApple2Tester("2enh", overrides)
+	if err == nil {
+		t.Error("The CFFA3000 is expected to be rejected")
+	}
+}
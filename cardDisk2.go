@@ -147,19 +147,29 @@ func (c *CardDisk2) assign(a *Apple2, slot int) {
 			// Update magnets and position
 			drive := &c.drive[c.selected]
 			drive.phases &^= (1 << phase)
-			drive.trackStep = moveDriveStepper(drive.phases, drive.trackStep)
+			prevStep := drive.trackStep
+			var bump bool
+			drive.trackStep, bump = moveDriveStepper(drive.phases, drive.trackStep)
+			if c.power {
+				c.a.driveSoundsForStep(drive.trackStep != prevStep, bump)
+			}
 
 			if c.trackTracer != nil {
 				c.trackTracer.traceTrack(drive.trackStep, c.slot, c.selected)
 			}
 
-			return c.dataLatch // All even addresses return the last dataLatch
+			return c.readDataLatch() // All even addresses return the data latch
 		}, fmt.Sprintf("PHASE%vOFF", phase))
 
 		c.addCardSoftSwitchRW((phase<<1)+1, func() uint8 { // Update magnets and position
 			drive := &c.drive[c.selected]
 			drive.phases |= (1 << phase)
-			drive.trackStep = moveDriveStepper(drive.phases, drive.trackStep)
+			prevStep := drive.trackStep
+			var bump bool
+			drive.trackStep, bump = moveDriveStepper(drive.phases, drive.trackStep)
+			if c.power {
+				c.a.driveSoundsForStep(drive.trackStep != prevStep, bump)
+			}
 
 			if c.trackTracer != nil {
 				c.trackTracer.traceTrack(drive.trackStep, slot, c.selected)
@@ -172,7 +182,7 @@ func (c *CardDisk2) assign(a *Apple2, slot int) {
 	// Q4, power switch
 	c.addCardSoftSwitchRW(0x8, func() uint8 {
 		c.softSwitchQ4(false)
-		return c.dataLatch
+		return c.readDataLatch()
 	}, "Q4DRIVEOFF")
 	c.addCardSoftSwitchRW(0x9, func() uint8 {
 		c.softSwitchQ4(true)
@@ -182,7 +192,7 @@ func (c *CardDisk2) assign(a *Apple2, slot int) {
 	// Q5, drive selecion
 	c.addCardSoftSwitchRW(0xA, func() uint8 {
 		c.softSwitchQ5(0)
-		return c.dataLatch
+		return c.readDataLatch()
 	}, "Q5SELECT1")
 	c.addCardSoftSwitchRW(0xB, func() uint8 {
 		c.softSwitchQ5(1)
@@ -210,6 +220,7 @@ func (c *CardDisk2) softSwitchQ4(value bool) {
 		if c.fastMode {
 			c.a.ReleaseFastMode()
 		}
+		c.a.driveSound(DriveSoundMotorOff)
 		drive := &c.drive[c.selected]
 		if drive.diskette != nil {
 			drive.diskette.PowerOff(c.a.GetCycles())
@@ -220,6 +231,7 @@ func (c *CardDisk2) softSwitchQ4(value bool) {
 		if c.fastMode {
 			c.a.RequestFastMode()
 		}
+		c.a.driveSound(DriveSoundMotorOn)
 		drive := &c.drive[c.selected]
 		if drive.diskette != nil {
 			drive.diskette.PowerOn(c.a.GetCycles())
@@ -258,12 +270,25 @@ func (c *CardDisk2) softSwitchQ6Q7(index uint8, in uint8) uint8 {
 
 	c.processQ6Q7(in)
 	if index&1 == 0 {
-		// All even addresses return the last dataLatch
+		// All even addresses return the dataLatch
 		return c.dataLatch
 	}
 	return 0
 }
 
+// readDataLatch returns the data latch, updated with the disk if not in write mode
+func (c *CardDisk2) readDataLatch() uint8 {
+	d := &c.drive[c.selected]
+	if !c.q7 && d.diskette != nil {
+		if !c.q6 {
+			c.dataLatch = d.diskette.Read(d.trackStep, c.a.GetCycles())
+		} else {
+			c.dataLatch = d.diskette.SenseWriteProtect(d.trackStep, c.a.GetCycles())
+		}
+	}
+	return c.dataLatch
+}
+
 func (c *CardDisk2) processQ6Q7(in uint8) {
 	d := &c.drive[c.selected]
 	if d.diskette == nil {
@@ -278,7 +303,7 @@ func (c *CardDisk2) processQ6Q7(in uint8) {
 	} else { // load
 		if !c.q7 { // Q6H-Q7L: Sense write protect / prewrite state
 			// Bit 7 of the control status register means write protected
-			c.dataLatch = 0 // Never write protected
+			c.dataLatch = d.diskette.SenseWriteProtect(d.trackStep, c.a.GetCycles())
 		} else { // Q6H-Q7H: Load data into the controller
 			c.dataLatch = in
 		}
@@ -15,6 +15,9 @@ We will consider that the cog would go to the prefferred position if there is on
 of the previous position. The previous position is only used to know if it goes up or down
 a full group.
 
+When the cog is pushed below the first step, the head hits the track 0 stop
+and bumps. That is the noise of the recalibration on boot.
+
 Phases are coded in 4 bits in an uint8. Q3, q2, q1 and q0 with q0 on the LSB.
 */
 
@@ -44,14 +47,14 @@ var cogPositions = []int{
 	undefinedPosition, // 1111
 }
 
-func moveDriveStepper(phases uint8, prevStep int) int {
+func moveDriveStepper(phases uint8, prevStep int) (int, bool) {
 
 	// fmt.Printf("magnets: 0x%x\n", phases)
 
 	cogPosition := cogPositions[phases]
 	if cogPosition == undefinedPosition {
 		// Don't move if magnets don't push on a defined direction.
-		return prevStep
+		return prevStep, false
 	}
 
 	prevPosition := prevStep % stepsPerGroup // Direction, step in the current group of magnets.
@@ -61,6 +64,7 @@ func moveDriveStepper(phases uint8, prevStep int) int {
 	}
 
 	var nextStep int
+	bump := false
 	if delta < 4 {
 		// Steps up
 		nextStep = prevStep + delta
@@ -75,9 +79,10 @@ func moveDriveStepper(phases uint8, prevStep int) int {
 		nextStep = prevStep + delta - stepsPerGroup
 		if nextStep < 0 {
 			nextStep = 0
+			bump = true
 		}
 	}
 
 	// fmt.Printf("[DiskII] 1/4 track: %03d %vO\n", nextStep, strings.Repeat(" ", nextStep))
-	return nextStep
+	return nextStep, bump
 }
@@ -31,8 +31,8 @@ type CardDisk2Sequencer struct {
 	motorDelay uint64  // NE556 timer, used to delay motor off
 	drive      [2]cardDisk2SequencerDrive
 
-	lastWriteValue  bool  // We write transitions to the WOZ file. We store the last value to send a pulse on change.
-	lastPulseCycles uint8 // There is a new pulse every 4ms, that's 8 cycles of 2Mhz
+	lastWriteValue bool   // We write transitions to the WOZ file. We store the last value to send a pulse on change.
+	pulsePs        uint64 // Time since the last pulse in picoseconds. The default is a pulse every 4us, 8 cycles of 2Mhz
 
 	lastCycle uint64 // 2 Mhz cycles
 
@@ -46,7 +46,7 @@ type cardDisk2Shared interface {
 
 const (
 	disk2MotorOffDelay = uint64(2 * 1000 * 1000) // 2 Mhz cycles. Total 1 second.
-	disk2PulseCyles    = uint8(8)                // 8 cycles = 4ms * 2Mhz
+	disk2CyclePs       = uint64(500_000)         // A 2 Mhz cycle is 500ns
 
 	/*
 	   We skip register calculations for long periods with the motor
@@ -127,9 +127,24 @@ func (c *CardDisk2Sequencer) GetInfo() map[string]string {
 
 func (c *CardDisk2Sequencer) reset() {
 	// UtA2e 9-12, all switches forced to off
+	if c.q[4] {
+		c.motorSound(false)
+	}
 	c.q = [8]bool{}
 }
 
+/*
+The motor sounds follow q4. The off delay is only advanced when the card
+is accessed and the motor would be heard until the next disk access.
+*/
+func (c *CardDisk2Sequencer) motorSound(on bool) {
+	if on {
+		c.a.driveSound(DriveSoundMotorOn)
+	} else {
+		c.a.driveSound(DriveSoundMotorOff)
+	}
+}
+
 func (c *CardDisk2Sequencer) setTrackTracer(tt trackTracer) {
 	c.trackTracer = tt
 }
@@ -145,7 +160,11 @@ func (c *CardDisk2Sequencer) assign(a *Apple2, slot int) {
 				slot_address[0] => latch_data
 				slot_dev_selct =>  latch_write_enable ;It will be true
 		*/
+		prevQ4 := c.q[4]
 		c.q[address>>1] = (address & 1) != 0
+		if c.q[4] != prevQ4 {
+			c.motorSound(c.q[4])
+		}
 
 		// Advance the Disk2 state machine since the last call to softswitches
 		c.catchUp(data)
@@ -233,8 +252,10 @@ func (c *CardDisk2Sequencer) step(data uint8, firstStep bool) bool {
 		q1 := c.q[1]
 		q2 := c.q[2]
 		q3 := c.q[3]
-		c.drive[0].moveHead(q0, q1, q2, q3, c.trackTracer, c.slot, 0)
-		c.drive[1].moveHead(q0, q1, q2, q3, c.trackTracer, c.slot, 1)
+		for i := range c.drive {
+			moved, bump := c.drive[i].moveHead(q0, q1, q2, q3, c.trackTracer, c.slot, i)
+			c.a.driveSoundsForStep(moved, bump)
+		}
 	}
 
 	/*
@@ -245,12 +266,16 @@ func (c *CardDisk2Sequencer) step(data uint8, firstStep bool) bool {
 		this detection.
 	*/
 	pulse := false
-	c.lastPulseCycles++
-	if c.lastPulseCycles == disk2PulseCyles {
+	c.pulsePs += disk2CyclePs
+	bitTimingPs := c.drive[0].bitTimingPs()
+	if c.drive[1].enabled {
+		bitTimingPs = c.drive[1].bitTimingPs()
+	}
+	if c.pulsePs >= bitTimingPs {
 		// Read
 		pulse = c.drive[0].readPulse() ||
 			c.drive[1].readPulse()
-		c.lastPulseCycles = 0
+		c.pulsePs -= bitTimingPs
 	}
 
 	/*
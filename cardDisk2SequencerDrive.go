@@ -25,7 +25,7 @@ func (d *cardDisk2SequencerDrive) insertDiskette(filename string) error {
 	if err != nil {
 		return err
 	}
-	f, err := storage.NewFileWoz(data)
+	f, err := storage.MakeFileWoz(data)
 	if err != nil {
 		return err
 	}
@@ -45,20 +45,30 @@ func (d *cardDisk2SequencerDrive) enable(enabled bool) {
 	d.enabled = enabled
 }
 
-func (d *cardDisk2SequencerDrive) moveHead(q0, q1, q2, q3 bool, trackTracer trackTracer, slot int, driveNumber int) {
+func (d *cardDisk2SequencerDrive) moveHead(q0, q1, q2, q3 bool, trackTracer trackTracer, slot int, driveNumber int) (moved bool, bump bool) {
 	if !d.enabled {
-		return
+		return false, false
 	}
 
 	phases := component.PinsToByte([8]bool{
 		q0, q1, q2, q3,
 		false, false, false, false,
 	})
-	d.currentQuarterTrack = moveDriveStepper(phases, d.currentQuarterTrack)
+	prevQuarterTrack := d.currentQuarterTrack
+	d.currentQuarterTrack, bump = moveDriveStepper(phases, d.currentQuarterTrack)
 
 	if trackTracer != nil {
 		trackTracer.traceTrack(d.currentQuarterTrack, slot, driveNumber)
 	}
+	return d.currentQuarterTrack != prevQuarterTrack, bump
+}
+
+// bitTimingPs returns the time between pulses in picoseconds
+func (d *cardDisk2SequencerDrive) bitTimingPs() uint64 {
+	if d.data == nil {
+		return 4_000_000 // 4 microseconds
+	}
+	return d.data.TrackBitTimingPs(d.currentQuarterTrack)
 }
 
 func (d *cardDisk2SequencerDrive) readPulse() bool {
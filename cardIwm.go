@@ -0,0 +1,299 @@
+package izapple2
+
+import (
+	"fmt"
+	"strconv"
+)
+
+/*
+IWM (Integrated Woz Machine) controller with Apple 3.5 drives.
+
+See:
+	"IWM Floppy Disk I/O Controller info" (https://www.brutaldeluxe.fr/documentation/iwm/apple2_IWM_INFO_19840510.pdf)
+	Apple IIgs Hardware Reference, chapter 7
+	https://applesaucefdc.com/woz/reference2/
+
+The IWM has the same softswitches as the Disk II card. The phases are used
+as the CA0, CA1, CA2 and LSTRB lines of the 3.5 drives. The Apple IIe has
+no $C031 register as the Apple IIgs, the SEL line is latched by the card
+from bit 7 of any write to the slot page $Cn00-$CnFF.
+
+Q6 and Q7 select the register of the IWM:
+	Q7L-Q6L: Data register, read
+	Q7L-Q6H: Status register, read
+	Q7H-Q6L: Write handshake register, read
+	Q7H-Q6H: Mode register (motor off) or data register (motor on), write
+
+The disks are read only, 3.5 WOZ or A2R files and 400Kb or 800Kb block
+images are supported. The firmware is a ProDOS block driver with a
+SmartPort entry built for the card, see cardIwmFirmware.go.
+*/
+
+// CardIwm is an IWM controller with two Apple 3.5 drives
+type CardIwm struct {
+	cardBase
+
+	selected  int    // Drive selected
+	enable    bool   // Motor on line to the drives
+	offDelay  uint64 // Cycle when the motor line goes off after being turned off
+	phases    uint8  // CA0, CA1, CA2 and LSTRB with CA0 on the LSB
+	sel       bool   // SEL line, bit 7 of the last write to the slot page
+	q6        bool
+	q7        bool
+	mode      uint8 // Mode register
+	data      uint8 // Data register
+	shift     uint8 // Shift register
+	drive     [2]cardIwmDrive35
+	fastMode  bool
+	lastCycle uint64
+	elapsedPs uint64 // Time since the last bit, in picoseconds
+	slotRom   []uint8
+	ram       [0x100]uint8 // RAM on $CF00 to $CFFF
+
+	trackTracer trackTracer
+}
+
+const (
+	iwmModeAsync        = 0x02
+	iwmModeTimerDisable = 0x04
+	iwmModeMask         = 0x1f
+	iwmStatusSense      = 0x80
+	iwmStatusEnable     = 0x20
+	iwmHandshakeReady   = 0xff              // Register ready, no underrun
+	iwmMotorOffDelay    = uint64(1_020_484) // One second in cycles
+)
+
+func newCardIwmBuilder() *cardBuilder {
+	return &cardBuilder{
+		name:        "IWM 3.5",
+		description: "IWM controller with Apple 3.5 drives",
+		defaultParams: &[]paramSpec{
+			{"disk1", "Diskette image for drive 1", ""},
+			{"disk2", "Diskette image for drive 2", ""},
+			{"tracktracer", "Trace how the disk head moves between tracks", "false"},
+			{"fast", "Enable CPU burst when accessing the disk", "true"},
+		},
+		buildFunc: func(params map[string]string) (Card, error) {
+			var c CardIwm
+
+			for i := range c.drive {
+				disk := paramsGetPath(params, fmt.Sprintf("disk%v", i+1))
+				if disk != "" {
+					err := c.drive[i].insertDiskette(disk)
+					if err != nil {
+						return nil, err
+					}
+				}
+			}
+
+			trackTracer := paramsGetBool(params, "tracktracer")
+			if trackTracer {
+				c.trackTracer = makeTrackTracerLogger()
+			}
+			c.fastMode = paramsGetBool(params, "fast")
+			return &c, nil
+		},
+	}
+}
+
+// GetInfo returns card info
+func (c *CardIwm) GetInfo() map[string]string {
+	info := make(map[string]string)
+	info["mode"] = fmt.Sprintf("%02x", c.mode)
+	info["enable"] = strconv.FormatBool(c.enable)
+	for i := range c.drive {
+		prefix := fmt.Sprintf("D%v ", i+1)
+		info[prefix+"name"] = c.drive[i].name
+		info[prefix+"track"] = strconv.Itoa(c.drive[i].track)
+		info[prefix+"motor"] = strconv.FormatBool(c.drive[i].motorOn)
+	}
+	return info
+}
+
+func (c *CardIwm) reset() {
+	c.phases = 0
+	c.setEnable(false)
+	c.selected = 0
+	c.q6 = false
+	c.q7 = false
+	c.mode = 0
+}
+
+func (c *CardIwm) setTrackTracer(tt trackTracer) {
+	c.trackTracer = tt
+}
+
+func (c *CardIwm) assign(a *Apple2, slot int) {
+	a.registerRemovableMediaDrive(&c.drive[0])
+	a.registerRemovableMediaDrive(&c.drive[1])
+
+	c.addCardSoftSwitches(func(address uint8, data uint8, write bool) uint8 {
+		c.catchUp()
+
+		on := address&1 == 1
+		switch address >> 1 {
+		case 0, 1, 2, 3:
+			c.setPhase(address>>1, on)
+		case 4:
+			c.setEnable(on)
+		case 5:
+			c.selected = int(address & 1)
+		case 6:
+			c.q6 = on
+		case 7:
+			c.q7 = on
+		}
+
+		if write && c.q6 && c.q7 && !c.isEnabled() {
+			c.mode = data & iwmModeMask
+		}
+
+		if on {
+			return 0
+		}
+		return c.readRegister()
+	}, "IWM")
+
+	c.slotRom = buildIwmSlotRom(slot)
+	c.romCxxx = c
+	c.cardBase.assign(a, slot)
+}
+
+func (c *CardIwm) peek(address uint16) uint8 {
+	switch {
+	case address < iwmFirmwareRomAddress:
+		return c.slotRom[address&0xff]
+	case address < iwmFirmwareRamAddress:
+		offset := int(address - iwmFirmwareRomAddress)
+		if offset < len(iwmFirmware) {
+			return iwmFirmware[offset]
+		}
+		return 0
+	default:
+		return c.ram[address-iwmFirmwareRamAddress]
+	}
+}
+
+func (c *CardIwm) poke(address uint16, value uint8) {
+	switch {
+	case address < iwmFirmwareRomAddress:
+		c.catchUp()
+		c.sel = value&0x80 != 0
+	case address >= iwmFirmwareRamAddress:
+		c.ram[address-iwmFirmwareRamAddress] = value
+	}
+}
+
+func (c *CardIwm) setPhase(phase uint8, on bool) {
+	previous := c.phases
+	if on {
+		c.phases |= 1 << phase
+	} else {
+		c.phases &^= 1 << phase
+	}
+
+	if phase == 3 && on && previous&0x8 == 0 && c.isEnabled() {
+		// LSTRB pulse, send the command to the drive
+		register := c.phases & 0x3
+		if c.sel {
+			register |= 0x4
+		}
+		value := c.phases&0x4 != 0
+		c.tracef("Command %x with %v to drive %v\n", register, value, c.selected+1)
+		c.drive[c.selected].control(register, value, c.trackTracer, c.slot, c.selected)
+	}
+}
+
+func (c *CardIwm) setEnable(enable bool) {
+	if enable == c.enable {
+		return
+	}
+	c.enable = enable
+	if c.a == nil {
+		return
+	}
+	if !enable {
+		c.offDelay = c.a.GetCycles() + iwmMotorOffDelay
+	}
+	if c.fastMode {
+		if enable {
+			c.a.RequestFastMode()
+		} else {
+			c.a.ReleaseFastMode()
+		}
+	}
+}
+
+// isEnabled returns true if the motor line is on, the IWM keeps it on
+// for one second after being turned off unless the timer is disabled
+func (c *CardIwm) isEnabled() bool {
+	if c.enable {
+		return true
+	}
+	return c.mode&iwmModeTimerDisable == 0 &&
+		c.a.GetCycles() < c.offDelay
+}
+
+func (c *CardIwm) readRegister() uint8 {
+	switch {
+	case !c.q6 && !c.q7:
+		if !c.isEnabled() {
+			return 0xff
+		}
+		value := c.data
+		if c.mode&iwmModeAsync != 0 && value&0x80 != 0 {
+			// On asynchronous mode the register is cleared after being read
+			c.data = 0
+		}
+		return value
+	case c.q6 && !c.q7:
+		status := c.mode
+		if c.isEnabled() {
+			status |= iwmStatusEnable
+		}
+		register := c.phases & 0x7
+		if c.sel {
+			register |= 0x8
+		}
+		if c.drive[c.selected].sense(register) {
+			status |= iwmStatusSense
+		}
+		return status
+	case !c.q6 && c.q7:
+		return iwmHandshakeReady
+	}
+	return 0
+}
+
+// catchUp reads the bits since the last access to the IWM
+func (c *CardIwm) catchUp() {
+	cycle := c.a.GetCycles()
+	if !c.isEnabled() {
+		c.lastCycle = cycle
+		c.elapsedPs = 0
+		return
+	}
+
+	d := &c.drive[c.selected]
+	if c.sel {
+		d.head = 1
+	} else {
+		d.head = 0
+	}
+
+	c.elapsedPs += (cycle - c.lastCycle) * 2 * disk2CyclePs
+	c.lastCycle = cycle
+	bitTimingPs := d.bitTimingPs()
+	for c.elapsedPs >= bitTimingPs {
+		c.elapsedPs -= bitTimingPs
+		c.shift <<= 1
+		if d.readBit() {
+			c.shift |= 1
+		}
+		if c.shift&0x80 != 0 {
+			// A full nibble is moved to the data register
+			c.data = c.shift
+			c.shift = 0
+		}
+	}
+}
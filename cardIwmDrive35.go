@@ -0,0 +1,169 @@
+package izapple2
+
+import (
+	"github.com/ivanizag/izapple2/storage"
+)
+
+/*
+Apple 3.5 drive as seen by the IWM.
+
+See:
+	"Inside Macintosh, volume III", chapter 2 "The Disk Driver"
+	Apple IIgs Hardware Reference, chapter 7
+
+The drive has 16 registers to read status and 8 to send commands. The
+register is selected with the CA0, CA1 and CA2 lines (phases 0 to 2 of
+the IWM) and the SEL line. The status is read on the sense line, bit 7
+of the IWM status register. To send a command the value is on CA2 and the
+LSTRB line (phase 3 of the IWM) is pulsed.
+
+The drive spins at different speeds depending on the track zone. As the
+bitstreams have the length of a full revolution, the zones come naturally
+reading the bits at a constant 2 microseconds rate.
+*/
+
+const (
+	drive35SenseDirection   = 0x0 // 0 to step to higher tracks
+	drive35SenseStepping    = 0x1 // 0 when a step is in progress
+	drive35SenseMotorOn     = 0x2 // 0 when the motor is on
+	drive35SenseSwitched    = 0x3 // 1 when the disk has been switched
+	drive35SenseReadData0   = 0x4 // Bit under the lower head
+	drive35SenseSuperDrive  = 0x5 // 1 for the FDHD SuperDrive
+	drive35SenseDoubleSided = 0x6 // 1 for double sided drives
+	drive35SenseNoDrive     = 0x7 // 0 when the drive is connected
+	drive35SenseNoDisk      = 0x8 // 0 when there is a disk in the drive
+	drive35SenseNoWriteProt = 0x9 // 0 when the disk is write protected
+	drive35SenseNotTrack0   = 0xa // 0 when the head is on track 0
+	drive35SenseTachometer  = 0xb // 60 pulses per revolution
+	drive35SenseReadData1   = 0xc // Bit under the upper head
+	drive35SenseNotReady    = 0xe // 0 when the drive is ready
+	drive35ControlDirection = 0x0 // CA2 to 0 to step to higher tracks
+	drive35ControlStep      = 0x1 // CA2 to 0 to step a track
+	drive35ControlMotor     = 0x2 // CA2 to 0 to turn the motor on
+	drive35ControlEject     = 0x3 // CA2 to 1 to eject the disk
+	drive35ControlSwitched  = 0x4 // CA2 to 1 to reset the switched flag. Address with SEL set
+	drive35TachometerPulses = 60
+	drive35Tracks           = 80
+)
+
+type cardIwmDrive35 struct {
+	name     string
+	data     *storage.FileWoz
+	track    int
+	head     int
+	inward   bool // Stepping to the higher tracks
+	motorOn  bool
+	switched bool
+
+	position    uint32 // Current position on the track
+	positionMax uint32 // As tracks have different lengths position is related of positionMax of the last track
+	lastBit     bool
+}
+
+func (d *cardIwmDrive35) insertDiskette(filename string) error {
+	data, _, err := LoadResource(filename)
+	if err != nil {
+		return err
+	}
+	f, err := storage.MakeFileWoz35(data)
+	if err != nil {
+		return err
+	}
+
+	d.name = filename
+	d.data = f
+	d.switched = true
+	return nil
+}
+
+func (d *cardIwmDrive35) eject() {
+	d.name = ""
+	d.data = nil
+	d.motorOn = false
+}
+
+// sense returns the value of the sense line for the selected register
+func (d *cardIwmDrive35) sense(register uint8) bool {
+	switch register {
+	case drive35SenseDirection:
+		return !d.inward
+	case drive35SenseStepping:
+		return true // The steps are immediate
+	case drive35SenseMotorOn:
+		return !d.motorOn
+	case drive35SenseSwitched:
+		return d.switched
+	case drive35SenseReadData0, drive35SenseReadData1:
+		return d.lastBit
+	case drive35SenseSuperDrive:
+		return false
+	case drive35SenseDoubleSided:
+		return true
+	case drive35SenseNoDrive:
+		return false
+	case drive35SenseNoDisk:
+		return d.data == nil
+	case drive35SenseNoWriteProt:
+		return false // Writes are not supported
+	case drive35SenseNotTrack0:
+		return d.track != 0
+	case drive35SenseTachometer:
+		if d.positionMax == 0 || !d.motorOn {
+			return false
+		}
+		return (uint64(d.position)*2*drive35TachometerPulses/uint64(d.positionMax))&1 == 1
+	case drive35SenseNotReady:
+		return d.data == nil || !d.motorOn
+	}
+	return true
+}
+
+// control executes a command with the value on CA2
+func (d *cardIwmDrive35) control(register uint8, value bool, trackTracer trackTracer, slot int, driveNumber int) {
+	switch register {
+	case drive35ControlDirection:
+		d.inward = !value
+	case drive35ControlStep:
+		if !value {
+			if d.inward && d.track < drive35Tracks-1 {
+				d.track++
+			} else if !d.inward && d.track > 0 {
+				d.track--
+			}
+			if trackTracer != nil {
+				trackTracer.traceTrack(d.track, slot, driveNumber)
+			}
+		}
+	case drive35ControlMotor:
+		d.motorOn = !value && d.data != nil
+	case drive35ControlEject:
+		if value {
+			d.eject()
+		}
+	case drive35ControlSwitched:
+		if value {
+			d.switched = false
+		}
+	}
+}
+
+// bitTimingPs returns the time between bits in picoseconds
+func (d *cardIwmDrive35) bitTimingPs() uint64 {
+	if d.data == nil {
+		return 2_000_000 // 2 microseconds
+	}
+	return d.data.BitTimingNs() * 1000
+}
+
+// readBit returns the next bit under the selected head
+func (d *cardIwmDrive35) readBit() bool {
+	if !d.motorOn || d.data == nil {
+		return false
+	}
+
+	d.lastBit, d.position, d.positionMax = d.data.GetNextBitAndPosition(
+		d.position,
+		d.positionMax,
+		d.track<<1|d.head)
+	return d.lastBit
+}
@@ -0,0 +1,991 @@
+package izapple2
+
+/*
+Firmware for the IWM card, a ProDOS block driver and a SmartPort interface
+for the 3.5 drives.
+
+The card has a slot page and, on the $C800 area, 1.75Kb of ROM and 256
+bytes of RAM from $CF00 to $CFFF. The slot page has the SmartPort signature
+and the entry points, they jump to the $C800 area with the slot * 16 in X
+and $Cn in Y.
+
+The driver talks to the drives with the IWM softswitches. As the IWM is
+not on the Apple IIe, the SEL line of the drives is latched from bit 7 of
+a write to the slot page. The routine on $CnE0 does that, it is called
+from the $C800 area with an indirect jump.
+
+To read a block the driver seeks the track, finds the address field of
+the sector and gets the 703 nibbles of the data field. There is no time
+to decode at 2 microseconds per bit, then the nibbles go to the ProDOS
+buffer, the last 191 to the card RAM, and they are decoded in place.
+The disks are read only, WRITE and FORMAT return a write protected error.
+
+The SmartPort entry has the units 1 and 2 for the drives with the STATUS,
+READBLOCK, WRITEBLOCK, FORMAT, CONTROL and INIT commands. It uses the
+ProDOS driver restoring the zero page after the call.
+
+The code on $C800 is assembled from doc/firmware/iwm.asm, with
+"go run . iwm.asm ../../cardIwmFirmware.go iwmFirmware" on that folder.
+*/
+
+const (
+	iwmFirmwareBoot        = 0xc800 // Boot code
+	iwmFirmwareEntry       = 0xc82b // ProDOS driver entry
+	iwmFirmwareSmartPort   = 0xcd1f // SmartPort entry
+	iwmFirmwareSelOffset   = 0xe0   // Routine on the slot page to latch the SEL line
+	iwmFirmwareRomAddress  = uint16(0xc800)
+	iwmFirmwareRamAddress  = uint16(0xcf00)
+	iwmFirmwareStatusBytes = 0x93 // Removable, 2 volumes, read and status
+)
+
+func buildIwmSlotRom(slot int) []uint8 {
+	data := make([]uint8, 256)
+	s := 0xc0 + uint8(slot)    // High byte of the ROM addresses
+	slot16 := uint8(slot << 4) // Slot in the high nibble, as ProDOS uses it
+
+	copy(data, []uint8{
+		// Preamble bytes to comply with the expectation in $Cn01, 3, 5 and 7
+		0xa2, 0x20, // LDX #$20
+		0xa0, 0x00, // LDY #$00
+		0xa2, 0x03, // LDX #$03
+		0xa2, 0x00, // LDX #$00 ; SmartPort
+		0xf0, 0x13, // BEQ boot ; $Cn1D
+	})
+
+	copy(data[0x0a:], []uint8{
+		// ProDOS driver entry point, it has to be in $Cn0a
+		0x18,       // CLC
+		0x90, 0x01, // BCC common
+		// SmartPort entry point, 3 bytes after the ProDOS entry
+		0x38, // SEC
+		// common: $Cn0E
+		0xa2, slot16, // LDX #$s0
+		0xa0, s, // LDY #$Cn
+		0x2c, 0xff, 0xcf, // BIT $CFFF ; Release the $C800 area of other cards
+		0xb0, 0x03, // BCS smartPort
+		0x4c, iwmFirmwareEntry & 0xff, iwmFirmwareEntry >> 8, // JMP entry
+		// smartPort: $Cn1A
+		0x4c, iwmFirmwareSmartPort & 0xff, iwmFirmwareSmartPort >> 8, // JMP spEntry
+	})
+
+	copy(data[0x1d:], []uint8{
+		// Boot code, read block 0 in $0800 and jump there
+		0xa2, slot16, // LDX #$s0
+		0xa0, s, // LDY #$Cn
+		0x2c, 0xff, 0xcf, // BIT $CFFF
+		0x4c, iwmFirmwareBoot & 0xff, iwmFirmwareBoot >> 8, // JMP boot
+	})
+
+	copy(data[iwmFirmwareSelOffset:], []uint8{
+		// Latch SEL from bit 7 of A
+		0x8d, 0x00, s, // STA $Cn00
+		0x60, // RTS
+	})
+
+	data[0xfc] = 0 // Blocks from STATUS
+	data[0xfd] = 0
+	data[0xfe] = iwmFirmwareStatusBytes
+	data[0xff] = 0x0a // Driver entry point
+
+	return data
+}
+
+// iwmFirmware is the code on $C800. It uses cmd, unit, bufLo, bufHi, blkLo
+// and blkHi on $42 to $47 as set by ProDOS, the 191 last nibbles of a
+// sector on $CF00 to $CFBF and the variables on the card RAM:
+//
+//	$CFC0 slot16      $CFCD addr (5)  $CFDA srcY          $CFE4 initDone
+//	$CFC1 selVec (2)  $CFD2 tries     $CFDB srcPage       $CFE5 dataSector
+//	$CFC3 drive       $CFD3 twos      $CFDC dstY          $CFE6 saveY
+//	$CFC4 track       $CFD4 va        $CFDD savedHi       $CFE7 chk0Next
+//	$CFC5 side        $CFD5 vb        $CFDE groups        $CFE8 dstPage
+//	$CFC6 sector      $CFD6 vc        $CFDF reg           $CFE9 spParams (7)
+//	$CFC7 spt         $CFD7 chk0      $CFE0 curTrack (2)  $CFF0 spCmd
+//	$CFC8 per         $CFD8 chk1      $CFE2 sides (2)     $CFF1 spCountLo
+//	$CFC9 count       $CFD9 chk2                          $CFF2 spCountHi
+//	$CFCA cntHi                                           $CFF3 spErr
+//	$CFCB remLo                                           $CFF4 saveZp (6)
+//	$CFCC remHi                                           $CFFA spRet (2)
+var iwmFirmware = []uint8{
+	// Boot: read block 0 of drive 1 on $0800. X is the slot * 16 and Y is $Cn
+	// boot: $C800
+	0x20, 0x38, 0xc8, // JSR init
+	0xa9, 0xff, // LDA #$FF
+	0x8d, 0xe0, 0xcf, // STA curTrack ; Unknown head positions
+	0x8d, 0xe1, 0xcf, // STA curTrack+1
+	0xa9, 0x01, // LDA #$01
+	0x85, 0x42, // STA cmd ; READ
+	0x86, 0x43, // STX unit ; Drive 1
+	0xa9, 0x00, // LDA #$00
+	0x85, 0x44, // STA bufLo
+	0x85, 0x46, // STA blkLo
+	0x85, 0x47, // STA blkHi
+	0xa9, 0x08, // LDA #$08
+	0x85, 0x45, // STA bufHi ; Buffer on $0800
+	0x20, 0x2e, 0xc8, // JSR driver
+	0xb0, 0x06, // BCS bootFail
+	0xae, 0xc0, 0xcf, // LDX slot16
+	0x4c, 0x01, 0x08, // JMP $0801
+	// bootFail: $C828
+	0x4c, 0x00, 0xe0, // JMP $E000 ; No disk, go to BASIC
+	// ProDOS driver entry. X is the slot * 16 and Y is $Cn
+	// entry: $C82B
+	0x20, 0x38, 0xc8, // JSR init
+	// driver: $C82E
+	0x08,             // PHP
+	0x78,             // SEI
+	0xd8,             // CLD
+	0x20, 0x60, 0xc8, // JSR command
+	0x28,       // PLP
+	0xc9, 0x01, // CMP #$01 ; Carry set on errors
+	0x60, // RTS
+	// init: $C838
+	0x8e, 0xc0, 0xcf, // STX slot16
+	0x8c, 0xc2, 0xcf, // STY selVec+1
+	0xa9, 0xe0, // LDA #$E0
+	0x8d, 0xc1, 0xcf, // STA selVec ; The SEL latch routine is on $CnE0
+	0xad, 0xe4, 0xcf, // LDA initDone
+	0xc9, 0xa5, // CMP #$A5
+	0xf0, 0x15, // BEQ initEnd
+	0xa9, 0xff, // LDA #$FF
+	0x8d, 0xe0, 0xcf, // STA curTrack
+	0x8d, 0xe1, 0xcf, // STA curTrack+1
+	0xa9, 0x00, // LDA #$00
+	0x8d, 0xe2, 0xcf, // STA sides
+	0x8d, 0xe3, 0xcf, // STA sides+1
+	0xa9, 0xa5, // LDA #$A5
+	0x8d, 0xe4, 0xcf, // STA initDone
+	// initEnd: $C85F
+	0x60, // RTS
+	// command: $C860
+	0xa5, 0x43, // LDA unit
+	0x0a,       // ASL A ; Drive on bit 7
+	0xa9, 0x00, // LDA #$00
+	0x2a,             // ROL A
+	0x8d, 0xc3, 0xcf, // STA drive
+	0xa5, 0x42, // LDA cmd
+	0xf0, 0x0e, // BEQ doStatus
+	0xc9, 0x01, // CMP #$01
+	0xf0, 0x38, // BEQ doRead
+	0xc9, 0x04, // CMP #$04
+	0xb0, 0x03, // BCS badCmd
+	0xa9, 0x2b, // LDA #$2B ; Write protected for WRITE and FORMAT
+	0x60, // RTS
+	// badCmd: $C878
+	0xa9, 0x01, // LDA #$01 ; Bad command
+	0x60, // RTS
+	// doStatus: $C87B
+	0x20, 0xf0, 0xc8, // JSR start
+	0xb0, 0x68, // BCS fail
+	0x20, 0xb6, 0xc9, // JSR getSides
+	0xb0, 0x61, // BCS ioFail
+	0xac, 0xc3, 0xcf, // LDY drive
+	0xb9, 0xe2, 0xcf, // LDA sides,Y
+	0xa2, 0x20, // LDX #$20 ; 800 blocks
+	0xa0, 0x03, // LDY #$03
+	0xc9, 0x02, // CMP #$02
+	0xd0, 0x04, // BNE status1
+	0xa2, 0x40, // LDX #$40 ; 1600 blocks
+	0xa0, 0x06, // LDY #$06
+	// status1: $C897
+	0x8e, 0xcb, 0xcf, // STX remLo
+	0x8c, 0xcc, 0xcf, // STY remHi
+	0xa9, 0x00, // LDA #$00
+	0x20, 0x5f, 0xc9, // JSR stop
+	0xae, 0xcb, 0xcf, // LDX remLo
+	0xac, 0xcc, 0xcf, // LDY remHi
+	0x60, // RTS
+	// doRead: $C8A9
+	0x20, 0xf0, 0xc8, // JSR start
+	0xb0, 0x3a, // BCS fail
+	0x20, 0xb6, 0xc9, // JSR getSides
+	0xb0, 0x33, // BCS ioFail
+	0x20, 0xe7, 0xc9, // JSR mapBlock
+	0xb0, 0x33, // BCS badBlock
+	0x20, 0x5c, 0xca, // JSR seek
+	0xb0, 0x29, // BCS ioFail
+	0xa9, 0x04, // LDA #$04
+	0x8d, 0xd2, 0xcf, // STA tries
+	// readTry: $C8C2
+	0xad, 0xc5, 0xcf, // LDA side
+	0x0a,       // ASL A
+	0x0a,       // ASL A
+	0x0a,       // ASL A
+	0x09, 0x04, // ORA #$04 ; Read data on the head for the side
+	0x20, 0x83, 0xc9, // JSR setRegister
+	0x20, 0x0e, 0xcb, // JSR findSector
+	0xb0, 0x0f, // BCS readRetry
+	0x20, 0x5c, 0xcb, // JSR readData
+	0xb0, 0x0a, // BCS readRetry
+	0x20, 0xc8, 0xcb, // JSR decode
+	0xb0, 0x05, // BCS readRetry
+	0xa9, 0x00, // LDA #$00
+	0x4c, 0x5f, 0xc9, // JMP stop
+	// readRetry: $C8E1
+	0xce, 0xd2, 0xcf, // DEC tries
+	0xd0, 0xdc, // BNE readTry
+	// ioFail: $C8E6
+	0xa9, 0x27, // LDA #$27 ; I/O error
+	// fail: $C8E8
+	0x4c, 0x5f, 0xc9, // JMP stop
+	// badBlock: $C8EB
+	0xa9, 0x2d, // LDA #$2D ; Invalid block
+	0x4c, 0x5f, 0xc9, // JMP stop
+	// Set the mode, select the drive and turn the motor on. Error on A with carry set
+	// start: $C8F0
+	0xae, 0xc0, 0xcf, // LDX slot16
+	0xbd, 0x8d, 0xc0, // LDA $C08D,X ; Q6H
+	0xbd, 0x8e, 0xc0, // LDA $C08E,X ; Q7L, status register
+	0x29, 0x1f, // AND #$1F
+	0xc9, 0x0b, // CMP #$0B
+	0xf0, 0x08, // BEQ startMode
+	0xa9, 0x0b, // LDA #$0B ; Fast, asynchronous and latched
+	0x9d, 0x8f, 0xc0, // STA $C08F,X ; Q7H, mode register with the motor off
+	0xbd, 0x8e, 0xc0, // LDA $C08E,X ; Q7L
+	// startMode: $C907
+	0xbd, 0x8c, 0xc0, // LDA $C08C,X ; Q6L
+	0xbd, 0x89, 0xc0, // LDA $C089,X ; Motor line on
+	0xad, 0xc3, 0xcf, // LDA drive
+	0xd0, 0x06, // BNE startDrive2
+	0xbd, 0x8a, 0xc0, // LDA $C08A,X ; Drive 1
+	0x4c, 0x1b, 0xc9, // JMP startDisk
+	// startDrive2: $C918
+	0xbd, 0x8b, 0xc0, // LDA $C08B,X ; Drive 2
+	// startDisk: $C91B
+	0xa9, 0x08, // LDA #$08 ; No disk
+	0x20, 0x6a, 0xc9, // JSR sense
+	0x10, 0x07, // BPL startSwitched
+	0xa9, 0x2f, // LDA #$2F ; Device offline
+	0x20, 0x5f, 0xc9, // JSR stop
+	0x38, // SEC
+	0x60, // RTS
+	// startSwitched: $C929
+	0xa9, 0x03, // LDA #$03 ; Disk switched
+	0x20, 0x6a, 0xc9, // JSR sense
+	0x10, 0x0d, // BPL startMotor
+	0xa9, 0x0c, // LDA #$0C ; Reset the switched flag
+	0x20, 0x79, 0xc9, // JSR control
+	0xac, 0xc3, 0xcf, // LDY drive
+	0xa9, 0x00, // LDA #$00
+	0x99, 0xe2, 0xcf, // STA sides,Y ; Sides unknown
+	// startMotor: $C93D
+	0xa9, 0x02, // LDA #$02 ; Motor on
+	0x20, 0x79, 0xc9, // JSR control
+	0xa0, 0x00, // LDY #$00
+	0x8c, 0xc9, 0xcf, // STY count
+	// startReady: $C947
+	0xa9, 0x0e, // LDA #$0E ; Not ready
+	0x20, 0x6a, 0xc9, // JSR sense
+	0x10, 0x0f, // BPL startEnd
+	0x88,       // DEY
+	0xd0, 0xf6, // BNE startReady
+	0xce, 0xc9, 0xcf, // DEC count
+	0xd0, 0xf1, // BNE startReady
+	0xa9, 0x2f, // LDA #$2F
+	0x20, 0x5f, 0xc9, // JSR stop
+	0x38, // SEC
+	0x60, // RTS
+	// startEnd: $C95D
+	0x18, // CLC
+	0x60, // RTS
+	// Turn the motor off keeping A
+	// stop: $C95F
+	0x48,       // PHA
+	0xa9, 0x06, // LDA #$06 ; Motor off
+	0x20, 0x79, 0xc9, // JSR control
+	0xdd, 0x88, 0xc0, // CMP $C088,X ; Motor line off
+	0x68, // PLA
+	0x60, // RTS
+	// Read the sense line of the register in A. Returns it on N
+	// sense: $C96A
+	0x20, 0x83, 0xc9, // JSR setRegister
+	0xbd, 0x8d, 0xc0, // LDA $C08D,X ; Q6H
+	0xbd, 0x8e, 0xc0, // LDA $C08E,X ; Q7L, status register
+	0xdd, 0x8c, 0xc0, // CMP $C08C,X ; Q6L
+	0x09, 0x00, // ORA #$00
+	0x60, // RTS
+	// Send the command in A, the value is on CA2
+	// control: $C979
+	0x20, 0x83, 0xc9, // JSR setRegister
+	0xdd, 0x87, 0xc0, // CMP $C087,X ; LSTRB on
+	0xdd, 0x86, 0xc0, // CMP $C086,X ; LSTRB off
+	0x60, // RTS
+	// Set CA0, CA1, CA2 and SEL from bits 0 to 3 of A. Keeps Y, X is slot * 16
+	// setRegister: $C983
+	0xae, 0xc0, 0xcf, // LDX slot16
+	0x8d, 0xdf, 0xcf, // STA reg
+	0x4e, 0xdf, 0xcf, // LSR reg
+	0x90, 0x01, // BCC setCA0
+	0xe8, // INX
+	// setCA0: $C98F
+	0xdd, 0x80, 0xc0, // CMP $C080,X ; CA0
+	0xae, 0xc0, 0xcf, // LDX slot16
+	0x4e, 0xdf, 0xcf, // LSR reg
+	0x90, 0x01, // BCC setCA1
+	0xe8, // INX
+	// setCA1: $C99B
+	0xdd, 0x82, 0xc0, // CMP $C082,X ; CA1
+	0xae, 0xc0, 0xcf, // LDX slot16
+	0x4e, 0xdf, 0xcf, // LSR reg
+	0x90, 0x01, // BCC setCA2
+	0xe8, // INX
+	// setCA2: $C9A7
+	0xdd, 0x84, 0xc0, // CMP $C084,X ; CA2
+	0xae, 0xc0, 0xcf, // LDX slot16
+	0xa9, 0x00, // LDA #$00
+	0x4e, 0xdf, 0xcf, // LSR reg
+	0x6a,             // ROR A ; SEL on bit 7
+	0x6c, 0xc1, 0xcf, // JMP (selVec)
+	// Get the sides from the format of an address field
+	// getSides: $C9B6
+	0xac, 0xc3, 0xcf, // LDY drive
+	0xb9, 0xe2, 0xcf, // LDA sides,Y
+	0xd0, 0x27, // BNE getSidesEnd
+	0xa9, 0x04, // LDA #$04 ; Read data on the lower head
+	0x20, 0x83, 0xc9, // JSR setRegister
+	0x20, 0xb8, 0xca, // JSR readAddress
+	0xb0, 0x1e, // BCS getSidesFail
+	0xac, 0xd0, 0xcf, // LDY addr+3
+	0xb9, 0xf1, 0xcd, // LDA untab-$80,Y ; Format, double sided on bit 5
+	0xc9, 0x40, // CMP #$40
+	0xb0, 0x14, // BCS getSidesFail
+	0xac, 0xc3, 0xcf, // LDY drive
+	0x29, 0x20, // AND #$20
+	0xf0, 0x07, // BEQ getSidesOne
+	0xa9, 0x02, // LDA #$02
+	0x99, 0xe2, 0xcf, // STA sides,Y
+	0x18, // CLC
+	0x60, // RTS
+	// getSidesOne: $C9E0
+	0xa9, 0x01, // LDA #$01
+	0x99, 0xe2, 0xcf, // STA sides,Y
+	// getSidesEnd: $C9E5
+	0x18, // CLC
+	// getSidesFail: $C9E6
+	0x60, // RTS
+	// Get track, side and sector of the block
+	// mapBlock: $C9E7
+	0xa5, 0x46, // LDA blkLo
+	0x8d, 0xcb, 0xcf, // STA remLo
+	0xa5, 0x47, // LDA blkHi
+	0x8d, 0xcc, 0xcf, // STA remHi
+	0xa9, 0x00, // LDA #$00
+	0x8d, 0xc4, 0xcf, // STA track
+	// mapTrack: $C9F6
+	0xad, 0xc4, 0xcf, // LDA track
+	0x4a,             // LSR A
+	0x4a,             // LSR A
+	0x4a,             // LSR A
+	0x4a,             // LSR A
+	0x8d, 0xc7, 0xcf, // STA spt
+	0xa9, 0x0c, // LDA #12
+	0x38,             // SEC
+	0xed, 0xc7, 0xcf, // SBC spt
+	0x8d, 0xc7, 0xcf, // STA spt ; 12 - zone sectors per track
+	0xac, 0xc3, 0xcf, // LDY drive
+	0xb9, 0xe2, 0xcf, // LDA sides,Y
+	0xc9, 0x02, // CMP #$02
+	0xad, 0xc7, 0xcf, // LDA spt
+	0x90, 0x01, // BCC mapSides
+	0x0a, // ASL A
+	// mapSides: $CA17
+	0x8d, 0xc8, 0xcf, // STA per ; Blocks per track
+	0xad, 0xcc, 0xcf, // LDA remHi
+	0xd0, 0x08, // BNE mapNext
+	0xad, 0xcb, 0xcf, // LDA remLo
+	0xcd, 0xc8, 0xcf, // CMP per
+	0x90, 0x1d, // BCC mapFound
+	// mapNext: $CA27
+	0xad, 0xcb, 0xcf, // LDA remLo
+	0x38,             // SEC
+	0xed, 0xc8, 0xcf, // SBC per
+	0x8d, 0xcb, 0xcf, // STA remLo
+	0xad, 0xcc, 0xcf, // LDA remHi
+	0xe9, 0x00, // SBC #$00
+	0x8d, 0xcc, 0xcf, // STA remHi
+	0xee, 0xc4, 0xcf, // INC track
+	0xad, 0xc4, 0xcf, // LDA track
+	0xc9, 0x50, // CMP #80
+	0x90, 0xb3, // BCC mapTrack
+	0x60, // RTS
+	// mapFound: $CA44
+	0xa9, 0x00, // LDA #$00
+	0x8d, 0xc5, 0xcf, // STA side
+	0xad, 0xcb, 0xcf, // LDA remLo
+	0xcd, 0xc7, 0xcf, // CMP spt
+	0x90, 0x06, // BCC mapSector
+	0xed, 0xc7, 0xcf, // SBC spt
+	0xee, 0xc5, 0xcf, // INC side
+	// mapSector: $CA57
+	0x8d, 0xc6, 0xcf, // STA sector
+	0x18, // CLC
+	0x60, // RTS
+	// Move the head to the track
+	// seek: $CA5C
+	0xac, 0xc3, 0xcf, // LDY drive
+	0xb9, 0xe0, 0xcf, // LDA curTrack,Y
+	0xc9, 0xff, // CMP #$FF
+	0xd0, 0x22, // BNE seekKnown
+	0xa9, 0x04, // LDA #$04 ; Direction to track 0
+	0x20, 0x79, 0xc9, // JSR control
+	0xa9, 0x5a, // LDA #90
+	0x8d, 0xc9, 0xcf, // STA count
+	// seekRecal: $CA70
+	0xa9, 0x0a, // LDA #$0A ; Not on track 0
+	0x20, 0x6a, 0xc9, // JSR sense
+	0x10, 0x0c, // BPL seekTrack0
+	0xa9, 0x01, // LDA #$01 ; Step
+	0x20, 0x79, 0xc9, // JSR control
+	0xce, 0xc9, 0xcf, // DEC count
+	0xd0, 0xef, // BNE seekRecal
+	0x38, // SEC
+	0x60, // RTS
+	// seekTrack0: $CA83
+	0xa9, 0x00, // LDA #$00
+	0x99, 0xe0, 0xcf, // STA curTrack,Y
+	// seekKnown: $CA88
+	0xad, 0xc4, 0xcf, // LDA track
+	0x38,             // SEC
+	0xf9, 0xe0, 0xcf, // SBC curTrack,Y
+	0xf0, 0x25, // BEQ seekEnd
+	0xb0, 0x0b, // BCS seekIn
+	0x49, 0xff, // EOR #$FF
+	0x69, 0x01, // ADC #$01
+	0x8d, 0xc9, 0xcf, // STA count
+	0xa9, 0x04, // LDA #$04 ; Direction to track 0
+	0xd0, 0x05, // BNE seekDirection
+	// seekIn: $CA9E
+	0x8d, 0xc9, 0xcf, // STA count
+	0xa9, 0x00, // LDA #$00 ; Direction to higher tracks
+	// seekDirection: $CAA3
+	0x20, 0x79, 0xc9, // JSR control
+	// seekStep: $CAA6
+	0xa9, 0x01, // LDA #$01 ; Step
+	0x20, 0x79, 0xc9, // JSR control
+	0xce, 0xc9, 0xcf, // DEC count
+	0xd0, 0xf6, // BNE seekStep
+	0xad, 0xc4, 0xcf, // LDA track
+	0x99, 0xe0, 0xcf, // STA curTrack,Y
+	// seekEnd: $CAB6
+	0x18, // CLC
+	0x60, // RTS
+	// Read the nibbles of an address field, with a timeout of about 400ms
+	// readAddress: $CAB8
+	0xa9, 0x80, // LDA #$80
+	0x8d, 0xca, 0xcf, // STA cntHi
+	0xa0, 0x00, // LDY #$00
+	// addrNext: $CABF
+	0x88,       // DEY
+	0xd0, 0x05, // BNE addrPoll
+	0xce, 0xca, 0xcf, // DEC cntHi
+	0xf0, 0x45, // BEQ addrTimeout
+	// addrPoll: $CAC7
+	0xbd, 0x8c, 0xc0, // LDA $C08C,X
+	0x10, 0xf3, // BPL addrNext
+	// addrD5: $CACC
+	0xc9, 0xd5, // CMP #$D5
+	0xd0, 0xef, // BNE addrNext
+	// addrAA: $CAD0
+	0xbd, 0x8c, 0xc0, // LDA $C08C,X
+	0x10, 0xfb, // BPL addrAA
+	0xc9, 0xaa, // CMP #$AA
+	0xd0, 0xf3, // BNE addrD5
+	// addr96: $CAD9
+	0xbd, 0x8c, 0xc0, // LDA $C08C,X
+	0x10, 0xfb, // BPL addr96
+	0xc9, 0x96, // CMP #$96
+	0xd0, 0xea, // BNE addrD5
+	// addr0: $CAE2
+	0xbd, 0x8c, 0xc0, // LDA $C08C,X
+	0x10, 0xfb, // BPL addr0
+	0x8d, 0xcd, 0xcf, // STA addr ; Track
+	// addr1: $CAEA
+	0xbd, 0x8c, 0xc0, // LDA $C08C,X
+	0x10, 0xfb, // BPL addr1
+	0x8d, 0xce, 0xcf, // STA addr+1 ; Sector
+	// addr2: $CAF2
+	0xbd, 0x8c, 0xc0, // LDA $C08C,X
+	0x10, 0xfb, // BPL addr2
+	0x8d, 0xcf, 0xcf, // STA addr+2 ; Side
+	// addr3: $CAFA
+	0xbd, 0x8c, 0xc0, // LDA $C08C,X
+	0x10, 0xfb, // BPL addr3
+	0x8d, 0xd0, 0xcf, // STA addr+3 ; Format
+	// addr4: $CB02
+	0xbd, 0x8c, 0xc0, // LDA $C08C,X
+	0x10, 0xfb, // BPL addr4
+	0x8d, 0xd1, 0xcf, // STA addr+4 ; Checksum
+	0x18, // CLC
+	0x60, // RTS
+	// addrTimeout: $CB0C
+	0x38, // SEC
+	0x60, // RTS
+	// Find the address field of the sector. The nibbles are compared quickly
+	// to be in time for the data field
+	// findSector: $CB0E
+	0xad, 0xc4, 0xcf, // LDA track
+	0x29, 0x3f, // AND #$3F
+	0x8d, 0xd4, 0xcf, // STA va ; Track low bits
+	0xad, 0xc4, 0xcf, // LDA track
+	0xc9, 0x40, // CMP #$40
+	0xa9, 0x00, // LDA #$00
+	0x2a,             // ROL A ; Track bit 6
+	0xac, 0xc5, 0xcf, // LDY side
+	0xf0, 0x02, // BEQ findSide
+	0x09, 0x20, // ORA #$20
+	// findSide: $CB25
+	0x8d, 0xd5, 0xcf, // STA vb ; Side byte
+	0xa9, 0x20, // LDA #$20
+	0x8d, 0xc9, 0xcf, // STA count
+	// findNext: $CB2D
+	0x20, 0xb8, 0xca, // JSR readAddress
+	0xb0, 0x29, // BCS findFail
+	0xac, 0xce, 0xcf, // LDY addr+1
+	0xb9, 0xf1, 0xcd, // LDA untab-$80,Y
+	0xcd, 0xc6, 0xcf, // CMP sector
+	0xd0, 0x18, // BNE findMiss
+	0xac, 0xcd, 0xcf, // LDY addr
+	0xb9, 0xf1, 0xcd, // LDA untab-$80,Y
+	0xcd, 0xd4, 0xcf, // CMP va
+	0xd0, 0x0d, // BNE findMiss
+	0xac, 0xcf, 0xcf, // LDY addr+2
+	0xb9, 0xf1, 0xcd, // LDA untab-$80,Y
+	0xcd, 0xd5, 0xcf, // CMP vb
+	0xd0, 0x02, // BNE findMiss
+	0x18, // CLC
+	0x60, // RTS
+	// findMiss: $CB55
+	0xce, 0xc9, 0xcf, // DEC count
+	0xd0, 0xd3, // BNE findNext
+	0x38, // SEC
+	// findFail: $CB5B
+	0x60, // RTS
+	// Read the nibbles of the data field. The first 512 go to the ProDOS
+	// buffer and the rest to the card RAM
+	// readData: $CB5C
+	0xa0, 0x40, // LDY #$40
+	// dataNext: $CB5E
+	0x88,       // DEY
+	0xf0, 0x65, // BEQ dataFail
+	// dataPoll: $CB61
+	0xbd, 0x8c, 0xc0, // LDA $C08C,X
+	0x10, 0xfb, // BPL dataPoll
+	// dataD5: $CB66
+	0xc9, 0xd5, // CMP #$D5
+	0xd0, 0xf4, // BNE dataNext
+	// dataAA: $CB6A
+	0xbd, 0x8c, 0xc0, // LDA $C08C,X
+	0x10, 0xfb, // BPL dataAA
+	0xc9, 0xaa, // CMP #$AA
+	0xd0, 0xf3, // BNE dataD5
+	// dataAD: $CB73
+	0xbd, 0x8c, 0xc0, // LDA $C08C,X
+	0x10, 0xfb, // BPL dataAD
+	0xc9, 0xad, // CMP #$AD
+	0xd0, 0xea, // BNE dataD5
+	// dataSec: $CB7C
+	0xbd, 0x8c, 0xc0, // LDA $C08C,X
+	0x10, 0xfb, // BPL dataSec
+	0x8d, 0xe5, 0xcf, // STA dataSector
+	0xa0, 0x00, // LDY #$00
+	// data0: $CB86
+	0xbd, 0x8c, 0xc0, // LDA $C08C,X
+	0x10, 0xfb, // BPL data0
+	0x91, 0x44, // STA (bufLo),Y
+	0xc8, // INY
+	// data0b: $CB8E
+	0xbd, 0x8c, 0xc0, // LDA $C08C,X
+	0x10, 0xfb, // BPL data0b
+	0x91, 0x44, // STA (bufLo),Y
+	0xc8,       // INY
+	0xd0, 0xee, // BNE data0
+	0xe6, 0x45, // INC bufHi
+	// data1: $CB9A
+	0xbd, 0x8c, 0xc0, // LDA $C08C,X
+	0x10, 0xfb, // BPL data1
+	0x91, 0x44, // STA (bufLo),Y
+	0xc8, // INY
+	// data1b: $CBA2
+	0xbd, 0x8c, 0xc0, // LDA $C08C,X
+	0x10, 0xfb, // BPL data1b
+	0x91, 0x44, // STA (bufLo),Y
+	0xc8,       // INY
+	0xd0, 0xee, // BNE data1
+	0xc6, 0x45, // DEC bufHi
+	// data2: $CBAE
+	0xbd, 0x8c, 0xc0, // LDA $C08C,X
+	0x10, 0xfb, // BPL data2
+	0x99, 0x00, 0xcf, // STA buf,Y
+	0xc8, // INY
+	// data2b: $CBB7
+	0xbd, 0x8c, 0xc0, // LDA $C08C,X
+	0x10, 0xfb, // BPL data2b
+	0x99, 0x00, 0xcf, // STA buf,Y
+	0xc8,       // INY
+	0xc0, 0xc0, // CPY #$C0
+	0xd0, 0xea, // BNE data2
+	0x18, // CLC
+	0x60, // RTS
+	// dataFail: $CBC6
+	0x38, // SEC
+	0x60, // RTS
+	// Decode the nibbles in place, the tags are skipped. The output is always
+	// behind the nibbles still to decode
+	// decode: $CBC8
+	0xad, 0xe5, 0xcf, // LDA dataSector
+	0x20, 0x12, 0xcd, // JSR untranslate
+	0xb0, 0x05, // BCS decodeBadSector
+	0xcd, 0xc6, 0xcf, // CMP sector
+	0xf0, 0x02, // BEQ decodeStart
+	// decodeBadSector: $CBD5
+	0x38, // SEC
+	0x60, // RTS
+	// decodeStart: $CBD7
+	0xa9, 0x00, // LDA #$00
+	0x8d, 0xda, 0xcf, // STA srcY
+	0x8d, 0xdb, 0xcf, // STA srcPage
+	0x8d, 0xdc, 0xcf, // STA dstY
+	0x8d, 0xe8, 0xcf, // STA dstPage
+	0x8d, 0xde, 0xcf, // STA groups
+	0x8d, 0xd7, 0xcf, // STA chk0
+	0x8d, 0xd8, 0xcf, // STA chk1
+	0x8d, 0xd9, 0xcf, // STA chk2
+	0xa5, 0x45, // LDA bufHi
+	0x8d, 0xdd, 0xcf, // STA savedHi
+	// decodeGroup: $CBF6
+	0x20, 0x7f, 0xcc, // JSR combine
+	0xb0, 0x7e, // BCS decodeEnd
+	0xad, 0xd7, 0xcf, // LDA chk0
+	0xc9, 0x80, // CMP #$80
+	0x2a,             // ROL A
+	0x8d, 0xd7, 0xcf, // STA chk0
+	0xad, 0xd4, 0xcf, // LDA va
+	0x4d, 0xd7, 0xcf, // EOR chk0
+	0x8d, 0xd4, 0xcf, // STA va
+	0x6d, 0xd9, 0xcf, // ADC chk2
+	0x8d, 0xd9, 0xcf, // STA chk2
+	0xad, 0xd5, 0xcf, // LDA vb
+	0x4d, 0xd9, 0xcf, // EOR chk2
+	0x8d, 0xd5, 0xcf, // STA vb
+	0x6d, 0xd8, 0xcf, // ADC chk1
+	0x8d, 0xd8, 0xcf, // STA chk1
+	0xad, 0xd6, 0xcf, // LDA vc
+	0x4d, 0xd8, 0xcf, // EOR chk1
+	0x8d, 0xd6, 0xcf, // STA vc
+	0x6d, 0xd7, 0xcf, // ADC chk0
+	0x8d, 0xe7, 0xcf, // STA chk0Next
+	0xad, 0xd4, 0xcf, // LDA va
+	0x20, 0xd2, 0xcc, // JSR put
+	0xad, 0xd5, 0xcf, // LDA vb
+	0x20, 0xd2, 0xcc, // JSR put
+	0xad, 0xde, 0xcf, // LDA groups
+	0xc9, 0xae, // CMP #174
+	0xf0, 0x11, // BEQ decodeChecksum ; The last group has only two bytes
+	0xad, 0xe7, 0xcf, // LDA chk0Next
+	0x8d, 0xd7, 0xcf, // STA chk0
+	0xad, 0xd6, 0xcf, // LDA vc
+	0x20, 0xd2, 0xcc, // JSR put
+	0xee, 0xde, 0xcf, // INC groups
+	0xd0, 0xa1, // BNE decodeGroup
+	// decodeChecksum: $CC55
+	0xee, 0xde, 0xcf, // INC groups
+	0x20, 0x7f, 0xcc, // JSR combine
+	0xb0, 0x1c, // BCS decodeEnd
+	0xad, 0xd4, 0xcf, // LDA va
+	0xcd, 0xd9, 0xcf, // CMP chk2
+	0xd0, 0x13, // BNE decodeFail
+	0xad, 0xd5, 0xcf, // LDA vb
+	0xcd, 0xd8, 0xcf, // CMP chk1
+	0xd0, 0x0b, // BNE decodeFail
+	0xad, 0xd6, 0xcf, // LDA vc
+	0xcd, 0xd7, 0xcf, // CMP chk0
+	0xd0, 0x03, // BNE decodeFail
+	0x18,       // CLC
+	0x90, 0x01, // BCC decodeEnd
+	// decodeFail: $CC78
+	0x38, // SEC
+	// decodeEnd: $CC79
+	0xad, 0xdd, 0xcf, // LDA savedHi
+	0x85, 0x45, // STA bufHi
+	0x60, // RTS
+	// Get the values of a group and combine the two bits of each byte
+	// combine: $CC7F
+	0x20, 0xf2, 0xcc, // JSR getValue
+	0xb0, 0x4d, // BCS combineEnd
+	0x8d, 0xd3, 0xcf, // STA twos
+	0x20, 0xf2, 0xcc, // JSR getValue
+	0xb0, 0x45, // BCS combineEnd
+	0x8d, 0xd4, 0xcf, // STA va
+	0x20, 0xf2, 0xcc, // JSR getValue
+	0xb0, 0x3d, // BCS combineEnd
+	0x8d, 0xd5, 0xcf, // STA vb
+	0xad, 0xde, 0xcf, // LDA groups
+	0xc9, 0xae, // CMP #174
+	0xf0, 0x08, // BEQ combineTwos
+	0x20, 0xf2, 0xcc, // JSR getValue
+	0xb0, 0x2e, // BCS combineEnd
+	0x8d, 0xd6, 0xcf, // STA vc
+	// combineTwos: $CCA6
+	0xad, 0xd3, 0xcf, // LDA twos
+	0x0a,       // ASL A
+	0x0a,       // ASL A
+	0x29, 0xc0, // AND #$C0
+	0x0d, 0xd4, 0xcf, // ORA va
+	0x8d, 0xd4, 0xcf, // STA va
+	0xad, 0xd3, 0xcf, // LDA twos
+	0x0a,       // ASL A
+	0x0a,       // ASL A
+	0x0a,       // ASL A
+	0x0a,       // ASL A
+	0x29, 0xc0, // AND #$C0
+	0x0d, 0xd5, 0xcf, // ORA vb
+	0x8d, 0xd5, 0xcf, // STA vb
+	0xad, 0xd3, 0xcf, // LDA twos
+	0x4a,       // LSR A
+	0x6a,       // ROR A
+	0x6a,       // ROR A
+	0x29, 0xc0, // AND #$C0
+	0x0d, 0xd6, 0xcf, // ORA vc
+	0x8d, 0xd6, 0xcf, // STA vc
+	0x18, // CLC
+	// combineEnd: $CCD1
+	0x60, // RTS
+	// Store A on the ProDOS buffer, skipping the 12 bytes of tags
+	// put: $CCD2
+	0xac, 0xde, 0xcf, // LDY groups
+	0xc0, 0x04, // CPY #$04
+	0x90, 0x18, // BCC putEnd
+	0x48,             // PHA
+	0xad, 0xdd, 0xcf, // LDA savedHi
+	0x18,             // CLC
+	0x6d, 0xe8, 0xcf, // ADC dstPage
+	0x85, 0x45, // STA bufHi
+	0x68,             // PLA
+	0xac, 0xdc, 0xcf, // LDY dstY
+	0x91, 0x44, // STA (bufLo),Y
+	0xee, 0xdc, 0xcf, // INC dstY
+	0xd0, 0x03, // BNE putEnd
+	0xee, 0xe8, 0xcf, // INC dstPage
+	// putEnd: $CCF1
+	0x60, // RTS
+	// Get the next 6 bits value from the nibbles. Carry set if invalid
+	// getValue: $CCF2
+	0xac, 0xda, 0xcf, // LDY srcY
+	0xad, 0xdb, 0xcf, // LDA srcPage
+	0xc9, 0x02, // CMP #$02
+	0xf0, 0x0b, // BEQ getValueRam
+	0x18,             // CLC
+	0x6d, 0xdd, 0xcf, // ADC savedHi
+	0x85, 0x45, // STA bufHi
+	0xb1, 0x44, // LDA (bufLo),Y
+	0x4c, 0x0a, 0xcd, // JMP getValueNext
+	// getValueRam: $CD07
+	0xb9, 0x00, 0xcf, // LDA buf,Y
+	// getValueNext: $CD0A
+	0xee, 0xda, 0xcf, // INC srcY
+	0xd0, 0x03, // BNE untranslate
+	0xee, 0xdb, 0xcf, // INC srcPage
+	// Translate a nibble to a 6 bits value, carry set if invalid. Keeps X and Y
+	// untranslate: $CD12
+	0x8c, 0xe6, 0xcf, // STY saveY
+	0xa8,             // TAY
+	0xb9, 0xf1, 0xcd, // LDA untab-$80,Y
+	0xac, 0xe6, 0xcf, // LDY saveY
+	0xc9, 0x40, // CMP #$40
+	0x60, // RTS
+	// SmartPort entry. X is the slot * 16 and Y is $Cn. The command and the
+	// address of the parameter list follow the JSR of the caller
+	// spEntry: $CD1F
+	0x20, 0x38, 0xc8, // JSR init
+	0xa0, 0x05, // LDY #$05
+	// spSave: $CD24
+	0xb9, 0x42, 0x00, // LDA cmd,Y
+	0x99, 0xf4, 0xcf, // STA saveZp,Y ; The ProDOS zero page is used by the driver
+	0x88,       // DEY
+	0x10, 0xf7, // BPL spSave
+	0x68,       // PLA
+	0x85, 0x44, // STA bufLo
+	0x68,       // PLA
+	0x85, 0x45, // STA bufHi ; Return address
+	0x08,       // PHP
+	0x78,       // SEI
+	0xd8,       // CLD
+	0xa5, 0x44, // LDA bufLo
+	0x18,       // CLC
+	0x69, 0x03, // ADC #$03
+	0x8d, 0xfa, 0xcf, // STA spRet ; Skip the command and the parameter list address
+	0xa5, 0x45, // LDA bufHi
+	0x69, 0x00, // ADC #$00
+	0x8d, 0xfb, 0xcf, // STA spRet+1
+	0xa0, 0x01, // LDY #$01
+	0xb1, 0x44, // LDA (bufLo),Y
+	0x8d, 0xf0, 0xcf, // STA spCmd
+	0xc8,       // INY
+	0xb1, 0x44, // LDA (bufLo),Y
+	0x48,       // PHA
+	0xc8,       // INY
+	0xb1, 0x44, // LDA (bufLo),Y
+	0x85, 0x45, // STA bufHi
+	0x68,       // PLA
+	0x85, 0x44, // STA bufLo
+	0xa0, 0x06, // LDY #$06
+	// spParam: $CD5A
+	0xb1, 0x44, // LDA (bufLo),Y
+	0x99, 0xe9, 0xcf, // STA spParams,Y ; Count, unit, buffer and block or status code
+	0x88,       // DEY
+	0x10, 0xf8, // BPL spParam
+	0xa9, 0x00, // LDA #$00
+	0x8d, 0xf1, 0xcf, // STA spCountLo
+	0x8d, 0xf2, 0xcf, // STA spCountHi
+	0x20, 0x90, 0xcd, // JSR spCommand
+	0x8d, 0xf3, 0xcf, // STA spErr
+	0xa0, 0x05, // LDY #$05
+	// spRestore: $CD72
+	0xb9, 0xf4, 0xcf, // LDA saveZp,Y
+	0x99, 0x42, 0x00, // STA cmd,Y
+	0x88,       // DEY
+	0x10, 0xf7, // BPL spRestore
+	0x28,             // PLP
+	0xad, 0xfb, 0xcf, // LDA spRet+1
+	0x48,             // PHA
+	0xad, 0xfa, 0xcf, // LDA spRet
+	0x48,             // PHA
+	0xae, 0xf1, 0xcf, // LDX spCountLo ; Bytes transferred
+	0xac, 0xf2, 0xcf, // LDY spCountHi
+	0xad, 0xf3, 0xcf, // LDA spErr
+	0xc9, 0x01, // CMP #$01 ; Carry set on errors
+	0x60, // RTS
+	// Run the SmartPort command with the error on A. The units 1 and 2 are the
+	// drives, the unit 0 is the host
+	// spCommand: $CD90
+	0xad, 0xeb, 0xcf, // LDA spParams+2
+	0x85, 0x44, // STA bufLo
+	0xad, 0xec, 0xcf, // LDA spParams+3
+	0x85, 0x45, // STA bufHi
+	0xad, 0xf0, 0xcf, // LDA spCmd
+	0xc9, 0x0a, // CMP #$0A
+	0xb0, 0x45, // BCS spBadCmd ; No extended commands
+	0xad, 0xea, 0xcf, // LDA spParams+1
+	0xd0, 0x21, // BNE spDrive
+	0xad, 0xf0, 0xcf, // LDA spCmd
+	0xd0, 0x44, // BNE spBadUnit ; Only STATUS for the host
+	0xad, 0xed, 0xcf, // LDA spParams+4
+	0xd0, 0x42, // BNE spBadCtl
+	0xa9, 0x02, // LDA #$02 ; Two devices
+	0xa0, 0x00, // LDY #$00
+	0x91, 0x44, // STA (bufLo),Y
+	0xa9, 0x00, // LDA #$00
+	// spHost: $CDB8
+	0xc8,       // INY
+	0x91, 0x44, // STA (bufLo),Y
+	0xc0, 0x07, // CPY #$07
+	0xd0, 0xf9, // BNE spHost
+	0xa9, 0x08, // LDA #$08
+	0x8d, 0xf1, 0xcf, // STA spCountLo
+	0xa9, 0x00, // LDA #$00
+	0x60, // RTS
+	// spDrive: $CDC7
+	0xc9, 0x03, // CMP #$03
+	0xb0, 0x24, // BCS spBadUnit
+	0xc9, 0x02, // CMP #$02
+	0xa9, 0x00, // LDA #$00
+	0x6a,             // ROR A ; The unit 2 is the drive 2, on bit 7
+	0x0d, 0xc0, 0xcf, // ORA slot16
+	0x85, 0x43, // STA unit
+	0xad, 0xf0, 0xcf, // LDA spCmd
+	0xf0, 0x3e, // BEQ spStatus
+	0xc9, 0x01, // CMP #$01
+	0xf0, 0x17, // BEQ spRead
+	0xc9, 0x04, // CMP #$04
+	0x90, 0x07, // BCC spProtected ; WRITEBLOCK and FORMAT
+	0xc9, 0x06, // CMP #$06
+	0x90, 0x06, // BCC spOk ; CONTROL and INIT do nothing
+	// spBadCmd: $CDE6
+	0xa9, 0x01, // LDA #$01 ; Bad command
+	0x60, // RTS
+	// spProtected: $CDE9
+	0xa9, 0x2b, // LDA #$2B ; Write protected
+	0x60, // RTS
+	// spOk: $CDEC
+	0xa9, 0x00, // LDA #$00
+	0x60, // RTS
+	// spBadUnit: $CDEF
+	0xa9, 0x28, // LDA #$28 ; No device connected
+	0x60, // RTS
+	// spBadCtl: $CDF2
+	0xa9, 0x21, // LDA #$21 ; Bad status code
+	0x60, // RTS
+	// spRead: $CDF5
+	0xad, 0xef, 0xcf, // LDA spParams+6
+	0xd0, 0x1b, // BNE spBadBlock
+	0xad, 0xed, 0xcf, // LDA spParams+4
+	0x85, 0x46, // STA blkLo
+	0xad, 0xee, 0xcf, // LDA spParams+5
+	0x85, 0x47, // STA blkHi
+	0xa9, 0x01, // LDA #$01
+	0x85, 0x42, // STA cmd ; READ
+	0x20, 0x60, 0xc8, // JSR command
+	0xc9, 0x00, // CMP #$00
+	0xd0, 0x05, // BNE spReadEnd
+	0xa0, 0x02, // LDY #$02
+	0x8c, 0xf2, 0xcf, // STY spCountHi ; 512 bytes read
+	// spReadEnd: $CE14
+	0x60, // RTS
+	// spBadBlock: $CE15
+	0xa9, 0x2d, // LDA #$2D ; Invalid block
+	0x60, // RTS
+	// Status code 0 is the general status and 3 adds the device information
+	// spStatus: $CE18
+	0xad, 0xed, 0xcf, // LDA spParams+4
+	0xf0, 0x04, // BEQ spGeneral
+	0xc9, 0x03, // CMP #$03
+	0xd0, 0xd1, // BNE spBadCtl
+	// spGeneral: $CE21
+	0xa9, 0x00, // LDA #$00
+	0x85, 0x42, // STA cmd ; STATUS
+	0x20, 0x60, 0xc8, // JSR command ; Blocks on X and Y
+	0xc9, 0x00, // CMP #$00
+	0xd0, 0x2f, // BNE spStatusEnd
+	0x98,       // TYA
+	0xa0, 0x02, // LDY #$02
+	0x91, 0x44, // STA (bufLo),Y
+	0x8a,       // TXA
+	0x88,       // DEY
+	0x91, 0x44, // STA (bufLo),Y
+	0x88,       // DEY
+	0xa9, 0xb4, // LDA #$B4 ; Block device, read allowed, online and write protected
+	0x91, 0x44, // STA (bufLo),Y
+	0xa9, 0x00, // LDA #$00
+	0xa0, 0x03, // LDY #$03
+	0x91, 0x44, // STA (bufLo),Y
+	0xa9, 0x04, // LDA #$04
+	0x8d, 0xf1, 0xcf, // STA spCountLo
+	0xad, 0xed, 0xcf, // LDA spParams+4
+	0xf0, 0x11, // BEQ spStatusEnd
+	0xa0, 0x04, // LDY #$04
+	// spDib: $CE4C
+	0xb9, 0x58, 0xce, // LDA spDibData-4,Y
+	0x91, 0x44, // STA (bufLo),Y
+	0xc8,       // INY
+	0xc0, 0x19, // CPY #25
+	0xd0, 0xf6, // BNE spDib
+	0x8c, 0xf1, 0xcf, // STY spCountLo
+	0xa9, 0x00, // LDA #$00
+	// spStatusEnd: $CE5B
+	0x60, // RTS
+	// Name, type 3.5 disk, subtype and version of the device information block
+	// spDibData: $CE5C
+	0x0b, 0x55, 0x4e, 0x49, 0x44, 0x49, 0x53, 0x4b, 0x20, 0x33, 0x2e, 0x35, // UNIDISK 3.5
+	0x20, 0x20, 0x20, 0x20, 0x20,
+	0x01, 0x00, 0x00, 0x01,
+	// 6 and 2 values of the nibbles $80 to $FF
+	// untab: $CE71
+	0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+	0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x00, 0x01, 0xff, 0xff, 0x02, 0x03, 0xff, 0x04, 0x05, 0x06,
+	0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x07, 0x08, 0xff, 0xff, 0xff, 0x09, 0x0a, 0x0b, 0x0c, 0x0d,
+	0xff, 0xff, 0x0e, 0x0f, 0x10, 0x11, 0x12, 0x13, 0xff, 0x14, 0x15, 0x16, 0x17, 0x18, 0x19, 0x1a,
+	0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x1b, 0xff, 0x1c, 0x1d, 0x1e,
+	0xff, 0xff, 0xff, 0x1f, 0xff, 0xff, 0x20, 0x21, 0xff, 0x22, 0x23, 0x24, 0x25, 0x26, 0x27, 0x28,
+	0xff, 0xff, 0xff, 0xff, 0xff, 0x29, 0x2a, 0x2b, 0xff, 0x2c, 0x2d, 0x2e, 0x2f, 0x30, 0x31, 0x32,
+	0xff, 0xff, 0x33, 0x34, 0x35, 0x36, 0x37, 0x38, 0xff, 0x39, 0x3a, 0x3b, 0x3c, 0x3d, 0x3e, 0x3f,
+}
@@ -0,0 +1,196 @@
+package izapple2
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ivanizag/izapple2/storage"
+)
+
+func TestIwmReadAddressFields(t *testing.T) {
+	overrides := newConfiguration()
+	overrides.set(confS5, "iwm,disk1=<internal>/A2DeskTop-1.4-en_800k.2mg")
+
+	at, err := makeApple2Tester("2enh", overrides)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := at.a
+	io := uint16(0xc0d0) // Slot 5
+
+	access := func(address uint16) uint8 {
+		a.cycles += 4
+		return a.mmu.Peek(io + address)
+	}
+	setRegister := func(register uint8) {
+		for i := uint16(0); i < 3; i++ {
+			access(i<<1 | uint16(register>>i)&1) // CA0, CA1 and CA2
+		}
+		a.mmu.Poke(0xc500, (register&0x8)<<4) // SEL, latched on writes to the slot page
+	}
+	command := func(register uint8, value uint8) {
+		setRegister(register | value<<2)
+		access(0x7) // LSTRB on
+		access(0x6) // LSTRB off
+	}
+	sense := func(register uint8) bool {
+		setRegister(register)
+		access(0xd) // Q6H
+		status := access(0xe)
+		access(0xc) // Q6L
+		return status&0x80 != 0
+	}
+	readNibbles := func() []uint8 {
+		var nibbles []uint8
+		for len(nibbles) < 2000 {
+			value := access(0xc)
+			if value&0x80 != 0 {
+				nibbles = append(nibbles, value)
+			}
+		}
+		return nibbles
+	}
+
+	// Asynchronous mode with fast bit cells, set with the motor off
+	access(0xd) // Q6H
+	a.mmu.Poke(io+0xf, 0x0f)
+	access(0xe) // Q7L
+	access(0xc) // Q6L
+
+	access(0x9) // Motor line on
+	access(0xa) // Drive 1
+	if sense(drive35SenseNoDisk) {
+		t.Fatal("Disk expected in drive 1")
+	}
+	command(drive35ControlMotor, 0)
+	if sense(drive35SenseMotorOn) {
+		t.Error("Motor expected to be on")
+	}
+	if sense(drive35SenseNotTrack0) {
+		t.Error("Head expected on track 0")
+	}
+
+	// Address fields: D5 AA 96, track, sector, side, format $22
+	setRegister(drive35SenseReadData0)
+	nibbles := readNibbles()
+	if !bytes.Contains(nibbles, []uint8{0xd5, 0xaa, 0x96, 0x96}) ||
+		!bytes.Contains(nibbles, []uint8{0x96, 0xd9}) {
+		t.Errorf("Address field for track 0 side 0 not found: %x", nibbles)
+	}
+
+	setRegister(drive35SenseReadData1)
+	nibbles = readNibbles()
+	if !bytes.Contains(nibbles, []uint8{0xd6, 0xd9}) {
+		t.Errorf("Address field for track 0 side 1 not found: %x", nibbles)
+	}
+
+	command(drive35ControlDirection, 0)
+	command(drive35ControlStep, 0)
+	if !sense(drive35SenseNotTrack0) {
+		t.Error("Head expected out of track 0")
+	}
+	setRegister(drive35SenseReadData0)
+	nibbles = readNibbles()
+	if !bytes.Contains(nibbles, []uint8{0xd5, 0xaa, 0x96, 0x97}) {
+		t.Errorf("Address field for track 1 not found: %x", nibbles)
+	}
+}
+
+func TestIwmSmartPort(t *testing.T) {
+	overrides := newConfiguration()
+	overrides.set(confS5, "iwm,disk1=<internal>/A2DeskTop-1.4-en_800k.2mg")
+	at, err := makeApple2Tester("2enh", overrides)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := at.a
+
+	data, _, err := LoadResource("<internal>/A2DeskTop-1.4-en_800k.2mg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bd, err := storage.NewBlockDiskMemory(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if a.mmu.Peek(0xc507) != 0x00 || a.mmu.Peek(0xc5ff) != 0x0a {
+		t.Fatal("SmartPort signature expected")
+	}
+
+	// Call the SmartPort entry with the parameter list on $0380
+	call := func(command uint8, params []uint8) (uint8, uint16, bool) {
+		program := []uint8{
+			0x20, 0x0d, 0xc5, // JSR $C50D
+			command,
+			0x80, 0x03, // Parameter list on $0380
+		}
+		for i, value := range program {
+			a.mmu.Poke(0x300+uint16(i), value)
+		}
+		for i, value := range params {
+			a.mmu.Poke(0x380+uint16(i), value)
+		}
+		a.mmu.Poke(0x42, 0x5a) // ProDOS zero page to be preserved
+
+		a.cpu.SetPC(0x300)
+		end := 0x300 + uint16(len(program))
+		for pc, _ := a.cpu.GetPCAndSP(); pc != end; pc, _ = a.cpu.GetPCAndSP() {
+			a.executeInstruction()
+		}
+		if a.mmu.Peek(0x42) != 0x5a {
+			t.Error("Zero page not restored")
+		}
+		regA, regX, regY, regP := a.cpu.GetAXYP()
+		if (regP&1 == 1) != (regA != 0) {
+			t.Errorf("Carry expected only on errors, A is $%02x", regA)
+		}
+		return regA, uint16(regX) | uint16(regY)<<8, regA == 0
+	}
+
+	// Host status, number of devices
+	errCode, count, _ := call(0x00, []uint8{3, 0, 0x00, 0x20, 0})
+	if errCode != 0 || count != 8 || a.mmu.Peek(0x2000) != 2 {
+		t.Errorf("Host status failed with $%02x, %v devices", errCode, a.mmu.Peek(0x2000))
+	}
+
+	// Device information block of the unit 1
+	errCode, count, _ = call(0x00, []uint8{3, 1, 0x00, 0x20, 3})
+	blocks := uint32(a.mmu.Peek(0x2001)) | uint32(a.mmu.Peek(0x2002))<<8
+	if errCode != 0 || count != 25 || blocks != bd.GetSizeInBlocks() {
+		t.Errorf("DIB failed with $%02x, %v bytes and %v blocks", errCode, count, blocks)
+	}
+	if a.mmu.Peek(0x2015) != 0x01 {
+		t.Errorf("3.5 disk type expected, got $%02x", a.mmu.Peek(0x2015))
+	}
+
+	// Read blocks on the first and on the last tracks
+	for _, block := range []uint32{2, 1599} {
+		errCode, count, _ = call(0x01, []uint8{3, 1, 0x00, 0x20, uint8(block), uint8(block >> 8), 0})
+		if errCode != 0 || count != 512 {
+			t.Fatalf("READBLOCK %v failed with $%02x", block, errCode)
+		}
+		expected, err := bd.Read(block)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, want := range expected {
+			if got := a.mmu.Peek(0x2000 + uint16(i)); got != want {
+				t.Fatalf("Mismatch on byte %v of block %v: $%02x instead of $%02x", i, block, got, want)
+			}
+		}
+	}
+
+	errCode, _, _ = call(0x02, []uint8{3, 1, 0x00, 0x20, 2, 0, 0})
+	if errCode != 0x2b {
+		t.Errorf("Write protected error expected, got $%02x", errCode)
+	}
+	errCode, _, _ = call(0x00, []uint8{3, 2, 0x00, 0x20, 0})
+	if errCode != 0x2f {
+		t.Errorf("Offline error expected for the empty drive 2, got $%02x", errCode)
+	}
+	errCode, _, _ = call(0x01, []uint8{3, 3, 0x00, 0x20, 0, 0, 0})
+	if errCode != 0x28 {
+		t.Errorf("No device error expected for unit 3, got $%02x", errCode)
+	}
+}
@@ -1,7 +1,10 @@
 package izapple2
 
 import (
+	"fmt"
 	"os"
+
+	"github.com/ivanizag/izapple2/printer"
 )
 
 /*
@@ -10,13 +13,29 @@ Apple II Parallel Printer Interface card.
 See:
 	https://mirrors.apple2.org.za/Apple%20II%20Documentation%20Project/Interface%20Cards/Parallel/Apple%20II%20Parallel%20Printer%20Interface%20Card/
 
+The printed bytes are dumped to a file or sent to an emulated Epson
+FX-80 or ImageWriter II printer that renders the pages to PNG or PDF.
+
+The firmware can be replaced with the rom parameter. With the interface
+parameter set to grappler the card has the registers of the Orange Micro
+Grappler+ and has to be used with the ROM of that card:
+	Apple: data written on $C0n0, status read on $C0n4
+	Grappler+: data written on $C0n0, status read on $C0n1 with
+		bit 0 busy, bit 1 paper out and bit 2 printer selected
+
+The emulated printers are always ready: not busy, with paper and
+selected.
 */
 
+const grapplerStatusSelect = uint8(0x04)
+
 // CardParallelPrinter represents a Parallel Printer Interface card
 type CardParallelPrinter struct {
 	cardBase
-	file  *os.File
-	ascii bool
+	file     *os.File
+	printer  *printer.Printer
+	ascii    bool
+	grappler bool // Grappler+ registers
 }
 
 func newCardParallelPrinterBuilder() *cardBuilder {
@@ -26,19 +45,59 @@ func newCardParallelPrinterBuilder() *cardBuilder {
 		defaultParams: &[]paramSpec{
 			{"file", "File to store the printed code", "printer.out"},
 			{"ascii", "Remove the 7 bit. Useful for normal text printing, but breaks graphics printing ", "false"},
+			{"printer", "Printer emulation: raw to dump the bytes to the file, epson or imagewriter", "raw"},
+			{"output", "Pages rendered by the printer, a PDF or a PNG for each page", "printer.pdf"},
+			{"autolf", "Add a line feed after each carriage return", "false"},
+			{"interface", "Registers of the card: apple or grappler, the Grappler+ needs its ROM", "apple"},
+			{"rom", "Alternative firmware, like the Grappler+ ROM", ""},
 		},
 		buildFunc: func(params map[string]string) (Card, error) {
 			var c CardParallelPrinter
 			c.ascii = paramsGetBool(params, "ascii")
-			filepath := paramsGetPath(params, "file")
-			f, err := os.OpenFile(filepath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+
+			cardInterface := paramsGetString(params, "interface")
+			if cardInterface == "grappler" {
+				c.grappler = true
+			} else if cardInterface != "apple" {
+				return nil, fmt.Errorf("invalid interface '%s', must be apple or grappler", cardInterface)
+			}
+
+			emulation := paramsGetString(params, "printer")
+			if emulation == "raw" {
+				filepath := paramsGetPath(params, "file")
+				f, err := os.OpenFile(filepath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+				if err != nil {
+					return nil, err
+				}
+				c.file = f
+			} else {
+				p, err := printer.NewPrinter(emulation,
+					paramsGetPath(params, "output"),
+					paramsGetBool(params, "autolf"))
+				if err != nil {
+					return nil, err
+				}
+				c.printer = p
+			}
+
+			rom := paramsGetPath(params, "rom")
+			if rom == "" {
+				if c.grappler {
+					return nil, fmt.Errorf("the Grappler+ interface needs the ROM of the card on the rom parameter")
+				}
+				rom = "<internal>/Apple II Parallel Printer Interface Card ROM fixed.bin"
+			}
+			data, _, err := LoadResource(rom)
 			if err != nil {
 				return nil, err
 			}
-			c.file = f
-			err = c.loadRomFromResource("<internal>/Apple II Parallel Printer Interface Card ROM fixed.bin", cardRomSimple)
+			layout := cardRomSimple
+			if len(data) == 0x800 {
+				layout = cardRomUpper
+			}
+			err = c.loadRom(data, layout)
 			if err != nil {
-				return nil, err
+				return nil, fmt.Errorf("invalid printer ROM: %w", err)
 			}
 			return &c, nil
 		},
@@ -50,14 +109,25 @@ func (c *CardParallelPrinter) assign(a *Apple2, slot int) {
 		c.printByte(value)
 	}, "PARALLELDEVW")
 
-	c.addCardSoftSwitchR(4, func() uint8 {
-		return 0xff // TODO: What are the bit values?
-	}, "PARALLELSTATUSR")
+	if c.grappler {
+		c.addCardSoftSwitchR(1, func() uint8 {
+			return grapplerStatusSelect // Not busy and with paper
+		}, "GRAPPLERSTATUSR")
+	} else {
+		c.addCardSoftSwitchR(4, func() uint8 {
+			return 0xff // TODO: What are the bit values?
+		}, "PARALLELSTATUSR")
+	}
 
 	c.cardBase.assign(a, slot)
 }
 
 func (c *CardParallelPrinter) printByte(value uint8) {
+	if c.printer != nil {
+		c.printer.Print(value)
+		return
+	}
+
 	if c.ascii {
 		// As text the MSB has to be removed, but if done, graphics modes won't work
 		value &= 0x7f // Remove the MSB bit
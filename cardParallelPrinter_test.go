@@ -0,0 +1,63 @@
+package izapple2
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func buildParallelPrinterTester(t *testing.T, conf string) (*apple2Tester, string) {
+	output := filepath.Join(t.TempDir(), "printer.out")
+	overrides := newConfiguration()
+	overrides.set(confS1, conf+",file="+output)
+	at, err := makeApple2Tester("2enh", overrides)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return at, output
+}
+
+func TestParallelPrinterRegisters(t *testing.T) {
+	rom := filepath.Join(t.TempDir(), "grappler.rom")
+	err := os.WriteFile(rom, make([]uint8, 0x100), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		conf           string
+		status         uint16
+		expectedStatus uint8
+	}{
+		{"parallel", 0xc094, 0xff},
+		{"parallel,interface=grappler,rom=" + rom, 0xc091, grapplerStatusSelect},
+	}
+
+	for _, c := range cases {
+		at, output := buildParallelPrinterTester(t, c.conf)
+		a := at.a
+
+		if status := a.mmu.Peek(c.status); status != c.expectedStatus {
+			t.Errorf("%s: unexpected status $%02x", c.conf, status)
+		}
+
+		a.mmu.Poke(0xc090, 'A')
+		a.mmu.Poke(0xc090, 'B')
+		data, err := os.ReadFile(output)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != "AB" {
+			t.Errorf("%s: unexpected output '%s'", c.conf, data)
+		}
+	}
+}
+
+func TestParallelPrinterGrapplerNeedsRom(t *testing.T) {
+	overrides := newConfiguration()
+	overrides.set(confS1, "parallel,interface=grappler")
+	_, err := makeApple2Tester("2enh", overrides)
+	if err == nil {
+		t.Error("The Grappler+ interface without ROM must fail")
+	}
+}
@@ -0,0 +1,409 @@
+package izapple2
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/ivanizag/izapple2/component"
+	"github.com/ivanizag/izapple2/storage"
+)
+
+/*
+Apple II SCSI Card, with a NCR 5380 controller.
+
+See:
+	Apple II SCSI Card Technical Reference.
+	https://mirrors.apple2.org.za/Apple%20II%20Documentation%20Project/Interface%20Cards/SCSI/Apple%20II%20SCSI%20Card/
+	https://github.com/mamedev/mame/blob/master/src/devices/bus/a2bus/a2scsi.cpp
+
+Registers:
+	$C0n0-$C0n7: NCR 5380 registers
+	$C0n8: pseudo DMA data
+	$C0n9: DIP switches with the card SCSI ID
+	$C0nA: banks, ROM bank on bits 0-3 and RAM bank on bits 4-6
+	$C0nB: reset the NCR 5380
+	$C0nD: enable pseudo DMA
+	$C0nE: DRQ on bit 7
+
+The C800 area has a 1KB window of the 16KB ROM on $C800 and a 1KB
+window of the 8KB RAM on $CC00.
+
+The targets are on the SCSI IDs 0 to 6, the card is the ID 7. The
+images with the iso extension are CD-ROMs, the rest are hard disks.
+
+The original firmware is not included, it can be loaded with the rom
+parameter, the image starts with the $Cn00 page. Without it, a minimal
+firmware with a ProDOS block driver for the first two targets is used.
+The driver is on the bank 0 of the ROM and uses the NCR 5380 as the
+original: selection without arbitration, READ(6) and WRITE(6) commands
+and the data transferred with pseudo DMA.
+*/
+
+// CardScsi represents an Apple II SCSI card
+type CardScsi struct {
+	cardBase
+	controller *component.Ncr5380
+	driveIds   [2]uint8 // Target ID bits of the drives of the built-in firmware
+	rom        []uint8
+	ram        [scsiRamSize]uint8
+	romBank    int
+	ramBank    int
+	trace      bool
+}
+
+const (
+	scsiTargets    = 7
+	scsiCardId     = 7
+	scsiBankSize   = 0x400
+	scsiRamSize    = 0x2000
+	scsiRamAddress = uint16(0xcc00)
+)
+
+func newCardScsiBuilder() *cardBuilder {
+	params := make([]paramSpec, 0, scsiTargets+2)
+	for i := 0; i < scsiTargets; i++ {
+		params = append(params, paramSpec{
+			"id" + strconv.Itoa(i),
+			"Disk image or ISO file for the SCSI ID " + strconv.Itoa(i),
+			""})
+	}
+	params = append(params,
+		paramSpec{"rom", "Firmware of the Apple II SCSI card, empty for a minimal ProDOS driver", ""},
+		paramSpec{"trace", "Trace the SCSI commands", "false"})
+
+	return &cardBuilder{
+		name:          "Apple II SCSI Card",
+		description:   "SCSI card with a NCR 5380 and hard disk or CD-ROM targets",
+		defaultParams: &params,
+		buildFunc: func(params map[string]string) (Card, error) {
+			var c CardScsi
+			c.trace = paramsGetBool(params, "trace")
+			c.controller = component.NewNcr5380()
+			c.controller.SetTrace(c.trace)
+
+			drives := 0
+			for i := 0; i < scsiTargets; i++ {
+				image := paramsGetPath(params, "id"+strconv.Itoa(i))
+				if image == "" {
+					continue
+				}
+				cdrom := strings.ToLower(filepath.Ext(image)) == ".iso"
+				var disk storage.BlockDisk
+				var err error
+				if cdrom {
+					disk, err = LoadCdromDisk(image)
+				} else {
+					disk, err = LoadBlockDisk(image)
+				}
+				if err != nil {
+					return nil, err
+				}
+				c.controller.SetTarget(i, component.NewScsiDisk(disk, cdrom))
+				if drives < len(c.driveIds) {
+					c.driveIds[drives] = 1 << i
+					drives++
+				}
+			}
+
+			rom := paramsGetPath(params, "rom")
+			if rom != "" {
+				data, _, err := LoadResource(rom)
+				if err != nil {
+					return nil, err
+				}
+				if len(data) == 0 || len(data)%scsiBankSize != 0 {
+					return nil, fmt.Errorf("invalid SCSI card ROM size")
+				}
+				c.rom = data
+				err = c.loadRom(data[:0x100], cardRomSimple)
+				if err != nil {
+					return nil, err
+				}
+				c.romC8xx = &c
+			}
+			return &c, nil
+		},
+	}
+}
+
+func (c *CardScsi) assign(a *Apple2, slot int) {
+	if c.rom == nil {
+		c.rom = buildScsiRom(slot, c.driveIds)
+		c.loadRom(c.rom[:0x100], cardRomSimple)
+		c.romC8xx = c
+	}
+
+	for i := uint8(0); i < 8; i++ {
+		register := i
+		c.addCardSoftSwitchR(register, func() uint8 {
+			return c.controller.Read(register)
+		}, fmt.Sprintf("NCR5380R%v", register))
+		c.addCardSoftSwitchW(register, func(value uint8) {
+			c.controller.Write(register, value)
+		}, fmt.Sprintf("NCR5380W%v", register))
+	}
+
+	c.addCardSoftSwitchR(8, func() uint8 {
+		return c.controller.DmaRead()
+	}, "SCSIDMAR")
+	c.addCardSoftSwitchW(8, func(value uint8) {
+		c.controller.DmaWrite(value)
+	}, "SCSIDMAW")
+
+	c.addCardSoftSwitchR(9, func() uint8 {
+		return scsiCardId
+	}, "SCSIDIPSWITCHES")
+
+	c.addCardSoftSwitchW(0xa, func(value uint8) {
+		c.romBank = int(value & 0x0f)
+		c.ramBank = int(value>>4) & 0x07
+	}, "SCSIBANKW")
+
+	c.addCardSoftSwitchRW(0xb, func() uint8 {
+		c.controller.Reset()
+		return 0
+	}, "SCSIRESET")
+
+	c.addCardSoftSwitchRW(0xd, func() uint8 {
+		// The pseudo DMA is always enabled
+		return 0
+	}, "SCSIDMAENABLE")
+
+	c.addCardSoftSwitchR(0xe, func() uint8 {
+		if c.controller.Drq() {
+			return 0x80
+		}
+		return 0
+	}, "SCSIDRQ")
+
+	c.cardBase.assign(a, slot)
+}
+
+func (c *CardScsi) reset() {
+	c.controller.Reset()
+	c.romBank = 0
+	c.ramBank = 0
+}
+
+func (c *CardScsi) peek(address uint16) uint8 {
+	if address >= scsiRamAddress {
+		return c.ram[c.ramBank*scsiBankSize+int(address-scsiRamAddress)]
+	}
+	offset := (c.romBank*scsiBankSize + int(address-0xc800)) % len(c.rom)
+	return c.rom[offset]
+}
+
+func (c *CardScsi) poke(address uint16, value uint8) {
+	if address >= scsiRamAddress {
+		c.ram[c.ramBank*scsiBankSize+int(address-scsiRamAddress)] = value
+	}
+}
+
+func buildScsiRom(slot int, driveIds [2]uint8) []uint8 {
+	data := make([]uint8, scsiBankSize)
+	s := 0xc0 + uint8(slot)    // High byte of the ROM addresses
+	r := 0x80 + uint8(slot<<4) // Low byte of the $C0n0 register
+	slot16 := uint8(slot << 4) // Slot in the high nibble, as ProDOS uses it
+
+	copy(data, []uint8{
+		// Preamble bytes to comply with the expectation in $Cn01, 3, 5 and 7
+		0xa2, 0x20, // LDX #$20
+		0xa0, 0x00, // LDY #$00
+		0xa2, 0x03, // LDX #$03
+		0xa2, 0x3c, // LDX #$3C
+		0xd0, 0x0b, // BNE boot
+	})
+
+	copy(data[0x0a:], []uint8{
+		// ProDOS driver entry point, it has to be in $Cn0a. The driver is on the bank 0 of the ROM on $C800
+		0x2c, 0xff, 0xcf, // entry: BIT $CFFF ; Release the $C800 area of the other cards
+		0xa9, 0x00, // LDA #$00
+		0x8d, r + 0xa, 0xc0, // STA $C0nA ; ROM and RAM banks 0
+		0x4c, 0x00, 0xc9, // JMP driver
+	})
+
+	copy(data[0x15:], []uint8{
+		// Boot code: read block 0 in address $0800, then jump there.
+		0xa9, 0x01, // boot: LDA #$01
+		0x85, 0x42, // STA $42 ; Command READ(1)
+		0xa9, slot16, // LDA #$s0
+		0x85, 0x43, // STA $43 ; Unit in this slot, drive 1
+		0xa9, 0x00, // LDA #$00
+		0x85, 0x44, // STA $44 ; Dest LO($0800)
+		0x85, 0x46, // STA $46 ; Block LO(0)
+		0x85, 0x47, // STA $47 ; Block HI(0)
+		0xa9, 0x08, // LDA #$08
+		0x85, 0x45, // STA $45 ; Dest HI($0800)
+		0x20, 0x0a, s, // JSR entry ; Call the driver
+		0xb0, 0x05, // BCS fail
+		0xa2, slot16, // LDX #$s0 ; Slot on the high nibble of X
+		0x4c, 0x01, 0x08, // JMP $801 ; Jump to the loaded boot sector
+		0x4c, 0x00, 0xe0, // fail: JMP $E000 ; BASIC
+	})
+
+	copy(data[0x100:], []uint8{
+		// Driver: select the target and send the command. Status and format check that the target is ready
+		0xa5, 0x42, // driver: LDA $42
+		0xc9, 0x04, // CMP #$04
+		0xb0, 0x1f, // BCS badCommand
+		0x20, 0xad, 0xc9, // JSR select ; Carry set and error in A when there is no target
+		0xb0, 0x19, // BCS return
+		0xa5, 0x42, // LDA $42
+		0xf0, 0x04, // BEQ status
+		0xc9, 0x03, // CMP #$03
+		0x90, 0x16, // BCC readWrite
+		0xa0, 0x06, // status: LDY #$06
+		0xa9, 0x00, // statusCdb: LDA #$00
+		0x20, 0xe8, 0xc9, // JSR sendByte ; TEST UNIT READY, six zeros
+		0x88,       // DEY
+		0xd0, 0xf8, // BNE statusCdb
+		0x20, 0x8b, 0xc9, // JSR finish
+		0xa2, 0xff, // LDX #$FF ; 65535 blocks in X and Y
+		0xa0, 0xff, // LDY #$FF
+		0x60,       // return: RTS
+		0xa9, 0x01, // badCommand: LDA #$01 ; Bad command
+		0x38, // SEC
+		0x60, // RTS
+	})
+
+	copy(data[0x129:], []uint8{
+		// READ(6) or WRITE(6) of one block, the data is transferred with pseudo DMA
+		0x0a,       // readWrite: ASL A
+		0x69, 0x06, // ADC #$06
+		0x20, 0xe8, 0xc9, // JSR sendByte ; Opcode $08 or $0A
+		0xa9, 0x00, // LDA #$00
+		0x20, 0xe8, 0xc9, // JSR sendByte ; LUN 0
+		0xa5, 0x47, // LDA $47
+		0x20, 0xe8, 0xc9, // JSR sendByte
+		0xa5, 0x46, // LDA $46
+		0x20, 0xe8, 0xc9, // JSR sendByte
+		0xa9, 0x01, // LDA #$01
+		0x20, 0xe8, 0xc9, // JSR sendByte ; One block
+		0xa9, 0x00, // LDA #$00
+		0x20, 0xe8, 0xc9, // JSR sendByte ; Control
+		0xa0, 0x00, // LDY #$00
+		0xa9, 0x02, // LDA #$02
+		0xa6, 0x42, // LDX $42
+		0xca,       // DEX
+		0xd0, 0x1a, // BNE write
+		0xa2, 0x01, // LDX #$01 ; Two pages
+		0x8e, r + 3, 0xc0, // STX $C0n3 ; Data in phase
+		0x8d, r + 2, 0xc0, // STA $C0n2 ; DMA mode
+		0x8d, r + 7, 0xc0, // STA $C0n7 ; Start DMA initiator receive
+		0xad, r + 8, 0xc0, // readLoop: LDA $C0n8 ; The controller does the handshake
+		0x91, 0x44, // STA ($44),Y
+		0xc8,       // INY
+		0xd0, 0xf8, // BNE readLoop
+		0xe6, 0x45, // INC $45
+		0xca,       // DEX
+		0x10, 0xf3, // BPL readLoop
+		0x30, 0x1c, // BMI transferEnd
+		0xa2, 0x00, // write: LDX #$00
+		0x8e, r + 3, 0xc0, // STX $C0n3 ; Data out phase
+		0xe8,              // INX ; Two pages
+		0x8e, r + 1, 0xc0, // STX $C0n1 ; Assert the data bus
+		0x8d, r + 2, 0xc0, // STA $C0n2 ; DMA mode
+		0x8d, r + 5, 0xc0, // STA $C0n5 ; Start DMA send
+		0xb1, 0x44, // writeLoop: LDA ($44),Y
+		0x8d, r + 8, 0xc0, // STA $C0n8 ; The controller does the handshake
+		0xc8,       // INY
+		0xd0, 0xf8, // BNE writeLoop
+		0xe6, 0x45, // INC $45
+		0xca,       // DEX
+		0x10, 0xf3, // BPL writeLoop
+		0xc6, 0x45, // transferEnd: DEC $45 ; Restore the buffer address
+		0xc6, 0x45, // DEC $45
+	})
+
+	copy(data[0x18b:], []uint8{
+		// Status and message in phases. Returns error 27 if the status is not GOOD
+		0xa9, 0x00, // finish: LDA #$00
+		0x8d, r + 2, 0xc0, // STA $C0n2 ; End DMA
+		0x8d, r + 1, 0xc0, // STA $C0n1 ; Release the data bus
+		0xa9, 0x03, // LDA #$03
+		0x8d, r + 3, 0xc0, // STA $C0n3 ; Status phase
+		0x20, 0xfd, 0xc9, // JSR receiveByte
+		0x48,       // PHA
+		0xa9, 0x07, // LDA #$07
+		0x8d, r + 3, 0xc0, // STA $C0n3 ; Message in phase
+		0x20, 0xfd, 0xc9, // JSR receiveByte ; Command complete, the target releases the bus
+		0x68,       // PLA
+		0xd0, 0x02, // BNE ioError
+		0x18,       // CLC
+		0x60,       // RTS
+		0xa9, 0x27, // ioError: LDA #$27 ; I/O error
+		0x38, // SEC
+		0x60, // RTS
+	})
+
+	copy(data[0x1ad:], []uint8{
+		// Select the target of the drive, without arbitration. Returns error 28 if there is no target
+		0xa9, 0x00, // select: LDA #$00
+		0x8d, r + 2, 0xc0, // STA $C0n2
+		0x8d, r + 1, 0xc0, // STA $C0n1
+		0xa5, 0x43, // LDA $43
+		0x0a,             // ASL A ; Drive bit to carry
+		0xad, 0x13, 0xca, // LDA drive1Id
+		0x90, 0x03, // BCC selectId
+		0xad, 0x14, 0xca, // LDA drive2Id
+		0xf0, 0x22, // selectId: BEQ noDevice
+		0x09, 0x80, // ORA #$80 ; Card on ID 7
+		0x8d, r + 0, 0xc0, // STA $C0n0
+		0xa9, 0x05, // LDA #$05
+		0x8d, r + 1, 0xc0, // STA $C0n1 ; Assert SEL and the data bus
+		0xad, r + 4, 0xc0, // LDA $C0n4
+		0x29, 0x40, // AND #$40 ; The target answers with BSY
+		0xf0, 0x0c, // BEQ selectFail
+		0xa9, 0x01, // LDA #$01
+		0x8d, r + 1, 0xc0, // STA $C0n1 ; Release SEL
+		0xa9, 0x02, // LDA #$02
+		0x8d, r + 3, 0xc0, // STA $C0n3 ; Command phase
+		0x18,       // CLC
+		0x60,       // RTS
+		0xa9, 0x00, // selectFail: LDA #$00
+		0x8d, r + 1, 0xc0, // STA $C0n1
+		0xa9, 0x28, // noDevice: LDA #$28 ; No device connected
+		0x38, // SEC
+		0x60, // RTS
+	})
+
+	copy(data[0x1e8:], []uint8{
+		// Send a byte with the REQ/ACK handshake
+		0x8d, r + 0, 0xc0, // sendByte: STA $C0n0
+		0xad, r + 4, 0xc0, // sendWait: LDA $C0n4
+		0x29, 0x20, // AND #$20 ; Wait for REQ
+		0xf0, 0xf9, // BEQ sendWait
+		0xa9, 0x11, // LDA #$11
+		0x8d, r + 1, 0xc0, // STA $C0n1 ; Assert ACK
+		0xa9, 0x01, // LDA #$01
+		0x8d, r + 1, 0xc0, // STA $C0n1 ; Release ACK
+		0x60, // RTS
+	})
+
+	copy(data[0x1fd:], []uint8{
+		// Receive a byte with the REQ/ACK handshake
+		0xad, r + 4, 0xc0, // receiveByte: LDA $C0n4
+		0x29, 0x20, // AND #$20 ; Wait for REQ
+		0xf0, 0xf9, // BEQ receiveByte
+		0xae, r + 0, 0xc0, // LDX $C0n0
+		0xa9, 0x10, // LDA #$10
+		0x8d, r + 1, 0xc0, // STA $C0n1 ; Assert ACK
+		0xa9, 0x00, // LDA #$00
+		0x8d, r + 1, 0xc0, // STA $C0n1 ; Release ACK
+		0x8a, // TXA
+		0x60, // RTS
+	})
+
+	// Target ID bits of the drives 1 and 2, read on select
+	data[0x213] = driveIds[0]
+	data[0x214] = driveIds[1]
+
+	data[0xfc] = 0
+	data[0xfd] = 0    // Use status to get the number of blocks
+	data[0xfe] = 0x17 // Two volumes. Status, read, write and format
+	data[0xff] = 0x0a // Driver entry point
+
+	return data
+}
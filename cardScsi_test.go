@@ -0,0 +1,98 @@
+package izapple2
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestScsiBoots(t *testing.T) {
+	overrides := newConfiguration()
+	overrides.set(confS7, "scsi,id0=<internal>/ProDOS_2_4_3.po")
+	testBoots(t, "2enh", "", overrides, 100_000_000, "BITSY  BYE", "NEW VOL", testTextMode40)
+}
+
+func TestScsiRegisters(t *testing.T) {
+	data, _, err := LoadResource("<internal>/ProDOS_2_4_3.po")
+	if err != nil {
+		t.Fatal(err)
+	}
+	image := filepath.Join(t.TempDir(), "prodos.po")
+	err = os.WriteFile(image, data, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	overrides := newConfiguration()
+	overrides.set(confS7, "scsi,id2=\""+image+"\"")
+	at, err := makeApple2Tester("2enh", overrides)
+	if err != nil {
+		t.Fatal(err)
+	}
+	io := at.a.io
+	handshake := func(value uint8, icr uint8) uint8 {
+		if io.peek(0xc0f4)&0x20 == 0 {
+			t.Fatal("REQ expected")
+		}
+		io.poke(0xc0f0, value)
+		received := io.peek(0xc0f0)
+		io.poke(0xc0f1, icr|0x10) // Assert ACK
+		io.poke(0xc0f1, icr)      // Release ACK
+		return received
+	}
+	command := func(cdb []uint8) {
+		io.poke(0xc0f0, 0x80|0x04) // Card ID 7 and target ID 2
+		io.poke(0xc0f1, 0x05)      // SEL and data bus
+		if io.peek(0xc0f4)&0x40 == 0 {
+			t.Fatal("The target has not been selected")
+		}
+		io.poke(0xc0f1, 0x01) // Release SEL
+		io.poke(0xc0f3, 0x02) // Command phase
+		for _, value := range cdb {
+			handshake(value, 0x01)
+		}
+		io.poke(0xc0f1, 0)
+	}
+	complete := func() uint8 {
+		io.poke(0xc0f2, 0)    // End DMA
+		io.poke(0xc0f3, 0x03) // Status phase
+		status := handshake(0, 0)
+		io.poke(0xc0f3, 0x07) // Message in phase
+		handshake(0, 0)
+		if io.peek(0xc0f4)&0x40 != 0 {
+			t.Error("Bus free expected after the command")
+		}
+		return status
+	}
+
+	// WRITE(6) of block 3 with pseudo DMA
+	command([]uint8{0x0a, 0, 0, 3, 1, 0})
+	io.poke(0xc0f3, 0x00) // Data out phase
+	io.poke(0xc0f1, 0x01) // Data bus
+	io.poke(0xc0f2, 0x02) // DMA mode
+	io.poke(0xc0f5, 0)    // Start DMA send
+	for i := 0; i < 512; i++ {
+		if io.peek(0xc0fe)&0x80 == 0 {
+			t.Fatalf("DRQ expected on byte %v", i)
+		}
+		io.poke(0xc0f8, uint8(i))
+	}
+	io.poke(0xc0f1, 0)
+	if status := complete(); status != 0 {
+		t.Errorf("Unexpected write status $%02x", status)
+	}
+
+	// READ(6) of block 3 with pseudo DMA
+	command([]uint8{0x08, 0, 0, 3, 1, 0})
+	io.poke(0xc0f3, 0x01) // Data in phase
+	io.poke(0xc0f2, 0x02) // DMA mode
+	io.poke(0xc0f7, 0)    // Start DMA initiator receive
+	for i := 0; i < 512; i++ {
+		if value := io.peek(0xc0f8); value != uint8(i) {
+			t.Fatalf("Unexpected value $%02x on byte %v", value, i)
+		}
+	}
+	if status := complete(); status != 0 {
+		t.Errorf("Unexpected read status $%02x", status)
+	}
+}
@@ -3,6 +3,8 @@ package izapple2
 import (
 	"fmt"
 	"strconv"
+
+	"github.com/ivanizag/izapple2/fujinet"
 )
 
 /*
@@ -66,9 +68,12 @@ func newCardSmartPortFujinetBuilder() *cardBuilder {
 		name:        "Fujinet",
 		description: "SmartPort interface card hosting the Fujinet",
 		defaultParams: &[]paramSpec{
+			{"sd", "Directory for the SD host slot", ""},
+			{"host", "TNFS server on the second host slot, for example tnfs.fujinet.online", ""},
 			{"tracesp", "Trace SmartPort calls", "false"},
 			{"tracenet", "Trace on the network device", "false"},
 			{"traceclock", "Trace on the clock device", "false"},
+			{"tracefuji", "Trace on the fuji device and disks", "false"},
 		},
 		buildFunc: func(params map[string]string) (Card, error) {
 			var c CardSmartPort
@@ -82,6 +87,22 @@ func newCardSmartPortFujinetBuilder() *cardBuilder {
 			clock.trace = paramsGetBool(params, "traceclock")
 			c.AddDevice(clock)
 
+			traceFuji := paramsGetBool(params, "tracefuji")
+			disks := make([]*SmartPortFujinetDisk, fujiDeviceSlots)
+			for i := range disks {
+				disks[i] = NewSmartPortFujinetDisk(&c, i)
+				disks[i].trace = traceFuji
+			}
+			fuji := NewSmartPortFujinetFuji(&c, disks)
+			fuji.trace = traceFuji
+			fuji.sdRoot = paramsGetPath(params, "sd")
+			fuji.SetHostSlot(0, fujinet.HostLocal)
+			fuji.SetHostSlot(1, paramsGetString(params, "host"))
+			c.AddDevice(fuji)
+			for _, disk := range disks {
+				c.AddDevice(disk)
+			}
+
 			return &c, nil
 		},
 	}
@@ -191,7 +212,7 @@ func (c *CardSmartPort) exec(call *smartPortCall) uint8 {
 		}
 	}
 
-	if c.trace {
+	if c.trace && result != smartPortBusy {
 		fmt.Printf("[CardSmartPort] Command %v on slot %v => result %s.\n",
 			call, c.slot, smartPortErrorMessage(result))
 	}
@@ -252,6 +273,7 @@ func buildHardDiskRom(slot int) []uint8 {
 		0x69, 0x03, // ADC #$03 ; Fix return address past the cmdblock
 		0x48,                   // PHA
 		0xad, ssBase + 3, 0xc0, // LDA $C0n3 ; Softswitch 3, execute command. Error code in reg A.
+		0x30, 0xfb, // BMI $FB ; Repeat the command while the device is busy
 		0x18,       // CLC ; Clear carry for no errors.
 		0xF0, 0x01, // BEQ $01 ; Skips the SEC if reg A is zero
 		0x38, // SEC ; Set carry on errors
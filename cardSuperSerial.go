@@ -0,0 +1,155 @@
+package izapple2
+
+import (
+	"github.com/ivanizag/izapple2/component"
+)
+
+/*
+Apple Super Serial Card with a 6551 ACIA connected to a virtual Hayes
+modem.
+
+See:
+	https://mirrors.apple2.org.za/Apple%20II%20Documentation%20Project/Interface%20Cards/Serial/Apple%20Super%20Serial%20Card/
+	http://archive.6502.org/datasheets/rockwell_r6551_acia.pdf
+
+The 6551 registers are on $C0n8 to $C0nB. The interrupts are not
+supported, the programs have to poll the status register.
+
+The original firmware is not included, it can be loaded with the rom
+parameter. Without it, the card has only the identification bytes of
+the Pascal 1.1 protocol, enough for the terminal programs that drive
+the 6551 directly, like ProTERM or Z-Link.
+*/
+
+// CardSuperSerial represents a Super Serial Card
+type CardSuperSerial struct {
+	cardBase
+	modem   *component.HayesModem
+	command uint8
+	control uint8
+	rxData  uint8
+	rxFull  bool
+}
+
+const (
+	acia6551StatusRxFull  = uint8(0x08)
+	acia6551StatusTxEmpty = uint8(0x10)
+	acia6551StatusNoDCD   = uint8(0x20)
+	acia6551CommandDTR    = uint8(0x01)
+)
+
+func newCardSuperSerialBuilder() *cardBuilder {
+	return &cardBuilder{
+		name:        "Super Serial Card",
+		description: "Serial card with a virtual Hayes modem that dials TCP connections",
+		defaultParams: &[]paramSpec{
+			{"listen", "TCP port for incoming calls, empty to not answer", ""},
+			{"telnet", "Handle the telnet negotiation on the connections", "true"},
+			{"rom", "Firmware of the Super Serial Card", ""},
+		},
+		buildFunc: func(params map[string]string) (Card, error) {
+			var c CardSuperSerial
+			rom := paramsGetPath(params, "rom")
+			var err error
+			if rom == "" {
+				err = c.loadRom(buildSuperSerialIdRom(), cardRomSimple)
+			} else {
+				err = c.loadRomFromResource(rom, cardRomUpper)
+			}
+			if err != nil {
+				return nil, err
+			}
+
+			c.modem, err = component.NewHayesModem(
+				paramsGetString(params, "listen"),
+				paramsGetBool(params, "telnet"))
+			if err != nil {
+				return nil, err
+			}
+			return &c, nil
+		},
+	}
+}
+
+// buildSuperSerialIdRom returns a firmware with only the identification bytes
+func buildSuperSerialIdRom() []uint8 {
+	data := make([]uint8, 0x100)
+	data[0x00] = 0x60 // RTS for PR# and IN#
+	data[0x05] = 0x38 // Pascal 1.1 signature
+	data[0x07] = 0x18
+	data[0x0b] = 0x01
+	data[0x0c] = 0x31 // Serial card, Super Serial Card
+	for i := 0x0d; i <= 0x10; i++ {
+		data[i] = 0x20 // Pascal entry points
+	}
+	data[0x20] = 0xa2 // LDX #$03, illegal operation
+	data[0x21] = 0x03
+	data[0x22] = 0x60 // RTS
+	return data
+}
+
+func (c *CardSuperSerial) assign(a *Apple2, slot int) {
+	c.addCardSoftSwitchR(8, func() uint8 {
+		c.receive()
+		c.rxFull = false
+		return c.rxData
+	}, "SSCDATAR")
+	c.addCardSoftSwitchW(8, func(value uint8) {
+		c.modem.Write(value)
+	}, "SSCDATAW")
+
+	c.addCardSoftSwitchR(9, func() uint8 {
+		c.receive()
+		status := acia6551StatusTxEmpty
+		if c.rxFull {
+			status |= acia6551StatusRxFull
+		}
+		if !c.modem.Carrier() {
+			status |= acia6551StatusNoDCD
+		}
+		return status
+	}, "SSCSTATUSR")
+	c.addCardSoftSwitchW(9, func(uint8) {
+		// Programmed reset
+		c.setCommand(c.command & 0xe0)
+	}, "SSCRESETW")
+
+	c.addCardSoftSwitchR(0xa, func() uint8 {
+		return c.command
+	}, "SSCCOMMANDR")
+	c.addCardSoftSwitchW(0xa, func(value uint8) {
+		c.setCommand(value)
+	}, "SSCCOMMANDW")
+
+	c.addCardSoftSwitchR(0xb, func() uint8 {
+		return c.control
+	}, "SSCCONTROLR")
+	c.addCardSoftSwitchW(0xb, func(value uint8) {
+		c.control = value
+	}, "SSCCONTROLW")
+
+	c.cardBase.assign(a, slot)
+}
+
+func (c *CardSuperSerial) reset() {
+	c.setCommand(0)
+	c.control = 0
+	c.rxFull = false
+}
+
+func (c *CardSuperSerial) setCommand(value uint8) {
+	c.command = value
+	c.modem.SetDTR(value&acia6551CommandDTR != 0)
+}
+
+// receive gets the next byte from the modem if the receive register is empty
+func (c *CardSuperSerial) receive() {
+	if c.rxFull {
+		return
+	}
+	value, ok := c.modem.Read()
+	if ok {
+		c.rxData = value
+		c.rxFull = true
+	}
+}
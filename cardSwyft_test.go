@@ -11,7 +11,7 @@ func TestSwyftTutorial(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	at.terminateCondition = buildTerminateConditionText("HOW TO USE SWYFTCARD", testTextMode80, 10_000_000)
+	at.terminateCondition = buildTerminateConditionText("HOW TO USE SWYFTCARD", testTextMode80, 10_500_000)
 
 	at.run()
 
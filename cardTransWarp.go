@@ -0,0 +1,97 @@
+package izapple2
+
+/*
+Applied Engineering TransWarp accelerator. It runs the CPU at 3.58 MHz
+from the reset.
+
+The speed is selected writing to $C074: 0 for the accelerated speed and
+1 for the normal speed. The slowdowns on the speaker, the paddles and the
+slots with disk controllers are configured with DIP switches on the real
+card, here with the card parameters.
+
+See:
+	https://github.com/a2-4am/4cade/blob/master/src/hw.accel.a
+*/
+
+// CardTransWarp represents a TransWarp accelerator
+type CardTransWarp struct {
+	cardBase
+	maxSpeed  float64
+	slowSlots uint8
+	speaker   bool
+	paddles   bool
+	normal    bool
+}
+
+const (
+	transWarpFast   = uint8(0)
+	transWarpNormal = uint8(1)
+)
+
+func newCardTransWarpBuilder() *cardBuilder {
+	return &cardBuilder{
+		name:        "TransWarp",
+		description: "Applied Engineering TransWarp accelerator",
+		defaultParams: &[]paramSpec{
+			{"speed", "Speed in MHz when accelerated", "3.58"},
+			{"slowslots", "Slots with slowdown, like 56 for slots 5 and 6", "6"},
+			{"speaker", "Slowdown on speaker accesses", "true"},
+			{"paddles", "Slowdown on paddle accesses", "true"},
+		},
+		buildFunc: func(params map[string]string) (Card, error) {
+			var c CardTransWarp
+			var err error
+			c.maxSpeed, err = paramsGetSpeed(params, "speed")
+			if err != nil {
+				return nil, err
+			}
+			c.slowSlots, err = paramsGetSlots(params, "slowslots")
+			if err != nil {
+				return nil, err
+			}
+			c.speaker = paramsGetBool(params, "speaker")
+			c.paddles = paramsGetBool(params, "paddles")
+			return &c, nil
+		},
+	}
+}
+
+func (c *CardTransWarp) reset() {
+	c.normal = false
+	c.updateSpeed()
+}
+
+func (c *CardTransWarp) assign(a *Apple2, slot int) {
+	// Only writes are decoded
+	a.io.addSoftSwitchW(0x74, func(value uint8) {
+		switch value {
+		case transWarpFast:
+			c.normal = false
+		case transWarpNormal:
+			c.normal = true
+		}
+		c.updateSpeed()
+	}, "TRANSWARP-SPEED")
+
+	c.cardBase.assign(a, slot)
+	a.setAccelerator(c)
+	c.updateSpeed()
+}
+
+func (c *CardTransWarp) updateSpeed() {
+	if c.a == nil {
+		return
+	}
+	if c.normal {
+		c.a.setAcceleratorSpeed(0)
+	} else {
+		c.a.setAcceleratorSpeed(c.maxSpeed)
+	}
+}
+
+func (c *CardTransWarp) ioAccess(address uint8) {
+	cycles := acceleratorSlowdown(address, c.speaker, c.paddles, c.slowSlots)
+	if cycles != 0 {
+		c.a.slowDownAccelerator(cycles)
+	}
+}
@@ -0,0 +1,209 @@
+package izapple2
+
+/*
+Uthernet II, network card with a WIZnet W5100 chip.
+
+See:
+	http://a2retrosystems.com/Uthernet II manual.pdf
+	https://www.wiznet.io/wp-content/uploads/wiznethome/Chip/W5100/Document/W5100_Datasheet_v1.2.6.pdf
+	https://github.com/AppleWin/AppleWin/blob/master/source/Uthernet2.cpp
+
+The W5100 is used in indirect mode with four softswitches:
+	$C0n4: Mode register
+	$C0n5: Address high byte
+	$C0n6: Address low byte
+	$C0n7: Data at the address, incremented if the AI bit is set
+
+The TCP and UDP sockets are mapped to sockets of the host. The IP
+configuration set on the W5100 is ignored, the host network is used.
+The MACRAW mode of socket 0 is supported with a TAP interface, only on
+Linux. The IPRAW and PPPoE modes are not supported.
+*/
+
+// CardUthernet2 represents a Uthernet II network card
+type CardUthernet2 struct {
+	cardBase
+	address uint16
+	memory  [w5100MemorySize]uint8
+	sockets [w5100SocketCount]*uthernet2Socket
+	events  chan func() // Events from the host sockets, run on the emulation thread
+	tapName string
+}
+
+const (
+	w5100MemorySize  = 0x8000
+	w5100SocketCount = 4
+
+	w5100Mode          = uint16(0x0000)
+	w5100RetryTime     = uint16(0x0017)
+	w5100RetryCount    = uint16(0x0019)
+	w5100RxMemorySize  = uint16(0x001a)
+	w5100TxMemorySize  = uint16(0x001b)
+	w5100SocketsBase   = uint16(0x0400)
+	w5100SocketsEnd    = uint16(0x0800)
+	w5100TxMemoryBase  = uint16(0x4000)
+	w5100RxMemoryBase  = uint16(0x6000)
+	w5100MemoryEnd     = uint16(0x8000)
+	w5100BuffersSize   = 0x2000
+	w5100ModeReset     = uint8(0x80)
+	w5100ModeIncrement = uint8(0x02)
+	w5100ModeIndirect  = uint8(0x01)
+)
+
+func newCardUthernet2Builder() *cardBuilder {
+	return &cardBuilder{
+		name:        "Uthernet II",
+		description: "Network card with a WIZnet W5100 chip",
+		defaultParams: &[]paramSpec{
+			{"tap", "TAP interface for the MACRAW mode, only on Linux", ""},
+		},
+		buildFunc: func(params map[string]string) (Card, error) {
+			var c CardUthernet2
+			c.tapName = paramsGetString(params, "tap")
+			c.events = make(chan func(), 64)
+			for i := range c.sockets {
+				c.sockets[i] = newUthernet2Socket(&c, i)
+			}
+			c.resetW5100()
+			return &c, nil
+		},
+	}
+}
+
+func (c *CardUthernet2) assign(a *Apple2, slot int) {
+	c.addCardSoftSwitchR(4, func() uint8 {
+		return c.memory[w5100Mode]
+	}, "UTHERNET2MODER")
+	c.addCardSoftSwitchW(4, func(value uint8) {
+		c.write(w5100Mode, value)
+	}, "UTHERNET2MODEW")
+
+	c.addCardSoftSwitchR(5, func() uint8 {
+		return uint8(c.address >> 8)
+	}, "UTHERNET2ADDRHIR")
+	c.addCardSoftSwitchW(5, func(value uint8) {
+		c.address = uint16(value)<<8 | c.address&0xff
+	}, "UTHERNET2ADDRHIW")
+
+	c.addCardSoftSwitchR(6, func() uint8 {
+		return uint8(c.address)
+	}, "UTHERNET2ADDRLOR")
+	c.addCardSoftSwitchW(6, func(value uint8) {
+		c.address = c.address&0xff00 | uint16(value)
+	}, "UTHERNET2ADDRLOW")
+
+	c.addCardSoftSwitchR(7, func() uint8 {
+		value := c.read(c.address)
+		c.incrementAddress()
+		return value
+	}, "UTHERNET2DATAR")
+	c.addCardSoftSwitchW(7, func(value uint8) {
+		c.write(c.address, value)
+		c.incrementAddress()
+	}, "UTHERNET2DATAW")
+
+	c.cardBase.assign(a, slot)
+}
+
+func (c *CardUthernet2) reset() {
+	c.resetW5100()
+}
+
+func (c *CardUthernet2) resetW5100() {
+	for _, s := range c.sockets {
+		s.close()
+	}
+	c.memory = [w5100MemorySize]uint8{}
+	c.address = 0
+	c.setRegister16(w5100RetryTime, 2000) // 200ms in units of 100us
+	c.memory[w5100RetryCount] = 8
+	c.memory[w5100RxMemorySize] = 0x55 // 2KB for each socket
+	c.memory[w5100TxMemorySize] = 0x55
+	for _, s := range c.sockets {
+		s.refresh()
+	}
+}
+
+func (c *CardUthernet2) incrementAddress() {
+	if c.memory[w5100Mode]&w5100ModeIncrement == 0 {
+		return
+	}
+	c.address++
+	// The auto increment wraps around on the buffers areas
+	if c.address == w5100RxMemoryBase {
+		c.address = w5100TxMemoryBase
+	} else if c.address == w5100MemoryEnd {
+		c.address = w5100RxMemoryBase
+	}
+}
+
+func (c *CardUthernet2) read(address uint16) uint8 {
+	if address >= w5100MemoryEnd {
+		return 0
+	}
+	if address >= w5100SocketsBase && address < w5100SocketsEnd {
+		c.processEvents()
+	}
+	return c.memory[address]
+}
+
+func (c *CardUthernet2) write(address uint16, value uint8) {
+	c.tracef("Write $%04x: $%02x\n", address, value)
+	switch {
+	case address == w5100Mode:
+		if value&w5100ModeReset != 0 {
+			c.resetW5100()
+			return
+		}
+		c.memory[address] = value
+
+	case address >= w5100SocketsBase && address < w5100SocketsEnd:
+		index := int(address-w5100SocketsBase) >> 8
+		if index < w5100SocketCount {
+			c.sockets[index].write(uint8(address), value)
+		}
+
+	case address < w5100MemoryEnd:
+		c.memory[address] = value
+	}
+}
+
+func (c *CardUthernet2) register16(address uint16) uint16 {
+	return uint16(c.memory[address])<<8 | uint16(c.memory[address+1])
+}
+
+func (c *CardUthernet2) setRegister16(address uint16, value uint16) {
+	c.memory[address] = uint8(value >> 8)
+	c.memory[address+1] = uint8(value)
+}
+
+// bufferArea returns the base and size of the buffer of a socket from the
+// RMSR or TMSR memory size registers
+func (c *CardUthernet2) bufferArea(sizeRegister uint16, base uint16, index int) (uint16, uint16) {
+	sizes := c.memory[sizeRegister]
+	end := base + w5100BuffersSize
+	for i := 0; i < w5100SocketCount; i++ {
+		size := uint16(1024) << ((sizes >> (2 * i)) & 0x3)
+		if base+size > end {
+			// There is no room left for this socket
+			size = 0
+		}
+		if i == index {
+			return base, size
+		}
+		base += size
+	}
+	return base, 0
+}
+
+// processEvents runs the pending events of the host sockets
+func (c *CardUthernet2) processEvents() {
+	for {
+		select {
+		case event := <-c.events:
+			event()
+		default:
+			return
+		}
+	}
+}
@@ -0,0 +1,443 @@
+package izapple2
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+/*
+Sockets of the W5100 mapped to sockets of the host.
+
+The host sockets are served by goroutines that send events to the card.
+The events are run on the emulation thread when the socket registers are
+read. Each event checks that the socket has not been reopened since.
+*/
+
+type uthernet2Socket struct {
+	c         *CardUthernet2
+	index     int
+	registers uint16 // Base address of the socket registers
+
+	generation int // Incremented on each close to discard old events
+	done       chan struct{}
+	conn       net.Conn
+	listener   net.Listener
+	udpConn    *net.UDPConn
+	tap        io.ReadWriteCloser
+
+	pending  [][]uint8 // Data received, waiting for room on the RX buffer
+	received uint16    // Bytes on the RX buffer
+	rxWrite  uint16    // Write pointer on the RX buffer
+	lastRxRd uint16    // Read pointer at the last RECV command
+}
+
+const (
+	// Socket registers
+	w5100SnMode       = uint8(0x00)
+	w5100SnCommand    = uint8(0x01)
+	w5100SnInterrupt  = uint8(0x02)
+	w5100SnStatus     = uint8(0x03)
+	w5100SnPort       = uint8(0x04)
+	w5100SnDestIP     = uint8(0x0c)
+	w5100SnDestPort   = uint8(0x10)
+	w5100SnTxFreeSize = uint8(0x20)
+	w5100SnTxRead     = uint8(0x22)
+	w5100SnTxWrite    = uint8(0x24)
+	w5100SnRxSize     = uint8(0x26)
+	w5100SnRxRead     = uint8(0x28)
+
+	// Protocols on the mode register
+	w5100ProtocolMask   = uint8(0x0f)
+	w5100ProtocolTcp    = uint8(0x01)
+	w5100ProtocolUdp    = uint8(0x02)
+	w5100ProtocolMacRaw = uint8(0x04)
+
+	// Commands
+	w5100CommandOpen     = uint8(0x01)
+	w5100CommandListen   = uint8(0x02)
+	w5100CommandConnect  = uint8(0x04)
+	w5100CommandDiscon   = uint8(0x08)
+	w5100CommandClose    = uint8(0x10)
+	w5100CommandSend     = uint8(0x20)
+	w5100CommandSendMac  = uint8(0x21)
+	w5100CommandSendKeep = uint8(0x22)
+	w5100CommandRecv     = uint8(0x40)
+
+	// Interrupt bits
+	w5100InterruptCon     = uint8(0x01)
+	w5100InterruptDiscon  = uint8(0x02)
+	w5100InterruptRecv    = uint8(0x04)
+	w5100InterruptTimeout = uint8(0x08)
+	w5100InterruptSendOk  = uint8(0x10)
+
+	// Status
+	w5100StatusClosed      = uint8(0x00)
+	w5100StatusInit        = uint8(0x13)
+	w5100StatusListen      = uint8(0x14)
+	w5100StatusSynSent     = uint8(0x15)
+	w5100StatusEstablished = uint8(0x17)
+	w5100StatusCloseWait   = uint8(0x1c)
+	w5100StatusUdp         = uint8(0x22)
+	w5100StatusMacRaw      = uint8(0x42)
+
+	uthernet2ConnectTimeout = 10 * time.Second
+	uthernet2PacketSize     = 1514
+)
+
+func newUthernet2Socket(c *CardUthernet2, index int) *uthernet2Socket {
+	var s uthernet2Socket
+	s.c = c
+	s.index = index
+	s.registers = w5100SocketsBase + uint16(index)<<8
+	return &s
+}
+
+func (s *uthernet2Socket) register(offset uint8) uint8 {
+	return s.c.memory[s.registers+uint16(offset)]
+}
+
+func (s *uthernet2Socket) setRegister(offset uint8, value uint8) {
+	s.c.memory[s.registers+uint16(offset)] = value
+}
+
+func (s *uthernet2Socket) register16(offset uint8) uint16 {
+	return s.c.register16(s.registers + uint16(offset))
+}
+
+func (s *uthernet2Socket) setRegister16(offset uint8, value uint16) {
+	s.c.setRegister16(s.registers+uint16(offset), value)
+}
+
+func (s *uthernet2Socket) write(offset uint8, value uint8) {
+	switch offset {
+	case w5100SnCommand:
+		s.command(value)
+	case w5100SnInterrupt:
+		// The bits set to one are cleared
+		s.setRegister(offset, s.register(offset)&^value)
+	case w5100SnStatus, w5100SnTxFreeSize, w5100SnTxFreeSize + 1,
+		w5100SnRxSize, w5100SnRxSize + 1:
+		// Read only
+	default:
+		s.setRegister(offset, value)
+		s.refresh()
+	}
+}
+
+// refresh updates the registers calculated from the socket state
+func (s *uthernet2Socket) refresh() {
+	_, txSize := s.c.bufferArea(w5100TxMemorySize, w5100TxMemoryBase, s.index)
+	used := s.register16(w5100SnTxWrite) - s.register16(w5100SnTxRead)
+	free := uint16(0)
+	if used < txSize {
+		free = txSize - used
+	}
+	s.setRegister16(w5100SnTxFreeSize, free)
+	s.setRegister16(w5100SnRxSize, s.received)
+}
+
+func (s *uthernet2Socket) status() uint8 {
+	return s.register(w5100SnStatus)
+}
+
+func (s *uthernet2Socket) setStatus(status uint8) {
+	s.setRegister(w5100SnStatus, status)
+}
+
+func (s *uthernet2Socket) interrupt(bits uint8) {
+	s.setRegister(w5100SnInterrupt, s.register(w5100SnInterrupt)|bits)
+}
+
+func (s *uthernet2Socket) command(command uint8) {
+	s.c.tracef("Socket %v command $%02x\n", s.index, command)
+	switch command {
+	case w5100CommandOpen:
+		s.open()
+	case w5100CommandListen:
+		if s.status() == w5100StatusInit {
+			s.listen()
+		}
+	case w5100CommandConnect:
+		if s.status() == w5100StatusInit {
+			s.connect()
+		}
+	case w5100CommandDiscon:
+		if s.conn != nil {
+			s.close()
+			s.interrupt(w5100InterruptDiscon)
+		}
+	case w5100CommandClose:
+		s.close()
+	case w5100CommandSend, w5100CommandSendMac:
+		s.send()
+	case w5100CommandSendKeep:
+		// Nothing to do, the host keeps the connection alive
+	case w5100CommandRecv:
+		consumed := s.register16(w5100SnRxRead) - s.lastRxRd
+		s.lastRxRd = s.register16(w5100SnRxRead)
+		if consumed > s.received {
+			consumed = s.received
+		}
+		s.received -= consumed
+		s.moveReceived()
+	}
+	// The command register is cleared when the command is accepted
+	s.setRegister(w5100SnCommand, 0)
+	s.refresh()
+}
+
+func (s *uthernet2Socket) open() {
+	s.close()
+	s.setRegister16(w5100SnTxRead, 0)
+	s.setRegister16(w5100SnTxWrite, 0)
+	s.setRegister16(w5100SnRxRead, 0)
+	s.done = make(chan struct{})
+
+	var err error
+	switch s.register(w5100SnMode) & w5100ProtocolMask {
+	case w5100ProtocolTcp:
+		s.setStatus(w5100StatusInit)
+
+	case w5100ProtocolUdp:
+		address := &net.UDPAddr{Port: int(s.register16(w5100SnPort))}
+		s.udpConn, err = net.ListenUDP("udp4", address)
+		if err != nil {
+			s.c.tracef("Socket %v UDP error: %v\n", s.index, err)
+			return
+		}
+		s.setStatus(w5100StatusUdp)
+		go s.receiveUdp(s.udpConn, s.generation, s.done)
+
+	case w5100ProtocolMacRaw:
+		if s.index != 0 || s.c.tapName == "" {
+			return
+		}
+		s.tap, err = openTap(s.c.tapName)
+		if err != nil {
+			s.c.tracef("Socket %v TAP error: %v\n", s.index, err)
+			return
+		}
+		s.setStatus(w5100StatusMacRaw)
+		go s.receiveMacRaw(s.tap, s.generation, s.done)
+
+	default:
+		// IPRAW and PPPoE are not supported, the socket stays closed
+	}
+}
+
+func (s *uthernet2Socket) listen() {
+	address := fmt.Sprintf(":%v", s.register16(w5100SnPort))
+	listener, err := net.Listen("tcp4", address)
+	if err != nil {
+		s.c.tracef("Socket %v listen error: %v\n", s.index, err)
+		s.setStatus(w5100StatusClosed)
+		return
+	}
+	s.listener = listener
+	s.setStatus(w5100StatusListen)
+
+	generation, done := s.generation, s.done
+	go func() {
+		// The W5100 sockets accept a single connection
+		conn, err := listener.Accept()
+		listener.Close()
+		s.sendEvent(generation, done, func() {
+			if err != nil {
+				s.close()
+				return
+			}
+			s.connected(conn)
+		})
+	}()
+}
+
+func (s *uthernet2Socket) connect() {
+	ip := s.c.memory[s.registers+uint16(w5100SnDestIP):][:4]
+	address := fmt.Sprintf("%v:%v", net.IP(ip), s.register16(w5100SnDestPort))
+	s.setStatus(w5100StatusSynSent)
+
+	generation, done := s.generation, s.done
+	go func() {
+		conn, err := net.DialTimeout("tcp4", address, uthernet2ConnectTimeout)
+		s.sendEvent(generation, done, func() {
+			if err != nil {
+				s.c.tracef("Socket %v connect error: %v\n", s.index, err)
+				s.close()
+				s.interrupt(w5100InterruptTimeout)
+				return
+			}
+			s.connected(conn)
+		})
+	}()
+}
+
+func (s *uthernet2Socket) connected(conn net.Conn) {
+	s.conn = conn
+	s.setStatus(w5100StatusEstablished)
+	s.interrupt(w5100InterruptCon)
+	go s.receiveTcp(conn, s.generation, s.done)
+}
+
+func (s *uthernet2Socket) close() {
+	if s.done != nil {
+		close(s.done)
+		s.done = nil
+	}
+	if s.conn != nil {
+		s.conn.Close()
+		s.conn = nil
+	}
+	if s.listener != nil {
+		s.listener.Close()
+		s.listener = nil
+	}
+	if s.udpConn != nil {
+		s.udpConn.Close()
+		s.udpConn = nil
+	}
+	if s.tap != nil {
+		s.tap.Close()
+		s.tap = nil
+	}
+	s.generation++
+	s.pending = nil
+	s.received = 0
+	s.rxWrite = 0
+	s.lastRxRd = 0
+	s.setStatus(w5100StatusClosed)
+}
+
+// sendEvent queues an event for the emulation thread, unless the socket is closed
+func (s *uthernet2Socket) sendEvent(generation int, done chan struct{}, event func()) {
+	select {
+	case s.c.events <- func() {
+		if s.generation == generation {
+			event()
+		}
+	}:
+	case <-done:
+	}
+}
+
+func (s *uthernet2Socket) receiveTcp(conn net.Conn, generation int, done chan struct{}) {
+	for {
+		buffer := make([]uint8, uthernet2PacketSize)
+		n, err := conn.Read(buffer)
+		if n > 0 {
+			s.sendEvent(generation, done, func() {
+				s.receive(buffer[:n])
+			})
+		}
+		if err != nil {
+			s.sendEvent(generation, done, func() {
+				// Closed by the remote host, the data received is kept
+				s.setStatus(w5100StatusCloseWait)
+				s.interrupt(w5100InterruptDiscon)
+			})
+			return
+		}
+	}
+}
+
+func (s *uthernet2Socket) receiveUdp(conn *net.UDPConn, generation int, done chan struct{}) {
+	for {
+		buffer := make([]uint8, uthernet2PacketSize)
+		n, remote, err := conn.ReadFromUDP(buffer)
+		if err != nil {
+			return
+		}
+		// Header with the source address and the size of the data
+		packet := make([]uint8, 0, 8+n)
+		packet = append(packet, remote.IP.To4()...)
+		packet = append(packet, uint8(remote.Port>>8), uint8(remote.Port), uint8(n>>8), uint8(n))
+		packet = append(packet, buffer[:n]...)
+		s.sendEvent(generation, done, func() {
+			s.receive(packet)
+		})
+	}
+}
+
+func (s *uthernet2Socket) receiveMacRaw(tap io.Reader, generation int, done chan struct{}) {
+	for {
+		buffer := make([]uint8, uthernet2PacketSize)
+		n, err := tap.Read(buffer)
+		if err != nil {
+			return
+		}
+		// Header with the size of the frame, including the header
+		size := n + 2
+		packet := append([]uint8{uint8(size >> 8), uint8(size)}, buffer[:n]...)
+		s.sendEvent(generation, done, func() {
+			s.receive(packet)
+		})
+	}
+}
+
+func (s *uthernet2Socket) receive(data []uint8) {
+	s.pending = append(s.pending, data)
+	s.moveReceived()
+	s.refresh()
+}
+
+// moveReceived copies the data pending to the RX buffer when there is room.
+// The TCP streams can be split, the packets of the other modes can't.
+func (s *uthernet2Socket) moveReceived() {
+	base, size := s.c.bufferArea(w5100RxMemorySize, w5100RxMemoryBase, s.index)
+	stream := s.conn != nil
+	for len(s.pending) > 0 {
+		if s.received >= size {
+			return
+		}
+		data := s.pending[0]
+		free := size - s.received
+		if !stream && int(free) < len(data) {
+			return
+		}
+		n := min(int(free), len(data))
+		for i := 0; i < n; i++ {
+			s.c.memory[base+(s.rxWrite&(size-1))] = data[i]
+			s.rxWrite++
+		}
+		s.received += uint16(n)
+		if n == len(data) {
+			s.pending = s.pending[1:]
+		} else {
+			s.pending[0] = data[n:]
+		}
+		s.interrupt(w5100InterruptRecv)
+	}
+}
+
+func (s *uthernet2Socket) send() {
+	base, size := s.c.bufferArea(w5100TxMemorySize, w5100TxMemoryBase, s.index)
+	if size == 0 {
+		return
+	}
+	start := s.register16(w5100SnTxRead)
+	end := s.register16(w5100SnTxWrite)
+	data := make([]uint8, 0, end-start)
+	for p := start; p != end; p++ {
+		data = append(data, s.c.memory[base+(p&(size-1))])
+	}
+	s.setRegister16(w5100SnTxRead, end)
+
+	var err error
+	switch {
+	case s.conn != nil:
+		_, err = s.conn.Write(data)
+	case s.udpConn != nil:
+		ip := s.c.memory[s.registers+uint16(w5100SnDestIP):][:4]
+		address := &net.UDPAddr{IP: net.IP(ip), Port: int(s.register16(w5100SnDestPort))}
+		_, err = s.udpConn.WriteToUDP(data, address)
+	case s.tap != nil:
+		_, err = s.tap.Write(data)
+	default:
+		return
+	}
+	if err != nil {
+		s.c.tracef("Socket %v send error: %v\n", s.index, err)
+	}
+	s.interrupt(w5100InterruptSendOk)
+}
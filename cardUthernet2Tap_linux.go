@@ -0,0 +1,30 @@
+package izapple2
+
+import (
+	"encoding/binary"
+	"io"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// openTap opens an existing TAP interface to send and receive ethernet frames
+func openTap(name string) (io.ReadWriteCloser, error) {
+	file, err := os.OpenFile("/dev/net/tun", os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	// struct ifreq with the interface name and the flags
+	var request [40]uint8
+	copy(request[:syscall.IFNAMSIZ-1], name)
+	binary.NativeEndian.PutUint16(request[syscall.IFNAMSIZ:], syscall.IFF_TAP|syscall.IFF_NO_PI)
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, file.Fd(),
+		uintptr(syscall.TUNSETIFF), uintptr(unsafe.Pointer(&request[0])))
+	if errno != 0 {
+		file.Close()
+		return nil, errno
+	}
+
+	return file, nil
+}
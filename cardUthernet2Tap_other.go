@@ -0,0 +1,13 @@
+//go:build !linux
+
+package izapple2
+
+import (
+	"errors"
+	"io"
+)
+
+// openTap is not supported outside Linux
+func openTap(name string) (io.ReadWriteCloser, error) {
+	return nil, errors.New("TAP interfaces are only supported on Linux")
+}
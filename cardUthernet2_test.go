@@ -0,0 +1,150 @@
+package izapple2
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func buildUthernet2Tester(t *testing.T) *CardUthernet2 {
+	overrides := newConfiguration()
+	overrides.set(confS3, "uthernet2")
+	at, err := makeApple2Tester("2enh", overrides)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := at.a.GetCards()[3].(*CardUthernet2)
+	t.Cleanup(c.resetW5100)
+	return c
+}
+
+func uthernet2Write(c *CardUthernet2, address uint16, data ...uint8) {
+	for i, value := range data {
+		c.write(address+uint16(i), value)
+	}
+}
+
+func uthernet2Read16(c *CardUthernet2, address uint16) uint16 {
+	return uint16(c.read(address))<<8 | uint16(c.read(address+1))
+}
+
+// uthernet2Wait waits until the register has the value, as the Apple II would do polling
+func uthernet2Wait(t *testing.T, c *CardUthernet2, address uint16, value uint16) {
+	deadline := time.Now().Add(5 * time.Second)
+	for uthernet2Read16(c, address) != value {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timeout waiting for $%04x to be $%04x", address, value)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// uthernet2Send writes the data on the TX buffer of socket 0 and sends it
+func uthernet2Send(c *CardUthernet2, data []uint8) {
+	write := uthernet2Read16(c, 0x0424)
+	for i, value := range data {
+		c.write(w5100TxMemoryBase+(write+uint16(i))&0x7ff, value)
+	}
+	write += uint16(len(data))
+	uthernet2Write(c, 0x0424, uint8(write>>8), uint8(write))
+	uthernet2Write(c, 0x0401, w5100CommandSend)
+}
+
+// uthernet2Receive reads the data on the RX buffer of socket 0
+func uthernet2Receive(c *CardUthernet2, length uint16) []uint8 {
+	read := uthernet2Read16(c, 0x0428)
+	data := make([]uint8, length)
+	for i := range data {
+		data[i] = c.read(w5100RxMemoryBase + (read+uint16(i))&0x7ff)
+	}
+	read += length
+	uthernet2Write(c, 0x0428, uint8(read>>8), uint8(read))
+	uthernet2Write(c, 0x0401, w5100CommandRecv)
+	return data
+}
+
+func TestUthernet2Tcp(t *testing.T) {
+	listener, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		buffer := make([]uint8, 100)
+		n, _ := conn.Read(buffer)
+		conn.Write(buffer[:n])
+		conn.Close()
+	}()
+	port := uint16(listener.Addr().(*net.TCPAddr).Port)
+
+	c := buildUthernet2Tester(t)
+	c.write(w5100Mode, w5100ModeIncrement|w5100ModeIndirect)
+	uthernet2Write(c, 0x0400, w5100ProtocolTcp)
+	uthernet2Write(c, 0x0401, w5100CommandOpen)
+	if c.read(0x0403) != w5100StatusInit {
+		t.Fatal("Socket not initialized")
+	}
+	uthernet2Write(c, 0x040c, 127, 0, 0, 1, uint8(port>>8), uint8(port))
+	uthernet2Write(c, 0x0401, w5100CommandConnect)
+	uthernet2Wait(t, c, 0x0402, uint16(w5100InterruptCon)<<8|uint16(w5100StatusEstablished))
+
+	uthernet2Send(c, []uint8("HELLO"))
+	uthernet2Wait(t, c, 0x0426, 5)
+	if data := uthernet2Receive(c, 5); string(data) != "HELLO" {
+		t.Errorf("Unexpected echo '%s'", data)
+	}
+	if uthernet2Read16(c, 0x0426) != 0 {
+		t.Error("RX buffer expected empty")
+	}
+	uthernet2Wait(t, c, 0x0403, uint16(w5100StatusCloseWait)<<8)
+}
+
+func TestUthernet2Udp(t *testing.T) {
+	server, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	go func() {
+		buffer := make([]uint8, 100)
+		n, remote, err := server.ReadFromUDP(buffer)
+		if err == nil {
+			server.WriteToUDP(buffer[:n], remote)
+		}
+	}()
+	port := uint16(server.LocalAddr().(*net.UDPAddr).Port)
+
+	c := buildUthernet2Tester(t)
+	uthernet2Write(c, 0x0400, w5100ProtocolUdp)
+	uthernet2Write(c, 0x0401, w5100CommandOpen)
+	if c.read(0x0403) != w5100StatusUdp {
+		t.Fatal("UDP socket not opened")
+	}
+	uthernet2Write(c, 0x040c, 127, 0, 0, 1, uint8(port>>8), uint8(port))
+	uthernet2Send(c, []uint8("PING"))
+
+	uthernet2Wait(t, c, 0x0426, 8+4)
+	packet := uthernet2Receive(c, 8+4)
+	if packet[0] != 127 || packet[3] != 1 ||
+		uint16(packet[4])<<8|uint16(packet[5]) != port ||
+		packet[7] != 4 || string(packet[8:]) != "PING" {
+		t.Errorf("Unexpected packet %v", packet)
+	}
+}
+
+func TestUthernet2AutoIncrement(t *testing.T) {
+	c := buildUthernet2Tester(t)
+	c.write(w5100Mode, w5100ModeIncrement|w5100ModeIndirect)
+	c.address = w5100RxMemoryBase - 1
+	c.incrementAddress()
+	if c.address != w5100TxMemoryBase {
+		t.Errorf("TX area expected to wrap, got $%04x", c.address)
+	}
+	if uthernet2Read16(c, 0x0420) != 0x800 {
+		t.Error("2KB free on the TX buffer expected")
+	}
+}
@@ -0,0 +1,217 @@
+package izapple2
+
+/*
+Zip Chip accelerator. It replaces the 6502 and runs it faster with a
+cache of the main memory.
+
+The registers are on the annunciator softswitches $C05A to $C05F. They
+have to be unlocked writing $5A four times to $C05A, and locked again
+writing $A5. While locked, the annunciators work as usual.
+
+Registers when unlocked:
+	$C05A W: any value other than $5A or $A5 disables the acceleration
+	$C05B W: enables the acceleration
+	$C05C RW: slowdowns, bit 0 for the speaker and bits 1 to 7 for the slots
+	$C05D W: speed, the bits 7 and 6 select 100%, 75%, 50% or 25%
+	$C05E R: status, bit 7 set if the acceleration is disabled
+	$C05F RW: bit 6 set disables the slowdown on the paddles
+
+The cache and the rest of the configuration options are not emulated.
+
+See:
+	https://github.com/a2-4am/4cade/blob/master/src/hw.accel.a
+*/
+
+// CardZipChip represents a Zip Chip accelerator
+type CardZipChip struct {
+	cardBase
+	maxSpeed      float64
+	unlockCounter uint8
+	unlocked      bool
+	disabled      bool
+	slowdowns     uint8
+	speed         uint8
+	paddles       uint8
+
+	defaultSlowdowns uint8
+	annunciatorsR    [6]softSwitchR
+	annunciatorsW    [6]softSwitchW
+}
+
+const (
+	zipChipUnlockToken   = uint8(0x5a)
+	zipChipUnlockRepeats = 4
+	zipChipLockToken     = uint8(0xa5)
+	zipChipSpeaker       = uint8(0x01)
+	zipChipNoPaddles     = uint8(0x40)
+	zipChipDisabled      = uint8(0x80)
+)
+
+func newCardZipChipBuilder() *cardBuilder {
+	return &cardBuilder{
+		name:        "Zip Chip",
+		description: "Accelerator replacing the 6502 with programmable speed",
+		defaultParams: &[]paramSpec{
+			{"speed", "Speed in MHz when accelerated", "8"},
+			{"slowslots", "Slots with slowdown, like 56 for slots 5 and 6", "6"},
+		},
+		buildFunc: func(params map[string]string) (Card, error) {
+			var c CardZipChip
+			var err error
+			c.maxSpeed, err = paramsGetSpeed(params, "speed")
+			if err != nil {
+				return nil, err
+			}
+			slots, err := paramsGetSlots(params, "slowslots")
+			if err != nil {
+				return nil, err
+			}
+			c.defaultSlowdowns = slots | zipChipSpeaker
+			c.reset()
+			return &c, nil
+		},
+	}
+}
+
+func (c *CardZipChip) reset() {
+	c.unlockCounter = 0
+	c.unlocked = false
+	c.disabled = false
+	c.slowdowns = c.defaultSlowdowns
+	c.speed = 0
+	c.paddles = 0
+	c.updateSpeed()
+}
+
+func (c *CardZipChip) assign(a *Apple2, slot int) {
+	// The annunciators are still available when the registers are locked
+	for i := 0; i < 6; i++ {
+		c.annunciatorsR[i] = a.io.softSwitchesR[0x5a+i]
+		c.annunciatorsW[i] = a.io.softSwitchesW[0x5a+i]
+	}
+
+	a.io.addSoftSwitchW(0x5a, func(value uint8) {
+		if !c.unlocked {
+			c.annunciatorW(0, value)
+			if value != zipChipUnlockToken {
+				c.unlockCounter = 0
+				return
+			}
+			c.unlockCounter++
+			if c.unlockCounter >= zipChipUnlockRepeats {
+				c.unlocked = true
+				c.unlockCounter = 0
+			}
+			return
+		}
+		switch value {
+		case zipChipLockToken:
+			c.unlocked = false
+		case zipChipUnlockToken:
+			// Already unlocked
+		default:
+			c.disabled = true
+			c.updateSpeed()
+		}
+	}, "ZIPCHIP-LOCK")
+
+	a.io.addSoftSwitchW(0x5b, func(value uint8) {
+		if !c.unlocked {
+			c.annunciatorW(1, value)
+			return
+		}
+		c.disabled = false
+		c.updateSpeed()
+	}, "ZIPCHIP-ENABLE")
+
+	a.io.addSoftSwitchR(0x5c, func() uint8 {
+		if !c.unlocked {
+			return c.annunciatorR(2)
+		}
+		return c.slowdowns
+	}, "ZIPCHIP-SLOWDOWNR")
+	a.io.addSoftSwitchW(0x5c, func(value uint8) {
+		if !c.unlocked {
+			c.annunciatorW(2, value)
+			return
+		}
+		c.slowdowns = value
+	}, "ZIPCHIP-SLOWDOWNW")
+
+	a.io.addSoftSwitchW(0x5d, func(value uint8) {
+		if !c.unlocked {
+			c.annunciatorW(3, value)
+			return
+		}
+		c.speed = value
+		c.updateSpeed()
+	}, "ZIPCHIP-SPEED")
+
+	a.io.addSoftSwitchR(0x5e, func() uint8 {
+		if !c.unlocked {
+			return c.annunciatorR(4)
+		}
+		if c.disabled {
+			return zipChipDisabled
+		}
+		return 0
+	}, "ZIPCHIP-STATUS")
+
+	a.io.addSoftSwitchR(0x5f, func() uint8 {
+		if !c.unlocked {
+			return c.annunciatorR(5)
+		}
+		return c.paddles
+	}, "ZIPCHIP-PADDLESR")
+	a.io.addSoftSwitchW(0x5f, func(value uint8) {
+		if !c.unlocked {
+			c.annunciatorW(5, value)
+			return
+		}
+		c.paddles = value
+	}, "ZIPCHIP-PADDLESW")
+
+	c.cardBase.assign(a, slot)
+	a.setAccelerator(c)
+	c.updateSpeed()
+}
+
+func (c *CardZipChip) annunciatorR(i int) uint8 {
+	if c.annunciatorsR[i] == nil {
+		return 0
+	}
+	return c.annunciatorsR[i]()
+}
+
+func (c *CardZipChip) annunciatorW(i int, value uint8) {
+	if c.annunciatorsW[i] != nil {
+		c.annunciatorsW[i](value)
+	}
+}
+
+func (c *CardZipChip) currentSpeed() float64 {
+	if c.disabled {
+		return 0
+	}
+	fraction := float64(4-(c.speed>>6)) / 4
+	speed := c.maxSpeed * fraction
+	if speed <= CPUClockMhz {
+		return 0
+	}
+	return speed
+}
+
+func (c *CardZipChip) updateSpeed() {
+	if c.a != nil {
+		c.a.setAcceleratorSpeed(c.currentSpeed())
+	}
+}
+
+func (c *CardZipChip) ioAccess(address uint8) {
+	speaker := c.slowdowns&zipChipSpeaker != 0
+	paddles := c.paddles&zipChipNoPaddles == 0
+	cycles := acceleratorSlowdown(address, speaker, paddles, c.slowdowns&^zipChipSpeaker)
+	if cycles != 0 {
+		c.a.slowDownAccelerator(cycles)
+	}
+}
@@ -0,0 +1,108 @@
+package izapple2
+
+import (
+	"math"
+	"testing"
+)
+
+// timingLoopCount runs a loop counting the iterations during a vertical
+// blanking, as the software measuring the CPU speed does. With disk, each
+// iteration reads $C0EC of the Disk II on slot 6.
+func timingLoopCount(a *Apple2, disk bool) int {
+	address := uint8(0xec)
+	if !disk {
+		address = 0x00 // Read $C000 instead, with the same cycles
+	}
+	program := []uint8{
+		0xad, 0x19, 0xc0, // w1: LDA $C019
+		0x30, 0xfb, // BMI w1 ; Wait for the end of a blanking
+		0xad, 0x19, 0xc0, // w2: LDA $C019
+		0x10, 0xfb, // BPL w2 ; Wait for the start of the blanking
+		0xa2, 0x00, // LDX #$00
+		0xa0, 0x00, // LDY #$00
+		0xe8,       // count: INX
+		0xd0, 0x01, // BNE skip
+		0xc8,                // INY
+		0xad, address, 0xc0, // skip: LDA $C0xx
+		0xad, 0x19, 0xc0, // LDA $C019
+		0x30, 0xf4, // BMI count ; While in the blanking
+	}
+	for i, value := range program {
+		a.mmu.Poke(0x300+uint16(i), value)
+	}
+
+	a.cpu.SetPC(0x300)
+	end := 0x300 + uint16(len(program))
+	for pc, _ := a.cpu.GetPCAndSP(); pc != end; pc, _ = a.cpu.GetPCAndSP() {
+		a.executeInstruction()
+	}
+	_, regX, regY, _ := a.cpu.GetAXYP()
+	return int(regX) + int(regY)<<8
+}
+
+func TestZipChipRegisters(t *testing.T) {
+	at, err := makeApple2Tester("2enh", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	boardCount := float64(timingLoopCount(at.a, false))
+	boardCountDisk := float64(timingLoopCount(at.a, true))
+
+	overrides := newConfiguration()
+	overrides.set(confS3, "zipchip,speed=8,slowslots=6")
+	at, err = makeApple2Tester("2enh", overrides)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := at.a
+	io := a.io
+
+	expectSpeed := func(mhz float64, message string) {
+		t.Helper()
+		measured := float64(timingLoopCount(a, false)) / boardCount * CPUClockMhz
+		if math.Abs(measured-mhz)/mhz > 0.05 {
+			t.Errorf("%s: expected %v MHz, measured %.2f MHz", message, mhz, measured)
+		}
+	}
+
+	expectSpeed(8, "After the reset")
+
+	// Locked, the register is the annunciator
+	io.poke(0xc05d, 0x80)
+	expectSpeed(8, "The speed must not change with the registers locked")
+
+	for i := 0; i < zipChipUnlockRepeats; i++ {
+		io.poke(0xc05a, zipChipUnlockToken)
+	}
+	if io.peek(0xc05c) != 0x41 {
+		t.Errorf("Expected slowdowns on the speaker and slot 6, got $%02x", io.peek(0xc05c))
+	}
+
+	io.poke(0xc05d, 0x80)
+	expectSpeed(4, "Half speed")
+
+	io.poke(0xc05a, 0x00)
+	expectSpeed(CPUClockMhz, "Acceleration disabled")
+	if io.peek(0xc05e)&zipChipDisabled == 0 {
+		t.Errorf("Expected the acceleration disabled on the status")
+	}
+
+	io.poke(0xc05b, 0x00)
+	expectSpeed(4, "Enabled again")
+
+	// Slot 6 accesses slow down to the board speed
+	count := float64(timingLoopCount(a, true))
+	if math.Abs(count-boardCountDisk)/boardCountDisk > 0.05 {
+		t.Errorf("Expected the board speed accessing the Disk II, got %v iterations instead of %v",
+			count, boardCountDisk)
+	}
+	for a.isAcceleratorSlowedDown() {
+		timingLoopCount(a, false)
+	}
+	expectSpeed(4, "After the slowdown")
+
+	io.poke(0xc05a, zipChipLockToken)
+	if io.peek(0xc05e)&zipChipDisabled != 0 {
+		t.Errorf("Expected the annunciator after locking the registers")
+	}
+}
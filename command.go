@@ -23,6 +23,8 @@ const (
 	CommandPause
 	// CommandStart restarts the emulator
 	CommandStart
+	// CommandStopRecording completes the video being recorded
+	CommandStopRecording
 	// CommandComplex for commands that use a struct with parameters
 	CommandComplex
 )
@@ -38,6 +40,18 @@ type commandSimple struct {
 type commandLoadDisk struct {
 	drive int
 	path  string
+	done  chan error
+}
+
+type commandStartRecording struct {
+	filename   string
+	screenMode int
+	done       chan error
+}
+
+type commandRun struct {
+	cycles uint64
+	done   chan error
 }
 
 func (c *commandSimple) getId() int {
@@ -48,8 +62,20 @@ func (c *commandLoadDisk) getId() int {
 	return CommandComplex
 }
 
+func (c *commandStartRecording) getId() int {
+	return CommandComplex
+}
+
+func (c *commandRun) getId() int {
+	return CommandComplex
+}
+
 func (a *Apple2) queueCommand(c command) {
 	a.commandChannel <- c
+	select {
+	case a.commandQueued <- struct{}{}:
+	default:
+	}
 }
 
 // SendCommand enqueues a command to the emulator thread
@@ -66,6 +92,35 @@ func (a *Apple2) SendLoadDisk(drive int, path string) {
 	a.queueCommand(&c)
 }
 
+// LoadDisk inserts a disk on a removable media drive and waits until completed
+func (a *Apple2) LoadDisk(drive int, path string) error {
+	var c commandLoadDisk
+	c.drive = drive
+	c.path = path
+	c.done = make(chan error, 1)
+	a.queueCommand(&c)
+	return <-c.done
+}
+
+// SendStartRecording starts recording a video. The format depends on the
+// extension of the file: .gif, .png for APNG or .y4m with a .wav for the sound
+func (a *Apple2) SendStartRecording(filename string, screenMode int) {
+	var c commandStartRecording
+	c.filename = filename
+	c.screenMode = screenMode
+	a.queueCommand(&c)
+}
+
+// StartRecording starts recording a video and waits until the files are created
+func (a *Apple2) StartRecording(filename string, screenMode int) error {
+	var c commandStartRecording
+	c.filename = filename
+	c.screenMode = screenMode
+	c.done = make(chan error, 1)
+	a.queueCommand(&c)
+	return <-c.done
+}
+
 func (a *Apple2) executeCommand(command command) {
 	switch command.getId() {
 	case CommandToggleSpeed:
@@ -88,13 +143,29 @@ func (a *Apple2) executeCommand(command command) {
 		a.cpu.SetTrace(a.cpuTrace)
 	case CommandReset:
 		a.reset()
+	case CommandStopRecording:
+		err := a.stopRecording()
+		if err != nil {
+			fmt.Printf("Could not complete the recording\n%v\n", err)
+		}
 	case CommandComplex:
 		switch t := command.(type) {
 		case *commandLoadDisk:
 			err := a.changeDisk(t.drive, t.path)
-			if err != nil {
+			if t.done != nil {
+				t.done <- err
+			} else if err != nil {
 				fmt.Printf("Could no load file %v\n%v\n", t.path, err)
 			}
+		case *commandRun:
+			a.startRun(t.cycles, t.done)
+		case *commandStartRecording:
+			err := a.startRecording(t.filename, t.screenMode)
+			if t.done != nil {
+				t.done <- err
+			} else if err != nil {
+				fmt.Printf("Could not record to %v\n%v\n", t.filename, err)
+			}
 		}
 	}
 }
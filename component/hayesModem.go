@@ -0,0 +1,584 @@
+package component
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+Hayes compatible modem that dials TCP connections.
+
+See:
+	https://en.wikipedia.org/wiki/Hayes_command_set
+	https://github.com/FozzTexx/tcpser
+
+The dial command ATDT host:port opens a TCP connection, the port is 23
+if missing. The incoming connections on the listen port ring the modem,
+answered with ATA or automatically after the number of rings on S0.
+
+The telnet negotiation is handled by the modem: echo and suppress go
+ahead are accepted, the rest of the options are refused.
+
+Supported commands: A, D, E, H, I, O, Q, V, Z, Sn=v, Sn? and &F. The
+rest of the commands are accepted and ignored.
+*/
+
+// HayesModem is a virtual modem
+type HayesModem struct {
+	mutex sync.Mutex
+	now   func() time.Time // Replaceable for tests
+
+	registers [hayesRegisters]uint8
+	echo      bool
+	quiet     bool
+	verbose   bool
+	telnet    bool
+	dtr       bool
+
+	line        []uint8 // Command being typed
+	lastCommand string
+	toComputer  []uint8 // Command mode output: echo and results
+	fromRemote  []uint8 // Data received from the connection
+
+	conn        net.Conn
+	online      bool // Connected and in data mode, not in command mode
+	dialing     bool
+	incoming    net.Conn // Connection ringing, waiting to be answered
+	lastRing    time.Time
+	listener    net.Listener
+	lastData    time.Time
+	escapeCount int
+	telnetState []uint8 // Telnet command being received
+}
+
+const (
+	hayesRegisters      = 32
+	hayesRegAutoAnswer  = 0
+	hayesRegRingCount   = 1
+	hayesRegEscape      = 2
+	hayesRegCR          = 3
+	hayesRegLF          = 4
+	hayesRegBS          = 5
+	hayesRegWaitCarrier = 7
+	hayesRegGuardTime   = 12
+
+	hayesResultOK         = 0
+	hayesResultConnect    = 1
+	hayesResultRing       = 2
+	hayesResultNoCarrier  = 3
+	hayesResultError      = 4
+	hayesResultBusy       = 7
+	hayesDefaultPort      = "23"
+	hayesRingPeriod       = 2 * time.Second
+	hayesMaxRemoteBuffer  = 16 * 1024
+	hayesRemoteBufferWait = 10 * time.Millisecond
+
+	telnetIAC  = 255
+	telnetDONT = 254
+	telnetDO   = 253
+	telnetWONT = 252
+	telnetWILL = 251
+	telnetSB   = 250
+	telnetSE   = 240
+	telnetEcho = 1
+	telnetSGA  = 3
+)
+
+var hayesResults = map[int]string{
+	hayesResultOK:        "OK",
+	hayesResultConnect:   "CONNECT",
+	hayesResultRing:      "RING",
+	hayesResultNoCarrier: "NO CARRIER",
+	hayesResultError:     "ERROR",
+	hayesResultBusy:      "BUSY",
+}
+
+// NewHayesModem creates a modem. If the listen port is not empty, the
+// incoming connections on that port ring the modem.
+func NewHayesModem(listenPort string, telnet bool) (*HayesModem, error) {
+	var m HayesModem
+	m.now = time.Now
+	m.telnet = telnet
+	m.dtr = true
+	m.factoryReset()
+
+	if listenPort != "" {
+		listener, err := net.Listen("tcp", ":"+listenPort)
+		if err != nil {
+			return nil, err
+		}
+		m.listener = listener
+		go m.acceptConnections(listener)
+	}
+	return &m, nil
+}
+
+func (m *HayesModem) factoryReset() {
+	m.registers = [hayesRegisters]uint8{}
+	m.registers[hayesRegEscape] = '+'
+	m.registers[hayesRegCR] = '\r'
+	m.registers[hayesRegLF] = '\n'
+	m.registers[hayesRegBS] = 8
+	m.registers[hayesRegWaitCarrier] = 50 // Seconds
+	m.registers[hayesRegGuardTime] = 50   // 1/50ths of a second
+	m.echo = true
+	m.quiet = false
+	m.verbose = true
+}
+
+// Close hangs up and stops listening
+func (m *HayesModem) Close() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.hangUp()
+	if m.listener != nil {
+		m.listener.Close()
+		m.listener = nil
+	}
+}
+
+// Carrier returns true when there is a connection
+func (m *HayesModem) Carrier() bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.poll()
+	return m.conn != nil
+}
+
+// SetDTR hangs up the connection when the computer drops DTR
+func (m *HayesModem) SetDTR(dtr bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.dtr && !dtr && m.conn != nil {
+		m.hangUp()
+		m.result(hayesResultNoCarrier)
+	}
+	m.dtr = dtr
+}
+
+// Read returns the next byte for the computer, if available
+func (m *HayesModem) Read() (uint8, bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.poll()
+
+	if len(m.toComputer) > 0 {
+		value := m.toComputer[0]
+		m.toComputer = m.toComputer[1:]
+		return value, true
+	}
+	if m.online && len(m.fromRemote) > 0 {
+		value := m.fromRemote[0]
+		m.fromRemote = m.fromRemote[1:]
+		return value, true
+	}
+	return 0, false
+}
+
+// Write receives a byte from the computer
+func (m *HayesModem) Write(value uint8) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.poll()
+
+	if m.online {
+		m.writeOnline(value)
+	} else {
+		m.writeCommand(value)
+	}
+}
+
+func (m *HayesModem) guardTime() time.Duration {
+	return time.Duration(m.registers[hayesRegGuardTime]) * time.Second / 50
+}
+
+// poll processes the events that depend on the time
+func (m *HayesModem) poll() {
+	now := m.now()
+
+	// Escape sequence completed with the guard time after the three escape characters
+	if m.online && m.escapeCount == 3 && now.Sub(m.lastData) >= m.guardTime() {
+		m.online = false
+		m.escapeCount = 0
+		m.result(hayesResultOK)
+	}
+
+	// Rings for the incoming connection
+	if m.incoming != nil && now.Sub(m.lastRing) >= hayesRingPeriod {
+		m.lastRing = now
+		m.registers[hayesRegRingCount]++
+		m.result(hayesResultRing)
+		autoAnswer := m.registers[hayesRegAutoAnswer]
+		if autoAnswer > 0 && m.registers[hayesRegRingCount] >= autoAnswer {
+			m.answer()
+		}
+	}
+}
+
+func (m *HayesModem) writeOnline(value uint8) {
+	now := m.now()
+	guard := m.guardTime()
+	escape := m.registers[hayesRegEscape]
+	if value == escape && escape < 128 &&
+		((m.escapeCount == 0 && now.Sub(m.lastData) >= guard) ||
+			(m.escapeCount > 0 && m.escapeCount < 3 && now.Sub(m.lastData) < guard)) {
+		m.escapeCount++
+	} else {
+		m.escapeCount = 0
+	}
+	m.lastData = now
+
+	data := []uint8{value}
+	if m.telnet && value == telnetIAC {
+		data = append(data, telnetIAC)
+	}
+	m.conn.Write(data)
+}
+
+func (m *HayesModem) writeCommand(value uint8) {
+	value &= 0x7f
+	if m.echo {
+		m.toComputer = append(m.toComputer, value)
+	}
+
+	switch {
+	case value == m.registers[hayesRegCR]:
+		line := strings.ToUpper(strings.TrimSpace(string(m.line)))
+		m.line = nil
+		if strings.HasPrefix(line, "AT") {
+			m.lastCommand = line
+			m.command(line[2:])
+		}
+	case value == m.registers[hayesRegBS]:
+		if len(m.line) > 0 {
+			m.line = m.line[:len(m.line)-1]
+		}
+	case value == '/' && strings.ToUpper(string(m.line)) == "A":
+		// Repeat the last command
+		m.line = nil
+		if m.lastCommand != "" {
+			m.command(m.lastCommand[2:])
+		}
+	case value >= 0x20:
+		m.line = append(m.line, value)
+	}
+}
+
+func (m *HayesModem) result(code int) {
+	if m.quiet {
+		return
+	}
+	cr := m.registers[hayesRegCR]
+	lf := m.registers[hayesRegLF]
+	if m.verbose {
+		m.toComputer = append(m.toComputer, cr, lf)
+		m.toComputer = append(m.toComputer, hayesResults[code]...)
+		m.toComputer = append(m.toComputer, cr, lf)
+	} else {
+		m.toComputer = append(m.toComputer, strconv.Itoa(code)...)
+		m.toComputer = append(m.toComputer, cr)
+	}
+}
+
+func (m *HayesModem) info(text string) {
+	cr := m.registers[hayesRegCR]
+	lf := m.registers[hayesRegLF]
+	m.toComputer = append(m.toComputer, cr, lf)
+	m.toComputer = append(m.toComputer, text...)
+	m.toComputer = append(m.toComputer, cr, lf)
+}
+
+// command runs the commands after the AT prefix
+func (m *HayesModem) command(commands string) {
+	i := 0
+	number := func() int {
+		start := i
+		for i < len(commands) && commands[i] >= '0' && commands[i] <= '9' {
+			i++
+		}
+		n, _ := strconv.Atoi(commands[start:i])
+		return n
+	}
+
+	for i < len(commands) {
+		c := commands[i]
+		i++
+		switch c {
+		case ' ':
+			// Ignore
+		case 'A':
+			if m.incoming == nil {
+				m.result(hayesResultNoCarrier)
+			} else {
+				m.answer()
+			}
+			return
+		case 'D':
+			m.dial(commands[i:])
+			return
+		case 'E':
+			m.echo = number() != 0
+		case 'H':
+			number()
+			m.hangUp()
+		case 'I':
+			number()
+			m.info("izapple2 Hayes modem emulation")
+		case 'O':
+			number()
+			if m.conn == nil {
+				m.result(hayesResultNoCarrier)
+			} else {
+				m.online = true
+				m.result(hayesResultConnect)
+			}
+			return
+		case 'Q':
+			m.quiet = number() != 0
+		case 'V':
+			m.verbose = number() != 0
+		case 'Z':
+			number()
+			m.hangUp()
+			m.factoryReset()
+		case 'S':
+			register := number()
+			if register >= hayesRegisters {
+				m.result(hayesResultError)
+				return
+			}
+			if i < len(commands) && commands[i] == '=' {
+				i++
+				m.registers[register] = uint8(number())
+			} else if i < len(commands) && commands[i] == '?' {
+				i++
+				m.info(fmt.Sprintf("%03d", m.registers[register]))
+			}
+		case '&':
+			if i < len(commands) {
+				if commands[i] == 'F' {
+					m.factoryReset()
+				}
+				i++
+				number()
+			}
+		case '\\', '%':
+			// Extended commands, ignored
+			if i < len(commands) {
+				i++
+				number()
+			}
+		case 'B', 'C', 'L', 'M', 'N', 'P', 'T', 'W', 'X', 'Y':
+			// Accepted and ignored
+			number()
+		default:
+			m.result(hayesResultError)
+			return
+		}
+	}
+	m.result(hayesResultOK)
+}
+
+// dialAddress returns the host and port to connect for the dialed number
+func dialAddress(number string) string {
+	// Remove the tone or pulse modifier, the host can start with T or P
+	number = strings.TrimLeft(number, " ")
+	if strings.HasPrefix(number, "T") || strings.HasPrefix(number, "P") {
+		number = number[1:]
+	}
+	number = strings.TrimSpace(strings.TrimRight(number, ";"))
+	if number == "" {
+		return ""
+	}
+	if _, _, err := net.SplitHostPort(number); err != nil {
+		return net.JoinHostPort(number, hayesDefaultPort)
+	}
+	return number
+}
+
+func (m *HayesModem) dial(number string) {
+	address := dialAddress(number)
+	if address == "" {
+		m.result(hayesResultError)
+		return
+	}
+
+	m.hangUp()
+	m.dialing = true
+	timeout := time.Duration(m.registers[hayesRegWaitCarrier]) * time.Second
+	go func() {
+		conn, err := net.DialTimeout("tcp", address, timeout)
+
+		m.mutex.Lock()
+		defer m.mutex.Unlock()
+		if !m.dialing {
+			// Cancelled
+			if conn != nil {
+				conn.Close()
+			}
+			return
+		}
+		m.dialing = false
+		if err != nil {
+			if opError, ok := err.(*net.OpError); ok && !opError.Timeout() {
+				m.result(hayesResultBusy)
+			} else {
+				m.result(hayesResultNoCarrier)
+			}
+			return
+		}
+		m.connected(conn)
+	}()
+}
+
+func (m *HayesModem) answer() {
+	conn := m.incoming
+	m.incoming = nil
+	m.registers[hayesRegRingCount] = 0
+	m.connected(conn)
+}
+
+func (m *HayesModem) connected(conn net.Conn) {
+	m.conn = conn
+	m.online = true
+	m.escapeCount = 0
+	m.lastData = m.now()
+	m.fromRemote = nil
+	m.telnetState = nil
+	m.result(hayesResultConnect)
+	go m.receive(conn)
+}
+
+func (m *HayesModem) hangUp() {
+	m.dialing = false
+	m.online = false
+	if m.conn != nil {
+		m.conn.Close()
+		m.conn = nil
+	}
+	if m.incoming != nil {
+		m.incoming.Close()
+		m.incoming = nil
+	}
+	m.fromRemote = nil
+}
+
+func (m *HayesModem) acceptConnections(listener net.Listener) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+
+		m.mutex.Lock()
+		if m.conn != nil || m.incoming != nil || m.dialing {
+			// Busy
+			conn.Close()
+		} else {
+			m.incoming = conn
+			m.lastRing = time.Time{}
+			m.registers[hayesRegRingCount] = 0
+		}
+		m.mutex.Unlock()
+	}
+}
+
+func (m *HayesModem) receive(conn net.Conn) {
+	buffer := make([]uint8, 1024)
+	for {
+		n, err := conn.Read(buffer)
+
+		m.mutex.Lock()
+		if m.conn != conn {
+			// Hung up
+			m.mutex.Unlock()
+			return
+		}
+		for _, value := range buffer[:n] {
+			m.receiveByte(value)
+		}
+		if err != nil {
+			m.hangUp()
+			m.result(hayesResultNoCarrier)
+			m.mutex.Unlock()
+			return
+		}
+		full := len(m.fromRemote) > hayesMaxRemoteBuffer
+		m.mutex.Unlock()
+
+		for full {
+			// Wait for the computer to read the data
+			time.Sleep(hayesRemoteBufferWait)
+			m.mutex.Lock()
+			full = m.conn == conn && len(m.fromRemote) > hayesMaxRemoteBuffer
+			m.mutex.Unlock()
+		}
+	}
+}
+
+// receiveByte processes the data from the remote, with the telnet commands
+func (m *HayesModem) receiveByte(value uint8) {
+	if !m.telnet {
+		m.fromRemote = append(m.fromRemote, value)
+		return
+	}
+
+	if len(m.telnetState) == 0 {
+		if value == telnetIAC {
+			m.telnetState = []uint8{value}
+		} else {
+			m.fromRemote = append(m.fromRemote, value)
+		}
+		return
+	}
+
+	m.telnetState = append(m.telnetState, value)
+	command := m.telnetState[1]
+	switch {
+	case command == telnetIAC:
+		// Escaped 255
+		m.fromRemote = append(m.fromRemote, telnetIAC)
+		m.telnetState = nil
+	case command == telnetSB:
+		// Subnegotiation, skipped up to IAC SE
+		n := len(m.telnetState)
+		if n >= 4 && m.telnetState[n-2] == telnetIAC && value == telnetSE {
+			m.telnetState = nil
+		}
+	case command >= telnetWILL && command <= telnetDONT:
+		if len(m.telnetState) < 3 {
+			return
+		}
+		m.telnetNegotiate(command, value)
+		m.telnetState = nil
+	default:
+		// Other commands without options
+		m.telnetState = nil
+	}
+}
+
+func (m *HayesModem) telnetNegotiate(command uint8, option uint8) {
+	var response uint8
+	switch command {
+	case telnetWILL:
+		if option == telnetEcho || option == telnetSGA {
+			response = telnetDO
+		} else {
+			response = telnetDONT
+		}
+	case telnetDO:
+		if option == telnetSGA {
+			response = telnetWILL
+		} else {
+			response = telnetWONT
+		}
+	default:
+		// WONT and DONT need no answer
+		return
+	}
+	m.conn.Write([]uint8{telnetIAC, response, option})
+}
@@ -0,0 +1,139 @@
+package component
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func modemType(m *HayesModem, s string) {
+	for _, ch := range []uint8(s) {
+		m.Write(ch)
+	}
+}
+
+// modemExpect reads from the modem until the text is received
+func modemExpect(t *testing.T, m *HayesModem, expected string) {
+	t.Helper()
+	received := ""
+	deadline := time.Now().Add(5 * time.Second)
+	for !strings.Contains(received, expected) {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected '%q', received '%q'", expected, received)
+		}
+		value, ok := m.Read()
+		if ok {
+			received += string([]uint8{value})
+		} else {
+			time.Sleep(time.Millisecond)
+		}
+	}
+}
+
+func TestHayesModemCommands(t *testing.T) {
+	m, err := NewHayesModem("", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	modemType(m, "AT S0=2 S0?\r")
+	modemExpect(t, m, "002\r\n\r\nOK\r\n")
+	modemType(m, "ATE0V0\r")
+	modemExpect(t, m, "0\r")
+	modemType(m, "ATJ\r")
+	modemExpect(t, m, "4\r")
+	modemType(m, "ATO\r")
+	modemExpect(t, m, "3\r")
+}
+
+func TestHayesModemDial(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		// Telnet negotiation and echo
+		conn.Write([]uint8{telnetIAC, telnetWILL, telnetEcho, 'B', 'B', 'S', telnetIAC, telnetIAC})
+		buffer := make([]uint8, 100)
+		for {
+			n, err := conn.Read(buffer)
+			if err != nil {
+				return
+			}
+			conn.Write(buffer[:n])
+		}
+	}()
+
+	m, err := NewHayesModem("", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	clock := time.Now()
+	m.now = func() time.Time { return clock }
+
+	modemType(m, "ATDT"+listener.Addr().String()+"\r")
+	modemExpect(t, m, "CONNECT\r\n")
+	modemExpect(t, m, "BBS\xff")
+	if !m.Carrier() {
+		t.Error("Carrier expected")
+	}
+
+	modemType(m, "HELLO")
+	modemExpect(t, m, "HELLO") // The echoed telnet answer is filtered
+
+	// Escape to command mode with the guard times
+	clock = clock.Add(2 * time.Second)
+	modemType(m, "+++")
+	clock = clock.Add(2 * time.Second)
+	modemExpect(t, m, "OK\r\n")
+	modemType(m, "ATH\r")
+	modemExpect(t, m, "OK\r\n")
+	if m.Carrier() {
+		t.Error("No carrier expected after hang up")
+	}
+}
+
+func TestHayesModemDialAddress(t *testing.T) {
+	cases := map[string]string{
+		"T TELEHACK.COM":      "TELEHACK.COM:23",
+		"TPOLARBBS":           "POLARBBS:23",
+		"PTELEHACK.COM:6400;": "TELEHACK.COM:6400",
+		" bbs.example.com":    "bbs.example.com:23",
+		"T":                   "",
+	}
+	for number, expected := range cases {
+		if address := dialAddress(number); address != expected {
+			t.Errorf("Dialing '%s' expected '%s', got '%s'", number, expected, address)
+		}
+	}
+}
+
+func TestHayesModemAutoAnswer(t *testing.T) {
+	m, err := NewHayesModem("0", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	modemType(m, "ATS0=1\r")
+	modemExpect(t, m, "OK\r\n")
+
+	conn, err := net.Dial("tcp", m.listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	modemExpect(t, m, "RING\r\n\r\nCONNECT\r\n")
+
+	conn.Write([]uint8("CALLER"))
+	modemExpect(t, m, "CALLER")
+	conn.Close()
+	modemExpect(t, m, "NO CARRIER")
+}
@@ -0,0 +1,417 @@
+package component
+
+import (
+	"fmt"
+)
+
+/*
+NCR 5380 SCSI bus controller, as an initiator.
+
+See:
+	NCR 5380 Family SCSI Protocol Controller Data Manual.
+	http://bitsavers.org/components/ncr/scsi/5380_Family_Data_Manual_Aug85.pdf
+
+The SCSI bus is modeled at the signal level as seen by the initiator:
+arbitration, selection with or without ATN and the REQ/ACK handshake of
+the information transfer phases. The targets answer immediately, there
+is no timing. The pseudo DMA transfers are done with DmaRead and
+DmaWrite, with the handshake done by the controller.
+*/
+
+// Ncr5380 is a SCSI bus controller with the targets connected
+type Ncr5380 struct {
+	targets [8]ScsiTarget
+	trace   bool
+
+	// Registers
+	outputData       uint8
+	initiatorCommand uint8
+	mode             uint8
+	targetCommand    uint8
+	selectEnable     uint8
+	dma              bool
+	interrupt        bool
+
+	// Bus state, driven by the selected target
+	target    ScsiTarget
+	busy      bool
+	selecting bool
+	request   bool
+	phase     uint8
+	data      []uint8
+	position  int
+	cdb       []uint8
+	status    uint8
+	message   uint8
+}
+
+// ScsiTarget is a device on the SCSI bus
+type ScsiTarget interface {
+	// Command executes a command. It returns the data for the data in phase,
+	// or the length of the data expected on the data out phase, and the status.
+	Command(cdb []uint8) (dataIn []uint8, dataOutLength int, status uint8)
+	// DataOut completes a command with the data received, it returns the status.
+	DataOut(cdb []uint8, data []uint8) uint8
+}
+
+const (
+	// Initiator command register
+	ncr5380IcrRst      = uint8(0x80)
+	ncr5380IcrAip      = uint8(0x40) // Arbitration in progress, on read
+	ncr5380IcrAck      = uint8(0x10)
+	ncr5380IcrBsy      = uint8(0x08)
+	ncr5380IcrSel      = uint8(0x04)
+	ncr5380IcrAtn      = uint8(0x02)
+	ncr5380IcrDataBus  = uint8(0x01)
+	ncr5380IcrWritable = uint8(0x9f)
+
+	// Mode register
+	ncr5380ModeArbitrate = uint8(0x01)
+	ncr5380ModeDma       = uint8(0x02)
+
+	// Current bus status register
+	ncr5380BusRst = uint8(0x80)
+	ncr5380BusBsy = uint8(0x40)
+	ncr5380BusReq = uint8(0x20)
+	ncr5380BusSel = uint8(0x02)
+
+	// Bus and status register
+	ncr5380StatusEndOfDma   = uint8(0x80)
+	ncr5380StatusDmaRequest = uint8(0x40)
+	ncr5380StatusIrq        = uint8(0x10)
+	ncr5380StatusPhaseMatch = uint8(0x08)
+	ncr5380StatusAtn        = uint8(0x02)
+	ncr5380StatusAck        = uint8(0x01)
+)
+
+// SCSI phases with the MSG, C/D and I/O bits as on the current bus status register
+const (
+	scsiPhaseDataOut    = uint8(0x00)
+	scsiPhaseDataIn     = uint8(0x04)
+	scsiPhaseCommand    = uint8(0x08)
+	scsiPhaseStatus     = uint8(0x0c)
+	scsiPhaseMessageOut = uint8(0x18)
+	scsiPhaseMessageIn  = uint8(0x1c)
+	scsiPhaseIO         = uint8(0x04)
+	scsiPhaseMask       = uint8(0x1c)
+)
+
+const (
+	scsiMessageCommandComplete = uint8(0x00)
+	scsiMessageAbort           = uint8(0x06)
+	scsiMessageDeviceReset     = uint8(0x0c)
+	scsiMessageIdentify        = uint8(0x80)
+)
+
+// NewNcr5380 returns a new NCR 5380 without targets
+func NewNcr5380() *Ncr5380 {
+	return &Ncr5380{}
+}
+
+// SetTarget connects a target on the SCSI ID
+func (n *Ncr5380) SetTarget(id int, target ScsiTarget) {
+	n.targets[id] = target
+}
+
+// SetTrace enables the trace of the SCSI commands
+func (n *Ncr5380) SetTrace(trace bool) {
+	n.trace = trace
+}
+
+// Reset resets the chip and the SCSI bus
+func (n *Ncr5380) Reset() {
+	n.outputData = 0
+	n.initiatorCommand = 0
+	n.mode = 0
+	n.targetCommand = 0
+	n.selectEnable = 0
+	n.dma = false
+	n.interrupt = false
+	n.busFree()
+}
+
+// Read returns the value of one of the eight registers
+func (n *Ncr5380) Read(register uint8) uint8 {
+	switch register & 0x07 {
+	case 0, 6: // Current SCSI data, input data
+		return n.currentData()
+	case 1: // Initiator command
+		value := n.initiatorCommand
+		if n.mode&ncr5380ModeArbitrate != 0 && !n.busy {
+			value |= ncr5380IcrAip
+		}
+		return value
+	case 2: // Mode
+		return n.mode
+	case 3: // Target command
+		return n.targetCommand
+	case 4: // Current SCSI bus status
+		value := uint8(0)
+		if n.initiatorCommand&ncr5380IcrRst != 0 {
+			value |= ncr5380BusRst
+		}
+		if n.busy || n.initiatorCommand&ncr5380IcrBsy != 0 {
+			value |= ncr5380BusBsy
+		}
+		if n.busy && !n.selecting {
+			value |= n.phase
+			if n.request {
+				value |= ncr5380BusReq
+			}
+		}
+		if n.initiatorCommand&ncr5380IcrSel != 0 {
+			value |= ncr5380BusSel
+		}
+		return value
+	case 5: // Bus and status
+		value := uint8(0)
+		if n.dma && !n.phaseMatch() {
+			value |= ncr5380StatusEndOfDma
+		}
+		if n.Drq() {
+			value |= ncr5380StatusDmaRequest
+		}
+		if n.interrupt {
+			value |= ncr5380StatusIrq
+		}
+		if n.phaseMatch() {
+			value |= ncr5380StatusPhaseMatch
+		}
+		if n.initiatorCommand&ncr5380IcrAtn != 0 {
+			value |= ncr5380StatusAtn
+		}
+		if n.initiatorCommand&ncr5380IcrAck != 0 {
+			value |= ncr5380StatusAck
+		}
+		return value
+	default: // 7, reset parity and interrupts
+		n.interrupt = false
+		return 0
+	}
+}
+
+// Write sets the value of one of the eight registers
+func (n *Ncr5380) Write(register uint8, value uint8) {
+	switch register & 0x07 {
+	case 0: // Output data
+		n.outputData = value
+	case 1: // Initiator command
+		n.writeInitiatorCommand(value)
+	case 2: // Mode
+		n.mode = value
+		if value&ncr5380ModeDma == 0 {
+			n.dma = false
+		}
+	case 3: // Target command
+		n.targetCommand = value
+	case 4: // Select enable
+		n.selectEnable = value
+	case 5, 7: // Start DMA send, start DMA initiator receive
+		n.dma = n.mode&ncr5380ModeDma != 0
+	case 6: // Start DMA target receive, not supported as there is no target mode
+	}
+}
+
+// Drq returns true when a byte is ready for a pseudo DMA transfer
+func (n *Ncr5380) Drq() bool {
+	return n.dma && n.busy && !n.selecting && n.request && n.phaseMatch()
+}
+
+// DmaRead reads a byte with the handshake done by the controller
+func (n *Ncr5380) DmaRead() uint8 {
+	if !n.Drq() || n.phase&scsiPhaseIO == 0 {
+		return 0
+	}
+	value := n.currentData()
+	n.transfer()
+	n.advance()
+	return value
+}
+
+// DmaWrite writes a byte with the handshake done by the controller
+func (n *Ncr5380) DmaWrite(value uint8) {
+	n.outputData = value
+	if !n.Drq() || n.phase&scsiPhaseIO != 0 {
+		return
+	}
+	n.transfer()
+	n.advance()
+}
+
+func (n *Ncr5380) currentData() uint8 {
+	if n.busy && n.request && n.phase&scsiPhaseIO != 0 && n.position < len(n.data) {
+		return n.data[n.position]
+	}
+	if n.initiatorCommand&ncr5380IcrDataBus != 0 || n.mode&ncr5380ModeArbitrate != 0 {
+		return n.outputData
+	}
+	return 0
+}
+
+func (n *Ncr5380) phaseMatch() bool {
+	return n.busy && !n.selecting && n.phase == (n.targetCommand&0x07)<<2
+}
+
+func (n *Ncr5380) writeInitiatorCommand(value uint8) {
+	previous := n.initiatorCommand
+	n.initiatorCommand = value & ncr5380IcrWritable
+
+	if value&ncr5380IcrRst != 0 {
+		n.busFree()
+		n.dma = false
+		return
+	}
+
+	if !n.busy && value&ncr5380IcrSel != 0 && value&ncr5380IcrBsy == 0 &&
+		value&ncr5380IcrDataBus != 0 {
+		n.selection()
+	}
+
+	if n.selecting && value&ncr5380IcrSel == 0 {
+		n.selecting = false
+		if value&ncr5380IcrAtn != 0 {
+			n.startPhase(scsiPhaseMessageOut)
+		} else {
+			n.startPhase(scsiPhaseCommand)
+		}
+		return
+	}
+
+	ackChanged := (previous^value)&ncr5380IcrAck != 0
+	if n.busy && !n.selecting && ackChanged {
+		if value&ncr5380IcrAck != 0 {
+			if n.request {
+				n.transfer()
+			}
+		} else if !n.request {
+			n.advance()
+		}
+	}
+}
+
+// selection selects the target with its ID bit on the data bus
+func (n *Ncr5380) selection() {
+	for id, target := range n.targets {
+		if target != nil && n.outputData&(1<<id) != 0 {
+			n.target = target
+			n.busy = true
+			n.selecting = true
+			n.cdb = nil
+			return
+		}
+	}
+}
+
+func (n *Ncr5380) busFree() {
+	n.target = nil
+	n.busy = false
+	n.selecting = false
+	n.request = false
+}
+
+func (n *Ncr5380) startPhase(phase uint8) {
+	if n.dma && (n.targetCommand&0x07)<<2 != phase {
+		// The phase mismatch ends the DMA transfer
+		n.interrupt = true
+	}
+	n.phase = phase
+	n.position = 0
+	n.request = true
+	switch phase {
+	case scsiPhaseCommand:
+		n.cdb = nil
+	case scsiPhaseStatus:
+		n.data = []uint8{n.status}
+	case scsiPhaseMessageIn:
+		n.data = []uint8{scsiMessageCommandComplete}
+	}
+}
+
+// transfer moves the byte on the bus, the target releases REQ
+func (n *Ncr5380) transfer() {
+	switch n.phase {
+	case scsiPhaseMessageOut:
+		n.message = n.outputData
+	case scsiPhaseCommand:
+		n.cdb = append(n.cdb, n.outputData)
+	case scsiPhaseDataOut:
+		if n.position < len(n.data) {
+			n.data[n.position] = n.outputData
+		}
+		n.position++
+	default:
+		n.position++
+	}
+	n.request = false
+}
+
+// advance requests the next byte or moves to the next phase
+func (n *Ncr5380) advance() {
+	switch n.phase {
+	case scsiPhaseMessageOut:
+		if n.message == scsiMessageAbort || n.message == scsiMessageDeviceReset {
+			n.busFree()
+		} else if n.initiatorCommand&ncr5380IcrAtn != 0 {
+			n.request = true
+		} else {
+			n.startPhase(scsiPhaseCommand)
+		}
+
+	case scsiPhaseCommand:
+		if len(n.cdb) < scsiCommandLength(n.cdb[0]) {
+			n.request = true
+			return
+		}
+		dataIn, dataOutLength, status := n.target.Command(n.cdb)
+		if n.trace {
+			fmt.Printf("[NCR5380] Command % x, %v bytes in, %v bytes out, status $%02x.\n",
+				n.cdb, len(dataIn), dataOutLength, status)
+		}
+		n.status = status
+		if len(dataIn) > 0 {
+			n.data = dataIn
+			n.startPhase(scsiPhaseDataIn)
+		} else if dataOutLength > 0 {
+			n.data = make([]uint8, dataOutLength)
+			n.startPhase(scsiPhaseDataOut)
+		} else {
+			n.startPhase(scsiPhaseStatus)
+		}
+
+	case scsiPhaseDataIn:
+		if n.position < len(n.data) {
+			n.request = true
+		} else {
+			n.startPhase(scsiPhaseStatus)
+		}
+
+	case scsiPhaseDataOut:
+		if n.position < len(n.data) {
+			n.request = true
+		} else {
+			n.status = n.target.DataOut(n.cdb, n.data)
+			if n.trace {
+				fmt.Printf("[NCR5380] Data out of %v bytes, status $%02x.\n", len(n.data), n.status)
+			}
+			n.startPhase(scsiPhaseStatus)
+		}
+
+	case scsiPhaseStatus:
+		n.startPhase(scsiPhaseMessageIn)
+
+	case scsiPhaseMessageIn:
+		n.busFree()
+	}
+}
+
+// scsiCommandLength returns the size of the command descriptor block of the group
+func scsiCommandLength(opcode uint8) int {
+	switch opcode >> 5 {
+	case 1, 2:
+		return 10
+	case 5:
+		return 12
+	default:
+		return 6
+	}
+}
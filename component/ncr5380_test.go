@@ -0,0 +1,191 @@
+package component
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ivanizag/izapple2/storage"
+)
+
+func newTestScsiDisk(t *testing.T, blocks int, cdrom bool) storage.BlockDisk {
+	data := make([]uint8, blocks*int(storage.ProDosBlockSize))
+	for i := range data {
+		data[i] = uint8(i / int(storage.ProDosBlockSize))
+	}
+	filename := filepath.Join(t.TempDir(), "disk.img")
+	err := os.WriteFile(filename, data, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.OpenFile(filename, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { file.Close() })
+	var disk storage.BlockDisk
+	if cdrom {
+		disk, err = storage.NewBlockDiskCdrom(file)
+	} else {
+		disk, err = storage.NewBlockDiskFile(file, false)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return disk
+}
+
+// scsiSelect arbitrates and selects the target with ATN, then sends IDENTIFY
+func scsiSelect(t *testing.T, n *Ncr5380, id int) {
+	n.Write(0, 0x80) // Initiator ID 7
+	n.Write(2, ncr5380ModeArbitrate)
+	if n.Read(1)&ncr5380IcrAip == 0 {
+		t.Fatal("Arbitration not in progress")
+	}
+	n.Write(1, ncr5380IcrSel|ncr5380IcrBsy|ncr5380IcrDataBus)
+	n.Write(0, 0x80|1<<id)
+	n.Write(2, 0)
+	n.Write(1, ncr5380IcrSel|ncr5380IcrAtn|ncr5380IcrDataBus)
+	if n.Read(4)&ncr5380BusBsy == 0 {
+		t.Fatal("Target not selected")
+	}
+	n.Write(1, ncr5380IcrAtn)
+
+	scsiWaitPhase(t, n, scsiPhaseMessageOut)
+	n.Write(1, 0) // Release ATN before the last message byte
+	scsiSendByte(n, scsiMessageIdentify)
+}
+
+func scsiWaitPhase(t *testing.T, n *Ncr5380, phase uint8) {
+	t.Helper()
+	status := n.Read(4)
+	if status&ncr5380BusReq == 0 || status&scsiPhaseMask != phase {
+		t.Fatalf("Phase $%02x expected, bus status is $%02x", phase, status)
+	}
+	n.Write(3, phase>>2)
+}
+
+func scsiSendByte(n *Ncr5380, value uint8) {
+	n.Write(0, value)
+	n.Write(1, ncr5380IcrDataBus)
+	n.Write(1, ncr5380IcrDataBus|ncr5380IcrAck)
+	n.Write(1, 0)
+}
+
+func scsiReceiveByte(n *Ncr5380) uint8 {
+	value := n.Read(0)
+	n.Write(1, ncr5380IcrAck)
+	n.Write(1, 0)
+	return value
+}
+
+// scsiCommand runs a command with the data phases on pseudo DMA, returns the status
+func scsiCommand(t *testing.T, n *Ncr5380, id int, cdb []uint8, dataOut []uint8) ([]uint8, uint8) {
+	scsiSelect(t, n, id)
+	scsiWaitPhase(t, n, scsiPhaseCommand)
+	for _, value := range cdb {
+		scsiSendByte(n, value)
+	}
+
+	var dataIn []uint8
+	status := n.Read(4)
+	phase := status & scsiPhaseMask
+	if phase == scsiPhaseDataIn || phase == scsiPhaseDataOut {
+		n.Write(3, phase>>2)
+		n.Write(2, ncr5380ModeDma)
+		n.Write(7, 0)
+		for n.Drq() {
+			if phase == scsiPhaseDataIn {
+				dataIn = append(dataIn, n.DmaRead())
+			} else {
+				n.DmaWrite(dataOut[0])
+				dataOut = dataOut[1:]
+			}
+		}
+		n.Write(2, 0)
+	}
+
+	scsiWaitPhase(t, n, scsiPhaseStatus)
+	result := scsiReceiveByte(n)
+	scsiWaitPhase(t, n, scsiPhaseMessageIn)
+	if scsiReceiveByte(n) != scsiMessageCommandComplete {
+		t.Error("Command complete message expected")
+	}
+	if n.Read(4)&ncr5380BusBsy != 0 {
+		t.Error("Bus free expected")
+	}
+	return dataIn, result
+}
+
+func TestScsiDisk(t *testing.T) {
+	n := NewNcr5380()
+	n.SetTarget(2, NewScsiDisk(newTestScsiDisk(t, 64, false), false))
+
+	data, status := scsiCommand(t, n, 2, []uint8{scsiCommandInquiry, 0, 0, 0, 36, 0}, nil)
+	if status != scsiStatusGood || len(data) != 36 || data[0] != scsiTypeDirectAccess ||
+		string(data[8:16]) != "IZAPPLE2" {
+		t.Errorf("Unexpected inquiry %v, status %v", data, status)
+	}
+
+	data, _ = scsiCommand(t, n, 2, []uint8{scsiCommandReadCapacity, 0, 0, 0, 0, 0, 0, 0, 0, 0}, nil)
+	if !bytes.Equal(data, []uint8{0, 0, 0, 63, 0, 0, 2, 0}) {
+		t.Errorf("Unexpected capacity %v", data)
+	}
+
+	data, _ = scsiCommand(t, n, 2, []uint8{scsiCommandRead10, 0, 0, 0, 0, 5, 0, 0, 2, 0}, nil)
+	if len(data) != 1024 || data[0] != 5 || data[1023] != 6 {
+		t.Errorf("Unexpected data read")
+	}
+
+	block := bytes.Repeat([]uint8{0xaa}, 512)
+	_, status = scsiCommand(t, n, 2, []uint8{scsiCommandWrite6, 0, 0, 7, 1, 0}, block)
+	if status != scsiStatusGood {
+		t.Errorf("Write failed with status %v", status)
+	}
+	data, _ = scsiCommand(t, n, 2, []uint8{scsiCommandRead6, 0, 0, 7, 1, 0}, nil)
+	if !bytes.Equal(data, block) {
+		t.Errorf("The written block was not read back")
+	}
+
+	// Out of range, with the sense data
+	_, status = scsiCommand(t, n, 2, []uint8{scsiCommandRead10, 0, 0, 0, 0, 64, 0, 0, 1, 0}, nil)
+	if status != scsiStatusCheckCondition {
+		t.Errorf("Check condition expected, got %v", status)
+	}
+	data, _ = scsiCommand(t, n, 2, []uint8{scsiCommandRequestSense, 0, 0, 0, 18, 0}, nil)
+	if data[2] != scsiSenseIllegalRequest || data[12] != scsiAscOutOfRange {
+		t.Errorf("Unexpected sense data %v", data)
+	}
+}
+
+func TestScsiCdrom(t *testing.T) {
+	n := NewNcr5380()
+	n.SetTarget(3, NewScsiDisk(newTestScsiDisk(t, 64, true), true))
+
+	data, _ := scsiCommand(t, n, 3, []uint8{scsiCommandReadCapacity, 0, 0, 0, 0, 0, 0, 0, 0, 0}, nil)
+	if !bytes.Equal(data, []uint8{0, 0, 0, 15, 0, 0, 8, 0}) {
+		t.Errorf("Unexpected capacity %v", data)
+	}
+
+	_, status := scsiCommand(t, n, 3, []uint8{scsiCommandWrite6, 0, 0, 1, 1, 0}, nil)
+	if status != scsiStatusCheckCondition {
+		t.Errorf("Writes on CD-ROM must fail")
+	}
+
+	// Change to 512 bytes blocks
+	_, status = scsiCommand(t, n, 3, []uint8{scsiCommandModeSelect6, 0x10, 0, 0, 12, 0},
+		[]uint8{0, 0, 0, 8, 0, 0, 0, 0, 0, 0, 2, 0})
+	if status != scsiStatusGood {
+		t.Errorf("Mode select failed with status %v", status)
+	}
+	data, _ = scsiCommand(t, n, 3, []uint8{scsiCommandRead6, 0, 0, 9, 1, 0}, nil)
+	if len(data) != 512 || data[0] != 9 {
+		t.Errorf("Unexpected data read with 512 bytes blocks")
+	}
+
+	data, _ = scsiCommand(t, n, 3, []uint8{scsiCommandReadToc, 0, 0, 0, 0, 0, 0, 0, 20, 0}, nil)
+	if len(data) != 20 || data[3] != 1 || data[14] != scsiCdromLeadOutTrack || data[19] != 64 {
+		t.Errorf("Unexpected TOC %v", data)
+	}
+}
@@ -0,0 +1,322 @@
+package component
+
+import (
+	"encoding/binary"
+
+	"github.com/ivanizag/izapple2/storage"
+)
+
+/*
+SCSI direct access device, a hard disk, or a CD-ROM drive.
+
+See:
+	SCSI-2 specification, X3.131-1994. Sections 8, 9 and 13.
+
+The hard disk has 512 bytes blocks. The CD-ROM has 2048 bytes blocks
+by default, with 512 bytes blocks after a MODE SELECT as some Apple II
+drivers use.
+*/
+
+// ScsiDisk is a SCSI target with a hard disk or a CD-ROM
+type ScsiDisk struct {
+	disk      storage.BlockDisk
+	cdrom     bool
+	blockSize uint32
+	senseKey  uint8
+	asc       uint8 // Additional sense code
+}
+
+const (
+	scsiStatusGood           = uint8(0x00)
+	scsiStatusCheckCondition = uint8(0x02)
+
+	scsiSenseNone           = uint8(0x00)
+	scsiSenseMediumError    = uint8(0x03)
+	scsiSenseIllegalRequest = uint8(0x05)
+	scsiSenseDataProtect    = uint8(0x07)
+
+	scsiAscInvalidCommand    = uint8(0x20)
+	scsiAscOutOfRange        = uint8(0x21)
+	scsiAscInvalidField      = uint8(0x24)
+	scsiAscWriteProtected    = uint8(0x27)
+	scsiAscUnrecoveredError  = uint8(0x11)
+	scsiTypeDirectAccess     = uint8(0x00)
+	scsiTypeCdrom            = uint8(0x05)
+	scsiInquiryRemovable     = uint8(0x80)
+	scsiModeSenseProtected   = uint8(0x80)
+	scsiBlockDescriptorSize  = 8
+	scsiModeParameterHeader  = 4
+	scsiCdromLeadOutTrack    = uint8(0xaa)
+	scsiCdromDataTrack       = uint8(0x14) // Q subchannel with the current position, data track
+	scsiCdromFramesPerSecond = 75
+	scsiCdromLeadInFrames    = 150
+)
+
+const (
+	scsiCommandTestUnitReady    = uint8(0x00)
+	scsiCommandRezeroUnit       = uint8(0x01)
+	scsiCommandRequestSense     = uint8(0x03)
+	scsiCommandFormatUnit       = uint8(0x04)
+	scsiCommandRead6            = uint8(0x08)
+	scsiCommandWrite6           = uint8(0x0a)
+	scsiCommandSeek6            = uint8(0x0b)
+	scsiCommandInquiry          = uint8(0x12)
+	scsiCommandModeSelect6      = uint8(0x15)
+	scsiCommandReserve          = uint8(0x16)
+	scsiCommandRelease          = uint8(0x17)
+	scsiCommandModeSense6       = uint8(0x1a)
+	scsiCommandStartStop        = uint8(0x1b)
+	scsiCommandPreventRemoval   = uint8(0x1e)
+	scsiCommandReadCapacity     = uint8(0x25)
+	scsiCommandRead10           = uint8(0x28)
+	scsiCommandWrite10          = uint8(0x2a)
+	scsiCommandSeek10           = uint8(0x2b)
+	scsiCommandVerify10         = uint8(0x2f)
+	scsiCommandReadToc          = uint8(0x43)
+	scsiCommandSynchronizeCache = uint8(0x35)
+)
+
+// NewScsiDisk returns a SCSI target for the block device
+func NewScsiDisk(disk storage.BlockDisk, cdrom bool) *ScsiDisk {
+	var d ScsiDisk
+	d.disk = disk
+	d.cdrom = cdrom
+	d.blockSize = storage.ProDosBlockSize
+	if cdrom {
+		d.blockSize = storage.CdromSectorSize
+	}
+	return &d
+}
+
+// Command executes a SCSI command
+func (d *ScsiDisk) Command(cdb []uint8) ([]uint8, int, uint8) {
+	switch cdb[0] {
+	case scsiCommandTestUnitReady, scsiCommandRezeroUnit, scsiCommandSeek6, scsiCommandSeek10,
+		scsiCommandReserve, scsiCommandRelease, scsiCommandStartStop, scsiCommandPreventRemoval,
+		scsiCommandVerify10, scsiCommandSynchronizeCache:
+		return nil, 0, d.good()
+
+	case scsiCommandRequestSense:
+		sense := make([]uint8, 18)
+		sense[0] = 0x70 // Current error, fixed format
+		sense[2] = d.senseKey
+		sense[7] = 10 // Additional length
+		sense[12] = d.asc
+		d.senseKey = scsiSenseNone
+		d.asc = 0
+		return allocation(sense, int(cdb[4])), 0, scsiStatusGood
+
+	case scsiCommandInquiry:
+		return allocation(d.inquiry(), int(cdb[4])), 0, d.good()
+
+	case scsiCommandReadCapacity:
+		capacity := make([]uint8, 8)
+		binary.BigEndian.PutUint32(capacity[0:], d.blocks()-1)
+		binary.BigEndian.PutUint32(capacity[4:], d.blockSize)
+		return capacity, 0, d.good()
+
+	case scsiCommandModeSense6:
+		return allocation(d.modeSense(), int(cdb[4])), 0, d.good()
+
+	case scsiCommandModeSelect6:
+		return nil, int(cdb[4]), scsiStatusGood
+
+	case scsiCommandFormatUnit:
+		if d.disk.IsReadOnly() {
+			return nil, 0, d.fail(scsiSenseDataProtect, scsiAscWriteProtected)
+		}
+		return nil, 0, d.good()
+
+	case scsiCommandRead6, scsiCommandRead10:
+		lba, count := transferBlocks(cdb)
+		if lba+count > d.blocks() || lba+count < lba {
+			return nil, 0, d.fail(scsiSenseIllegalRequest, scsiAscOutOfRange)
+		}
+		data, err := d.read(lba, count)
+		if err != nil {
+			return nil, 0, d.fail(scsiSenseMediumError, scsiAscUnrecoveredError)
+		}
+		return data, 0, d.good()
+
+	case scsiCommandWrite6, scsiCommandWrite10:
+		lba, count := transferBlocks(cdb)
+		if d.disk.IsReadOnly() {
+			return nil, 0, d.fail(scsiSenseDataProtect, scsiAscWriteProtected)
+		}
+		if lba+count > d.blocks() || lba+count < lba {
+			return nil, 0, d.fail(scsiSenseIllegalRequest, scsiAscOutOfRange)
+		}
+		return nil, int(count * d.blockSize), scsiStatusGood
+
+	case scsiCommandReadToc:
+		if !d.cdrom {
+			break
+		}
+		length := int(binary.BigEndian.Uint16(cdb[7:]))
+		return allocation(d.toc(cdb[1]&0x02 != 0), length), 0, d.good()
+	}
+
+	return nil, 0, d.fail(scsiSenseIllegalRequest, scsiAscInvalidCommand)
+}
+
+// DataOut completes the commands with data
+func (d *ScsiDisk) DataOut(cdb []uint8, data []uint8) uint8 {
+	switch cdb[0] {
+	case scsiCommandModeSelect6:
+		return d.modeSelect(data)
+
+	case scsiCommandWrite6, scsiCommandWrite10:
+		lba, _ := transferBlocks(cdb)
+		start := lba * d.blockSize / storage.ProDosBlockSize
+		for i := uint32(0); i*storage.ProDosBlockSize < uint32(len(data)); i++ {
+			err := d.disk.Write(start+i, data[i*storage.ProDosBlockSize:(i+1)*storage.ProDosBlockSize])
+			if err != nil {
+				return d.fail(scsiSenseMediumError, scsiAscUnrecoveredError)
+			}
+		}
+		return d.good()
+	}
+	return d.good()
+}
+
+func (d *ScsiDisk) good() uint8 {
+	d.senseKey = scsiSenseNone
+	d.asc = 0
+	return scsiStatusGood
+}
+
+func (d *ScsiDisk) fail(senseKey uint8, asc uint8) uint8 {
+	d.senseKey = senseKey
+	d.asc = asc
+	return scsiStatusCheckCondition
+}
+
+// blocks returns the number of logical blocks
+func (d *ScsiDisk) blocks() uint32 {
+	return d.disk.GetSizeInBlocks() / (d.blockSize / storage.ProDosBlockSize)
+}
+
+func (d *ScsiDisk) read(lba uint32, count uint32) ([]uint8, error) {
+	data := make([]uint8, 0, count*d.blockSize)
+	start := lba * d.blockSize / storage.ProDosBlockSize
+	end := (lba + count) * d.blockSize / storage.ProDosBlockSize
+	for block := start; block < end; block++ {
+		blockData, err := d.disk.Read(block)
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, blockData...)
+	}
+	return data, nil
+}
+
+func (d *ScsiDisk) inquiry() []uint8 {
+	data := make([]uint8, 36)
+	product := "VIRTUAL DISK"
+	if d.cdrom {
+		data[0] = scsiTypeCdrom
+		data[1] = scsiInquiryRemovable
+		product = "VIRTUAL CD-ROM"
+	} else {
+		data[0] = scsiTypeDirectAccess
+	}
+	data[2] = 2      // SCSI-2
+	data[3] = 2      // Response data format
+	data[4] = 36 - 5 // Additional length
+	copy(data[8:], padded("IZAPPLE2", 8))
+	copy(data[16:], padded(product, 16))
+	copy(data[32:], padded("1.0", 4))
+	return data
+}
+
+func (d *ScsiDisk) modeSense() []uint8 {
+	data := make([]uint8, scsiModeParameterHeader+scsiBlockDescriptorSize)
+	data[0] = uint8(len(data) - 1)
+	if d.disk.IsReadOnly() {
+		data[2] = scsiModeSenseProtected
+	}
+	data[3] = scsiBlockDescriptorSize
+	descriptor := data[scsiModeParameterHeader:]
+	blocks := d.blocks()
+	if blocks > 0xffffff {
+		blocks = 0xffffff
+	}
+	binary.BigEndian.PutUint32(descriptor[0:], blocks) // The density code on the first byte is 0
+	binary.BigEndian.PutUint32(descriptor[4:], d.blockSize)
+	return data
+}
+
+// modeSelect accepts a change of the block size on CD-ROMs
+func (d *ScsiDisk) modeSelect(data []uint8) uint8 {
+	if len(data) < scsiModeParameterHeader+scsiBlockDescriptorSize ||
+		data[3] < scsiBlockDescriptorSize {
+		return d.good()
+	}
+	descriptor := data[scsiModeParameterHeader:]
+	blockSize := binary.BigEndian.Uint32(descriptor[4:]) & 0xffffff
+	if blockSize == d.blockSize {
+		return d.good()
+	}
+	if !d.cdrom || (blockSize != storage.ProDosBlockSize && blockSize != storage.CdromSectorSize) {
+		return d.fail(scsiSenseIllegalRequest, scsiAscInvalidField)
+	}
+	d.blockSize = blockSize
+	return d.good()
+}
+
+// toc returns the table of contents of a CD-ROM with one data track
+func (d *ScsiDisk) toc(msf bool) []uint8 {
+	data := make([]uint8, 4+8*2)
+	binary.BigEndian.PutUint16(data[0:], uint16(len(data)-2))
+	data[2] = 1 // First track
+	data[3] = 1 // Last track
+
+	sectors := d.disk.GetSizeInBlocks() / (storage.CdromSectorSize / storage.ProDosBlockSize)
+	for i, track := range []struct {
+		number  uint8
+		address uint32
+	}{{1, 0}, {scsiCdromLeadOutTrack, sectors}} {
+		descriptor := data[4+8*i:]
+		descriptor[1] = scsiCdromDataTrack
+		descriptor[2] = track.number
+		if msf {
+			frames := track.address + scsiCdromLeadInFrames
+			descriptor[5] = uint8(frames / (60 * scsiCdromFramesPerSecond))
+			descriptor[6] = uint8(frames / scsiCdromFramesPerSecond % 60)
+			descriptor[7] = uint8(frames % scsiCdromFramesPerSecond)
+		} else {
+			binary.BigEndian.PutUint32(descriptor[4:], track.address*storage.CdromSectorSize/d.blockSize)
+		}
+	}
+	return data
+}
+
+// transferBlocks returns the logical block and the number of blocks of a read or write
+func transferBlocks(cdb []uint8) (uint32, uint32) {
+	if cdb[0] == scsiCommandRead6 || cdb[0] == scsiCommandWrite6 {
+		lba := uint32(cdb[1]&0x1f)<<16 | uint32(cdb[2])<<8 | uint32(cdb[3])
+		count := uint32(cdb[4])
+		if count == 0 {
+			count = 256
+		}
+		return lba, count
+	}
+	return binary.BigEndian.Uint32(cdb[2:]), uint32(binary.BigEndian.Uint16(cdb[7:]))
+}
+
+// allocation truncates the data to the allocation length of the command
+func allocation(data []uint8, length int) []uint8 {
+	if len(data) > length {
+		return data[:length]
+	}
+	return data
+}
+
+func padded(s string, length int) []uint8 {
+	data := make([]uint8, length)
+	for i := range data {
+		data[i] = ' '
+	}
+	copy(data, s)
+	return data
+}
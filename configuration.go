@@ -23,12 +23,16 @@ const (
 	confCharRom    = "charrom"
 	confCpu        = "cpu"
 	confSpeed      = "speed"
+	confVideo      = "video"
+	confPalette    = "palette"
+	confComposite  = "composite"
 	confRamworks   = "ramworks"
 	confNsc        = "nsc"
 	confTrace      = "trace"
 	confProfile    = "profile"
 	confShowConfig = "showConfig"
 	confForceCaps  = "forceCaps"
+	confDiskSounds = "diskSounds"
 	confRgb        = "rgb"
 	confRomx       = "romx"
 	confMods       = "mods"
@@ -194,6 +198,9 @@ func setupFlags(models *configurationModels, configuration *configuration) error
 		confCharRom:    "rom file for the character generator",
 		confCpu:        "cpu type, can be '6502' or '65c02'",
 		confSpeed:      "cpu speed in Mhz, can be 'ntsc', 'pal', 'full' or a decimal nunmber",
+		confVideo:      "video standard, can be 'ntsc' or 'pal'",
+		confPalette:    "palette file with the colours, like <internal>/palettes/iigs.pal, or 'default'",
+		confComposite:  "filters of the composite signal screen mode, like 'luma=4,chroma=8,saturation=0.8,hue=0', or 'default'",
 		confMods:       "comma separated list of mods applied to the board, available mods are 'shift', 'four-colors",
 		confRamworks:   "memory to use with RAMWorks card, max is 16384",
 		confNsc:        "add a DS1216 No-Slot-Clock on the main ROM (use 'main') or a slot ROM",
@@ -201,6 +208,7 @@ func setupFlags(models *configurationModels, configuration *configuration) error
 		confProfile:    "generate profile trace to analyse with pprof",
 		confShowConfig: "show the calculated configuration and exit",
 		confForceCaps:  "force all letters to be uppercased (no need for caps lock!)",
+		confDiskSounds: "play the mechanical sounds of the disk drives",
 		confRgb:        "emulate the RGB modes of the 80col RGB card for DHGR",
 		confRomx:       "emulate a RomX",
 		confS0:         "slot 0 configuration.",
@@ -213,7 +221,7 @@ func setupFlags(models *configurationModels, configuration *configuration) error
 		confS7:         "slot 7 configuration.",
 	}
 
-	boolParams := []string{confProfile, confShowConfig, confForceCaps, confRgb, confRomx}
+	boolParams := []string{confProfile, confShowConfig, confForceCaps, confDiskSounds, confRgb, confRomx}
 
 	for name, description := range paramDescription {
 		defaultValue, ok := configuration.getHas(name)
@@ -23,8 +23,8 @@ func TestConfigurationModel(t *testing.T) {
 		}
 
 		requiredFields := []string{
-			confRom, confCharRom, confCpu, confSpeed, confRamworks, confNsc,
-			confTrace, confProfile, confShowConfig, confForceCaps, confRgb, confRomx,
+			confRom, confCharRom, confCpu, confSpeed, confVideo, confPalette, confComposite, confRamworks, confNsc,
+			confTrace, confProfile, confShowConfig, confForceCaps, confDiskSounds, confRgb, confRomx,
 			confS0, confS1, confS2, confS3, confS4, confS5, confS6, confS7,
 		}
 		availabledModels := models.availableModels()
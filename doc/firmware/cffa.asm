@@ -0,0 +1,370 @@
+; Firmware of the CFFA card on $C800, see cardCffaFirmware.go. To update the
+; Go code after changing it:
+;   go run . cffa.asm ../../cardCffaFirmware.go cffaFirmware
+;
+; ProDOS zero page used by the driver
+cmd = $42
+unit = $43
+bufLo = $44
+bufHi = $45
+blkLo = $46
+blkHi = $47
+; $Cn of the card using the $C800 area, set by the slot page
+mslot = $07F8
+; Screen holes of the slot, the zero page is saved there on SmartPort calls
+hole0 = $0478
+hole1 = $04F8
+hole2 = $0578
+hole3 = $05F8
+hole4 = $0678
+hole5 = $06F8
+; ATA registers, indexed with the slot * 16
+dataHigh = $C080
+dataLow = $C088
+count = $C08A
+lba0 = $C08B
+lba8 = $C08C
+lba16 = $C08D
+head = $C08E
+status = $C08F
+command = $C08F
+        .org $C800
+; Boot: read block 0 of the first partition on $0800
+boot:   LDA #$01
+        STA cmd             ; READ
+        JSR getSlot
+        STX unit            ; Drive 1
+        LDA #$00
+        STA bufLo
+        STA blkLo
+        STA blkHi
+        LDA #$08
+        STA bufHi           ; Buffer on $0800
+        JSR entry
+        BCS bootFail
+        JSR getSlot         ; The boot code gets the slot * 16 on X
+        JMP $0801
+bootFail:
+        JMP $E000           ; No disk, go to BASIC
+; ProDOS driver entry, the drives 1 and 2 are the first two partitions
+entry:  JSR getSlot
+        LDA unit
+        ASL                 ; Drive bit to carry
+        LDA #$00
+        ROL
+        TAY                 ; Partition
+        LDA cmd
+        BNE notStatus
+        LDX #$FF            ; 65535 blocks
+        LDY #$FF
+        CLC
+        RTS
+notStatus:
+        CMP #$03
+        BCC block           ; READ or WRITE
+        BEQ success         ; FORMAT, nothing to do
+badCmd: LDA #$01
+        SEC
+        RTS
+success:
+        LDA #$00
+        CLC
+        RTS
+; Read or write the block blkLo, blkHi of the partition Y, 0 to 7, with
+; the buffer on bufLo, bufHi. A is 1 to read and 2 to write, X is the
+; slot * 16. Returns the error on A and carry.
+block:  PHA
+        JSR setup
+        PLA
+        LSR                 ; Carry set to read
+        BCC write
+        LDA #$20            ; READ SECTORS
+        STA command,X
+        JSR wait
+        BCS ioError
+        LDY #$00
+read1:  LDA dataLow,X       ; Latches the high byte
+        STA (bufLo),Y
+        INY
+        LDA dataHigh,X
+        STA (bufLo),Y
+        INY
+        BNE read1
+        INC bufHi
+read2:  LDA dataLow,X
+        STA (bufLo),Y
+        INY
+        LDA dataHigh,X
+        STA (bufLo),Y
+        INY
+        BNE read2
+        DEC bufHi           ; Restore the buffer address
+        JMP success
+write:  LDA #$30            ; WRITE SECTORS
+        STA command,X
+        JSR wait
+        BCS ioError
+        LDY #$00
+write1: INY
+        LDA (bufLo),Y
+        STA dataHigh,X      ; Latch for the high byte
+        DEY
+        LDA (bufLo),Y
+        STA dataLow,X       ; Writes the word
+        INY
+        INY
+        BNE write1
+        INC bufHi
+write2: INY
+        LDA (bufLo),Y
+        STA dataHigh,X
+        DEY
+        LDA (bufLo),Y
+        STA dataLow,X
+        INY
+        INY
+        BNE write2
+        DEC bufHi           ; Restore the buffer address
+        JSR wait
+        BCC success
+ioError:
+        LDA #$27            ; I/O error
+        SEC
+        RTS
+; Set the registers for one sector, the block blkLo, blkHi of the
+; partition Y. The partitions 4 to 7 are on the second device.
+setup:  LDA status,X
+        BMI setup           ; Wait while busy
+        LDA #$01
+        STA count,X
+        LDA blkLo
+        STA lba0,X
+        LDA blkHi
+        STA lba8,X
+        TYA
+        AND #$03
+        STA lba16,X         ; Partition on the device
+        TYA
+        AND #$04
+        ASL
+        ASL                 ; Device on bit 4
+        ORA #$E0            ; LBA mode
+        STA head,X
+        RTS
+; Wait while busy, carry set when the device is not ready or has an error
+wait:   LDA status,X
+        BMI wait
+        AND #$41            ; Ready and error bits
+        EOR #$40
+        CMP #$01
+        RTS
+; Return the slot * 16 on X
+getSlot:
+        LDA mslot
+        ASL
+        ASL
+        ASL
+        ASL
+        TAX
+        RTS
+; SmartPort entry, the command and the parameter list pointer follow the
+; JSR. Units 1 to 8 are the partitions.
+spEntry:
+        LDA mslot
+        AND #$07
+        TAY                 ; Slot for the screen holes
+        LDA cmd
+        STA hole0,Y
+        LDA unit
+        STA hole1,Y
+        LDA bufLo
+        STA hole2,Y
+        LDA bufHi
+        STA hole3,Y
+        LDA blkLo
+        STA hole4,Y
+        LDA blkHi
+        STA hole5,Y
+        PLA                 ; Return address, the last byte of the JSR
+        STA cmd
+        PLA
+        STA unit
+        CLC
+        LDA cmd
+        ADC #$03
+        TAX
+        LDA unit
+        ADC #$00
+        PHA                 ; Return past the command and the list pointer
+        TXA
+        PHA
+        PHP
+        SEI
+        CLD
+        LDY #$01
+        LDA (cmd),Y
+        PHA                 ; Command
+        INY
+        LDA (cmd),Y
+        TAX
+        INY
+        LDA (cmd),Y
+        STA unit
+        STX cmd             ; Parameter list
+        LDY #$06
+        LDA (cmd),Y
+        PHA                 ; Block bits 16 to 23
+        LDY #$05
+spCopy: LDA (cmd),Y
+        STA cmd,Y           ; Unit, buffer and block or status code
+        DEY
+        BNE spCopy
+        PLA
+        TAY
+        PLA
+        JSR spCommand
+        PHA
+        TXA
+        PHA
+        TYA
+        PHA
+        LDA mslot
+        AND #$07
+        TAY
+        LDA hole0,Y         ; Restore the zero page
+        STA cmd
+        LDA hole1,Y
+        STA unit
+        LDA hole2,Y
+        STA bufLo
+        LDA hole3,Y
+        STA bufHi
+        LDA hole4,Y
+        STA blkLo
+        LDA hole5,Y
+        STA blkHi
+        PLA
+        TAY
+        PLA
+        TAX
+        PLA
+        PLP
+        CMP #$01            ; Carry set on errors
+        RTS
+; Execute the SmartPort command on A, Y has the block bits 16 to 23.
+; Returns the error on A and the bytes transferred on X and Y.
+spCommand:
+        STA cmd
+        JSR getSlot
+        LDA unit
+        BNE spUnit
+        LDA cmd             ; The host has only STATUS
+        BNE spBadCmd
+        LDA blkLo
+        BNE badCode
+        LDY #$07
+        LDA #$00
+spHost: STA (bufLo),Y
+        DEY
+        BNE spHost
+        LDA #$08            ; Devices
+        STA (bufLo),Y
+        LDX #$08
+        LDA #$00
+        RTS
+badCode:
+        LDA #$21            ; Bad status code
+        RTS
+spUnit: CMP #$09
+        BCC spValid
+        LDA #$28            ; No device
+        RTS
+spValid:
+        LDA cmd
+        BEQ spStatus
+        CMP #$03
+        BCS spOther
+        CPY #$00
+        BNE badBlock
+        LDY unit
+        DEY                 ; Partition
+        JSR block
+        LDX #$00            ; 512 bytes
+        LDY #$02
+        RTS
+badBlock:
+        LDA #$2D            ; Bad block number
+        RTS
+spOther:
+        CMP #$06
+        BCS spBadCmd        ; Only FORMAT, CONTROL and INIT
+        LDA #$00
+        TAX
+        TAY
+        RTS
+spBadCmd:
+        LDA #$01            ; Bad command
+        RTS
+spStatus:
+        LDA blkLo
+        BEQ spStatus0
+        CMP #$03
+        BNE badCode
+        LDY #$18
+spDib:  LDA dib-4,Y
+        STA (bufLo),Y
+        DEY
+        CPY #$04
+        BCS spDib
+        JSR unitStatus
+        LDX #$19            ; 25 bytes
+        LDY #$00
+        LDA #$00
+        RTS
+spStatus0:
+        JSR unitStatus
+        LDX #$04            ; 4 bytes
+        LDY #$00
+        LDA #$00
+        RTS
+; Write the status and the blocks of the unit, it is offline when the
+; first block of the partition can't be verified
+unitStatus:
+        LDY unit
+        DEY
+        LDA #$00
+        STA blkLo
+        STA blkHi
+        JSR setup
+        LDA #$40            ; VERIFY SECTORS
+        STA command,X
+        JSR wait
+        LDY #$00
+        BCS offline
+        LDA #$F8            ; Block device, write, read, online and format
+        STA (bufLo),Y
+        LDA #$FF            ; 65535 blocks
+        INY
+        STA (bufLo),Y
+        INY
+        STA (bufLo),Y
+        BNE statusEnd
+offline:
+        LDA #$E8
+        STA (bufLo),Y
+        LDA #$00
+        INY
+        STA (bufLo),Y
+        INY
+        STA (bufLo),Y
+statusEnd:
+        LDA #$00
+        INY
+        STA (bufLo),Y
+        RTS
+; Device information block from the name length
+dib:    .byte $04
+        .byte $43, $46, $46, $41 ; CFFA
+        .byte $20, $20, $20, $20, $20, $20, $20, $20, $20, $20, $20, $20
+        .byte $02, $00      ; Hard disk
+        .byte $00, $01      ; Version
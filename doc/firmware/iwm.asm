@@ -0,0 +1,820 @@
+; Firmware of the IWM card on $C800, see cardIwmFirmware.go. To update the
+; Go code after changing it:
+;   go run . iwm.asm ../../cardIwmFirmware.go iwmFirmware
+;
+; ProDOS zero page used by the driver
+cmd = $42
+unit = $43
+bufLo = $44
+bufHi = $45
+blkLo = $46
+blkHi = $47
+; Card RAM
+buf = $CF00
+slot16 = $CFC0
+selVec = $CFC1
+drive = $CFC3
+track = $CFC4
+side = $CFC5
+sector = $CFC6
+spt = $CFC7
+per = $CFC8
+count = $CFC9
+cntHi = $CFCA
+remLo = $CFCB
+remHi = $CFCC
+addr = $CFCD
+tries = $CFD2
+twos = $CFD3
+va = $CFD4
+vb = $CFD5
+vc = $CFD6
+chk0 = $CFD7
+chk1 = $CFD8
+chk2 = $CFD9
+srcY = $CFDA
+srcPage = $CFDB
+dstY = $CFDC
+savedHi = $CFDD
+groups = $CFDE
+reg = $CFDF
+curTrack = $CFE0
+sides = $CFE2
+initDone = $CFE4
+dataSector = $CFE5
+saveY = $CFE6
+chk0Next = $CFE7
+dstPage = $CFE8
+spParams = $CFE9
+spCmd = $CFF0
+spCountLo = $CFF1
+spCountHi = $CFF2
+spErr = $CFF3
+saveZp = $CFF4
+spRet = $CFFA
+        .org $C800
+; Boot: read block 0 of drive 1 on $0800. X is the slot * 16 and Y is $Cn
+boot:   JSR init
+        LDA #$FF
+        STA curTrack        ; Unknown head positions
+        STA curTrack+1
+        LDA #$01
+        STA cmd             ; READ
+        STX unit            ; Drive 1
+        LDA #$00
+        STA bufLo
+        STA blkLo
+        STA blkHi
+        LDA #$08
+        STA bufHi           ; Buffer on $0800
+        JSR driver
+        BCS bootFail
+        LDX slot16
+        JMP $0801
+bootFail: JMP $E000         ; No disk, go to BASIC
+; ProDOS driver entry. X is the slot * 16 and Y is $Cn
+entry:  JSR init
+driver: PHP
+        SEI
+        CLD
+        JSR command
+        PLP
+        CMP #$01            ; Carry set on errors
+        RTS
+init:   STX slot16
+        STY selVec+1
+        LDA #$E0
+        STA selVec          ; The SEL latch routine is on $CnE0
+        LDA initDone
+        CMP #$A5
+        BEQ initEnd
+        LDA #$FF
+        STA curTrack
+        STA curTrack+1
+        LDA #$00
+        STA sides
+        STA sides+1
+        LDA #$A5
+        STA initDone
+initEnd: RTS
+command: LDA unit
+        ASL A               ; Drive on bit 7
+        LDA #$00
+        ROL A
+        STA drive
+        LDA cmd
+        BEQ doStatus
+        CMP #$01
+        BEQ doRead
+        CMP #$04
+        BCS badCmd
+        LDA #$2B            ; Write protected for WRITE and FORMAT
+        RTS
+badCmd: LDA #$01            ; Bad command
+        RTS
+doStatus: JSR start
+        BCS fail
+        JSR getSides
+        BCS ioFail
+        LDY drive
+        LDA sides,Y
+        LDX #$20            ; 800 blocks
+        LDY #$03
+        CMP #$02
+        BNE status1
+        LDX #$40            ; 1600 blocks
+        LDY #$06
+status1: STX remLo
+        STY remHi
+        LDA #$00
+        JSR stop
+        LDX remLo
+        LDY remHi
+        RTS
+doRead: JSR start
+        BCS fail
+        JSR getSides
+        BCS ioFail
+        JSR mapBlock
+        BCS badBlock
+        JSR seek
+        BCS ioFail
+        LDA #$04
+        STA tries
+readTry: LDA side
+        ASL A
+        ASL A
+        ASL A
+        ORA #$04            ; Read data on the head for the side
+        JSR setRegister
+        JSR findSector
+        BCS readRetry
+        JSR readData
+        BCS readRetry
+        JSR decode
+        BCS readRetry
+        LDA #$00
+        JMP stop
+readRetry: DEC tries
+        BNE readTry
+ioFail: LDA #$27            ; I/O error
+fail:   JMP stop
+badBlock: LDA #$2D          ; Invalid block
+        JMP stop
+; Set the mode, select the drive and turn the motor on. Error on A with carry set
+start:  LDX slot16
+        LDA $C08D,X         ; Q6H
+        LDA $C08E,X         ; Q7L, status register
+        AND #$1F
+        CMP #$0B
+        BEQ startMode
+        LDA #$0B            ; Fast, asynchronous and latched
+        STA $C08F,X         ; Q7H, mode register with the motor off
+        LDA $C08E,X         ; Q7L
+startMode: LDA $C08C,X      ; Q6L
+        LDA $C089,X         ; Motor line on
+        LDA drive
+        BNE startDrive2
+        LDA $C08A,X         ; Drive 1
+        JMP startDisk
+startDrive2: LDA $C08B,X    ; Drive 2
+startDisk: LDA #$08         ; No disk
+        JSR sense
+        BPL startSwitched
+        LDA #$2F            ; Device offline
+        JSR stop
+        SEC
+        RTS
+startSwitched: LDA #$03     ; Disk switched
+        JSR sense
+        BPL startMotor
+        LDA #$0C            ; Reset the switched flag
+        JSR control
+        LDY drive
+        LDA #$00
+        STA sides,Y         ; Sides unknown
+startMotor: LDA #$02        ; Motor on
+        JSR control
+        LDY #$00
+        STY count
+startReady: LDA #$0E        ; Not ready
+        JSR sense
+        BPL startEnd
+        DEY
+        BNE startReady
+        DEC count
+        BNE startReady
+        LDA #$2F
+        JSR stop
+        SEC
+        RTS
+startEnd: CLC
+        RTS
+; Turn the motor off keeping A
+stop:   PHA
+        LDA #$06            ; Motor off
+        JSR control
+        CMP $C088,X         ; Motor line off
+        PLA
+        RTS
+; Read the sense line of the register in A. Returns it on N
+sense:  JSR setRegister
+        LDA $C08D,X         ; Q6H
+        LDA $C08E,X         ; Q7L, status register
+        CMP $C08C,X         ; Q6L
+        ORA #$00
+        RTS
+; Send the command in A, the value is on CA2
+control: JSR setRegister
+        CMP $C087,X         ; LSTRB on
+        CMP $C086,X         ; LSTRB off
+        RTS
+; Set CA0, CA1, CA2 and SEL from bits 0 to 3 of A. Keeps Y, X is slot * 16
+setRegister: LDX slot16
+        STA reg
+        LSR reg
+        BCC setCA0
+        INX
+setCA0: CMP $C080,X         ; CA0
+        LDX slot16
+        LSR reg
+        BCC setCA1
+        INX
+setCA1: CMP $C082,X         ; CA1
+        LDX slot16
+        LSR reg
+        BCC setCA2
+        INX
+setCA2: CMP $C084,X         ; CA2
+        LDX slot16
+        LDA #$00
+        LSR reg
+        ROR A               ; SEL on bit 7
+        JMP (selVec)
+; Get the sides from the format of an address field
+getSides: LDY drive
+        LDA sides,Y
+        BNE getSidesEnd
+        LDA #$04            ; Read data on the lower head
+        JSR setRegister
+        JSR readAddress
+        BCS getSidesFail
+        LDY addr+3
+        LDA untab-$80,Y     ; Format, double sided on bit 5
+        CMP #$40
+        BCS getSidesFail
+        LDY drive
+        AND #$20
+        BEQ getSidesOne
+        LDA #$02
+        STA sides,Y
+        CLC
+        RTS
+getSidesOne: LDA #$01
+        STA sides,Y
+getSidesEnd: CLC
+getSidesFail: RTS
+; Get track, side and sector of the block
+mapBlock: LDA blkLo
+        STA remLo
+        LDA blkHi
+        STA remHi
+        LDA #$00
+        STA track
+mapTrack: LDA track
+        LSR A
+        LSR A
+        LSR A
+        LSR A
+        STA spt
+        LDA #12
+        SEC
+        SBC spt
+        STA spt             ; 12 - zone sectors per track
+        LDY drive
+        LDA sides,Y
+        CMP #$02
+        LDA spt
+        BCC mapSides
+        ASL A
+mapSides: STA per           ; Blocks per track
+        LDA remHi
+        BNE mapNext
+        LDA remLo
+        CMP per
+        BCC mapFound
+mapNext: LDA remLo
+        SEC
+        SBC per
+        STA remLo
+        LDA remHi
+        SBC #$00
+        STA remHi
+        INC track
+        LDA track
+        CMP #80
+        BCC mapTrack
+        RTS
+mapFound: LDA #$00
+        STA side
+        LDA remLo
+        CMP spt
+        BCC mapSector
+        SBC spt
+        INC side
+mapSector: STA sector
+        CLC
+        RTS
+; Move the head to the track
+seek:   LDY drive
+        LDA curTrack,Y
+        CMP #$FF
+        BNE seekKnown
+        LDA #$04            ; Direction to track 0
+        JSR control
+        LDA #90
+        STA count
+seekRecal: LDA #$0A         ; Not on track 0
+        JSR sense
+        BPL seekTrack0
+        LDA #$01            ; Step
+        JSR control
+        DEC count
+        BNE seekRecal
+        SEC
+        RTS
+seekTrack0: LDA #$00
+        STA curTrack,Y
+seekKnown: LDA track
+        SEC
+        SBC curTrack,Y
+        BEQ seekEnd
+        BCS seekIn
+        EOR #$FF
+        ADC #$01
+        STA count
+        LDA #$04            ; Direction to track 0
+        BNE seekDirection
+seekIn: STA count
+        LDA #$00            ; Direction to higher tracks
+seekDirection: JSR control
+seekStep: LDA #$01          ; Step
+        JSR control
+        DEC count
+        BNE seekStep
+        LDA track
+        STA curTrack,Y
+seekEnd: CLC
+        RTS
+; Read the nibbles of an address field, with a timeout of about 400ms
+readAddress: LDA #$80
+        STA cntHi
+        LDY #$00
+addrNext: DEY
+        BNE addrPoll
+        DEC cntHi
+        BEQ addrTimeout
+addrPoll: LDA $C08C,X
+        BPL addrNext
+addrD5: CMP #$D5
+        BNE addrNext
+addrAA: LDA $C08C,X
+        BPL addrAA
+        CMP #$AA
+        BNE addrD5
+addr96: LDA $C08C,X
+        BPL addr96
+        CMP #$96
+        BNE addrD5
+addr0:  LDA $C08C,X
+        BPL addr0
+        STA addr            ; Track
+addr1:  LDA $C08C,X
+        BPL addr1
+        STA addr+1          ; Sector
+addr2:  LDA $C08C,X
+        BPL addr2
+        STA addr+2          ; Side
+addr3:  LDA $C08C,X
+        BPL addr3
+        STA addr+3          ; Format
+addr4:  LDA $C08C,X
+        BPL addr4
+        STA addr+4          ; Checksum
+        CLC
+        RTS
+addrTimeout: SEC
+        RTS
+; Find the address field of the sector. The nibbles are compared quickly
+; to be in time for the data field
+findSector: LDA track
+        AND #$3F
+        STA va              ; Track low bits
+        LDA track
+        CMP #$40
+        LDA #$00
+        ROL A               ; Track bit 6
+        LDY side
+        BEQ findSide
+        ORA #$20
+findSide: STA vb            ; Side byte
+        LDA #$20
+        STA count
+findNext: JSR readAddress
+        BCS findFail
+        LDY addr+1
+        LDA untab-$80,Y
+        CMP sector
+        BNE findMiss
+        LDY addr
+        LDA untab-$80,Y
+        CMP va
+        BNE findMiss
+        LDY addr+2
+        LDA untab-$80,Y
+        CMP vb
+        BNE findMiss
+        CLC
+        RTS
+findMiss: DEC count
+        BNE findNext
+        SEC
+findFail: RTS
+; Read the nibbles of the data field. The first 512 go to the ProDOS
+; buffer and the rest to the card RAM
+readData: LDY #$40
+dataNext: DEY
+        BEQ dataFail
+dataPoll: LDA $C08C,X
+        BPL dataPoll
+dataD5: CMP #$D5
+        BNE dataNext
+dataAA: LDA $C08C,X
+        BPL dataAA
+        CMP #$AA
+        BNE dataD5
+dataAD: LDA $C08C,X
+        BPL dataAD
+        CMP #$AD
+        BNE dataD5
+dataSec: LDA $C08C,X
+        BPL dataSec
+        STA dataSector
+        LDY #$00
+data0:  LDA $C08C,X
+        BPL data0
+        STA (bufLo),Y
+        INY
+data0b: LDA $C08C,X
+        BPL data0b
+        STA (bufLo),Y
+        INY
+        BNE data0
+        INC bufHi
+data1:  LDA $C08C,X
+        BPL data1
+        STA (bufLo),Y
+        INY
+data1b: LDA $C08C,X
+        BPL data1b
+        STA (bufLo),Y
+        INY
+        BNE data1
+        DEC bufHi
+data2:  LDA $C08C,X
+        BPL data2
+        STA buf,Y
+        INY
+data2b: LDA $C08C,X
+        BPL data2b
+        STA buf,Y
+        INY
+        CPY #$C0
+        BNE data2
+        CLC
+        RTS
+dataFail: SEC
+        RTS
+; Decode the nibbles in place, the tags are skipped. The output is always
+; behind the nibbles still to decode
+decode: LDA dataSector
+        JSR untranslate
+        BCS decodeBadSector
+        CMP sector
+        BEQ decodeStart
+decodeBadSector: SEC
+        RTS
+decodeStart: LDA #$00
+        STA srcY
+        STA srcPage
+        STA dstY
+        STA dstPage
+        STA groups
+        STA chk0
+        STA chk1
+        STA chk2
+        LDA bufHi
+        STA savedHi
+decodeGroup: JSR combine
+        BCS decodeEnd
+        LDA chk0
+        CMP #$80
+        ROL A
+        STA chk0
+        LDA va
+        EOR chk0
+        STA va
+        ADC chk2
+        STA chk2
+        LDA vb
+        EOR chk2
+        STA vb
+        ADC chk1
+        STA chk1
+        LDA vc
+        EOR chk1
+        STA vc
+        ADC chk0
+        STA chk0Next
+        LDA va
+        JSR put
+        LDA vb
+        JSR put
+        LDA groups
+        CMP #174
+        BEQ decodeChecksum  ; The last group has only two bytes
+        LDA chk0Next
+        STA chk0
+        LDA vc
+        JSR put
+        INC groups
+        BNE decodeGroup
+decodeChecksum: INC groups
+        JSR combine
+        BCS decodeEnd
+        LDA va
+        CMP chk2
+        BNE decodeFail
+        LDA vb
+        CMP chk1
+        BNE decodeFail
+        LDA vc
+        CMP chk0
+        BNE decodeFail
+        CLC
+        BCC decodeEnd
+decodeFail: SEC
+decodeEnd: LDA savedHi
+        STA bufHi
+        RTS
+; Get the values of a group and combine the two bits of each byte
+combine: JSR getValue
+        BCS combineEnd
+        STA twos
+        JSR getValue
+        BCS combineEnd
+        STA va
+        JSR getValue
+        BCS combineEnd
+        STA vb
+        LDA groups
+        CMP #174
+        BEQ combineTwos
+        JSR getValue
+        BCS combineEnd
+        STA vc
+combineTwos: LDA twos
+        ASL A
+        ASL A
+        AND #$C0
+        ORA va
+        STA va
+        LDA twos
+        ASL A
+        ASL A
+        ASL A
+        ASL A
+        AND #$C0
+        ORA vb
+        STA vb
+        LDA twos
+        LSR A
+        ROR A
+        ROR A
+        AND #$C0
+        ORA vc
+        STA vc
+        CLC
+combineEnd: RTS
+; Store A on the ProDOS buffer, skipping the 12 bytes of tags
+put:    LDY groups
+        CPY #$04
+        BCC putEnd
+        PHA
+        LDA savedHi
+        CLC
+        ADC dstPage
+        STA bufHi
+        PLA
+        LDY dstY
+        STA (bufLo),Y
+        INC dstY
+        BNE putEnd
+        INC dstPage
+putEnd: RTS
+; Get the next 6 bits value from the nibbles. Carry set if invalid
+getValue: LDY srcY
+        LDA srcPage
+        CMP #$02
+        BEQ getValueRam
+        CLC
+        ADC savedHi
+        STA bufHi
+        LDA (bufLo),Y
+        JMP getValueNext
+getValueRam: LDA buf,Y
+getValueNext: INC srcY
+        BNE untranslate
+        INC srcPage
+; Translate a nibble to a 6 bits value, carry set if invalid. Keeps X and Y
+untranslate: STY saveY
+        TAY
+        LDA untab-$80,Y
+        LDY saveY
+        CMP #$40
+        RTS
+; SmartPort entry. X is the slot * 16 and Y is $Cn. The command and the
+; address of the parameter list follow the JSR of the caller
+spEntry: JSR init
+        LDY #$05
+spSave: LDA cmd,Y
+        STA saveZp,Y        ; The ProDOS zero page is used by the driver
+        DEY
+        BPL spSave
+        PLA
+        STA bufLo
+        PLA
+        STA bufHi           ; Return address
+        PHP
+        SEI
+        CLD
+        LDA bufLo
+        CLC
+        ADC #$03
+        STA spRet           ; Skip the command and the parameter list address
+        LDA bufHi
+        ADC #$00
+        STA spRet+1
+        LDY #$01
+        LDA (bufLo),Y
+        STA spCmd
+        INY
+        LDA (bufLo),Y
+        PHA
+        INY
+        LDA (bufLo),Y
+        STA bufHi
+        PLA
+        STA bufLo
+        LDY #$06
+spParam: LDA (bufLo),Y
+        STA spParams,Y      ; Count, unit, buffer and block or status code
+        DEY
+        BPL spParam
+        LDA #$00
+        STA spCountLo
+        STA spCountHi
+        JSR spCommand
+        STA spErr
+        LDY #$05
+spRestore: LDA saveZp,Y
+        STA cmd,Y
+        DEY
+        BPL spRestore
+        PLP
+        LDA spRet+1
+        PHA
+        LDA spRet
+        PHA
+        LDX spCountLo       ; Bytes transferred
+        LDY spCountHi
+        LDA spErr
+        CMP #$01            ; Carry set on errors
+        RTS
+; Run the SmartPort command with the error on A. The units 1 and 2 are the
+; drives, the unit 0 is the host
+spCommand: LDA spParams+2
+        STA bufLo
+        LDA spParams+3
+        STA bufHi
+        LDA spCmd
+        CMP #$0A
+        BCS spBadCmd        ; No extended commands
+        LDA spParams+1
+        BNE spDrive
+        LDA spCmd
+        BNE spBadUnit       ; Only STATUS for the host
+        LDA spParams+4
+        BNE spBadCtl
+        LDA #$02            ; Two devices
+        LDY #$00
+        STA (bufLo),Y
+        LDA #$00
+spHost: INY
+        STA (bufLo),Y
+        CPY #$07
+        BNE spHost
+        LDA #$08
+        STA spCountLo
+        LDA #$00
+        RTS
+spDrive: CMP #$03
+        BCS spBadUnit
+        CMP #$02
+        LDA #$00
+        ROR A               ; The unit 2 is the drive 2, on bit 7
+        ORA slot16
+        STA unit
+        LDA spCmd
+        BEQ spStatus
+        CMP #$01
+        BEQ spRead
+        CMP #$04
+        BCC spProtected     ; WRITEBLOCK and FORMAT
+        CMP #$06
+        BCC spOk            ; CONTROL and INIT do nothing
+spBadCmd: LDA #$01          ; Bad command
+        RTS
+spProtected: LDA #$2B       ; Write protected
+        RTS
+spOk:   LDA #$00
+        RTS
+spBadUnit: LDA #$28         ; No device connected
+        RTS
+spBadCtl: LDA #$21          ; Bad status code
+        RTS
+spRead: LDA spParams+6
+        BNE spBadBlock
+        LDA spParams+4
+        STA blkLo
+        LDA spParams+5
+        STA blkHi
+        LDA #$01
+        STA cmd             ; READ
+        JSR command
+        CMP #$00
+        BNE spReadEnd
+        LDY #$02
+        STY spCountHi       ; 512 bytes read
+spReadEnd: RTS
+spBadBlock: LDA #$2D        ; Invalid block
+        RTS
+; Status code 0 is the general status and 3 adds the device information
+spStatus: LDA spParams+4
+        BEQ spGeneral
+        CMP #$03
+        BNE spBadCtl
+spGeneral: LDA #$00
+        STA cmd             ; STATUS
+        JSR command         ; Blocks on X and Y
+        CMP #$00
+        BNE spStatusEnd
+        TYA
+        LDY #$02
+        STA (bufLo),Y
+        TXA
+        DEY
+        STA (bufLo),Y
+        DEY
+        LDA #$B4            ; Block device, read allowed, online and write protected
+        STA (bufLo),Y
+        LDA #$00
+        LDY #$03
+        STA (bufLo),Y
+        LDA #$04
+        STA spCountLo
+        LDA spParams+4
+        BEQ spStatusEnd
+        LDY #$04
+spDib:  LDA spDibData-4,Y
+        STA (bufLo),Y
+        INY
+        CPY #25
+        BNE spDib
+        STY spCountLo
+        LDA #$00
+spStatusEnd: RTS
+; Name, type 3.5 disk, subtype and version of the device information block
+spDibData: .byte 11, $55, $4E, $49, $44, $49, $53, $4B, $20, $33, $2E, $35 ; UNIDISK 3.5
+        .byte $20, $20, $20, $20, $20
+        .byte $01, $00, $00, $01
+; 6 and 2 values of the nibbles $80 to $FF
+untab:
+        .byte $FF, $FF, $FF, $FF, $FF, $FF, $FF, $FF, $FF, $FF, $FF, $FF, $FF, $FF, $FF, $FF
+        .byte $FF, $FF, $FF, $FF, $FF, $FF, $00, $01, $FF, $FF, $02, $03, $FF, $04, $05, $06
+        .byte $FF, $FF, $FF, $FF, $FF, $FF, $07, $08, $FF, $FF, $FF, $09, $0A, $0B, $0C, $0D
+        .byte $FF, $FF, $0E, $0F, $10, $11, $12, $13, $FF, $14, $15, $16, $17, $18, $19, $1A
+        .byte $FF, $FF, $FF, $FF, $FF, $FF, $FF, $FF, $FF, $FF, $FF, $1B, $FF, $1C, $1D, $1E
+        .byte $FF, $FF, $FF, $1F, $FF, $FF, $20, $21, $FF, $22, $23, $24, $25, $26, $27, $28
+        .byte $FF, $FF, $FF, $FF, $FF, $29, $2A, $2B, $FF, $2C, $2D, $2E, $2F, $30, $31, $32
+        .byte $FF, $FF, $33, $34, $35, $36, $37, $38, $FF, $39, $3A, $3B, $3C, $3D, $3E, $3F
@@ -0,0 +1,429 @@
+package main
+
+/*
+Minimal 6502 assembler for the firmware of the emulated cards that have no
+original ROM available.
+
+It replaces the byte slice named varName in the Go file with the assembled
+code, one instruction per line with the source as comment:
+
+	go run . iwm.asm ../../cardIwmFirmware.go iwmFirmware
+
+The syntax is a subset of the usual one: "name = value" for constants,
+"label:" at the start of a line, .org, .byte and comments after ";".
+The operands can add and subtract labels and $hex, %binary and decimal
+numbers, with < and > for the low and high byte. Only the addressing
+modes used on the firmware are supported.
+*/
+
+import (
+	"fmt"
+	"go/format"
+	"log"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type addressingMode int
+
+const (
+	modeImplied addressingMode = iota
+	modeAccumulator
+	modeImmediate
+	modeZeroPage
+	modeZeroPageX
+	modeIndirectY
+	modeRelative
+	modeAbsolute
+	modeAbsoluteX
+	modeAbsoluteY
+	modeIndirect
+)
+
+var opcodes = map[string]map[addressingMode]uint8{
+	"ADC": {modeImmediate: 0x69, modeZeroPage: 0x65, modeAbsolute: 0x6d, modeAbsoluteX: 0x7d, modeAbsoluteY: 0x79, modeIndirectY: 0x71},
+	"AND": {modeImmediate: 0x29, modeZeroPage: 0x25, modeAbsolute: 0x2d, modeAbsoluteX: 0x3d, modeAbsoluteY: 0x39, modeIndirectY: 0x31},
+	"ASL": {modeAccumulator: 0x0a, modeZeroPage: 0x06, modeAbsolute: 0x0e},
+	"BCC": {modeRelative: 0x90},
+	"BCS": {modeRelative: 0xb0},
+	"BEQ": {modeRelative: 0xf0},
+	"BMI": {modeRelative: 0x30},
+	"BNE": {modeRelative: 0xd0},
+	"BPL": {modeRelative: 0x10},
+	"BVC": {modeRelative: 0x50},
+	"BVS": {modeRelative: 0x70},
+	"BIT": {modeZeroPage: 0x24, modeAbsolute: 0x2c},
+	"CLC": {modeImplied: 0x18},
+	"CLD": {modeImplied: 0xd8},
+	"CLI": {modeImplied: 0x58},
+	"CLV": {modeImplied: 0xb8},
+	"CMP": {modeImmediate: 0xc9, modeZeroPage: 0xc5, modeAbsolute: 0xcd, modeAbsoluteX: 0xdd, modeAbsoluteY: 0xd9, modeIndirectY: 0xd1},
+	"CPX": {modeImmediate: 0xe0, modeZeroPage: 0xe4, modeAbsolute: 0xec},
+	"CPY": {modeImmediate: 0xc0, modeZeroPage: 0xc4, modeAbsolute: 0xcc},
+	"DEC": {modeZeroPage: 0xc6, modeAbsolute: 0xce, modeAbsoluteX: 0xde},
+	"DEX": {modeImplied: 0xca},
+	"DEY": {modeImplied: 0x88},
+	"EOR": {modeImmediate: 0x49, modeZeroPage: 0x45, modeAbsolute: 0x4d, modeAbsoluteX: 0x5d, modeAbsoluteY: 0x59, modeIndirectY: 0x51},
+	"INC": {modeZeroPage: 0xe6, modeAbsolute: 0xee, modeAbsoluteX: 0xfe},
+	"INX": {modeImplied: 0xe8},
+	"INY": {modeImplied: 0xc8},
+	"JMP": {modeAbsolute: 0x4c, modeIndirect: 0x6c},
+	"JSR": {modeAbsolute: 0x20},
+	"LDA": {modeImmediate: 0xa9, modeZeroPage: 0xa5, modeZeroPageX: 0xb5, modeAbsolute: 0xad, modeAbsoluteX: 0xbd, modeAbsoluteY: 0xb9, modeIndirectY: 0xb1},
+	"LDX": {modeImmediate: 0xa2, modeZeroPage: 0xa6, modeAbsolute: 0xae, modeAbsoluteY: 0xbe},
+	"LDY": {modeImmediate: 0xa0, modeZeroPage: 0xa4, modeAbsolute: 0xac, modeAbsoluteX: 0xbc},
+	"LSR": {modeAccumulator: 0x4a, modeZeroPage: 0x46, modeAbsolute: 0x4e},
+	"NOP": {modeImplied: 0xea},
+	"ORA": {modeImmediate: 0x09, modeZeroPage: 0x05, modeAbsolute: 0x0d, modeAbsoluteX: 0x1d, modeAbsoluteY: 0x19, modeIndirectY: 0x11},
+	"PHA": {modeImplied: 0x48},
+	"PHP": {modeImplied: 0x08},
+	"PLA": {modeImplied: 0x68},
+	"PLP": {modeImplied: 0x28},
+	"ROL": {modeAccumulator: 0x2a, modeZeroPage: 0x26, modeAbsolute: 0x2e},
+	"ROR": {modeAccumulator: 0x6a, modeZeroPage: 0x66, modeAbsolute: 0x6e},
+	"RTS": {modeImplied: 0x60},
+	"SBC": {modeImmediate: 0xe9, modeZeroPage: 0xe5, modeAbsolute: 0xed, modeAbsoluteX: 0xfd, modeAbsoluteY: 0xf9, modeIndirectY: 0xf1},
+	"SEC": {modeImplied: 0x38},
+	"SEI": {modeImplied: 0x78},
+	"STA": {modeZeroPage: 0x85, modeAbsolute: 0x8d, modeAbsoluteX: 0x9d, modeAbsoluteY: 0x99, modeIndirectY: 0x91},
+	"STX": {modeZeroPage: 0x86, modeAbsolute: 0x8e},
+	"STY": {modeZeroPage: 0x84, modeAbsolute: 0x8c},
+	"TAX": {modeImplied: 0xaa},
+	"TAY": {modeImplied: 0xa8},
+	"TSX": {modeImplied: 0xba},
+	"TXA": {modeImplied: 0x8a},
+	"TXS": {modeImplied: 0x9a},
+	"TYA": {modeImplied: 0x98},
+}
+
+// line is an assembled line of the source
+type line struct {
+	address uint16
+	bytes   []uint8
+	text    string // Instruction as written, empty for data and comments
+	label   string
+	comment string
+}
+
+type assembler struct {
+	symbols map[string]int
+	final   bool // On the second pass all the symbols must be defined
+	started bool // The comments before the first .org are not code
+	lines   []line
+}
+
+var (
+	reLabel     = regexp.MustCompile(`^([A-Za-z_][A-Za-z0-9_]*):?\s*(.*)$`)
+	reIndexX    = regexp.MustCompile(`(?i),\s*x$`)
+	reIndexY    = regexp.MustCompile(`(?i),\s*y$`)
+	reIndirectY = regexp.MustCompile(`(?i)^\((.*)\),\s*y$`)
+	reTerms     = regexp.MustCompile(`[+-]`)
+)
+
+func (as *assembler) term(t string) (int, error) {
+	t = strings.TrimSpace(t)
+	switch {
+	case strings.HasPrefix(t, "<"):
+		v, err := as.term(t[1:])
+		return v & 0xff, err
+	case strings.HasPrefix(t, ">"):
+		v, err := as.term(t[1:])
+		return (v >> 8) & 0xff, err
+	case strings.HasPrefix(t, "$"):
+		v, err := strconv.ParseInt(t[1:], 16, 32)
+		return int(v), err
+	case strings.HasPrefix(t, "%"):
+		v, err := strconv.ParseInt(t[1:], 2, 32)
+		return int(v), err
+	case len(t) > 0 && t[0] >= '0' && t[0] <= '9':
+		v, err := strconv.ParseInt(t, 10, 32)
+		return int(v), err
+	}
+	v, ok := as.symbols[t]
+	if !ok {
+		if as.final {
+			return 0, fmt.Errorf("undefined symbol '%v'", t)
+		}
+		return 0x1234, nil // Assume an absolute address until defined
+	}
+	return v, nil
+}
+
+func (as *assembler) value(s string) (int, error) {
+	s = strings.TrimSpace(s)
+	operators := reTerms.FindAllString(s, -1)
+	terms := reTerms.Split(s, -1)
+	total := 0
+	for i, t := range terms {
+		v, err := as.term(t)
+		if err != nil {
+			return 0, err
+		}
+		if i > 0 && operators[i-1] == "-" {
+			total -= v
+		} else {
+			total += v
+		}
+	}
+	return total, nil
+}
+
+func (as *assembler) pass(source string) error {
+	as.lines = nil
+	as.started = false
+	pc := 0
+	for n, raw := range strings.Split(source, "\n") {
+		err := as.assembleLine(raw, &pc)
+		if err != nil {
+			return fmt.Errorf("line %v: %w", n+1, err)
+		}
+	}
+	return nil
+}
+
+func (as *assembler) assembleLine(raw string, pc *int) error {
+	code, comment, _ := strings.Cut(raw, ";")
+	comment = strings.TrimSpace(comment)
+	code = strings.TrimRight(code, " \t")
+	if strings.TrimSpace(code) == "" {
+		if as.started && comment != "" && strings.HasPrefix(strings.TrimSpace(raw), ";") {
+			as.lines = append(as.lines, line{address: uint16(*pc), comment: comment})
+		}
+		return nil
+	}
+
+	label := ""
+	if code[0] != ' ' && code[0] != '\t' {
+		m := reLabel.FindStringSubmatch(code)
+		if m == nil {
+			return fmt.Errorf("invalid label on '%v'", code)
+		}
+		label = m[1]
+		code = m[2]
+		if after, found := strings.CutPrefix(strings.TrimSpace(code), "="); found {
+			v, err := as.value(after)
+			if err != nil {
+				return err
+			}
+			as.symbols[label] = v
+			return nil
+		}
+		as.symbols[label] = *pc
+	}
+
+	code = strings.TrimSpace(code)
+	if code == "" {
+		as.lines = append(as.lines, line{address: uint16(*pc), label: label, comment: comment})
+		return nil
+	}
+	mnemonic, operand, _ := strings.Cut(code, " ")
+	mnemonic = strings.ToUpper(mnemonic)
+	operand = strings.TrimSpace(operand)
+
+	var bytes []uint8
+	text := mnemonic
+	switch mnemonic {
+	case ".ORG":
+		v, err := as.value(operand)
+		*pc = v
+		as.started = true
+		return err
+	case ".BYTE":
+		for _, item := range strings.Split(operand, ",") {
+			v, err := as.value(item)
+			if err != nil {
+				return err
+			}
+			bytes = append(bytes, uint8(v))
+		}
+		text = ""
+	default:
+		var err error
+		bytes, err = as.instruction(mnemonic, operand, *pc)
+		if err != nil {
+			return err
+		}
+		if operand != "" {
+			text += " " + operand
+		}
+	}
+
+	as.lines = append(as.lines, line{uint16(*pc), bytes, text, label, comment})
+	*pc += len(bytes)
+	return nil
+}
+
+func (as *assembler) instruction(mnemonic string, operand string, pc int) ([]uint8, error) {
+	modes, ok := opcodes[mnemonic]
+	if !ok {
+		return nil, fmt.Errorf("unknown instruction '%v'", mnemonic)
+	}
+
+	var mode addressingMode
+	argument := operand
+	switch {
+	case operand == "":
+		mode = modeImplied
+		if _, ok := modes[modeImplied]; !ok {
+			mode = modeAccumulator
+		}
+	case strings.EqualFold(operand, "A"):
+		mode = modeAccumulator
+	case strings.HasPrefix(operand, "#"):
+		mode = modeImmediate
+		argument = operand[1:]
+	case reIndirectY.MatchString(operand):
+		mode = modeIndirectY
+		argument = reIndirectY.FindStringSubmatch(operand)[1]
+	case strings.HasPrefix(operand, "("):
+		mode = modeIndirect
+		argument = strings.Trim(operand, "()")
+	case reIndexX.MatchString(operand):
+		argument = reIndexX.ReplaceAllString(operand, "")
+		mode = modeAbsoluteX
+		if v, _ := as.value(argument); v < 0x100 {
+			if _, ok := modes[modeZeroPageX]; ok {
+				mode = modeZeroPageX
+			}
+		}
+	case reIndexY.MatchString(operand):
+		argument = reIndexY.ReplaceAllString(operand, "")
+		mode = modeAbsoluteY
+	default:
+		if _, ok := modes[modeRelative]; ok {
+			mode = modeRelative
+			break
+		}
+		mode = modeAbsolute
+		if v, _ := as.value(argument); v < 0x100 {
+			if _, ok := modes[modeZeroPage]; ok {
+				mode = modeZeroPage
+			}
+		}
+	}
+
+	opcode, ok := modes[mode]
+	if !ok {
+		return nil, fmt.Errorf("invalid addressing mode for '%v %v'", mnemonic, operand)
+	}
+	if mode == modeImplied || mode == modeAccumulator {
+		return []uint8{opcode}, nil
+	}
+	v, err := as.value(argument)
+	if err != nil {
+		return nil, err
+	}
+	switch mode {
+	case modeRelative:
+		offset := v - (pc + 2)
+		if as.final && (offset < -128 || offset > 127) {
+			return nil, fmt.Errorf("branch out of range to '%v'", argument)
+		}
+		return []uint8{opcode, uint8(offset)}, nil
+	case modeImmediate, modeZeroPage, modeZeroPageX, modeIndirectY:
+		return []uint8{opcode, uint8(v)}, nil
+	default:
+		return []uint8{opcode, uint8(v), uint8(v >> 8)}, nil
+	}
+}
+
+func assemble(source string) (*assembler, error) {
+	as := &assembler{symbols: make(map[string]int)}
+	err := as.pass(source)
+	if err != nil {
+		return nil, err
+	}
+	as.final = true
+	err = as.pass(source)
+	return as, err
+}
+
+func hexBytes(bytes []uint8) string {
+	var sb strings.Builder
+	for i, b := range bytes {
+		if i > 0 {
+			sb.WriteString(" ")
+		}
+		fmt.Fprintf(&sb, "0x%02x,", b)
+	}
+	return sb.String()
+}
+
+// goLines has the lines of the byte slice, without the declaration
+func (as *assembler) goLines() []string {
+	var lines []string
+	for _, l := range as.lines {
+		if l.label != "" {
+			lines = append(lines, fmt.Sprintf("\t// %v: $%04X", l.label, l.address))
+		} else if len(l.bytes) == 0 {
+			lines = append(lines, "\t// "+l.comment)
+		}
+		if len(l.bytes) == 0 {
+			continue
+		}
+		s := "\t" + hexBytes(l.bytes)
+		if l.text != "" {
+			s += " // " + l.text
+			if l.comment != "" {
+				s += " ; " + l.comment
+			}
+		} else if l.comment != "" {
+			s += " // " + l.comment
+		}
+		lines = append(lines, s)
+	}
+	return lines
+}
+
+func main() {
+	if len(os.Args) != 4 {
+		log.Fatal("Usage: go run . source.asm target.go varName")
+	}
+	sourceFile, targetFile, varName := os.Args[1], os.Args[2], os.Args[3]
+
+	source, err := os.ReadFile(sourceFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	as, err := assemble(string(source))
+	if err != nil {
+		log.Fatalf("%v: %v", sourceFile, err)
+	}
+
+	target, err := os.ReadFile(targetFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	declaration := "var " + varName + " = []uint8{\n"
+	before, rest, found := strings.Cut(string(target), declaration)
+	if !found {
+		log.Fatalf("'%v' not found on %v", strings.TrimSpace(declaration), targetFile)
+	}
+	_, after, found := strings.Cut(rest, "\n}\n")
+	if !found {
+		log.Fatalf("End of '%v' not found on %v", varName, targetFile)
+	}
+	code := before + declaration + strings.Join(as.goLines(), "\n") + "\n}\n" + after
+	formatted, err := format.Source([]byte(code))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	err = os.WriteFile(targetFile, formatted, 0644)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Show the labels to update the constants of the Go code
+	var labels []string
+	for _, l := range as.lines {
+		if l.label != "" {
+			labels = append(labels, fmt.Sprintf("$%04X %v", l.address, l.label))
+		}
+	}
+	sort.Strings(labels)
+	fmt.Println(strings.Join(labels, "\n"))
+}
@@ -7,6 +7,14 @@ echo "Building MacOS ARM console frontend"
 CGO_ENABLED=1 go build -tags static -ldflags "-s -w" ../frontend/console
 mv console build/izapple2console_mac_arm64
 
+echo "Building MacOS ARM web frontend"
+CGO_ENABLED=0 go build -ldflags "-s -w" ../frontend/web
+mv web build/izapple2web_mac_arm64
+
+echo "Building MacOS ARM VNC frontend"
+CGO_ENABLED=0 go build -ldflags "-s -w" ../frontend/vnc
+mv vnc build/izapple2vnc_mac_arm64
+
 echo "Building MacOS ARM SDL frontend"
 CGO_ENABLED=1 go build -tags static -ldflags "-s -w" ../frontend/a2sdl
 mv a2sdl build/izapple2sdl_mac_arm64
@@ -20,6 +28,14 @@ echo "Building MacOS Intel console frontend"
 GOARCH=amd64 CGO_ENABLED=1 go build -tags static -ldflags "-s -w" ../frontend/console
 mv console build/izapple2console_mac_amd64
 
+echo "Building MacOS Intel web frontend"
+GOARCH=amd64 CGO_ENABLED=0 go build -ldflags "-s -w" ../frontend/web
+mv web build/izapple2web_mac_amd64
+
+echo "Building MacOS Intel VNC frontend"
+GOARCH=amd64 CGO_ENABLED=0 go build -ldflags "-s -w" ../frontend/vnc
+mv vnc build/izapple2vnc_mac_amd64
+
 echo "Building MacOS Intel SDL frontend"
 GOARCH=amd64 CGO_ENABLED=1 go build -tags static -ldflags "-s -w" ../frontend/a2sdl
 mv a2sdl build/izapple2sdl_mac_amd64
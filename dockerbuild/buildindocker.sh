@@ -16,6 +16,34 @@ env CGO_ENABLED=1 CC=x86_64-w64-mingw32-gcc GOOS=windows CGO_LDFLAGS="-L/usr/x86
 chown --reference /build izapple2console.exe
 cp izapple2console.exe /build/izapple2console_windows_amd64.exe
 
+# Build izapple2web for Linux
+echo "Building Linux web frontend"
+cd /tmp/izapple2/frontend/web
+env CGO_ENABLED=0 go build -ldflags "-s -w" .
+chown --reference /build web
+cp web /build/izapple2web_linux_amd64
+
+# Build izapple2web.exe for Windows
+echo "Building Windows web frontend"
+cd /tmp/izapple2/frontend/web
+env CGO_ENABLED=0 GOOS=windows go build -ldflags "-s -w" -o izapple2web.exe .
+chown --reference /build izapple2web.exe
+cp izapple2web.exe /build/izapple2web_windows_amd64.exe
+
+# Build izapple2vnc for Linux
+echo "Building Linux VNC frontend"
+cd /tmp/izapple2/frontend/vnc
+env CGO_ENABLED=0 go build -ldflags "-s -w" .
+chown --reference /build vnc
+cp vnc /build/izapple2vnc_linux_amd64
+
+# Build izapple2vnc.exe for Windows
+echo "Building Windows VNC frontend"
+cd /tmp/izapple2/frontend/vnc
+env CGO_ENABLED=0 GOOS=windows go build -ldflags "-s -w" -o izapple2vnc.exe .
+chown --reference /build izapple2vnc.exe
+cp izapple2vnc.exe /build/izapple2vnc_windows_amd64.exe
+
 # Build izapple2sdl for Linux
 echo "Building Linux SDL frontend"
 cd /tmp/izapple2/frontend/a2sdl
@@ -0,0 +1,171 @@
+package izapple2
+
+import (
+	"math"
+	"sync/atomic"
+)
+
+/*
+Mechanical sounds of the disk drives.
+
+The Disk II cards report the head steps, the head bumping against the
+track 0 stop and the motor turning on and off. The frontends mix the
+sounds generated by DriveSounds with the speaker output. There is no
+attempt to reproduce a recording of a real drive, just enough to know
+that the drive is working on a long load.
+
+The motor state is always kept, only the steps and bumps can be lost if
+the frontend doesn't consume the samples fast enough.
+*/
+
+// DriveSoundEvent is a mechanical event on a disk drive
+type DriveSoundEvent uint8
+
+const (
+	// DriveSoundStep is a head step to an adjacent quarter track
+	DriveSoundStep DriveSoundEvent = iota
+	// DriveSoundBump is the head hitting the track 0 stop
+	DriveSoundBump
+	// DriveSoundMotorOn is a drive motor starting
+	DriveSoundMotorOn
+	// DriveSoundMotorOff is a drive motor stopping
+	DriveSoundMotorOff
+)
+
+// DriveSoundsProvider receives the mechanical events of the disk drives
+type DriveSoundsProvider interface {
+	// DriveSound receives a drive event. The argument is the CPU cycle when it is generated
+	DriveSound(event DriveSoundEvent, cycle uint64)
+}
+
+const (
+	driveSoundsBufferSize = 1000
+	driveSoundsHumHz      = 50 // Rotation is 300 rpm, the belt and the motor hum lower
+	driveSoundsHumLevel   = 0.04
+	driveSoundsStepMs     = 4
+	driveSoundsStepLevel  = 0.25
+	driveSoundsBumpMs     = 20
+	driveSoundsBumpHz     = 120
+	driveSoundsBumpLevel  = 0.5
+)
+
+// DriveSounds synthesizes the sound of the disk drives from their events
+type DriveSounds struct {
+	eventChannel chan DriveSoundEvent
+	samplingHz   int
+	motors       atomic.Int32 // Drives with the motor on
+
+	humPhase   float64 // Position on the hum wave cycle, from 0 to 1
+	knock      int     // Samples remaining on the current step or bump
+	knockTotal int
+	knockBump  bool
+	noise      uint32
+}
+
+// NewDriveSounds returns a drive sounds synthesizer for the sampling rate provided
+func NewDriveSounds(samplingHz int) *DriveSounds {
+	var s DriveSounds
+	s.eventChannel = make(chan DriveSoundEvent, driveSoundsBufferSize)
+	s.samplingHz = samplingHz
+	s.noise = 1
+	return &s
+}
+
+// DriveSound receives a drive event. The argument is the CPU cycle when it is generated
+func (s *DriveSounds) DriveSound(event DriveSoundEvent, _ uint64) {
+	switch event {
+	case DriveSoundMotorOn:
+		s.motors.Add(1)
+		return
+	case DriveSoundMotorOff:
+		if s.motors.Add(-1) < 0 {
+			s.motors.Add(1)
+		}
+		return
+	}
+
+	select {
+	case s.eventChannel <- event:
+		// Sent
+	default:
+		// The channel is full, the event is lost.
+	}
+}
+
+func (s *DriveSounds) processEvent(event DriveSoundEvent) {
+	switch event {
+	case DriveSoundStep:
+		if s.knock == 0 || !s.knockBump {
+			s.knockTotal = s.samplingHz * driveSoundsStepMs / 1000
+			s.knock = s.knockTotal
+			s.knockBump = false
+		}
+	case DriveSoundBump:
+		s.knockTotal = s.samplingHz * driveSoundsBumpMs / 1000
+		s.knock = s.knockTotal
+		s.knockBump = true
+	}
+}
+
+// NextSample returns the next sample of the drives sound, from -1 to 1
+func (s *DriveSounds) NextSample() float32 {
+	done := false
+	for !done {
+		select {
+		case event := <-s.eventChannel:
+			s.processEvent(event)
+		default:
+			done = true
+		}
+	}
+
+	level := 0.0
+	if s.motors.Load() > 0 {
+		// Rough hum, a triangle wave with some noise
+		s.humPhase += float64(driveSoundsHumHz) / float64(s.samplingHz)
+		if s.humPhase >= 1 {
+			s.humPhase -= 1
+		}
+		triangle := 4*math.Abs(s.humPhase-0.5) - 1
+		level += driveSoundsHumLevel * (0.8*triangle + 0.2*s.nextNoise())
+	}
+
+	if s.knock > 0 {
+		// Exponentially decaying noise, the bumps add a low tone
+		elapsed := s.knockTotal - s.knock
+		decay := math.Exp(-5 * float64(elapsed) / float64(s.knockTotal))
+		if s.knockBump {
+			tone := math.Sin(2 * math.Pi * driveSoundsBumpHz * float64(elapsed) / float64(s.samplingHz))
+			level += driveSoundsBumpLevel * decay * (0.7*tone + 0.3*s.nextNoise())
+		} else {
+			level += driveSoundsStepLevel * decay * s.nextNoise()
+		}
+		s.knock--
+	}
+
+	return float32(level)
+}
+
+// nextNoise returns a pseudo random value from -1 to 1
+func (s *DriveSounds) nextNoise() float64 {
+	// Xorshift
+	s.noise ^= s.noise << 13
+	s.noise ^= s.noise >> 17
+	s.noise ^= s.noise << 5
+	return float64(s.noise)/float64(math.MaxUint32)*2 - 1
+}
+
+// driveSoundsForStep sends the sounds of a head movement
+func (a *Apple2) driveSoundsForStep(moved bool, bump bool) {
+	if bump {
+		a.driveSound(DriveSoundBump)
+	} else if moved {
+		a.driveSound(DriveSoundStep)
+	}
+}
+
+func (a *Apple2) driveSound(event DriveSoundEvent) {
+	if a.diskSoundsOn && a.driveSounds != nil {
+		a.driveSounds.DriveSound(event, a.GetCycles())
+	}
+}
@@ -0,0 +1,82 @@
+package izapple2
+
+import (
+	"testing"
+)
+
+func TestDriveStepperBump(t *testing.T) {
+	// Phase 0 on track 0, then phase 3 pushes the head below track 0
+	step, bump := moveDriveStepper(0x1, 0)
+	if step != 0 || bump {
+		t.Errorf("Head expected on step 0 without bump, got %v %v", step, bump)
+	}
+	step, bump = moveDriveStepper(0x8, step)
+	if step != 0 || !bump {
+		t.Errorf("Head expected to bump on step 0, got %v %v", step, bump)
+	}
+
+	// Phase 1 moves the head up two steps
+	step, bump = moveDriveStepper(0x2, step)
+	if step != 2 || bump {
+		t.Errorf("Head expected on step 2 without bump, got %v %v", step, bump)
+	}
+}
+
+func TestDriveSounds(t *testing.T) {
+	s := NewDriveSounds(48000)
+	peak := func(samples int) float32 {
+		var max float32
+		for i := 0; i < samples; i++ {
+			v := s.NextSample()
+			if v < 0 {
+				v = -v
+			}
+			if v > max {
+				max = v
+			}
+		}
+		return max
+	}
+
+	if peak(1000) != 0 {
+		t.Error("Silence expected with no events")
+	}
+
+	s.DriveSound(DriveSoundMotorOn, 0)
+	hum := peak(1000)
+	if hum == 0 {
+		t.Error("Motor hum expected")
+	}
+
+	s.DriveSound(DriveSoundStep, 0)
+	if peak(100) <= hum {
+		t.Error("Step louder than the hum expected")
+	}
+
+	s.DriveSound(DriveSoundMotorOff, 0)
+	peak(2000) // Let the step sound decay
+	if peak(1000) != 0 {
+		t.Error("Silence expected after the motor is off")
+	}
+}
+
+func TestDriveSoundsMotorWithFullChannel(t *testing.T) {
+	s := NewDriveSounds(48000)
+	for i := 0; i < driveSoundsBufferSize+10; i++ {
+		s.DriveSound(DriveSoundStep, 0)
+	}
+	s.DriveSound(DriveSoundMotorOn, 0)
+	s.DriveSound(DriveSoundStep, 0)
+	s.DriveSound(DriveSoundMotorOff, 0)
+	s.DriveSound(DriveSoundMotorOn, 0)
+	if s.motors.Load() != 1 {
+		t.Errorf("Motor on expected with the channel full, got %v motors", s.motors.Load())
+	}
+
+	// An extra motor off is ignored
+	s.DriveSound(DriveSoundMotorOff, 0)
+	s.DriveSound(DriveSoundMotorOff, 0)
+	if s.motors.Load() != 0 {
+		t.Errorf("Motor off expected, got %v motors", s.motors.Load())
+	}
+}
@@ -1,8 +1,12 @@
 package izapple2
 
 import (
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+
+	"github.com/ivanizag/izapple2/storage"
 )
 
 func testBoots(t *testing.T, model string, disk string, overrides *configuration, cycles uint64, banner string, prompt string, textMode testTextModeFunc) {
@@ -40,6 +44,10 @@ func TestPlusBoots(t *testing.T) {
 	testBoots(t, "2plus", "", nil, 200_000, "APPLE ][", "\n]", testTextMode40)
 }
 
+func TestEuroplusBoots(t *testing.T) {
+	testBoots(t, "europlus", "", nil, 200_000, "APPLE ][", "\n]", testTextMode40)
+}
+
 func Test2EBoots(t *testing.T) {
 	testBoots(t, "2e", "", nil, 200_000, "Apple ][", "\n]", testTextMode40)
 }
@@ -63,6 +71,13 @@ func TestPlusDOS32Boots(t *testing.T) {
 	testBoots(t, "2plus", "", overrides, 100_000_000, "MASTER DISKETTE VERSION 3.2 STANDARD", "\n>", testTextMode40)
 }
 
+func TestPlusDOS32AutodetectBoots(t *testing.T) {
+	overrides := newConfiguration()
+	overrides.set(confS0, "multirom,bank=7,basic=0")
+	overrides.set(confS6, "diskii,disk1=<internal>/dos32.nib")
+	testBoots(t, "2plus", "", overrides, 100_000_000, "MASTER DISKETTE VERSION 3.2 STANDARD", "\n>", testTextMode40)
+}
+
 func TestPlusDOS33Boots(t *testing.T) {
 	testBoots(t, "2plus", "<internal>/dos33.dsk", nil, 100_000_000, "DOS VERSION 3.3", "\n]", testTextMode40)
 }
@@ -74,3 +89,23 @@ func TestProdDOSBoots(t *testing.T) {
 func TestCPM65Boots(t *testing.T) {
 	testBoots(t, "2enh", "<internal>/cpm65.po", nil, 5_000_000, "CP/M-65 for the Apple II", "\nA>", testTextMode80)
 }
+
+func TestIwm35WozBoots(t *testing.T) {
+	data, _, err := LoadResource("<internal>/A2DeskTop-1.4-en_800k.2mg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := storage.MakeFileWoz35(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	disk := filepath.Join(t.TempDir(), "A2DeskTop.woz")
+	err = os.WriteFile(disk, f.ToWoz2(), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	overrides := newConfiguration()
+	overrides.set(confS7, "iwm,disk1=\""+disk+"\"")
+	testBoots(t, "2enh", "", overrides, 100_000_000, "Starting Apple II DeskTop", "...", testTextMode80)
+}
@@ -45,6 +45,7 @@ const (
 	all  = 0
 	seq  = 1 // Passes only with the sequencer implementation
 	none = 2 // Fails also with the sequencer implementation
+	beh  = 3 // Passes only with the behavioral implementation
 )
 
 func TestWoz(t *testing.T) {
@@ -61,7 +62,8 @@ func TestWoz(t *testing.T) {
 
 		// Next choices
 		{"Bouncing Kamungas", all, "Bouncing Kamungas - Disk 1, Side A.woz", 30_000_000, []int{0, 32, 0, 40, 0}},
-		// Runs but the test is unstable {"Commando", seq, "Commando - Disk 1, Side A.woz", 15_000_000, []int{0, 136, 68, 128, 68, 128, 68, 124, 12, 116, 108}},
+		// Runs with the sequencer but the test is unstable
+		{"Commando", beh, "Commando - Disk 1, Side A.woz", 15_000_000, []int{0, 136, 68, 128, 68, 128, 68, 124, 12, 116, 108}},
 		{"Planetfall", all, "Planetfall - Disk 1, Side A.woz", 4_000_000, []int{0, 8}},
 		{"Rescue Raiders", all, "Rescue Raiders - Disk 1, Side B.woz", 80_000_000, []int{
 			0, 84, 44, 46,
@@ -73,7 +75,7 @@ func TestWoz(t *testing.T) {
 		// Cross track sync
 		{"Blazing Paddles", all, "Blazing Paddles (Baudville).woz", 6_000_000, []int{0, 28, 0, 16, 12, 56, 52, 80}},
 		{"Take 1", all, "Take 1 (Baudville).woz", 8_000_000, []int{0, 28, 0, 4, 0, 72, 0, 20, 0}},
-		{"Hard Hat Mack", all, "Hard Hat Mack - Disk 1, Side A.woz", 10_000_000, []int{0, 134, 132}},
+		{"Hard Hat Mack", all, "Hard Hat Mack - Disk 1, Side A.woz", 10_500_000, []int{0, 134, 132}},
 
 		// Half tracks
 		{"The Bilestoad", all, "The Bilestoad - Disk 1, Side A.woz", 6_000_000, []int{0, 24}},
@@ -87,27 +89,27 @@ func TestWoz(t *testing.T) {
 		{"The Print Shop Companion", all, "The Print Shop Companion - Disk 1, Side A.woz", 14_000_000, []int{0, 68, 44, 68, 40, 68, 40, 136, 60}},
 
 		// What is the lifepsan of the data latch?
-		{"First Math Adventures", seq, "First Math Adventures - Understanding Word Problems.woz", 6_000_000, []int{0, 8, 0, 68, 12, 20}},
+		{"First Math Adventures", all, "First Math Adventures - Understanding Word Problems.woz", 6_000_000, []int{0, 8, 0, 68, 12, 20}},
 
 		// Reading Offset Data Streams
-		{"Wings of Fury", seq, "Wings of Fury - Disk 1, Side A.woz", 410_000_000, []int{0, 4, 0, 136, 124, 128, 24, 136, 124, 128, 24, 136, 124, 128, 24, 136, 124, 128, 24, 104}},
+		{"Wings of Fury", all, "Wings of Fury - Disk 1, Side A.woz", 410_000_000, []int{0, 4, 0, 136, 124, 128, 24, 136, 124, 128, 24, 136, 124, 128, 24, 136, 124, 128, 24, 104}},
 		{"Stickybear Town Builder", all, "Stickybear Town Builder - Disk 1, Side A.woz", 8_000_000, []int{0, 16, 12, 112, 80, 100, 8}},
 
 		// Optimal bit timing
-		// Requires disk change {"Border Zone", "Border Zone - Disk 1, Side A.woz", 500_000_000, []int{1,1,1,1,1,1}},
+		// Not working, the boot ROM loses nibbles with the 3.5us timing of the INFO chunk
+		// {"Border Zone", all, "Border Zone - Disk 1, Side A.woz", 40_000_000, []int{0, 112}},
 
 		// Extra
-		{"Mr. Do", seq, "Mr. Do.woz", 95_000_000, []int{0, 108, 48, 104, 72, 84, 0, 4}},
+		{"Mr. Do", all, "Mr. Do.woz", 95_000_000, []int{0, 108, 48, 104, 72, 84, 0, 4}},
 		{"Wavy Navy", all, "Wavy Navy.woz", 9_000_000, []int{0, 136}},
 		// SAGA6 requires disk change,
-		// Note that Congo Bongo works with the non sequencer implementation but the test is unstable
-		{"Congo Bongo", seq, "Congo Bongo.woz", 8_000_000, []int{0, 4, 2, 40, 20, 40, 16, 124, 116}},
-		// Wizardry III requires disk change,
-
+		{"Congo Bongo", all, "Congo Bongo.woz", 8_000_000, []int{0, 4, 2, 40, 20, 40, 16, 124, 116}},
+		// Wizardry III requires disk change, the boot disk loads the title
+		{"Wizardry III", all, "Wizardry III side B - boot.woz", 25_000_000, []int{0, 120, 0, 136, 120, 128, 124, 128, 0, 136, 124, 132}},
 	}
 
 	for _, tc := range testCases {
-		if tc.skip == all {
+		if tc.skip == all || tc.skip == beh {
 			t.Run(tc.name, func(t *testing.T) {
 				testWoz(t, false, tc.disk, tc.expectedTracks, tc.cycleLimit)
 			})
@@ -120,3 +122,22 @@ func TestWoz(t *testing.T) {
 
 	}
 }
+
+func BenchmarkWoz(b *testing.B) {
+	for _, card := range []string{"diskii", "diskiiseq"} {
+		b.Run(card, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				overrides := newConfiguration()
+				overrides.set(confS6, card+",disk1=\"woz_test_images/DOS 3.3 System Master.woz\"")
+				at, err := makeApple2Tester("2enh", overrides)
+				if err != nil {
+					b.Fatal(err)
+				}
+				at.terminateCondition = func(a *Apple2) bool {
+					return a.GetCycles() > 10_000_000
+				}
+				at.run()
+			}
+		})
+	}
+}
@@ -29,7 +29,7 @@ func newEbitenKeyBoard(a *izapple2.Apple2) *ebitenKeyboard {
 	k.a = a
 	k.keyChannel = izapple2.NewKeyboardChannel(a)
 
-	k.screenMode = screen.ScreenModeNTSC
+	k.screenMode = a.GetVideoTiming().DefaultScreenMode()
 	return &k
 }
 
@@ -145,6 +145,14 @@ func (k *ebitenKeyboard) putKey(key ebiten.Key) {
 	case ebiten.KeyPrintScreen:
 		if ctrl {
 			screen.AddScenario(k.a.GetVideoSource(), "../../screen/test_resources/")
+		} else if shift {
+			if k.a.IsRecording() {
+				k.a.SendCommand(izapple2.CommandStopRecording)
+				fmt.Println("Saving video 'recording.png'")
+			} else {
+				k.a.SendStartRecording("recording.png", k.screenMode)
+				fmt.Println("Recording video")
+			}
 		} else {
 			err := screen.SaveSnapshot(k.a.GetVideoSource(), screen.ScreenModeNTSC, "snapshot.png")
 			if err != nil {
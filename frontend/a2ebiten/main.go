@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"image"
 	"image/color"
+	"math"
 
 	"github.com/ivanizag/izapple2"
 	a_screen "github.com/ivanizag/izapple2/screen"
@@ -50,7 +51,7 @@ func (g *Game) Update() error {
 		g.paused = g.a.IsPaused()
 	}
 
-	if g.updates%3 == 0 && !g.a.IsPaused() { // 20 times per second
+	if g.updates%3 == 0 && !g.a.IsPaused() { // 20 times per second, 16.7 with PAL
 		var img *image.RGBA
 		vs := g.a.GetVideoSource()
 		if g.keyboard.showHelp {
@@ -70,7 +71,7 @@ func (g *Game) Update() error {
 		}
 	}
 
-	if g.updates%60 == 0 { // Once per second
+	if g.updates%uint64(ebiten.TPS()) == 0 { // Once per second
 		g.freq = g.a.GetCurrentFreqMHz()
 	}
 
@@ -125,6 +126,8 @@ func main() {
 func ebitenRun(a *izapple2.Apple2) {
 	ebiten.SetWindowSize(virtualWidth/2, virtualHeight/2)
 	ebiten.SetWindowResizingMode(ebiten.WindowResizingModeEnabled)
+	// One update per frame, 60Hz for NTSC and 50Hz for PAL
+	ebiten.SetTPS(int(math.Round(a.GetVideoTiming().FrameRate())))
 
 	title := "iz-" + a.Name + " (F1 for help)"
 	ebiten.SetWindowTitle(title)
@@ -134,7 +137,8 @@ func ebitenRun(a *izapple2.Apple2) {
 		keyboard: newEbitenKeyBoard(a),
 		speaker:  newEbitenSpeaker(),
 	}
-	a.SetSpeakerProvider(game.speaker)
+	a.SetSpeakerProvider(game.speaker.mixer)
+	a.SetDriveSoundsProvider(game.speaker.mixer.DriveSounds())
 
 	var err error
 	game.fontSource, err = text.NewGoTextFaceSource(bytes.NewReader(fonts.MPlus1pRegular_ttf))
@@ -161,6 +165,7 @@ var helpMessage = `
     Ctrl-F10: Show/Hide character set
    Shift-F10: Show/Hide alternate text
          F12: Save screen snapshot
+   Shift-F12: Start/stop recording a video
        Pause: Pause the emulation
 
   Left alt or option key: Open-Apple
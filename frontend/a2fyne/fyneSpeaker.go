@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/ivanizag/izapple2"
+
+	"github.com/ebitengine/oto/v3"
+)
+
+const (
+	samplingHz = 48000
+)
+
+type fyneSpeaker struct {
+	context *oto.Context
+	player  *oto.Player
+
+	mixer   *izapple2.SpeakerMixer
+	samples []float32
+}
+
+func newFyneSpeaker() *fyneSpeaker {
+	var s fyneSpeaker
+	s.mixer = izapple2.NewSpeakerMixer(samplingHz)
+	return &s
+}
+
+// Read is io.Reader's Read
+func (s *fyneSpeaker) Read(buf []byte) (n int, err error) {
+	const bytesPerSample = 4 // One float32 for the only channel
+	samples := len(buf) / bytesPerSample
+	if cap(s.samples) < samples {
+		s.samples = make([]float32, samples)
+	}
+	s.samples = s.samples[:samples]
+
+	s.mixer.FillSamples(s.samples)
+	for i, sample := range s.samples {
+		putFloat32InBuffer(buf, i, sample)
+	}
+	return samples * bytesPerSample, nil
+}
+
+func putFloat32InBuffer(buf []byte, i int, f float32) {
+	v := math.Float32bits(f)
+	buf[i*4] = byte(v)
+	buf[i*4+1] = byte(v >> 8)
+	buf[i*4+2] = byte(v >> 16)
+	buf[i*4+3] = byte(v >> 24)
+}
+
+func (s *fyneSpeaker) start() {
+	var ready chan struct{}
+	var err error
+	s.context, ready, err = oto.NewContext(&oto.NewContextOptions{
+		SampleRate:   samplingHz,
+		ChannelCount: 1,
+		Format:       oto.FormatFloat32LE,
+	})
+	if err != nil {
+		fmt.Printf("Error starting the audio: %v.\n", err)
+		return
+	}
+	<-ready
+
+	s.player = s.context.NewPlayer(s)
+	s.player.Play()
+}
@@ -57,7 +57,7 @@ func main() {
 }
 
 func fyneRun(s *state) {
-	s.screenMode = screen.ScreenModeNTSC
+	s.screenMode = s.a.GetVideoTiming().DefaultScreenMode()
 
 	s.app = app.New()
 	s.app.SetIcon(resourceApple2Png)
@@ -83,9 +83,16 @@ func fyneRun(s *state) {
 	j.start()
 	s.a.SetJoysticksProvider(j)
 
+	speaker := newFyneSpeaker()
+	speaker.start()
+	s.a.SetSpeakerProvider(speaker.mixer)
+	s.a.SetDriveSoundsProvider(speaker.mixer.DriveSounds())
+
 	go s.a.Run()
 
-	ticker := time.NewTicker(60 * time.Millisecond)
+	// Refresh every four frames, 66ms for NTSC and 80ms for PAL
+	frameTime := float64(time.Second) / s.a.GetVideoTiming().FrameRate()
+	ticker := time.NewTicker(time.Duration(4 * frameTime))
 	done := make(chan bool)
 	go func() {
 		for {
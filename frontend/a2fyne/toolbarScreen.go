@@ -9,14 +9,18 @@ import (
 )
 
 type toolbarScreen struct {
-	s             *state
-	w             fyne.CanvasObject
-	ntsc          *widget.Button
-	ntscDisabled  *widget.Icon
-	plain         *widget.Button
-	plainDisabled *widget.Icon
-	green         *widget.Button
-	greenDisabled *widget.Icon
+	s                 *state
+	w                 fyne.CanvasObject
+	ntsc              *widget.Button
+	ntscDisabled      *widget.Icon
+	composite         *widget.Button
+	compositeDisabled *widget.Icon
+	pal               *widget.Button
+	palDisabled       *widget.Icon
+	plain             *widget.Button
+	plainDisabled     *widget.Icon
+	green             *widget.Button
+	greenDisabled     *widget.Icon
 }
 
 func newToolbarScreen(s *state) *toolbarScreen {
@@ -31,6 +35,22 @@ func newToolbarScreen(s *state) *toolbarScreen {
 	tbs.ntscDisabled = widget.NewIcon(
 		theme.NewDisabledResource(resourceTelevisionClassicSvg))
 
+	tbs.composite = widget.NewButtonWithIcon("",
+		theme.ColorPaletteIcon(),
+		func() {
+			tbs.setScreenMode(screen.ScreenModeComposite)
+		})
+	tbs.compositeDisabled = widget.NewIcon(
+		theme.NewDisabledResource(theme.ColorPaletteIcon()))
+
+	tbs.pal = widget.NewButtonWithIcon("",
+		theme.ColorChromaticIcon(),
+		func() {
+			tbs.setScreenMode(screen.ScreenModePAL)
+		})
+	tbs.palDisabled = widget.NewIcon(
+		theme.NewDisabledResource(theme.ColorChromaticIcon()))
+
 	tbs.plain = widget.NewButtonWithIcon("",
 		theme.NewThemedResource(resourceTelevisionSvg),
 		func() {
@@ -49,10 +69,14 @@ func newToolbarScreen(s *state) *toolbarScreen {
 
 	tbs.w = container.NewHBox(
 		tbs.ntsc, tbs.ntscDisabled,
+		tbs.composite, tbs.compositeDisabled,
+		tbs.pal, tbs.palDisabled,
 		tbs.plain, tbs.plainDisabled,
 		tbs.green, tbs.greenDisabled)
 
 	tbs.ntscDisabled.Hide()
+	tbs.compositeDisabled.Hide()
+	tbs.palDisabled.Hide()
 	tbs.plainDisabled.Hide()
 	tbs.greenDisabled.Hide()
 	tbs.setScreenMode(s.screenMode)
@@ -65,6 +89,12 @@ func (tbs *toolbarScreen) setScreenMode(screenMode int) {
 	case screen.ScreenModeNTSC:
 		tbs.ntsc.Show()
 		tbs.ntscDisabled.Hide()
+	case screen.ScreenModeComposite:
+		tbs.composite.Show()
+		tbs.compositeDisabled.Hide()
+	case screen.ScreenModePAL:
+		tbs.pal.Show()
+		tbs.palDisabled.Hide()
 	case screen.ScreenModePlain:
 		tbs.plain.Show()
 		tbs.plainDisabled.Hide()
@@ -79,6 +109,12 @@ func (tbs *toolbarScreen) setScreenMode(screenMode int) {
 	case screen.ScreenModeNTSC:
 		tbs.ntsc.Hide()
 		tbs.ntscDisabled.Show()
+	case screen.ScreenModeComposite:
+		tbs.composite.Hide()
+		tbs.compositeDisabled.Show()
+	case screen.ScreenModePAL:
+		tbs.pal.Hide()
+		tbs.palDisabled.Show()
 	case screen.ScreenModePlain:
 		tbs.plain.Hide()
 		tbs.plainDisabled.Show()
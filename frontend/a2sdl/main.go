@@ -49,7 +49,8 @@ func sdlRun(a *izapple2.Apple2) {
 
 	s := newSDLSpeaker()
 	s.start()
-	a.SetSpeakerProvider(s)
+	a.SetSpeakerProvider(s.mixer)
+	a.SetDriveSoundsProvider(s.mixer.DriveSounds())
 
 	j := newSDLJoysticks(!a.UsesMouse())
 	a.SetJoysticksProvider(j)
@@ -59,6 +60,9 @@ func sdlRun(a *izapple2.Apple2) {
 
 	go a.Run()
 
+	// Refresh every two frames, 30Hz for NTSC and 25Hz for PAL
+	frameDelay := uint32(2000 / a.GetVideoTiming().FrameRate())
+
 	var x int32
 	paused := false
 	running := true
@@ -144,7 +148,7 @@ func sdlRun(a *izapple2.Apple2) {
 				texture.Destroy()
 			}
 		}
-		sdl.Delay(1000 / 30)
+		sdl.Delay(frameDelay)
 	}
 
 }
@@ -162,6 +166,7 @@ var helpMessage = `
     Ctrl-F10: Show/Hide character set
    Shift-F10: Show/Hide alternate text
          F12: Save screen snapshot
+   Shift-F12: Start/stop recording a video
        Pause: Pause the emulation
 
   Left alt or option key: Open-Apple
@@ -24,7 +24,7 @@ func newSDLKeyBoard(a *izapple2.Apple2) *sdlKeyboard {
 	k.a = a
 	k.keyChannel = izapple2.NewKeyboardChannel(a)
 
-	k.screenMode = screen.ScreenModeNTSC
+	k.screenMode = a.GetVideoTiming().DefaultScreenMode()
 	return &k
 }
 
@@ -126,6 +126,14 @@ func (k *sdlKeyboard) putKey(keyEvent *sdl.KeyboardEvent) {
 	case sdl.K_PRINTSCREEN:
 		if ctrl {
 			screen.AddScenario(k.a.GetVideoSource(), "../../screen/test_resources/")
+		} else if shift {
+			if k.a.IsRecording() {
+				k.a.SendCommand(izapple2.CommandStopRecording)
+				fmt.Println("Saving video 'recording.png'")
+			} else {
+				k.a.SendStartRecording("recording.png", k.screenMode)
+				fmt.Println("Recording video")
+			}
 		} else {
 			err := screen.SaveSnapshot(k.a.GetVideoSource(), screen.ScreenModeNTSC, "snapshot.png")
 			if err != nil {
@@ -2,23 +2,40 @@ package main
 
 import (
 	"bufio"
+	"flag"
 	"fmt"
 	"os"
 	"strings"
 	"time"
 
 	apple2 "github.com/ivanizag/izapple2"
+	"github.com/ivanizag/izapple2/screen"
 )
 
 func main() {
+	graphicsFlag := flag.String("graphics", "text",
+		"terminal output, can be 'text', 'sixel', 'kitty', 'halfblocks', 'braille' or 'auto'")
+	columnsFlag := flag.Int("columns", 80, "width of the terminal for the 'halfblocks' and 'braille' outputs")
+
 	a, err := apple2.CreateConfiguredApple()
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	graphics, ok := screen.TerminalGraphicsByName(*graphicsFlag)
+	if !ok {
+		fmt.Printf("Error: unknown terminal output '%s'\n", *graphicsFlag)
+		os.Exit(1)
 	}
 
 	fe := &ansiConsoleFrontend{}
 	a.SetKeyboardProvider(fe)
-	go fe.textModeGoRoutine(a)
+	if graphics == screen.TerminalText {
+		go fe.textModeGoRoutine(a)
+	} else {
+		go fe.graphicsGoRoutine(a, graphics, *columnsFlag)
+	}
 
 	a.Run()
 }
@@ -30,6 +47,9 @@ for input is added at the end.
 Outut is done in place using ANSI escape sequences.
 
 Those tricks do not work with the Apple2e ROM
+
+With graphics, the full screen is redrawn from the top of the terminal
+with Sixel, kitty graphics or Unicode characters in 24 bits colour.
 */
 
 type ansiConsoleFrontend struct {
@@ -114,3 +134,21 @@ func (fe *ansiConsoleFrontend) textModeGoRoutine(a *apple2.Apple2) {
 		time.Sleep(refreshDelayMs * time.Millisecond)
 	}
 }
+
+func (fe *ansiConsoleFrontend) graphicsGoRoutine(a *apple2.Apple2, graphics int, columns int) {
+	screenMode := a.GetVideoTiming().DefaultScreenMode()
+
+	fmt.Print("\033[2J") // Clear the terminal
+	for {
+		// Home, image, clear the rest and the input line
+		content := "\033[H"
+		content += screen.RenderTerminalGraphics(a.GetVideoSource(), screenMode, graphics, columns)
+		content += "\033[J\033[KLine: "
+
+		if content != fe.lastContent {
+			fmt.Print(content)
+			fe.lastContent = content
+		}
+		time.Sleep(refreshDelayMs * time.Millisecond)
+	}
+}
@@ -2,6 +2,8 @@ package main
 
 import (
 	"bufio"
+	"errors"
+	"flag"
 	"fmt"
 	"image/gif"
 	"os"
@@ -14,14 +16,22 @@ import (
 )
 
 func main() {
+	scriptFlag := flag.String("script", "", "file with commands to run instead of reading them from the console")
+
 	a, err := izapple2.CreateConfiguredApple()
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
+		os.Exit(exitError)
 	}
 	fe := &headLessFrontend{}
 	fe.keyChannel = make(chan uint8, 200)
 	a.SetKeyboardProvider(fe)
 	go a.Start(true /*paused*/)
+	spinWait(func() bool { return a.IsPaused() })
+
+	if *scriptFlag != "" {
+		os.Exit(runScript(a, fe, *scriptFlag))
+	}
 
 	inReader := bufio.NewReader(os.Stdin)
 	done := false
@@ -31,94 +41,222 @@ func main() {
 		if err != nil {
 			panic(err)
 		}
-		text = strings.TrimSpace(text)
-		parts := strings.Split(text, " ")
-		command := strings.ToLower(parts[0])
-		switch command {
-
-		// General commands
-		case "quit":
-			a.SendCommand(izapple2.CommandKill)
-			done = true
-		case "help":
-			fmt.Print(help)
-
-		// Emulation control commands
-		case "start":
-			a.SendCommand(izapple2.CommandStart)
-			spinWait(func() bool { return !a.IsPaused() })
-		case "pause":
-			a.SendCommand(izapple2.CommandPause)
-			spinWait(func() bool { return a.IsPaused() })
-		case "run":
-			if len(parts) != 2 {
-				fmt.Printf("Usage: run <cycles>\n")
-			} else if cycles, err := strconv.Atoi(parts[1]); err != nil {
-				fmt.Printf("Usage: run <cycles>\n")
-			} else if !a.IsPaused() {
-				fmt.Printf("Emulation is already running\n")
-			} else {
-				a.RequestFastMode()
-				a.SetCycleBreakpoint(a.GetCycles() + uint64(cycles)*1000)
-				a.SendCommand(izapple2.CommandStart)
-				spinWait(func() bool { return a.BreakPoint() })
-				a.ReleaseFastMode()
-			}
-		case "cycle":
-			fmt.Printf("%v\n", a.GetCycles())
-		case "reset":
-			a.SendCommand(izapple2.CommandReset)
-
-		// Keyboard related commands
-		case "key":
-			if len(parts) < 2 {
-				fmt.Println("Usage: key <number>")
-			} else {
-				code, err := strconv.Atoi(parts[1])
-				if err != nil || code < 0 || code > 127 {
-					fmt.Println("Usage: key <number from 0 to 127>")
-				} else {
-					fe.putKey(uint8(code))
-				}
-			}
-		case "type":
-			text := strings.Join(parts[1:], " ")
-			for _, char := range text {
-				fe.putKey(uint8(char))
-			}
-		case "enter":
-			fe.putKey(13)
-		case "clearkeys":
-			fe.clearKeyQueue()
-
-		// Screen related commands
-		case "text":
-			fmt.Print(izapple2.DumpTextModeAnsi(a))
-
-		// Old:
-		case "png":
-			err := screen.SaveSnapshot(a.GetVideoSource(), screen.ScreenModeNTSC, "snapshot.png")
+		done, err = fe.execute(a, strings.TrimSpace(text))
+		if err != nil {
+			fmt.Println(err)
+		}
+	}
+}
+
+/*
+execute runs a command. It returns true when the session has to end. The
+errors are failures of the checks or the usage message of the command.
+*/
+func (fe *headLessFrontend) execute(a *izapple2.Apple2, text string) (bool, error) {
+	parts := strings.Split(text, " ")
+	command := strings.ToLower(parts[0])
+	switch command {
+
+	// General commands
+	case "quit":
+		if a.IsRecording() {
+			a.SendCommand(izapple2.CommandStopRecording)
+			spinWait(func() bool { return !a.IsRecording() })
+		}
+		a.SendCommand(izapple2.CommandKill)
+		return true, nil
+	case "help":
+		fmt.Print(help)
+
+	// Emulation control commands
+	case "start":
+		a.SendCommand(izapple2.CommandStart)
+		spinWait(func() bool { return !a.IsPaused() })
+	case "pause":
+		a.SendCommand(izapple2.CommandPause)
+		spinWait(func() bool { return a.IsPaused() })
+	case "run":
+		if len(parts) != 2 {
+			return false, errors.New("usage: run <cycles>")
+		}
+		cycles, err := strconv.Atoi(parts[1])
+		if err != nil || cycles < 0 {
+			return false, errors.New("usage: run <cycles>")
+		}
+		return false, a.RunCycles(uint64(cycles) * 1000)
+	case "cycle":
+		fmt.Printf("%v\n", a.GetCycles())
+	case "reset":
+		a.SendCommand(izapple2.CommandReset)
+	case "wait-for-text":
+		if len(parts) < 3 {
+			return false, errors.New("usage: wait-for-text <seconds> <text>")
+		}
+		timeout, err := parseSeconds(parts[1])
+		if err != nil {
+			return false, errors.New("usage: wait-for-text <seconds> <text>")
+		}
+		return false, waitForText(a, unescape(strings.Join(parts[2:], " ")), timeout)
+	case "wait-for-pc":
+		if len(parts) != 3 {
+			return false, errors.New("usage: wait-for-pc <address> <seconds>")
+		}
+		pc, err := parseAddress(parts[1])
+		if err != nil {
+			return false, errors.New("usage: wait-for-pc <address> <seconds>")
+		}
+		timeout, err := parseSeconds(parts[2])
+		if err != nil {
+			return false, errors.New("usage: wait-for-pc <address> <seconds>")
+		}
+		return false, waitForPC(a, pc, timeout)
+
+	// Keyboard related commands
+	case "key":
+		if len(parts) < 2 {
+			return false, errors.New("usage: key <number>")
+		}
+		code, err := strconv.Atoi(parts[1])
+		if err != nil || code < 0 || code > 127 {
+			return false, errors.New("usage: key <number from 0 to 127>")
+		}
+		fe.putKey(uint8(code))
+	case "type":
+		text := unescape(strings.Join(parts[1:], " "))
+		for _, char := range text {
+			fe.putKey(keyFromRune(char))
+		}
+	case "type-paced":
+		if len(parts) < 3 {
+			return false, errors.New("usage: type-paced <milliseconds> <text>")
+		}
+		delay, err := strconv.Atoi(parts[1])
+		if err != nil || delay < 0 {
+			return false, errors.New("usage: type-paced <milliseconds> <text>")
+		}
+		text := unescape(strings.Join(parts[2:], " "))
+		for _, char := range text {
+			fe.putKey(keyFromRune(char))
+			err := a.RunCycles(secondsToCycles(float64(delay) / 1000))
 			if err != nil {
-				fmt.Printf("Error saving screen: %v.\n.", err)
-			} else {
-				fmt.Println("Saving screen 'snapshot.png'")
+				return false, err
 			}
-
-		case "pngm":
-			err := screen.SaveSnapshot(a.GetVideoSource(), screen.ScreenModePlain, "snapshot.png")
+		}
+	case "enter":
+		fe.putKey(13)
+	case "clearkeys":
+		fe.clearKeyQueue()
+
+	// Screen related commands
+	case "text":
+		fmt.Print(izapple2.DumpTextModeAnsi(a))
+	case "png", "pngm", "pngc":
+		screenMode := screen.ScreenModeNTSC
+		if command == "pngm" {
+			screenMode = screen.ScreenModePlain
+		} else if command == "pngc" {
+			screenMode = screen.ScreenModeComposite
+		}
+		filename := "snapshot.png"
+		if len(parts) >= 2 {
+			filename = parts[1]
+		}
+		err := screen.SaveSnapshot(a.GetVideoSource(), screenMode, filename)
+		if err != nil {
+			return false, fmt.Errorf("error saving screen: %w", err)
+		}
+		fmt.Printf("Saving screen '%s'\n", filename)
+	case "gif", "gifm":
+		screenMode := screen.ScreenModeNTSC
+		if command == "gifm" {
+			screenMode = screen.ScreenModePlain
+		}
+		filename := "snapshot.gif"
+		seconds := 1.0
+		delay := 5
+		var err error
+		if len(parts) >= 2 {
+			filename = parts[1]
+		}
+		if len(parts) >= 3 {
+			seconds, err = strconv.ParseFloat(parts[2], 64)
+		}
+		if err == nil && len(parts) >= 4 {
+			delay, err = strconv.Atoi(parts[3])
+		}
+		if err != nil || seconds < 0 || delay <= 0 || len(parts) > 4 {
+			return false, fmt.Errorf("usage: %s <filename> <seconds> <delay>", command)
+		}
+		err = SaveGif(a, filename, screenMode, seconds, delay)
+		if err != nil {
+			return false, fmt.Errorf("error saving screen: %w", err)
+		}
+		fmt.Printf("Saving screen '%s'\n", filename)
+	case "record":
+		screenMode := screen.ScreenModeNTSC
+		ok := len(parts) == 2 || len(parts) == 3
+		if len(parts) == 3 {
+			screenMode, ok = screen.ScreenModeByName(parts[2])
+		}
+		if !ok {
+			return false, errors.New("usage: record <filename> [green|plain|ntsc|composite|pal]")
+		}
+		err := a.StartRecording(parts[1], screenMode)
+		if err != nil {
+			return false, fmt.Errorf("error recording video: %w", err)
+		}
+		fmt.Printf("Recording to '%s'\n", parts[1])
+	case "stoprecord":
+		if !a.IsRecording() {
+			return false, errors.New("not recording")
+		}
+		a.SendCommand(izapple2.CommandStopRecording)
+		spinWait(func() bool { return !a.IsRecording() })
+		fmt.Println("Recording completed.")
+
+	// Checks
+	case "assert-text":
+		if len(parts) < 2 {
+			return false, errors.New("usage: assert-text <text>")
+		}
+		return false, assertText(a, unescape(strings.Join(parts[1:], " ")))
+	case "assert-memory":
+		if len(parts) < 3 {
+			return false, errors.New("usage: assert-memory <address> <value> [<value>...]")
+		}
+		address, err := parseAddress(parts[1])
+		if err != nil {
+			return false, errors.New("usage: assert-memory <address> <value> [<value>...]")
+		}
+		values := make([]uint8, 0, len(parts)-2)
+		for _, part := range parts[2:] {
+			value, err := parseByte(part)
 			if err != nil {
-				fmt.Printf("Error saving screen: %v.\n.", err)
-			} else {
-				fmt.Println("Saving screen 'snapshot.png'")
+				return false, errors.New("usage: assert-memory <address> <value> [<value>...]")
 			}
+			values = append(values, value)
+		}
+		return false, assertMemory(a, address, values)
 
-		case "gif":
-			SaveGif(a, "snapshot.gif")
-
-		default:
-			fmt.Println("Unknown command.")
+	// Storage related commands
+	case "load-disk":
+		if len(parts) < 3 {
+			return false, errors.New("usage: load-disk <drive> <filename>")
+		}
+		drive, err := strconv.Atoi(parts[1])
+		if err != nil || drive < 1 {
+			return false, errors.New("usage: load-disk <drive> <filename>")
+		}
+		filename := strings.Join(parts[2:], " ")
+		err = a.LoadDisk(drive-1, filename)
+		if err != nil {
+			return false, fmt.Errorf("could not load '%s' in drive %v: %w", filename, drive, err)
 		}
+
+	default:
+		return false, errors.New("unknown command")
 	}
+	return false, nil
 }
 
 var help = `
@@ -131,7 +269,7 @@ General commands:
 Emulation control commands:
 	start
 		Runs the emulator
-	stop
+	pause
 		Stops the emulator
 	run <cycles>
 		Runs the emulator for <cycles> thousand cycles at full speed. Waits until completed.
@@ -139,12 +277,21 @@ Emulation control commands:
 		Prints the current cycle count
 	reset
 		Sends a reset to the emulator
+	wait-for-text <seconds> <text>
+		Runs the emulator at full speed until <text> is on the text screen. Fails if it
+		does not show up in <seconds> of emulated time.
+	wait-for-pc <address> <seconds>
+		Runs the emulator at full speed until the 6502 is about to execute <address>. Fails
+		if it does not get there in <seconds> of emulated time.
 
 Keyboard related commands:
 	key <key>
 		Queues the key to the emulator. <key> is a decimal number from 0 to 127.
 	type <string>
 		Queues the string characters to the emulator. No quotes for the argument, it can have spaces.
+	type-paced <milliseconds> <string>
+		Types the string characters running the emulator <milliseconds> of emulated time
+		after each key. For programs that miss keys when typed too fast.
 	enter
 		Queues the enter key to the emulator. Alias for "key 13".
 	clearkeys
@@ -153,22 +300,43 @@ Keyboard related commands:
 Screen related commands:
 	text
 		Prints the text mode screen.
-	* png <filename>
-		Stores the active screen to <filename> in PNG format as NTSC color.
-	* pngm <filename>
+	png [<filename>]
+		Stores the active screen to <filename> in PNG format as NTSC color. The default
+		filename is "snapshot.png".
+	pngm [<filename>]
 		Same as "png" in monochrome.
-	* gif <filename> <seconds> <delay>
+	pngc [<filename>]
+		Same as "png" simulating the composite video signal.
+	gif [<filename> [<seconds> [<delay>]]]
 		Stores the running screen to <filename> in GIF format during <seconds> with a <delay> per frame
-		in 100ths of a second as NTSC color.
-		If the emulators is stopped. It is run at full speed during <seconds> and the stopped again.
-	* gifm <filename> <seconds> <delay>
+		in 100ths of a second as NTSC color. By default, "snapshot.gif" for 1 second with a delay of 5.
+		If the emulator is paused, it is run at full speed during <seconds> and paused again.
+	gifm [<filename> [<seconds> [<delay>]]]
 		Same as "gif" in monochrome.
+	record <filename> [green|plain|ntsc|composite|pal]
+		Starts recording a video of the emulated frames, NTSC color by default. The format
+		depends on the extension: .gif, .png for APNG or .y4m with the sound on a .wav file.
+		The frames are captured at the emulated frame rate, also with "run" at full speed.
+	stoprecord
+		Completes the video file.
+
+Checks:
+	assert-text <text>
+		Fails if <text> is not on the text screen.
+	assert-memory <address> <value> [<value>...]
+		Fails if the memory starting at <address> does not have the values. Use $ or 0x
+		for hexadecimal numbers.
+
+Storage related commands:
+	load-disk <drive> <filename>
+		Inserts the disk image on the drive, 1 or 2 for the first disk controller.
+
+In the texts, "\n" is a line break and a return when typed.
 
 `
 
 /*
 TODO:
-	floppy related commands: load disk....
 	joystick related commands: set paddle and button state, dump state
 */
 
@@ -178,23 +346,32 @@ func spinWait(f func() bool) {
 	}
 }
 
-func SaveGif(a *izapple2.Apple2, filename string) error {
+/*
+SaveGif stores an animation of the screen. If the emulator is paused, it is
+run at full speed for each frame. If not, the frames are taken in real time.
+*/
+func SaveGif(a *izapple2.Apple2, filename string, screenMode int, seconds float64, delayHundredsS int) error {
 	animation := gif.GIF{}
 
-	delay := 50 * time.Millisecond
-	delayHundredsS := 5
-	frames := 20 // 1 second
+	delay := time.Duration(delayHundredsS) * 10 * time.Millisecond
+	frames := max(int(seconds*100)/delayHundredsS, 1)
+	paused := a.IsPaused()
 
 	planned := time.Now()
 	for i := 0; i < frames; i++ {
-		lapse := time.Until(planned)
-		fmt.Printf("%v\n", lapse)
-		if lapse > 0 {
-			time.Sleep(lapse)
+		if paused {
+			err := a.RunCycles(secondsToCycles(delay.Seconds()))
+			if err != nil {
+				return err
+			}
+		} else {
+			lapse := time.Until(planned)
+			if lapse > 0 {
+				time.Sleep(lapse)
+			}
 		}
 
-		fmt.Printf("%v\n", time.Now())
-		img := screen.SnapshotPaletted(a.GetVideoSource(), screen.ScreenModeNTSC)
+		img := screen.SnapshotPaletted(a.GetVideoSource(), screenMode)
 		animation.Image = append(animation.Image, img)
 		animation.Delay = append(animation.Delay, delayHundredsS)
 
@@ -207,9 +384,7 @@ func SaveGif(a *izapple2.Apple2, filename string) error {
 	}
 	defer f.Close()
 
-	gif.EncodeAll(f, &animation)
-	return nil
-
+	return gif.EncodeAll(f, &animation)
 }
 
 /*
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/ivanizag/izapple2"
+)
+
+/*
+Script mode. The commands are read from a file, one per line. Empty lines
+and lines starting with # are ignored. The script stops on the first error
+and the exit code tells what happened:
+	0: all the commands completed
+	1: a check failed, like an assertion or a wait timeout
+	2: the script is not valid or could not be run
+
+Sample to boot the DOS 3.3 master disk, run a program and check the output:
+	wait-for-text 120 COPYRIGHT APPLE COMPUTER
+	type-paced 20 10 POKE 768,42\nRUN\n
+	wait-for-text 5 RUN\n\n]
+	assert-memory $300 42
+	png result.png
+*/
+
+const (
+	exitOK      = 0
+	exitFailure = 1
+	exitError   = 2
+)
+
+// failure is the error for the checks on the emulated machine that do not pass
+type failure struct {
+	message string
+}
+
+func (f *failure) Error() string {
+	return f.message
+}
+
+func failed(format string, args ...any) error {
+	return &failure{fmt.Sprintf(format, args...)}
+}
+
+func runScript(a *izapple2.Apple2, fe *headLessFrontend, filename string) int {
+	file, err := os.Open(filename)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return exitError
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	lineNumber := 0
+	done := false
+	for !done && scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		done, err = fe.execute(a, line)
+		if err != nil {
+			var f *failure
+			if errors.As(err, &f) {
+				fmt.Printf("%s:%v: failed: %v\n", filename, lineNumber, err)
+				fe.execute(a, "quit")
+				return exitFailure
+			}
+			fmt.Printf("%s:%v: error on '%s': %v\n", filename, lineNumber, line, err)
+			fe.execute(a, "quit")
+			return exitError
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return exitError
+	}
+
+	if !done {
+		fe.execute(a, "quit")
+	}
+	return exitOK
+}
+
+func waitForText(a *izapple2.Apple2, text string, timeout uint64) error {
+	found, err := a.RunUntil(func() bool {
+		return strings.Contains(izapple2.DumpTextMode(a), text)
+	}, timeout)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return failed("text '%s' not found", text)
+	}
+	return nil
+}
+
+func waitForPC(a *izapple2.Apple2, pc uint16, timeout uint64) error {
+	a.SetPCBreakpoint(pc)
+	err := a.RunCycles(timeout)
+	a.ClearPCBreakpoint()
+	if err != nil {
+		return err
+	}
+	if a.GetPC() != pc {
+		return failed("PC $%04x not reached, it is $%04x", pc, a.GetPC())
+	}
+	return nil
+}
+
+func assertText(a *izapple2.Apple2, text string) error {
+	screenText := izapple2.DumpTextMode(a)
+	if !strings.Contains(screenText, text) {
+		return failed("text '%s' not found on:\n%s", text, screenText)
+	}
+	return nil
+}
+
+func assertMemory(a *izapple2.Apple2, address uint16, values []uint8) error {
+	for i, value := range values {
+		actual := a.Peek(address + uint16(i))
+		if actual != value {
+			return failed("memory at $%04x is $%02x, expected $%02x", address+uint16(i), actual, value)
+		}
+	}
+	return nil
+}
+
+func secondsToCycles(seconds float64) uint64 {
+	return uint64(seconds * izapple2.CPUClockMhz * 1_000_000)
+}
+
+func parseSeconds(s string) (uint64, error) {
+	seconds, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, err
+	}
+	if seconds < 0 {
+		return 0, errors.New("negative time")
+	}
+	return secondsToCycles(seconds), nil
+}
+
+func parseNumber(s string, bitSize int) (uint64, error) {
+	if strings.HasPrefix(s, "$") {
+		return strconv.ParseUint(s[1:], 16, bitSize)
+	}
+	return strconv.ParseUint(s, 0, bitSize)
+}
+
+func parseAddress(s string) (uint16, error) {
+	value, err := parseNumber(s, 16)
+	return uint16(value), err
+}
+
+func parseByte(s string) (uint8, error) {
+	value, err := parseNumber(s, 8)
+	return uint8(value), err
+}
+
+// unescape replaces \n with a line break and \\ with a backslash
+func unescape(s string) string {
+	return strings.NewReplacer(`\\`, `\`, `\n`, "\n").Replace(s)
+}
+
+func keyFromRune(r rune) uint8 {
+	if r == '\n' {
+		return 13
+	}
+	return uint8(r)
+}
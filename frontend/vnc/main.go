@@ -0,0 +1,56 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"os"
+
+	"github.com/ivanizag/izapple2"
+)
+
+/*
+VNC frontend. Exposes the emulator as a RFB server to use it with any VNC
+client on hosts without a display. There is no authentication, by default
+it listens only on localhost. Use a SSH tunnel to access it remotely or
+listen on all the interfaces with:
+	izapple2vnc -listen :5900
+
+Several clients can be connected at the same time, all of them see the
+same machine and send input to it.
+*/
+
+func main() {
+	listenFlag := flag.String("listen", "localhost:5900", "address for the VNC server, use ':5900' to listen on all interfaces")
+
+	a, err := izapple2.CreateConfiguredApple()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	listener, err := net.Listen("tcp", *listenFlag)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("VNC server listening on %s\n", listener.Addr())
+
+	input := newVncInput(a)
+	go a.Run()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			continue
+		}
+		go func() {
+			c := newRfbConn(conn, a, input)
+			err := c.serve()
+			if err != nil {
+				fmt.Printf("VNC client %s disconnected: %v\n", conn.RemoteAddr(), err)
+			}
+		}()
+	}
+}
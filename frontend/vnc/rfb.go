@@ -0,0 +1,411 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/ivanizag/izapple2"
+	"github.com/ivanizag/izapple2/screen"
+)
+
+/*
+RFB server, the protocol used by VNC. Supports the versions 3.3, 3.7 and
+3.8 with no authentication and the raw encoding, the one supported by all
+the clients. Only the changed tiles of the screen are sent.
+
+See https://datatracker.ietf.org/doc/html/rfc6143
+*/
+
+const (
+	rfbVersion = "RFB 003.008\n"
+
+	rfbSecurityNone = 1
+
+	rfbClientSetPixelFormat           = 0
+	rfbClientSetEncodings             = 2
+	rfbClientFramebufferUpdateRequest = 3
+	rfbClientKeyEvent                 = 4
+	rfbClientPointerEvent             = 5
+	rfbClientCutText                  = 6
+
+	rfbServerFramebufferUpdate = 0
+	rfbEncodingRaw             = 0
+
+	// The framebuffer has a fixed size, the snapshots are scaled to it
+	rfbWidth  = 560
+	rfbHeight = 384
+	rfbTile   = 16
+
+	rfbFramesPerUpdate = 3 // 20 times per second, 16.7 with PAL
+	rfbMaxCutText      = 64 * 1024
+)
+
+type rfbPixelFormat struct {
+	BitsPerPixel uint8
+	Depth        uint8
+	BigEndian    uint8
+	TrueColor    uint8
+	RedMax       uint16
+	GreenMax     uint16
+	BlueMax      uint16
+	RedShift     uint8
+	GreenShift   uint8
+	BlueShift    uint8
+	Padding      [3]uint8
+}
+
+// The default pixel format is 32 bits RGB
+var rfbDefaultPixelFormat = rfbPixelFormat{32, 24, 0, 1, 255, 255, 255, 16, 8, 0, [3]uint8{}}
+
+type rfbConn struct {
+	conn  net.Conn
+	r     *bufio.Reader
+	w     *bufio.Writer
+	a     *izapple2.Apple2
+	input *vncInput
+
+	mutex       sync.Mutex // For the fields modified by the client messages
+	format      rfbPixelFormat
+	requested   bool
+	incremental bool
+	region      image.Rectangle
+	last        *image.RGBA // What the client has
+}
+
+func newRfbConn(conn net.Conn, a *izapple2.Apple2, input *vncInput) *rfbConn {
+	var c rfbConn
+	c.conn = conn
+	c.r = bufio.NewReader(conn)
+	c.w = bufio.NewWriter(conn)
+	c.a = a
+	c.input = input
+	c.format = rfbDefaultPixelFormat
+	return &c
+}
+
+// serve runs the session until the client disconnects
+func (c *rfbConn) serve() error {
+	defer c.conn.Close()
+	err := c.handshake()
+	if err != nil {
+		return err
+	}
+
+	done := make(chan bool)
+	defer close(done)
+	go c.updateLoop(done)
+
+	return c.readLoop()
+}
+
+func (c *rfbConn) handshake() error {
+	_, err := c.w.WriteString(rfbVersion)
+	if err == nil {
+		err = c.w.Flush()
+	}
+	if err != nil {
+		return err
+	}
+
+	var version [12]uint8
+	_, err = io.ReadFull(c.r, version[:])
+	if err != nil {
+		return err
+	}
+	var major, minor int
+	_, err = fmt.Sscanf(string(version[:]), "RFB %03d.%03d\n", &major, &minor)
+	if err != nil || major != 3 {
+		return fmt.Errorf("unsupported RFB version %q", version)
+	}
+
+	if minor < 7 {
+		// Version 3.3, the server decides
+		binary.Write(c.w, binary.BigEndian, uint32(rfbSecurityNone))
+	} else {
+		c.w.Write([]uint8{1, rfbSecurityNone})
+		err = c.w.Flush()
+		if err != nil {
+			return err
+		}
+		securityType, err := c.r.ReadByte()
+		if err != nil {
+			return err
+		}
+		if securityType != rfbSecurityNone {
+			return fmt.Errorf("unsupported security type %v", securityType)
+		}
+		if minor >= 8 {
+			binary.Write(c.w, binary.BigEndian, uint32(0)) // SecurityResult OK
+		}
+	}
+	err = c.w.Flush()
+	if err != nil {
+		return err
+	}
+
+	// ClientInit, the shared flag is ignored. All the clients share the screen
+	_, err = c.r.ReadByte()
+	if err != nil {
+		return err
+	}
+
+	// ServerInit
+	binary.Write(c.w, binary.BigEndian, uint16(rfbWidth))
+	binary.Write(c.w, binary.BigEndian, uint16(rfbHeight))
+	binary.Write(c.w, binary.BigEndian, c.format)
+	name := "izapple2 - " + c.a.Name
+	binary.Write(c.w, binary.BigEndian, uint32(len(name)))
+	c.w.WriteString(name)
+	return c.w.Flush()
+}
+
+func (c *rfbConn) readLoop() error {
+	for {
+		messageType, err := c.r.ReadByte()
+		if err != nil {
+			return err
+		}
+
+		switch messageType {
+		case rfbClientSetPixelFormat:
+			var m struct {
+				Padding [3]uint8
+				Format  rfbPixelFormat
+			}
+			err = binary.Read(c.r, binary.BigEndian, &m)
+			if err != nil {
+				return err
+			}
+			if m.Format.TrueColor == 0 {
+				return fmt.Errorf("colour map pixel formats are not supported")
+			}
+			if m.Format.BitsPerPixel != 8 && m.Format.BitsPerPixel != 16 && m.Format.BitsPerPixel != 32 {
+				return fmt.Errorf("unsupported %v bits per pixel", m.Format.BitsPerPixel)
+			}
+			c.mutex.Lock()
+			c.format = m.Format
+			c.last = nil // Resend everything with the new format
+			c.mutex.Unlock()
+
+		case rfbClientSetEncodings:
+			var m struct {
+				Padding uint8
+				Count   uint16
+			}
+			err = binary.Read(c.r, binary.BigEndian, &m)
+			if err == nil {
+				// Ignored, raw is always supported
+				_, err = c.r.Discard(4 * int(m.Count))
+			}
+			if err != nil {
+				return err
+			}
+
+		case rfbClientFramebufferUpdateRequest:
+			var m struct {
+				Incremental         uint8
+				X, Y, Width, Height uint16
+			}
+			err = binary.Read(c.r, binary.BigEndian, &m)
+			if err != nil {
+				return err
+			}
+			c.mutex.Lock()
+			c.requested = true
+			c.incremental = m.Incremental != 0
+			c.region = image.Rect(int(m.X), int(m.Y), int(m.X)+int(m.Width), int(m.Y)+int(m.Height)).
+				Intersect(image.Rect(0, 0, rfbWidth, rfbHeight))
+			c.mutex.Unlock()
+
+		case rfbClientKeyEvent:
+			var m struct {
+				Down    uint8
+				Padding [2]uint8
+				Key     uint32
+			}
+			err = binary.Read(c.r, binary.BigEndian, &m)
+			if err != nil {
+				return err
+			}
+			c.input.putKey(m.Key, m.Down != 0)
+
+		case rfbClientPointerEvent:
+			var m struct {
+				Buttons uint8
+				X, Y    uint16
+			}
+			err = binary.Read(c.r, binary.BigEndian, &m)
+			if err != nil {
+				return err
+			}
+			c.input.putPointer(m.Buttons, m.X, m.Y, rfbWidth, rfbHeight)
+
+		case rfbClientCutText:
+			var m struct {
+				Padding [3]uint8
+				Length  uint32
+			}
+			err = binary.Read(c.r, binary.BigEndian, &m)
+			if err != nil {
+				return err
+			}
+			if m.Length > rfbMaxCutText {
+				return fmt.Errorf("cut text too long, %v bytes", m.Length)
+			}
+			text := make([]uint8, m.Length)
+			_, err = io.ReadFull(c.r, text)
+			if err != nil {
+				return err
+			}
+			// Paste the text, it is latin-1
+			c.input.putText(string(text))
+
+		default:
+			return fmt.Errorf("unknown RFB message type %v", messageType)
+		}
+	}
+}
+
+func (c *rfbConn) updateLoop(done chan bool) {
+	period := time.Duration(float64(time.Second) * rfbFramesPerUpdate / c.a.GetVideoTiming().FrameRate())
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			err := c.update()
+			if err != nil {
+				c.conn.Close()
+				return
+			}
+		}
+	}
+}
+
+// update answers a pending update request if there are changes
+func (c *rfbConn) update() error {
+	c.mutex.Lock()
+	if !c.requested {
+		c.mutex.Unlock()
+		return nil
+	}
+	incremental := c.incremental && c.last != nil
+	region := c.region
+	format := c.format
+	if c.last == nil {
+		c.last = image.NewRGBA(image.Rect(0, 0, rfbWidth, rfbHeight))
+	}
+	last := c.last
+	c.mutex.Unlock()
+
+	img := screen.Snapshot(c.a.GetVideoSource(), c.input.getScreenMode())
+	img = screen.FitFrame(img, rfbWidth, rfbHeight)
+
+	var rects []image.Rectangle
+	if incremental {
+		rects = dirtyRects(last, img, region)
+		if len(rects) == 0 {
+			// Wait for changes
+			return nil
+		}
+	} else {
+		rects = []image.Rectangle{region}
+	}
+
+	c.mutex.Lock()
+	c.requested = false
+	c.mutex.Unlock()
+
+	c.w.Write([]uint8{rfbServerFramebufferUpdate, 0})
+	binary.Write(c.w, binary.BigEndian, uint16(len(rects)))
+	buffer := make([]uint8, 0, rfbTile*rfbWidth*4)
+	for _, r := range rects {
+		binary.Write(c.w, binary.BigEndian, []uint16{
+			uint16(r.Min.X), uint16(r.Min.Y), uint16(r.Dx()), uint16(r.Dy()),
+		})
+		binary.Write(c.w, binary.BigEndian, int32(rfbEncodingRaw))
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			buffer = buffer[:0]
+			for x := r.Min.X; x < r.Max.X; x++ {
+				buffer = format.appendPixel(buffer, img.RGBAAt(x, y))
+			}
+			_, err := c.w.Write(buffer)
+			if err != nil {
+				return err
+			}
+			// Now the client has this line
+			offset := img.PixOffset(r.Min.X, y)
+			copy(last.Pix[offset:offset+4*r.Dx()], img.Pix[offset:offset+4*r.Dx()])
+		}
+	}
+	return c.w.Flush()
+}
+
+// appendPixel encodes a colour with the pixel format of the client
+func (f *rfbPixelFormat) appendPixel(buffer []uint8, c color.RGBA) []uint8 {
+	value := (uint32(c.R)*uint32(f.RedMax)/255)<<f.RedShift |
+		(uint32(c.G)*uint32(f.GreenMax)/255)<<f.GreenShift |
+		(uint32(c.B)*uint32(f.BlueMax)/255)<<f.BlueShift
+	switch f.BitsPerPixel {
+	case 8:
+		return append(buffer, uint8(value))
+	case 16:
+		if f.BigEndian != 0 {
+			return binary.BigEndian.AppendUint16(buffer, uint16(value))
+		}
+		return binary.LittleEndian.AppendUint16(buffer, uint16(value))
+	default:
+		if f.BigEndian != 0 {
+			return binary.BigEndian.AppendUint32(buffer, value)
+		}
+		return binary.LittleEndian.AppendUint32(buffer, value)
+	}
+}
+
+// dirtyRects returns the changed tiles of the region, the consecutive tiles of a row are joined
+func dirtyRects(previous *image.RGBA, img *image.RGBA, region image.Rectangle) []image.Rectangle {
+	var rects []image.Rectangle
+	for ty := 0; ty < rfbHeight; ty += rfbTile {
+		var current image.Rectangle
+		for tx := 0; tx < rfbWidth; tx += rfbTile {
+			tile := image.Rect(tx, ty, tx+rfbTile, ty+rfbTile).Intersect(region)
+			if tile.Empty() || !tileChanged(previous, img, tile) {
+				if !current.Empty() {
+					rects = append(rects, current)
+					current = image.Rectangle{}
+				}
+				continue
+			}
+			if current.Empty() {
+				current = tile
+			} else {
+				current = current.Union(tile)
+			}
+		}
+		if !current.Empty() {
+			rects = append(rects, current)
+		}
+	}
+	return rects
+}
+
+func tileChanged(previous *image.RGBA, img *image.RGBA, tile image.Rectangle) bool {
+	for y := tile.Min.Y; y < tile.Max.Y; y++ {
+		offset := img.PixOffset(tile.Min.X, y)
+		end := offset + 4*tile.Dx()
+		if !bytes.Equal(previous.Pix[offset:end], img.Pix[offset:end]) {
+			return true
+		}
+	}
+	return false
+}
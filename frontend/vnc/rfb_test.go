@@ -0,0 +1,283 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"image"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ivanizag/izapple2"
+	"github.com/ivanizag/izapple2/screen"
+)
+
+type rfbTestClient struct {
+	conn          net.Conn
+	r             *bufio.Reader
+	bytesPerPixel int
+}
+
+type rfbTestRect struct {
+	rect   image.Rectangle
+	pixels []uint8
+}
+
+// newRfbTestServer serves a paused emulator with the text screen cleared
+func newRfbTestServer(t *testing.T) (*izapple2.Apple2, *vncInput, string) {
+	a, err := izapple2.CreateApple("2enh", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.Poke(0xc051, 0) // TEXT on
+	for address := uint16(0x400); address < 0x800; address++ {
+		a.Poke(address, 0xa0) // Normal space
+	}
+	input := newVncInput(a)
+	input.screenMode.Store(screen.ScreenModePlain)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go newRfbConn(conn, a, input).serve()
+		}
+	}()
+	return a, input, listener.Addr().String()
+}
+
+func dialRfb(t *testing.T, address string, version string) (*rfbTestClient, string) {
+	conn, err := net.Dial("tcp", address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	c := &rfbTestClient{conn, bufio.NewReader(conn), 4}
+
+	serverVersion := make([]uint8, 12)
+	c.read(t, serverVersion)
+	if string(serverVersion) != rfbVersion {
+		t.Fatalf("Unexpected server version %q", serverVersion)
+	}
+	conn.Write([]uint8(version))
+	if version == "RFB 003.003\n" {
+		var security uint32
+		c.read(t, &security)
+		if security != rfbSecurityNone {
+			t.Fatalf("Unexpected security %v", security)
+		}
+	} else {
+		types := make([]uint8, 2)
+		c.read(t, types)
+		if types[0] != 1 || types[1] != rfbSecurityNone {
+			t.Fatalf("Unexpected security types %v", types)
+		}
+		conn.Write([]uint8{rfbSecurityNone})
+		var result uint32
+		c.read(t, &result)
+		if result != 0 {
+			t.Fatalf("Unexpected security result %v", result)
+		}
+	}
+
+	conn.Write([]uint8{1}) // ClientInit, shared
+	var init struct {
+		Width, Height uint16
+		Format        rfbPixelFormat
+		NameLength    uint32
+	}
+	c.read(t, &init)
+	if init.Width != rfbWidth || init.Height != rfbHeight || init.Format != rfbDefaultPixelFormat {
+		t.Errorf("Unexpected ServerInit %+v", init)
+	}
+	name := make([]uint8, init.NameLength)
+	c.read(t, name)
+	return c, string(name)
+}
+
+func (c *rfbTestClient) read(t *testing.T, data any) {
+	var err error
+	if buffer, ok := data.([]uint8); ok {
+		_, err = io.ReadFull(c.r, buffer)
+	} else {
+		err = binary.Read(c.r, binary.BigEndian, data)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func (c *rfbTestClient) write(t *testing.T, data ...any) {
+	for _, d := range data {
+		err := binary.Write(c.conn, binary.BigEndian, d)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func (c *rfbTestClient) setPixelFormat(t *testing.T, format rfbPixelFormat) {
+	c.write(t, []uint8{rfbClientSetPixelFormat, 0, 0, 0}, format)
+	c.bytesPerPixel = int(format.BitsPerPixel / 8)
+}
+
+func (c *rfbTestClient) requestUpdate(t *testing.T, incremental bool, r image.Rectangle) []rfbTestRect {
+	flag := uint8(0)
+	if incremental {
+		flag = 1
+	}
+	c.write(t, []uint8{rfbClientFramebufferUpdateRequest, flag},
+		[]uint16{uint16(r.Min.X), uint16(r.Min.Y), uint16(r.Dx()), uint16(r.Dy())})
+
+	var header struct {
+		MessageType uint8
+		Padding     uint8
+		Count       uint16
+	}
+	c.read(t, &header)
+	if header.MessageType != rfbServerFramebufferUpdate {
+		t.Fatalf("Unexpected message type %v", header.MessageType)
+	}
+	rects := make([]rfbTestRect, header.Count)
+	for i := range rects {
+		var m struct {
+			X, Y, Width, Height uint16
+			Encoding            int32
+		}
+		c.read(t, &m)
+		if m.Encoding != rfbEncodingRaw {
+			t.Fatalf("Unexpected encoding %v", m.Encoding)
+		}
+		rects[i].rect = image.Rect(int(m.X), int(m.Y), int(m.X+m.Width), int(m.Y+m.Height))
+		rects[i].pixels = make([]uint8, int(m.Width)*int(m.Height)*c.bytesPerPixel)
+		c.read(t, rects[i].pixels)
+	}
+	return rects
+}
+
+func TestRfbHandshake(t *testing.T) {
+	_, _, address := newRfbTestServer(t)
+	for _, version := range []string{"RFB 003.003\n", "RFB 003.008\n"} {
+		_, name := dialRfb(t, address, version)
+		if name != "izapple2 - Apple //e" {
+			t.Errorf("Unexpected name '%s' with %q", name, version)
+		}
+	}
+}
+
+func TestRfbPixelFormats(t *testing.T) {
+	a, _, address := newRfbTestServer(t)
+	a.Poke(0x400, 0x20) // Inverse space, a white block on the top left
+	c, _ := dialRfb(t, address, "RFB 003.008\n")
+
+	rgb555 := rfbPixelFormat{16, 15, 0, 1, 31, 31, 31, 10, 5, 0, [3]uint8{}}
+	rgb888 := rfbPixelFormat{32, 24, 0, 1, 255, 255, 255, 16, 8, 0, [3]uint8{}}
+	cases := []struct {
+		format    rfbPixelFormat
+		bigEndian uint8
+		expected  []uint8
+	}{
+		{rgb555, 0, []uint8{0xff, 0x7f}},
+		{rgb555, 1, []uint8{0x7f, 0xff}},
+		{rgb888, 0, []uint8{0xff, 0xff, 0xff, 0x00}},
+		{rgb888, 1, []uint8{0x00, 0xff, 0xff, 0xff}},
+	}
+	for _, tc := range cases {
+		format := tc.format
+		format.BigEndian = tc.bigEndian
+		c.setPixelFormat(t, format)
+		// The pixel after the block is black
+		rects := c.requestUpdate(t, false, image.Rect(13, 0, 15, 1))
+		if len(rects) != 1 || rects[0].rect != image.Rect(13, 0, 15, 1) {
+			t.Fatalf("Unexpected rectangles %v", rects)
+		}
+		size := c.bytesPerPixel
+		white := rects[0].pixels[:size]
+		black := rects[0].pixels[size : 2*size]
+		if !bytes.Equal(white, tc.expected) || !bytes.Equal(black, make([]uint8, size)) {
+			t.Errorf("Unexpected pixels %v %v for %v bits per pixel and big endian %v", white, black, format.BitsPerPixel, tc.bigEndian)
+		}
+	}
+}
+
+func TestRfbIncrementalUpdate(t *testing.T) {
+	a, input, address := newRfbTestServer(t)
+	c, _ := dialRfb(t, address, "RFB 003.008\n")
+	full := image.Rect(0, 0, rfbWidth, rfbHeight)
+
+	// The first incremental request gets the full screen
+	rects := c.requestUpdate(t, true, full)
+	if len(rects) != 1 || rects[0].rect != full {
+		t.Fatalf("Unexpected first update %v", rects)
+	}
+	before := screen.FitFrame(screen.Snapshot(a.GetVideoSource(), input.getScreenMode()), rfbWidth, rfbHeight)
+
+	// The second character spans two tiles, they are sent joined
+	a.Poke(0x401, 0x20)
+	after := screen.FitFrame(screen.Snapshot(a.GetVideoSource(), input.getScreenMode()), rfbWidth, rfbHeight)
+	expected := dirtyRects(before, after, full)
+	if len(expected) != 1 || expected[0] != image.Rect(0, 0, 2*rfbTile, rfbTile) {
+		t.Errorf("Unexpected dirty rectangles %v", expected)
+	}
+	rects = c.requestUpdate(t, true, full)
+	if len(rects) != len(expected) || rects[0].rect != expected[0] {
+		t.Errorf("Unexpected update %v, expected %v", rects, expected)
+	}
+
+	// Only the region requested
+	a.Poke(0x427, 0x20) // Last character of the first line
+	region := image.Rect(0, 0, rfbWidth/2, rfbHeight)
+	a.Poke(0x400, 0x20)
+	rects = c.requestUpdate(t, true, region)
+	if len(rects) != 1 || rects[0].rect != image.Rect(0, 0, rfbTile, rfbTile) {
+		t.Errorf("Unexpected update of the region %v", rects)
+	}
+}
+
+func TestRfbKeyEvents(t *testing.T) {
+	_, input, address := newRfbTestServer(t)
+	c, _ := dialRfb(t, address, "RFB 003.008\n")
+	keyEvent := func(key uint32, down bool) {
+		flag := uint8(0)
+		if down {
+			flag = 1
+		}
+		c.write(t, []uint8{rfbClientKeyEvent, flag, 0, 0}, key)
+	}
+
+	keyEvent('A', true)
+	keyEvent('A', false)
+	keyEvent(xkControlL, true)
+	keyEvent('c', true)
+	keyEvent('c', false)
+	keyEvent(xkControlL, false)
+	keyEvent(xkReturn, true)
+	keyEvent(xkLeft, true)
+
+	expected := []uint8{'A', 3, 13, 8}
+	for _, key := range expected {
+		var got uint8
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			var ok bool
+			got, ok = input.keyChannel.GetKey(true)
+			if ok || time.Now().After(deadline) {
+				break
+			}
+			time.Sleep(time.Millisecond)
+		}
+		if got != key {
+			t.Errorf("Expected key %v, got %v", key, got)
+		}
+	}
+}
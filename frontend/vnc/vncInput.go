@@ -0,0 +1,201 @@
+package main
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/ivanizag/izapple2"
+	"github.com/ivanizag/izapple2/screen"
+)
+
+/*
+Input from the VNC clients. The keys are X11 keysyms. The pointer is the
+mouse card if the machine has one, or the first joystick if not. The left
+and right alt keys are the open and solid apple keys, the buttons 0 and 1.
+
+See https://www.x.org/releases/current/doc/xproto/x11protocol.html#keysym_encoding
+*/
+
+const (
+	xkBackSpace = 0xff08
+	xkTab       = 0xff09
+	xkReturn    = 0xff0d
+	xkPause     = 0xff13
+	xkEscape    = 0xff1b
+	xkLeft      = 0xff51
+	xkUp        = 0xff52
+	xkRight     = 0xff53
+	xkDown      = 0xff54
+	xkKPEnter   = 0xff8d
+	xkF2        = 0xffbf
+	xkF5        = 0xffc2
+	xkF6        = 0xffc3
+	xkControlL  = 0xffe3
+	xkControlR  = 0xffe4
+	xkAltL      = 0xffe9
+	xkAltR      = 0xffea
+	xkAltGr     = 0xfe03 // ISO_Level3_Shift
+	xkDelete    = 0xffff
+)
+
+type vncInput struct {
+	a          *izapple2.Apple2
+	keyChannel *izapple2.KeyboardChannel
+	screenMode atomic.Int32
+	mutex      sync.Mutex
+
+	ctrl     bool
+	keys     [2]bool
+	useMouse bool
+
+	x       uint16
+	y       uint16
+	buttons uint8
+	width   int
+	height  int
+}
+
+func newVncInput(a *izapple2.Apple2) *vncInput {
+	var in vncInput
+	in.a = a
+	in.keyChannel = izapple2.NewKeyboardChannel(a)
+	in.screenMode.Store(int32(a.GetVideoTiming().DefaultScreenMode()))
+	in.useMouse = a.UsesMouse()
+	in.width = rfbWidth
+	in.height = rfbHeight
+	in.x = rfbWidth / 2
+	in.y = rfbHeight / 2
+	a.SetJoysticksProvider(&in)
+	a.SetMouseProvider(&in)
+	return &in
+}
+
+func (in *vncInput) getScreenMode() int {
+	return int(in.screenMode.Load())
+}
+
+func (in *vncInput) putText(text string) {
+	in.keyChannel.PutText(text)
+}
+
+func (in *vncInput) putKey(key uint32, down bool) {
+	in.mutex.Lock()
+	switch key {
+	case xkControlL, xkControlR:
+		in.ctrl = down
+	case xkAltL:
+		in.keys[0] = down
+	case xkAltR, xkAltGr:
+		in.keys[1] = down
+	}
+	ctrl := in.ctrl
+	in.mutex.Unlock()
+
+	if !down {
+		// Process only key pushes
+		return
+	}
+
+	if key >= ' ' && key <= '~' {
+		if ctrl && key >= 'a' && key <= 'z' {
+			in.keyChannel.PutChar(uint8(key) - 'a' + 1)
+		} else if ctrl && key >= 'A' && key <= 'Z' {
+			in.keyChannel.PutChar(uint8(key) - 'A' + 1)
+		} else {
+			in.keyChannel.PutRune(rune(key))
+		}
+		return
+	}
+
+	result := uint8(0)
+	switch key {
+	case xkEscape:
+		result = 27
+	case xkBackSpace:
+		result = 8
+	case xkReturn, xkKPEnter:
+		result = 13
+	case xkLeft:
+		result = 8
+	case xkRight:
+		result = 21
+
+	// Apple //e
+	case xkUp:
+		result = 11
+	case xkDown:
+		result = 10
+	case xkTab:
+		result = 9
+	case xkDelete:
+		result = 127
+
+	// Control of the emulator
+	case xkF2:
+		if ctrl {
+			in.a.SendCommand(izapple2.CommandReset)
+		}
+	case xkF5:
+		if ctrl {
+			in.a.SendCommand(izapple2.CommandShowSpeed)
+		} else {
+			in.a.SendCommand(izapple2.CommandToggleSpeed)
+		}
+	case xkF6:
+		in.screenMode.Store(int32(screen.NextScreenMode(in.getScreenMode())))
+	case xkPause:
+		in.a.SendCommand(izapple2.CommandPauseUnpause)
+	}
+
+	if result != 0 {
+		in.keyChannel.PutChar(result)
+	}
+}
+
+func (in *vncInput) putPointer(buttons uint8, x uint16, y uint16, width int, height int) {
+	in.mutex.Lock()
+	defer in.mutex.Unlock()
+	in.buttons = buttons
+	in.x = x
+	in.y = y
+	in.width = width
+	in.height = height
+}
+
+// ReadButton returns the pointer buttons when used as joystick, and the apple keys
+func (in *vncInput) ReadButton(i int) bool {
+	in.mutex.Lock()
+	defer in.mutex.Unlock()
+	joystick := !in.useMouse
+	switch i {
+	case 0:
+		return in.keys[0] || (joystick && in.buttons&0x1 != 0) // Left button
+	case 1:
+		return in.keys[1] || (joystick && in.buttons&0x4 != 0) // Right button
+	case 2:
+		return joystick && in.buttons&0x2 != 0 // Middle button
+	}
+	return false
+}
+
+// ReadPaddle returns the pointer position as the first joystick
+func (in *vncInput) ReadPaddle(i int) (uint8, bool) {
+	if in.useMouse || i >= 2 {
+		return 255, false
+	}
+	in.mutex.Lock()
+	defer in.mutex.Unlock()
+	if i == 0 {
+		return uint8(255 * int(in.x) / max(in.width-1, 1)), true
+	}
+	return uint8(255 * int(in.y) / max(in.height-1, 1)), true
+}
+
+// ReadMouse returns the pointer position on the screen
+func (in *vncInput) ReadMouse() (x uint16, y uint16, pressed bool) {
+	in.mutex.Lock()
+	defer in.mutex.Unlock()
+	x = uint16(65535 * int(in.x) / max(in.width-1, 1))
+	y = uint16(65535 * int(in.y) / max(in.height-1, 1))
+	return x, y, in.buttons&0x1 != 0
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"embed"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/ivanizag/izapple2"
+)
+
+/*
+Web frontend. Serves a page with the screen of the emulator and streams
+the frames and the sound with a WebSocket. The keyboard, the gamepads and
+the mouse of the browser are sent back to the emulator.
+
+Many browsers can be connected at the same time, all of them see the same
+machine and send input to it.
+
+To share the emulator on the LAN, listen on all the interfaces with:
+	izapple2web -listen :8080
+*/
+
+//go:embed static/index.html
+var static embed.FS
+
+func main() {
+	listenFlag := flag.String("listen", "localhost:8080", "address for the web server, use ':8080' to listen on all interfaces")
+
+	a, err := izapple2.CreateConfiguredApple()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	streamer := newWebStreamer(a)
+	input := newWebInput(a, streamer)
+
+	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		page, _ := static.Open("static/index.html")
+		defer page.Close()
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		io.Copy(w, page)
+	})
+
+	http.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
+		ws, err := upgradeWebSocket(w, r)
+		if err != nil {
+			fmt.Printf("Error on the websocket: %v\n", err)
+			return
+		}
+		c := streamer.addClient(ws)
+		defer streamer.removeClient(c)
+		for {
+			_, message, err := ws.readMessage()
+			if err != nil {
+				return
+			}
+			err = input.putMessage(message)
+			if err != nil {
+				fmt.Printf("Invalid input message: %v\n", err)
+			}
+		}
+	})
+
+	go streamer.run()
+	go a.Run()
+
+	address := *listenFlag
+	if strings.HasPrefix(address, ":") {
+		address = "localhost" + address
+	}
+	fmt.Printf("Open http://%s in a browser\n", address)
+	err = http.ListenAndServe(*listenFlag, nil)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+}
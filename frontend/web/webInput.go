@@ -0,0 +1,217 @@
+package main
+
+import (
+	"encoding/json"
+	"sync"
+
+	"github.com/ivanizag/izapple2"
+	"github.com/ivanizag/izapple2/screen"
+)
+
+/*
+Input from the browsers. The page sends JSON messages with the keyboard
+events, the state of the gamepads from the Gamepad API and the mouse.
+
+  Apple 2 supports four paddles and 3 pushbuttons. The first two paddles are
+the X, Y axis of the first gamepad. The second two correspond the the second
+gamepad. The left and right alt keys are the open and solid apple keys, the
+buttons 0 and 1.
+*/
+
+type webInputMessage struct {
+	Type string `json:"t"`
+
+	// Keyboard
+	Key   string `json:"key"`
+	Down  bool   `json:"down"`
+	Ctrl  bool   `json:"ctrl"`
+	Shift bool   `json:"shift"`
+	Right bool   `json:"right"` // The right alt key
+
+	// Gamepad
+	Index   int       `json:"i"`
+	Axes    []float64 `json:"axes"`
+	Buttons []bool    `json:"buttons"`
+
+	// Mouse, the coordinates are 0 to 65535
+	X       uint16 `json:"x"`
+	Y       uint16 `json:"y"`
+	Pressed bool   `json:"pressed"`
+}
+
+type webInput struct {
+	a          *izapple2.Apple2
+	keyChannel *izapple2.KeyboardChannel
+	streamer   *webStreamer
+	mutex      sync.Mutex
+
+	paddle    [4]uint8
+	hasPaddle [4]bool
+	button    [4]bool
+	keys      [2]bool
+
+	mouseX       uint16
+	mouseY       uint16
+	mousePressed bool
+}
+
+func newWebInput(a *izapple2.Apple2, streamer *webStreamer) *webInput {
+	var in webInput
+	in.a = a
+	in.keyChannel = izapple2.NewKeyboardChannel(a)
+	in.streamer = streamer
+
+	// Initialize to max resistance if unplugged
+	for i := range in.paddle {
+		in.paddle[i] = 255
+	}
+	a.SetJoysticksProvider(&in)
+	a.SetMouseProvider(&in)
+	return &in
+}
+
+func (in *webInput) putMessage(data []uint8) error {
+	var m webInputMessage
+	err := json.Unmarshal(data, &m)
+	if err != nil {
+		return err
+	}
+
+	switch m.Type {
+	case "key":
+		in.putKey(&m)
+	case "gamepad":
+		in.putGamepad(&m)
+	case "mouse":
+		in.mutex.Lock()
+		in.mouseX = m.X
+		in.mouseY = m.Y
+		in.mousePressed = m.Pressed
+		in.mutex.Unlock()
+	}
+	return nil
+}
+
+func (in *webInput) putKey(m *webInputMessage) {
+	if m.Key == "Alt" {
+		in.mutex.Lock()
+		if m.Right {
+			in.keys[1] = m.Down
+		} else {
+			in.keys[0] = m.Down
+		}
+		in.mutex.Unlock()
+		return
+	}
+	if !m.Down {
+		// Process only key pushes
+		return
+	}
+
+	// The key names are the ones of the KeyboardEvent.key property
+	runes := []rune(m.Key)
+	if len(runes) == 1 {
+		ch := runes[0]
+		if m.Ctrl && ch >= 'a' && ch <= 'z' {
+			in.keyChannel.PutChar(uint8(ch) - 'a' + 1)
+		} else if m.Ctrl && ch >= 'A' && ch <= 'Z' {
+			in.keyChannel.PutChar(uint8(ch) - 'A' + 1)
+		} else {
+			in.keyChannel.PutRune(ch)
+		}
+		return
+	}
+
+	result := uint8(0)
+	switch m.Key {
+	case "Escape":
+		result = 27
+	case "Backspace":
+		result = 8
+	case "Enter":
+		result = 13
+	case "ArrowLeft":
+		result = 8
+	case "ArrowRight":
+		result = 21
+
+	// Apple //e
+	case "ArrowUp":
+		result = 11
+	case "ArrowDown":
+		result = 10
+	case "Tab":
+		result = 9
+	case "Delete":
+		result = 127
+
+	// Control of the emulator
+	case "F2":
+		if m.Ctrl {
+			in.a.SendCommand(izapple2.CommandReset)
+		}
+	case "F5":
+		if m.Ctrl {
+			in.a.SendCommand(izapple2.CommandShowSpeed)
+		} else {
+			in.a.SendCommand(izapple2.CommandToggleSpeed)
+		}
+	case "F6":
+		in.streamer.setScreenMode(screen.NextScreenMode(in.streamer.getScreenMode()))
+	case "Pause":
+		in.a.SendCommand(izapple2.CommandPauseUnpause)
+	}
+
+	if result != 0 {
+		in.keyChannel.PutChar(result)
+	}
+}
+
+func (in *webInput) putGamepad(m *webInputMessage) {
+	if m.Index < 0 || m.Index >= 2 {
+		// Process only the first two gamepads
+		return
+	}
+
+	in.mutex.Lock()
+	defer in.mutex.Unlock()
+	for axis := 0; axis < 2 && axis < len(m.Axes); axis++ {
+		value := (m.Axes[axis] + 1) * 128
+		value = min(max(value, 0), 255)
+		in.paddle[m.Index*2+axis] = uint8(value)
+		in.hasPaddle[m.Index*2+axis] = true
+	}
+	for button := 0; button < 2 && button < len(m.Buttons); button++ {
+		in.button[m.Index*2+button] = m.Buttons[button]
+	}
+}
+
+// ReadButton returns the state of the buttons of the gamepads and the apple keys
+func (in *webInput) ReadButton(i int) bool {
+	in.mutex.Lock()
+	defer in.mutex.Unlock()
+	switch i {
+	case 0:
+		return in.button[0] || in.keys[0]
+	case 1:
+		// It can be secondary of first or primary of second
+		return in.button[1] || in.button[2] || in.keys[1]
+	case 2:
+		return in.button[3]
+	}
+	return false
+}
+
+// ReadPaddle returns the axis of the gamepads
+func (in *webInput) ReadPaddle(i int) (uint8, bool) {
+	in.mutex.Lock()
+	defer in.mutex.Unlock()
+	return in.paddle[i], in.hasPaddle[i]
+}
+
+// ReadMouse returns the position of the mouse on the screen
+func (in *webInput) ReadMouse() (x uint16, y uint16, pressed bool) {
+	in.mutex.Lock()
+	defer in.mutex.Unlock()
+	return in.mouseX, in.mouseY, in.mousePressed
+}
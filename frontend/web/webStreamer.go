@@ -0,0 +1,255 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/png"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ivanizag/izapple2"
+	"github.com/ivanizag/izapple2/screen"
+)
+
+/*
+Streams the screen and the speaker to the browsers connected with a
+WebSocket. The messages are binary with a type byte:
+  - 1: frame, the rectangle that changed since the previous frame as a
+    PNG. Followed by x, y, width and height of the full screen as 16 bits
+    little endian numbers and the PNG file.
+  - 2: audio, 16 bits little endian mono samples.
+
+The first message is a text message with JSON describing the stream. The
+browsers that are too slow to receive the messages lose the frames and get
+a full frame when they are ready again.
+*/
+
+const (
+	webMessageFrame = 1
+	webMessageAudio = 2
+
+	webFramesPerUpdate = 3 // 20 times per second, 16.7 with PAL
+	webSampleRate      = 22050
+	webAudioMaxDelayMs = 500
+	webClientQueueSize = 16
+)
+
+type webClient struct {
+	ws        *webSocket
+	send      chan []uint8
+	needsFull atomic.Bool
+}
+
+type webStreamer struct {
+	a          *izapple2.Apple2
+	mutex      sync.Mutex
+	clients    map[*webClient]bool
+	screenMode atomic.Int32
+	last       *image.RGBA
+
+	clickChannel chan uint64
+	resampler    *izapple2.SpeakerResampler
+	samples      []int16
+}
+
+func newWebStreamer(a *izapple2.Apple2) *webStreamer {
+	var s webStreamer
+	s.a = a
+	s.clients = make(map[*webClient]bool)
+	s.screenMode.Store(int32(a.GetVideoTiming().DefaultScreenMode()))
+	s.clickChannel = make(chan uint64, 1000)
+	s.resampler = izapple2.NewSpeakerResampler(a.GetVideoTiming().ClockMhz, webSampleRate, a.GetPublishedCycles())
+	a.SetSpeakerProvider(&s)
+	return &s
+}
+
+func (s *webStreamer) getScreenMode() int {
+	return int(s.screenMode.Load())
+}
+
+func (s *webStreamer) setScreenMode(screenMode int) {
+	s.screenMode.Store(int32(screenMode))
+}
+
+// Click receives a speaker click. The argument is the CPU cycle when it is generated
+func (s *webStreamer) Click(cycle uint64) {
+	select {
+	case s.clickChannel <- cycle:
+		// Sent
+	default:
+		// The channel is full, the click is lost.
+	}
+}
+
+func (s *webStreamer) addClient(ws *webSocket) *webClient {
+	c := &webClient{ws: ws, send: make(chan []uint8, webClientQueueSize)}
+	c.needsFull.Store(true)
+
+	info, _ := json.Marshal(map[string]any{
+		"name":       s.a.Name,
+		"sampleRate": webSampleRate,
+		"frameRate":  s.a.GetVideoTiming().FrameRate() / webFramesPerUpdate,
+	})
+	ws.writeMessage(wsOpText, info)
+
+	s.mutex.Lock()
+	s.clients[c] = true
+	s.mutex.Unlock()
+
+	go func() {
+		for message := range c.send {
+			err := ws.writeMessage(wsOpBinary, message)
+			if err != nil {
+				ws.close()
+				return
+			}
+		}
+	}()
+	return c
+}
+
+func (s *webStreamer) removeClient(c *webClient) {
+	s.mutex.Lock()
+	if s.clients[c] {
+		delete(s.clients, c)
+		close(c.send)
+	}
+	s.mutex.Unlock()
+	c.ws.close()
+}
+
+// run sends the updates to the clients until the program ends
+func (s *webStreamer) run() {
+	period := time.Duration(float64(time.Second) * webFramesPerUpdate / s.a.GetVideoTiming().FrameRate())
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+	for range ticker.C {
+		s.update()
+	}
+}
+
+func (s *webStreamer) update() {
+	audio := s.buildAudioMessage()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if len(s.clients) == 0 {
+		s.last = nil
+		return
+	}
+
+	img := screen.Snapshot(s.a.GetVideoSource(), s.getScreenMode())
+	var full, delta []uint8
+	if s.last == nil || s.last.Bounds() != img.Bounds() {
+		full = buildFrameMessage(img, img.Bounds())
+		delta = full
+	} else {
+		changed := changedRect(s.last, img)
+		if !changed.Empty() {
+			delta = buildFrameMessage(img, changed)
+		}
+	}
+	s.last = img
+
+	for c := range s.clients {
+		message := delta
+		if c.needsFull.Load() {
+			if full == nil {
+				full = buildFrameMessage(img, img.Bounds())
+			}
+			message = full
+		}
+		if message != nil && !s.trySend(c, message) {
+			// The next frame has to be complete
+			c.needsFull.Store(true)
+			continue
+		}
+		c.needsFull.Store(false)
+		if audio != nil {
+			s.trySend(c, audio)
+		}
+	}
+}
+
+func (s *webStreamer) trySend(c *webClient, message []uint8) bool {
+	select {
+	case c.send <- message:
+		return true
+	default:
+		return false
+	}
+}
+
+// changedRect returns the rectangle with the pixels that are different
+func changedRect(previous *image.RGBA, img *image.RGBA) image.Rectangle {
+	b := img.Bounds()
+	minX, minY, maxX, maxY := b.Max.X, b.Max.Y, b.Min.X, b.Min.Y
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		offset := img.PixOffset(b.Min.X, y)
+		rowP := previous.Pix[offset : offset+4*b.Dx()]
+		row := img.Pix[offset : offset+4*b.Dx()]
+		if bytes.Equal(rowP, row) {
+			continue
+		}
+		minY = min(minY, y)
+		maxY = max(maxY, y+1)
+		for x := 0; x < b.Dx(); x++ {
+			if !bytes.Equal(rowP[4*x:4*x+4], row[4*x:4*x+4]) {
+				minX = min(minX, b.Min.X+x)
+				maxX = max(maxX, b.Min.X+x+1)
+			}
+		}
+	}
+	if minY >= maxY {
+		return image.Rectangle{} // No changes
+	}
+	return image.Rect(minX, minY, maxX, maxY)
+}
+
+func buildFrameMessage(img *image.RGBA, rect image.Rectangle) []uint8 {
+	var buffer bytes.Buffer
+	buffer.WriteByte(webMessageFrame)
+	binary.Write(&buffer, binary.LittleEndian, []uint16{
+		uint16(rect.Min.X), uint16(rect.Min.Y),
+		uint16(img.Bounds().Dx()), uint16(img.Bounds().Dy()),
+	})
+	encoder := png.Encoder{CompressionLevel: png.BestSpeed}
+	err := encoder.Encode(&buffer, img.SubImage(rect))
+	if err != nil {
+		fmt.Printf("Error encoding frame: %v\n", err)
+		return nil
+	}
+	return buffer.Bytes()
+}
+
+// buildAudioMessage generates the samples up to the current cycle from the speaker clicks
+func (s *webStreamer) buildAudioMessage() []uint8 {
+	// The clicks received later are after this cycle
+	untilCycle := s.a.GetPublishedCycles()
+	done := false
+	for !done {
+		select {
+		case cycle := <-s.clickChannel:
+			s.resampler.Click(cycle)
+		default:
+			done = true
+		}
+	}
+
+	// Too far behind, after a pause or on full speed. Skip.
+	s.resampler.Resync(untilCycle, webSampleRate, webAudioMaxDelayMs)
+
+	s.samples = s.resampler.AppendSamples(s.samples[:0], untilCycle)
+	if len(s.samples) == 0 {
+		return nil
+	}
+	message := []uint8{webMessageAudio}
+	for _, sample := range s.samples {
+		message = binary.LittleEndian.AppendUint16(message, uint16(sample))
+	}
+	return message
+}
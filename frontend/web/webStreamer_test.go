@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"testing"
+	"time"
+
+	"github.com/ivanizag/izapple2"
+)
+
+// applyFrameMessage draws a frame message on the image, as the page does
+func applyFrameMessage(t *testing.T, img *image.RGBA, message []uint8) *image.RGBA {
+	if message[0] != webMessageFrame {
+		t.Fatalf("Unexpected message type %v", message[0])
+	}
+	var header [4]uint16
+	err := binary.Read(bytes.NewReader(message[1:9]), binary.LittleEndian, &header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img == nil {
+		img = image.NewRGBA(image.Rect(0, 0, int(header[2]), int(header[3])))
+	}
+	rect, err := png.Decode(bytes.NewReader(message[9:]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	origin := image.Pt(int(header[0]), int(header[1]))
+	draw.Draw(img, rect.Bounds().Sub(rect.Bounds().Min).Add(origin), rect, rect.Bounds().Min, draw.Src)
+	return img
+}
+
+func TestWebFrameDeltas(t *testing.T) {
+	first := image.NewRGBA(image.Rect(0, 0, 280, 192))
+	draw.Draw(first, first.Bounds(), image.NewUniform(color.RGBA{0, 0, 0, 255}), image.Point{}, draw.Src)
+	second := image.NewRGBA(first.Bounds())
+	copy(second.Pix, first.Pix)
+	second.Set(10, 20, color.RGBA{255, 0, 0, 255})
+	second.Set(30, 25, color.RGBA{0, 255, 0, 255})
+
+	changed := changedRect(first, second)
+	if changed != image.Rect(10, 20, 31, 26) {
+		t.Errorf("Unexpected changed rectangle %v", changed)
+	}
+	if !changedRect(second, second).Empty() {
+		t.Error("No changes expected")
+	}
+
+	// The page rebuilds the screen with the full frame and the delta
+	img := applyFrameMessage(t, nil, buildFrameMessage(first, first.Bounds()))
+	if !bytes.Equal(img.Pix, first.Pix) {
+		t.Error("The full frame is different")
+	}
+	img = applyFrameMessage(t, img, buildFrameMessage(second, changed))
+	if !bytes.Equal(img.Pix, second.Pix) {
+		t.Error("The frame after the delta is different")
+	}
+}
+
+func TestWebAudioWhileRunning(t *testing.T) {
+	a, err := izapple2.CreateApple("2enh", map[string]string{"speed": "full"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newWebStreamer(a)
+	done := make(chan struct{})
+	go func() {
+		a.Run()
+		close(done)
+	}()
+	defer func() {
+		a.SendCommand(izapple2.CommandKill)
+		<-done
+	}()
+
+	// The samples follow the cycles of the emulation running on its goroutine
+	samples := 0
+	for i := 0; i < 1000 && samples == 0; i++ {
+		samples += len(s.buildAudioMessage())
+		time.Sleep(time.Millisecond)
+	}
+	if samples == 0 {
+		t.Error("No audio samples while the emulation runs")
+	}
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+/*
+Minimal WebSocket server, just what is needed to talk with the page. No
+extensions and no fragmented messages from the browser. The upgrades from
+pages served by other sites are rejected, they could type on the emulator.
+
+See https://datatracker.ietf.org/doc/html/rfc6455
+*/
+
+const (
+	wsOpText   = 0x1
+	wsOpBinary = 0x2
+	wsOpClose  = 0x8
+	wsOpPing   = 0x9
+	wsOpPong   = 0xa
+
+	wsMagicKey        = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+	wsMaxMessageBytes = 64 * 1024
+)
+
+type webSocket struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	mutex  sync.Mutex // For the writes
+}
+
+func wsAcceptKey(key string) string {
+	h := sha1.New()
+	h.Write([]byte(key + wsMagicKey))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// wsSameOrigin returns true if the request comes from a page of this server.
+// The clients that are not browsers don't send the Origin header.
+func wsSameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, r.Host)
+}
+
+// upgradeWebSocket completes the handshake of a WebSocket request
+func upgradeWebSocket(w http.ResponseWriter, r *http.Request) (*webSocket, error) {
+	if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		http.Error(w, "websocket expected", http.StatusBadRequest)
+		return nil, errors.New("not a websocket request")
+	}
+	if !wsSameOrigin(r) {
+		http.Error(w, "cross origin websocket", http.StatusForbidden)
+		return nil, fmt.Errorf("websocket from origin %v rejected", r.Header.Get("Origin"))
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if key == "" {
+		http.Error(w, "missing Sec-WebSocket-Key", http.StatusBadRequest)
+		return nil, errors.New("missing Sec-WebSocket-Key")
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "websocket not supported", http.StatusInternalServerError)
+		return nil, errors.New("the connection can't be hijacked")
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, err
+	}
+
+	response := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + wsAcceptKey(key) + "\r\n\r\n"
+	_, err = rw.WriteString(response)
+	if err == nil {
+		err = rw.Flush()
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &webSocket{conn: conn, reader: rw.Reader}, nil
+}
+
+// readMessage returns the next text or binary message, answering the pings
+func (ws *webSocket) readMessage() (uint8, []uint8, error) {
+	for {
+		var header [2]uint8
+		_, err := io.ReadFull(ws.reader, header[:])
+		if err != nil {
+			return 0, nil, err
+		}
+		opcode := header[0] & 0x0f
+		masked := header[1]&0x80 != 0
+		length := uint64(header[1] & 0x7f)
+		switch length {
+		case 126:
+			var ext [2]uint8
+			_, err = io.ReadFull(ws.reader, ext[:])
+			length = uint64(binary.BigEndian.Uint16(ext[:]))
+		case 127:
+			var ext [8]uint8
+			_, err = io.ReadFull(ws.reader, ext[:])
+			length = binary.BigEndian.Uint64(ext[:])
+		}
+		if err != nil {
+			return 0, nil, err
+		}
+		if length > wsMaxMessageBytes {
+			return 0, nil, fmt.Errorf("websocket message too long, %v bytes", length)
+		}
+
+		var mask [4]uint8
+		if masked {
+			_, err = io.ReadFull(ws.reader, mask[:])
+			if err != nil {
+				return 0, nil, err
+			}
+		}
+		payload := make([]uint8, length)
+		_, err = io.ReadFull(ws.reader, payload)
+		if err != nil {
+			return 0, nil, err
+		}
+		if masked {
+			for i := range payload {
+				payload[i] ^= mask[i%4]
+			}
+		}
+
+		switch opcode {
+		case wsOpPing:
+			err = ws.writeMessage(wsOpPong, payload)
+			if err != nil {
+				return 0, nil, err
+			}
+		case wsOpPong:
+			// Ignored
+		case wsOpClose:
+			ws.writeMessage(wsOpClose, nil)
+			return 0, nil, io.EOF
+		default:
+			return opcode, payload, nil
+		}
+	}
+}
+
+// writeMessage sends a message in a single unmasked frame
+func (ws *webSocket) writeMessage(opcode uint8, payload []uint8) error {
+	header := []uint8{0x80 | opcode} // Final fragment
+	length := len(payload)
+	switch {
+	case length < 126:
+		header = append(header, uint8(length))
+	case length <= 0xffff:
+		header = append(header, 126)
+		header = binary.BigEndian.AppendUint16(header, uint16(length))
+	default:
+		header = append(header, 127)
+		header = binary.BigEndian.AppendUint64(header, uint64(length))
+	}
+
+	ws.mutex.Lock()
+	defer ws.mutex.Unlock()
+	_, err := ws.conn.Write(append(header, payload...))
+	return err
+}
+
+func (ws *webSocket) close() error {
+	return ws.conn.Close()
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// wsTestClient is the browser side of the connection, it sends masked frames
+type wsTestClient struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+func dialWebSocket(t *testing.T, server *httptest.Server, origin string) (*wsTestClient, *http.Response) {
+	conn, err := net.Dial("tcp", server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	request, _ := http.NewRequest("GET", server.URL+"/ws", nil)
+	request.Header.Set("Upgrade", "websocket")
+	request.Header.Set("Connection", "Upgrade")
+	request.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	request.Header.Set("Sec-WebSocket-Version", "13")
+	if origin != "" {
+		request.Header.Set("Origin", origin)
+	}
+	err = request.Write(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader := bufio.NewReader(conn)
+	response, err := http.ReadResponse(reader, request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &wsTestClient{conn, reader}, response
+}
+
+func (c *wsTestClient) write(t *testing.T, opcode uint8, payload []uint8) {
+	mask := []uint8{0x12, 0x34, 0x56, 0x78}
+	frame := []uint8{0x80 | opcode}
+	if len(payload) < 126 {
+		frame = append(frame, 0x80|uint8(len(payload)))
+	} else {
+		frame = append(frame, 0x80|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(len(payload)))
+	}
+	frame = append(frame, mask...)
+	for i, value := range payload {
+		frame = append(frame, value^mask[i%4])
+	}
+	_, err := c.conn.Write(frame)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func (c *wsTestClient) read(t *testing.T) (uint8, []uint8) {
+	var header [2]uint8
+	_, err := io.ReadFull(c.reader, header[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header[1]&0x80 != 0 {
+		t.Error("the server frames must not be masked")
+	}
+	length := int(header[1] & 0x7f)
+	if length == 126 {
+		var ext [2]uint8
+		io.ReadFull(c.reader, ext[:])
+		length = int(binary.BigEndian.Uint16(ext[:]))
+	}
+	payload := make([]uint8, length)
+	_, err = io.ReadFull(c.reader, payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return header[0] & 0x0f, payload
+}
+
+// newEchoServer returns the messages received back to the client
+func newEchoServer(t *testing.T) (*httptest.Server, chan error) {
+	done := make(chan error, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws, err := upgradeWebSocket(w, r)
+		if err != nil {
+			done <- err
+			return
+		}
+		defer ws.close()
+		for {
+			opcode, message, err := ws.readMessage()
+			if err != nil {
+				done <- err
+				return
+			}
+			ws.writeMessage(opcode, message)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, done
+}
+
+func TestWebSocketAcceptKey(t *testing.T) {
+	// Example from RFC 6455, section 1.3
+	if key := wsAcceptKey("dGhlIHNhbXBsZSBub25jZQ=="); key != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Errorf("Unexpected accept key %v", key)
+	}
+}
+
+func TestWebSocketHandshake(t *testing.T) {
+	server, _ := newEchoServer(t)
+	_, response := dialWebSocket(t, server, "")
+	if response.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("Unexpected status %v", response.Status)
+	}
+	if key := response.Header.Get("Sec-WebSocket-Accept"); key != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Errorf("Unexpected accept key %v", key)
+	}
+}
+
+func TestWebSocketOrigin(t *testing.T) {
+	server, done := newEchoServer(t)
+	_, response := dialWebSocket(t, server, server.URL)
+	if response.StatusCode != http.StatusSwitchingProtocols {
+		t.Errorf("Same origin rejected with status %v", response.Status)
+	}
+
+	_, response = dialWebSocket(t, server, "http://example.com")
+	if response.StatusCode != http.StatusForbidden {
+		t.Errorf("Cross origin accepted with status %v", response.Status)
+	}
+	if err := <-done; err == nil || !strings.Contains(err.Error(), "origin") {
+		t.Errorf("Unexpected error %v", err)
+	}
+}
+
+func TestWebSocketMessages(t *testing.T) {
+	server, done := newEchoServer(t)
+	client, _ := dialWebSocket(t, server, "")
+
+	// Masked frames are decoded
+	client.write(t, wsOpText, []uint8("hello"))
+	opcode, payload := client.read(t)
+	if opcode != wsOpText || string(payload) != "hello" {
+		t.Errorf("Unexpected echo %v '%s'", opcode, payload)
+	}
+	long := bytes.Repeat([]uint8{1, 2, 3}, 100)
+	client.write(t, wsOpBinary, long)
+	opcode, payload = client.read(t)
+	if opcode != wsOpBinary || !bytes.Equal(payload, long) {
+		t.Errorf("Unexpected echo %v of %v bytes", opcode, len(payload))
+	}
+
+	// The pings are answered with the same payload
+	client.write(t, wsOpPing, []uint8("ping"))
+	opcode, payload = client.read(t)
+	if opcode != wsOpPong || string(payload) != "ping" {
+		t.Errorf("Unexpected answer to ping %v '%s'", opcode, payload)
+	}
+
+	// The pongs are ignored
+	client.write(t, wsOpPong, nil)
+	client.write(t, wsOpText, []uint8("after"))
+	_, payload = client.read(t)
+	if string(payload) != "after" {
+		t.Errorf("Unexpected echo '%s'", payload)
+	}
+
+	// The close is answered and ends the reads
+	client.write(t, wsOpClose, nil)
+	opcode, _ = client.read(t)
+	if opcode != wsOpClose {
+		t.Errorf("Unexpected answer to close %v", opcode)
+	}
+	if err := <-done; err != io.EOF {
+		t.Errorf("Unexpected error after close %v", err)
+	}
+}
@@ -0,0 +1,128 @@
+package fujinet
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+/*
+Hosts on the host slots of the Fujinet. "SD" is the local SD card, mapped
+to a directory of the computer running the emulator. Any other name is a
+TNFS server.
+*/
+
+// HostLocal is the name of the host slot for the SD card
+const HostLocal = "SD"
+
+// Host is a source of disk images
+type Host interface {
+	Mount() error
+	Unmount()
+	ReadDir(path string) ([]DirEntry, error)
+	ReadFile(path string) ([]uint8, error)
+}
+
+// DirEntry is a file or directory on a host
+type DirEntry struct {
+	Name     string
+	IsDir    bool
+	Size     uint32
+	Modified time.Time
+}
+
+// NewHost returns the host for a name on a host slot
+func NewHost(name string, localRoot string) Host {
+	if strings.EqualFold(name, HostLocal) {
+		return &hostLocal{root: localRoot}
+	}
+	return &hostTnfs{client: NewTnfsClient(name)}
+}
+
+type hostLocal struct {
+	root string
+}
+
+func (h *hostLocal) Mount() error {
+	if h.root == "" {
+		return errors.New("no directory configured for the SD host")
+	}
+	_, err := os.ReadDir(h.root)
+	return err
+}
+
+func (h *hostLocal) Unmount() {
+	// Nothing to do
+}
+
+func (h *hostLocal) localPath(path string) string {
+	// Paths are always inside the root
+	return filepath.Join(h.root, filepath.FromSlash(filepath.Clean("/"+path)))
+}
+
+func (h *hostLocal) ReadDir(path string) ([]DirEntry, error) {
+	files, err := os.ReadDir(h.localPath(path))
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []DirEntry
+	for _, file := range files {
+		info, err := file.Info()
+		if err != nil {
+			continue
+		}
+		entries = append(entries, DirEntry{
+			Name:     file.Name(),
+			IsDir:    file.IsDir(),
+			Size:     uint32(info.Size()),
+			Modified: info.ModTime(),
+		})
+	}
+	return entries, nil
+}
+
+func (h *hostLocal) ReadFile(path string) ([]uint8, error) {
+	return os.ReadFile(h.localPath(path))
+}
+
+type hostTnfs struct {
+	client *TnfsClient
+}
+
+func (h *hostTnfs) Mount() error {
+	return h.client.Mount("/")
+}
+
+func (h *hostTnfs) Unmount() {
+	// Nobody waits for the answer, the host is not used again
+	go h.client.Umount()
+}
+
+func (h *hostTnfs) ReadDir(path string) ([]DirEntry, error) {
+	names, err := h.client.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+
+	var entries []DirEntry
+	for _, name := range names {
+		entry := DirEntry{Name: name}
+		stat, err := h.client.Stat(strings.TrimSuffix(path, "/") + "/" + name)
+		if err == nil {
+			entry.IsDir = stat.IsDir
+			entry.Size = stat.Size
+			entry.Modified = stat.Modified
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+func (h *hostTnfs) ReadFile(path string) ([]uint8, error) {
+	return h.client.ReadFile(path)
+}
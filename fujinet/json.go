@@ -1,7 +1,10 @@
 package fujinet
 
 import (
+	"bytes"
 	"encoding/json"
+	"io"
+	"math"
 	"strconv"
 	"strings"
 )
@@ -10,11 +13,27 @@ import (
 See:
 	https://github.com/FujiNetWIFI/fujinet-platformio/tree/master/lib/fnjson
 	https://github.com/FujiNetWIFI/fujinet-platformio/wiki/JSON-Query-Format
+
+The queries are JSON pointers as in RFC 6901, like "/a/0/b". The objects
+keep the order of the members to return the values in the same order as
+the cJSON library used by the firmware.
+
+The values are also generated by setting values on paths, to build the
+bodies of POST requests.
 */
 
 type FnJson struct {
-	data   any
-	Result []uint8
+	data       any
+	LineEnding string // Separator of the values of arrays and objects, added at the end
+	Result     []uint8
+}
+
+// jsonObject is a JSON object with the members in order
+type jsonObject []jsonMember
+
+type jsonMember struct {
+	key   string
+	value any
 }
 
 func NewFnJson() *FnJson {
@@ -24,57 +43,133 @@ func NewFnJson() *FnJson {
 
 func (js *FnJson) Parse(data []uint8) ErrorCode {
 	// See FNJSON::parse()
-	err := json.Unmarshal(data, &js.data)
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	value, err := parseJsonValue(decoder)
 	if err != nil {
 		return NetworkErrorJsonParseError
 	}
+	if _, err = decoder.Token(); err != io.EOF {
+		// Extra data after the value
+		return NetworkErrorJsonParseError
+	}
+	js.data = value
 	return NoError
 }
 
+func parseJsonValue(decoder *json.Decoder) (any, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch token {
+	case json.Delim('{'):
+		object := make(jsonObject, 0)
+		for decoder.More() {
+			key, err := decoder.Token()
+			if err != nil {
+				return nil, err
+			}
+			value, err := parseJsonValue(decoder)
+			if err != nil {
+				return nil, err
+			}
+			object = append(object, jsonMember{key.(string), value})
+		}
+		_, err = decoder.Token() // Closing delimiter
+		return object, err
+
+	case json.Delim('['):
+		array := make([]any, 0)
+		for decoder.More() {
+			value, err := parseJsonValue(decoder)
+			if err != nil {
+				return nil, err
+			}
+			array = append(array, value)
+		}
+		_, err = decoder.Token() // Closing delimiter
+		return array, err
+	}
+
+	// A bool, float64, string or nil
+	return token, nil
+}
+
 func (js *FnJson) Query(query []uint8) {
 	// See FNJSON::setReadQuery
 	// See https://github.com/kbranigan/cJSON/blob/master/cJSON_Utils.c
-	if query[len(query)-1] == 0 {
-		query = query[0 : len(query)-1]
+	current, found := js.resolve(query)
+	if !found {
+		current = nil
 	}
-	queryString := string(query)
-	queryString = strings.TrimSuffix(queryString, "/0")
+	js.Result = append(js.getJsonValue(current), js.LineEnding...)
+}
+
+// queryPath splits the JSON pointer on the unescaped path segments
+func queryPath(query []uint8) []string {
+	queryString := strings.TrimRight(string(query), "\x00\r\n")
 	queryString = strings.TrimPrefix(queryString, "/")
 	queryString = strings.TrimSuffix(queryString, "/")
+	if queryString == "" {
+		// The full document
+		return nil
+	}
+
 	path := strings.Split(queryString, "/")
+	for i := range path {
+		path[i] = strings.ReplaceAll(path[i], "~1", "/")
+		path[i] = strings.ReplaceAll(path[i], "~0", "~")
+	}
+	return path
+}
+
+// arrayIndex decodes an index as cJSON does, only digits without leading zeros
+func arrayIndex(segment string) (int, bool) {
+	if segment == "" || (len(segment) > 1 && segment[0] == '0') {
+		return 0, false
+	}
+	for _, c := range segment {
+		if c < '0' || c > '9' {
+			return 0, false
+		}
+	}
+	index, err := strconv.Atoi(segment)
+	return index, err == nil
+}
 
-	js.Result = getJsonValue(nil)
+func (js *FnJson) resolve(query []uint8) (any, bool) {
 	current := js.data
-	for i := 0; i < len(path); i++ {
+	for _, segment := range queryPath(query) {
 		switch v := current.(type) {
-		case map[string]any:
-			var found bool
-			current, found = v[path[i]]
+		case jsonObject:
+			found := false
+			for _, member := range v {
+				if member.key == segment {
+					current = member.value
+					found = true
+					break
+				}
+			}
 			if !found {
-				// Not found
-				return
+				return nil, false
 			}
 		case []any:
-			index, err := strconv.Atoi(path[i])
-			if err != nil {
-				// Path for arrays should be an int
-				return
-			}
-			if index < 0 || index >= len(v) {
-				// Index out of bounds
-				return
+			index, ok := arrayIndex(segment)
+			if !ok || index >= len(v) {
+				// Path for arrays should be an int in bounds
+				return nil, false
 			}
 			current = v[index]
 		default:
 			// It's a leaf. We can't go down
-			return
+			return nil, false
 		}
 	}
-
-	js.Result = getJsonValue(current)
+	return current, true
 }
 
-func getJsonValue(data any) []uint8 {
+func (js *FnJson) getJsonValue(data any) []uint8 {
 	// See FNJson::getValue
 	if data == nil {
 		return []uint8("NULL")
@@ -88,25 +183,32 @@ func getJsonValue(data any) []uint8 {
 			return []uint8("FALSE")
 		}
 	case float64:
-		// if math.Floor(v) == v { // As done in FNJSON__getValue()
-		//  It's an integer
-		return []uint8(strconv.Itoa(int(v)))
-		// } else {
-		//	 return []uint8(fmt.Sprintf("%.10f", v))
-		// }
+		if math.Floor(v) == v && math.Abs(v) < math.MaxInt64 {
+			// It's an integer
+			return []uint8(strconv.FormatInt(int64(v), 10))
+		}
+		// Same as the default formatting of C++ streams
+		return []uint8(strconv.FormatFloat(v, 'g', 6, 64))
 	case string:
 		return []uint8(v)
 	case []any:
 		s := make([]uint8, 0)
 		for i := 0; i < len(v); i++ {
-			s = append(s, getJsonValue(v[i])...)
+			if i > 0 {
+				s = append(s, js.LineEnding...)
+			}
+			s = append(s, js.getJsonValue(v[i])...)
 		}
 		return s
-	case map[string]any:
+	case jsonObject:
 		s := make([]uint8, 0)
-		for k, e := range v {
-			s = append(s, []uint8(k)...)
-			s = append(s, getJsonValue(e)...)
+		for i, member := range v {
+			if i > 0 {
+				s = append(s, js.LineEnding...)
+			}
+			s = append(s, member.key...)
+			s = append(s, js.LineEnding...)
+			s = append(s, js.getJsonValue(member.value)...)
 		}
 		return s
 	default:
@@ -114,3 +216,126 @@ func getJsonValue(data any) []uint8 {
 		return []uint8("UNKNOWN")
 	}
 }
+
+// Set stores a value on the path, creating the objects and arrays needed.
+// The value is stored as a bool for TRUE and FALSE, as null for NULL, as
+// a number if it is a valid JSON number and as a string otherwise. Quoted
+// values are always strings.
+func (js *FnJson) Set(query []uint8, value []uint8) ErrorCode {
+	path := queryPath(query)
+	if len(path) == 0 {
+		js.data = parseSetValue(value)
+		return NoError
+	}
+
+	var err ErrorCode
+	js.data, err = setJsonValue(js.data, path, parseSetValue(value))
+	return err
+}
+
+func parseSetValue(value []uint8) any {
+	valueString := strings.TrimRight(string(value), "\x00\r\n")
+	switch valueString {
+	case "TRUE":
+		return true
+	case "FALSE":
+		return false
+	case "NULL":
+		return nil
+	}
+
+	var parsed any
+	if strings.HasPrefix(valueString, "\"") {
+		var s string
+		if json.Unmarshal([]uint8(valueString), &s) == nil {
+			return s
+		}
+	} else if json.Unmarshal([]uint8(valueString), &parsed) == nil {
+		if number, ok := parsed.(float64); ok {
+			return number
+		}
+	}
+	return valueString
+}
+
+func setJsonValue(current any, path []string, value any) (any, ErrorCode) {
+	if len(path) == 0 {
+		return value, NoError
+	}
+	segment := path[0]
+
+	if current == nil {
+		// Create the container, an array if the path is an index
+		if _, ok := arrayIndex(segment); ok {
+			current = make([]any, 0)
+		} else {
+			current = make(jsonObject, 0)
+		}
+	}
+
+	switch v := current.(type) {
+	case jsonObject:
+		for i := range v {
+			if v[i].key == segment {
+				var err ErrorCode
+				v[i].value, err = setJsonValue(v[i].value, path[1:], value)
+				return v, err
+			}
+		}
+		child, err := setJsonValue(nil, path[1:], value)
+		return append(v, jsonMember{segment, child}), err
+
+	case []any:
+		index, ok := arrayIndex(segment)
+		if !ok || index > len(v) {
+			// Only existing elements or the next one can be set
+			return v, NetworkErrorInvalidCommand
+		}
+		if index == len(v) {
+			v = append(v, nil)
+		}
+		var err ErrorCode
+		v[index], err = setJsonValue(v[index], path[1:], value)
+		return v, err
+	}
+
+	// A leaf can't have children
+	return current, NetworkErrorInvalidCommand
+}
+
+// Generate returns the JSON text of the values set
+func (js *FnJson) Generate() []uint8 {
+	return generateJsonValue(nil, js.data)
+}
+
+func generateJsonValue(s []uint8, data any) []uint8 {
+	switch v := data.(type) {
+	case []any:
+		s = append(s, '[')
+		for i, element := range v {
+			if i > 0 {
+				s = append(s, ',')
+			}
+			s = generateJsonValue(s, element)
+		}
+		return append(s, ']')
+	case jsonObject:
+		s = append(s, '{')
+		for i, member := range v {
+			if i > 0 {
+				s = append(s, ',')
+			}
+			s = generateJsonValue(s, member.key)
+			s = append(s, ':')
+			s = generateJsonValue(s, member.value)
+		}
+		return append(s, '}')
+	}
+
+	// Scalars can always be encoded
+	var buffer bytes.Buffer
+	encoder := json.NewEncoder(&buffer)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(data)
+	return append(s, bytes.TrimSuffix(buffer.Bytes(), []uint8{'\n'})...)
+}
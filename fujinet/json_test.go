@@ -125,3 +125,96 @@ func TestQueryArray(t *testing.T) {
 	}
 	testQuerys(t, testArrayMessage, testCases)
 }
+
+func TestQueryNested(t *testing.T) {
+	// See https://github.com/FujiNetWIFI/fujinet-apps/tree/master/weather
+	testMessage := `{"coord": {"lon": -0.1257, "lat": 51.5085},
+		"weather": [{"id": 803, "main": "Clouds", "description": "broken clouds"}],
+		"main": {"temp": 281.15, "pressure": 1012, "humidity": 1e-7},
+		"visibility": 10000, "rain": null, "snow": false,
+		"a/b": 1, "m~n": 2, "list": [[1, 2], [3, [4, 5]]]}`
+
+	testCases := [][]string{
+		{"/weather/0/description", "broken clouds"},
+		{"/weather/0/id", "803"},
+		{"/coord/lon", "-0.1257"},
+		{"/main/temp", "281.15"},
+		{"/main/humidity", "1e-07"},
+		{"/visibility", "10000"},
+		{"/rain", "NULL"},
+		{"/snow", "FALSE"},
+		{"/a~1b", "1"},
+		{"/m~0n", "2"},
+		{"/list/1/1/0", "4"},
+		{"/list/01", "NULL"},
+		{"/weather/0", "id803mainCloudsdescriptionbroken clouds"},
+		{"/list", "12345"},
+		{"/weather/0/description\x00", "broken clouds"},
+	}
+	testQuerys(t, testMessage, testCases)
+}
+
+func TestQueryLineEnding(t *testing.T) {
+	js := NewFnJson()
+	js.LineEnding = JsonLineEnding(TranslationLF)
+	js.Parse([]uint8(`{"b": 2, "a": [true, "x"]}`))
+
+	js.Query([]uint8("/b"))
+	if string(js.Result) != "2\r" {
+		t.Errorf("Unexpected leaf value %q", js.Result)
+	}
+	js.Query([]uint8("/"))
+	if string(js.Result) != "b\r2\ra\rTRUE\rx\r" {
+		t.Errorf("Unexpected object value %q", js.Result)
+	}
+}
+
+func TestParseError(t *testing.T) {
+	for _, message := range []string{`{"a": }`, `{"a": 1} 2`, ``} {
+		if NewFnJson().Parse([]uint8(message)) != NetworkErrorJsonParseError {
+			t.Errorf("Parse error expected for '%s'", message)
+		}
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	js := NewFnJson()
+	values := [][]string{
+		{"/name", "Apple <II>"},
+		{"/year", "1977"},
+		{"/color", "TRUE"},
+		{"/code", `"0123"`},
+		{"/cpu/0/model", "6502"},
+		{"/cpu/1/model", "65C02"},
+		{"/slots", "NULL"},
+	}
+	for _, pair := range values {
+		if js.Set([]uint8(pair[0]), []uint8(pair[1])) != NoError {
+			t.Errorf("Error setting %s", pair[0])
+		}
+	}
+	if js.Set([]uint8("/cpu/5"), []uint8("1")) == NoError {
+		t.Error("Error expected setting an array index out of bounds")
+	}
+
+	expected := `{"name":"Apple <II>","year":1977,"color":true,"code":"0123",` +
+		`"cpu":[{"model":6502},{"model":"65C02"}],"slots":null}`
+	if result := string(js.Generate()); result != expected {
+		t.Errorf("Unexpected JSON %s", result)
+	}
+}
+
+func TestTranslation(t *testing.T) {
+	if result := TranslateReceive([]uint8("a\r\nb\r\n"), TranslationCRLF); string(result) != "a\rb\r" {
+		t.Errorf("Unexpected CRLF translation %q", result)
+	}
+	if result := TranslateReceive([]uint8("a\nb"), TranslationLF); string(result) != "a\rb" {
+		t.Errorf("Unexpected LF translation %q", result)
+	}
+	if result := TranslateTransmit([]uint8("a\r"), TranslationCRLF); string(result) != "a\r\n" {
+		t.Errorf("Unexpected CRLF transmit translation %q", result)
+	}
+	if result := TranslateReceive([]uint8("a\r\n"), TranslationNone); string(result) != "a\r\n" {
+		t.Errorf("Unexpected translation without mode %q", result)
+	}
+}
@@ -6,21 +6,52 @@ import (
 )
 
 type Protocol interface {
-	Open(urlParsed *url.URL)
+	Open(urlParsed *url.URL) ErrorCode
 	Close()
+	// ReadAll consumes all the data received, it does not block
 	ReadAll() ([]uint8, ErrorCode)
 	Write(data []uint8) error
 }
 
+// StreamProtocol is a protocol read in chunks, like TCP, UDP or HTTP
+type StreamProtocol interface {
+	Protocol
+	// Status returns the bytes waiting to be read and the connection state
+	Status() (waiting int, connected bool, errorCode ErrorCode)
+	// Read consumes up to length bytes of the data waiting
+	Read(length int) ([]uint8, ErrorCode)
+	// Control executes the protocol specific commands
+	Control(code uint8, data []uint8) ErrorCode
+}
+
+// BackgroundProtocol is a protocol receiving the full response in the background
+type BackgroundProtocol interface {
+	// Ready returns true when the response is complete, it sends the request if needed
+	Ready() bool
+}
+
+// SeekableProtocol is a protocol where the reads can start at any position
+type SeekableProtocol interface {
+	Seek(position int)
+}
+
 type ErrorCode uint8
 
 const (
 	// See fujinet-platformio/lib/network-protocol/status_error_codes.h
 	NoError                       = ErrorCode(0)
+	NetworkErrorInvalidCommand    = ErrorCode(132)
 	NetworkErrorEndOfFile         = ErrorCode(136)
 	NetworkErrorGeneral           = ErrorCode(144)
 	NetworkErrorNotImplemented    = ErrorCode(146)
 	NetworkErrorInvalidDeviceSpec = ErrorCode(165)
+	NetworkErrorAccessDenied      = ErrorCode(167)
+	NetworkErrorFileNotFound      = ErrorCode(170)
+	NetworkErrorConnectionRefused = ErrorCode(200)
+	NetworkErrorAddressInUse      = ErrorCode(206)
+	NetworkErrorNotConnected      = ErrorCode(207)
+	NetworkErrorServerNotRunning  = ErrorCode(208)
+	NetworkErrorNoConnWaiting     = ErrorCode(209)
 
 	// New
 	NetworkErrorJsonParseError = ErrorCode(250)
@@ -33,6 +64,10 @@ func InstantiateProtocol(urlParsed *url.URL, method uint8) (Protocol, ErrorCode)
 		return newProtocolHttp(method), NoError
 	case "HTTPS":
 		return newProtocolHttp(method), NoError
+	case "TCP":
+		return newProtocolTcp(), NoError
+	case "UDP":
+		return newProtocolUdp(), NoError
 	default:
 		return nil, NetworkErrorGeneral
 	}
@@ -1,48 +1,276 @@
 package fujinet
 
 import (
+	"bytes"
 	"io"
 	"net/http"
+	"net/textproto"
 	"net/url"
+	"slices"
+	"strings"
+	"time"
 )
 
+/*
+HTTP protocol of the Fujinet network device.
+
+See:
+	https://github.com/FujiNetWIFI/fujinet-platformio/blob/master/lib/network-protocol/HTTP.cpp
+
+The method comes from the open mode. The request is sent on the first
+read or status, with the data written before as body. It runs in the
+background to not stop the emulation, no data is waiting until the full
+response arrives. Requests other than GET never read are sent on close. The channel mode,
+set with the 'M' command, changes the meaning of the writes and reads:
+	0: Body of the request and the response
+	1: Collect headers, the writes are names of response headers to keep
+	2: Get headers, the reads return the response headers collected
+	3: Set headers, the writes are "Name: value" request headers
+	4: Send post data, the writes are added to the body
+*/
+
+const (
+	httpOpenGet             = 4
+	httpOpenDelete          = 5
+	httpOpenPut             = 8
+	httpOpenDeleteHeaders   = 9
+	httpOpenGetHeaders      = 12
+	httpOpenPost            = 13
+	httpOpenPutHeaders      = 14
+	httpModeBody            = 0
+	httpModeCollectHeaders  = 1
+	httpModeGetHeaders      = 2
+	httpModeSetHeaders      = 3
+	httpModeSendPostData    = 4
+	httpHeaderLineSeparator = "\n"
+	httpTimeout             = 10 * time.Second
+)
+
+var httpClient = &http.Client{Timeout: httpTimeout}
+
 type protocolHttp struct {
 	method uint8
 	url    *url.URL
+	mode   uint8
+
+	requestHeaders http.Header
+	collectHeaders []string
+	requestBody    []uint8
+
+	started   bool
+	done      chan struct{} // Closed when the response is complete
+	errorCode ErrorCode
+	body      []uint8
+	headers   []uint8 // Collected response headers
+	position  int     // Read position on the body
+	headerPos int     // Read position on the headers
 }
 
 func newProtocolHttp(method uint8) *protocolHttp {
 	var p protocolHttp
 	p.method = method
+	p.requestHeaders = make(http.Header)
+	p.done = make(chan struct{})
 	return &p
 }
 
-func (p *protocolHttp) Open(urlParsed *url.URL) {
+func (p *protocolHttp) Open(urlParsed *url.URL) ErrorCode {
 	p.url = urlParsed
+	if p.httpMethod() == "" {
+		return NetworkErrorNotImplemented
+	}
+	return NoError
+}
+
+func (p *protocolHttp) httpMethod() string {
+	switch p.method {
+	case httpOpenGet, httpOpenGetHeaders:
+		return http.MethodGet
+	case httpOpenDelete, httpOpenDeleteHeaders:
+		return http.MethodDelete
+	case httpOpenPut, httpOpenPutHeaders:
+		return http.MethodPut
+	case httpOpenPost:
+		return http.MethodPost
+	}
+	return ""
 }
 
 func (p *protocolHttp) Close() {
-	// nothing to do
+	if p.httpMethod() != http.MethodGet {
+		// Writes without reads, the request is sent on close
+		p.start()
+	}
 }
 
-func (p *protocolHttp) ReadAll() ([]uint8, ErrorCode) {
-	if p.method == 12 /*GET*/ {
-		resp, err := http.Get(p.url.String())
-		if err != nil {
-			return nil, NetworkErrorGeneral
+// start sends the request in the background, once
+func (p *protocolHttp) start() {
+	if p.started {
+		return
+	}
+	p.started = true
+
+	var body io.Reader
+	if len(p.requestBody) > 0 {
+		body = bytes.NewReader(slices.Clone(p.requestBody))
+	}
+	request, err := http.NewRequest(p.httpMethod(), p.url.String(), body)
+	if err != nil {
+		p.errorCode = NetworkErrorInvalidDeviceSpec
+		close(p.done)
+		return
+	}
+	for name, values := range p.requestHeaders {
+		request.Header[name] = slices.Clone(values)
+	}
+	collectHeaders := slices.Clone(p.collectHeaders)
+
+	go func() {
+		p.fetch(request, collectHeaders)
+		close(p.done)
+	}()
+}
+
+// Ready returns true when the response is complete, it sends the request if needed
+func (p *protocolHttp) Ready() bool {
+	p.start()
+	select {
+	case <-p.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// fetch receives the full response, it runs on its own goroutine
+func (p *protocolHttp) fetch(request *http.Request, collectHeaders []string) {
+	response, err := httpClient.Do(request)
+	if err != nil {
+		p.errorCode = NetworkErrorGeneral
+		return
+	}
+	defer response.Body.Close()
+
+	p.body, err = io.ReadAll(response.Body)
+	if err != nil {
+		p.errorCode = NetworkErrorGeneral
+		return
+	}
+
+	for _, name := range collectHeaders {
+		value := response.Header.Get(name)
+		if value != "" {
+			line := textproto.CanonicalMIMEHeaderKey(name) + ": " + value + httpHeaderLineSeparator
+			p.headers = append(p.headers, line...)
 		}
-		defer resp.Body.Close()
+	}
 
-		data, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return nil, NetworkErrorGeneral
+	switch {
+	case response.StatusCode == http.StatusNotFound:
+		p.errorCode = NetworkErrorFileNotFound
+	case response.StatusCode == http.StatusUnauthorized || response.StatusCode == http.StatusForbidden:
+		p.errorCode = NetworkErrorAccessDenied
+	case response.StatusCode >= 400:
+		p.errorCode = NetworkErrorGeneral
+	}
+}
+
+// pending returns the data left to read on the current channel mode
+func (p *protocolHttp) pending() []uint8 {
+	if p.mode == httpModeGetHeaders {
+		return p.headers[p.headerPos:]
+	}
+	return p.body[min(p.position, len(p.body)):]
+}
+
+func (p *protocolHttp) Status() (int, bool, ErrorCode) {
+	if !p.Ready() {
+		// The request is in progress
+		return 0, true, NoError
+	}
+	waiting := len(p.pending())
+	if p.errorCode != NoError {
+		return waiting, false, p.errorCode
+	}
+	if waiting == 0 {
+		return 0, false, NetworkErrorEndOfFile
+	}
+	return waiting, true, NoError
+}
+
+func (p *protocolHttp) Read(length int) ([]uint8, ErrorCode) {
+	if !p.Ready() {
+		return nil, NoError
+	}
+	data := p.pending()
+	if len(data) == 0 {
+		if p.errorCode != NoError {
+			return nil, p.errorCode
 		}
-		return data, NoError
+		return nil, NetworkErrorEndOfFile
 	}
 
-	return nil, NetworkErrorNotImplemented
+	data = data[:min(length, len(data))]
+	if p.mode == httpModeGetHeaders {
+		p.headerPos += len(data)
+	} else {
+		p.position += len(data)
+	}
+	return data, NoError
+}
+
+// Seek sets the position of the next read on the body
+func (p *protocolHttp) Seek(position int) {
+	p.position = position
+}
+
+func (p *protocolHttp) ReadAll() ([]uint8, ErrorCode) {
+	if !p.Ready() {
+		return nil, NoError
+	}
+	if p.errorCode != NoError {
+		return nil, p.errorCode
+	}
+	return p.body, NoError
 }
 
 func (p *protocolHttp) Write(data []uint8) error {
+	switch p.mode {
+	case httpModeSetHeaders:
+		for _, line := range splitLines(data) {
+			name, value, found := strings.Cut(line, ":")
+			if found {
+				p.requestHeaders.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+			}
+		}
+	case httpModeCollectHeaders:
+		for _, line := range splitLines(data) {
+			p.collectHeaders = append(p.collectHeaders, strings.TrimSpace(line))
+		}
+	case httpModeBody, httpModeSendPostData:
+		p.requestBody = append(p.requestBody, data...)
+	}
 	return nil
 }
+
+func (p *protocolHttp) Control(code uint8, data []uint8) ErrorCode {
+	switch code {
+	case 'M':
+		// Set the channel mode
+		if len(data) < 1 || data[0] > httpModeSendPostData {
+			return NetworkErrorInvalidCommand
+		}
+		p.mode = data[0]
+		return NoError
+	}
+
+	return NetworkErrorNotImplemented
+}
+
+// splitLines returns the non empty lines, ended in CR, LF or zero
+func splitLines(data []uint8) []string {
+	lines := strings.FieldsFunc(string(data), func(r rune) bool {
+		return r == '\r' || r == '\n' || r == 0
+	})
+	return lines
+}
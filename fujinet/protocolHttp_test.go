@@ -0,0 +1,162 @@
+package fujinet
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Requests received on /log
+var logged = make(chan string, 10)
+
+func startHttpTestServer(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/text":
+			w.Write([]uint8("0123456789"))
+		case "/slow":
+			time.Sleep(200 * time.Millisecond)
+			w.Write([]uint8("done"))
+		case "/echo":
+			body, _ := io.ReadAll(r.Body)
+			w.Header().Set("X-Method", r.Method)
+			w.Write([]uint8(r.Header.Get("X-Test") + ":"))
+			w.Write(body)
+		case "/log":
+			body, _ := io.ReadAll(r.Body)
+			logged <- r.Method + ":" + string(body)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func openHttpTest(t *testing.T, method uint8, address string) *protocolHttp {
+	urlParsed, err := url.Parse(address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := newProtocolHttp(method)
+	if p.Open(urlParsed) != NoError {
+		t.Fatal("Error opening " + address)
+	}
+	return p
+}
+
+// wait blocks until the response is complete or the request times out
+func (p *protocolHttp) wait() {
+	p.start()
+	<-p.done
+}
+
+func TestHttpGetChunked(t *testing.T) {
+	server := startHttpTestServer(t)
+	p := openHttpTest(t, httpOpenGet, server.URL+"/text")
+
+	p.wait()
+	waiting, connected, errorCode := p.Status()
+	if waiting != 10 || !connected || errorCode != NoError {
+		t.Errorf("Unexpected status %v, %v, %v", waiting, connected, errorCode)
+	}
+
+	data, _ := p.Read(4)
+	if string(data) != "0123" {
+		t.Errorf("Unexpected first chunk '%s'", data)
+	}
+	p.Seek(8)
+	data, _ = p.Read(4)
+	if string(data) != "89" {
+		t.Errorf("Unexpected chunk after seek '%s'", data)
+	}
+
+	_, _, errorCode = p.Status()
+	if errorCode != NetworkErrorEndOfFile {
+		t.Errorf("End of file expected, got %v", errorCode)
+	}
+}
+
+func TestHttpPostWithHeaders(t *testing.T) {
+	server := startHttpTestServer(t)
+	p := openHttpTest(t, httpOpenPost, server.URL+"/echo")
+
+	p.Control('M', []uint8{httpModeSetHeaders})
+	p.Write([]uint8("X-Test: hello\r\n"))
+	p.Control('M', []uint8{httpModeCollectHeaders})
+	p.Write([]uint8("x-method\n"))
+	p.Control('M', []uint8{httpModeSendPostData})
+	p.Write([]uint8("body"))
+
+	p.Control('M', []uint8{httpModeBody})
+	p.wait()
+	data, errorCode := p.Read(100)
+	if errorCode != NoError || string(data) != "hello:body" {
+		t.Errorf("Unexpected response '%s', %v", data, errorCode)
+	}
+
+	p.Control('M', []uint8{httpModeGetHeaders})
+	data, _ = p.Read(100)
+	if string(data) != "X-Method: POST\n" {
+		t.Errorf("Unexpected headers '%s'", data)
+	}
+}
+
+func TestHttpNotFound(t *testing.T) {
+	server := startHttpTestServer(t)
+	p := openHttpTest(t, httpOpenGet, server.URL+"/missing")
+
+	p.wait()
+	_, _, errorCode := p.Status()
+	if errorCode != NetworkErrorFileNotFound {
+		t.Errorf("File not found expected, got %v", errorCode)
+	}
+}
+
+func TestHttpInBackground(t *testing.T) {
+	server := startHttpTestServer(t)
+	p := openHttpTest(t, httpOpenGet, server.URL+"/slow")
+
+	waiting, connected, errorCode := p.Status()
+	if waiting != 0 || !connected || errorCode != NoError {
+		t.Errorf("Request in progress expected, got %v, %v, %v", waiting, connected, errorCode)
+	}
+	data, errorCode := p.Read(100)
+	if len(data) != 0 || errorCode != NoError {
+		t.Errorf("No data expected while in progress, got '%s', %v", data, errorCode)
+	}
+
+	p.wait()
+	data, _ = p.Read(100)
+	if string(data) != "done" {
+		t.Errorf("Unexpected response '%s'", data)
+	}
+}
+
+func TestHttpSentOnClose(t *testing.T) {
+	server := startHttpTestServer(t)
+	methods := map[uint8]string{
+		httpOpenPost:   "POST:post",
+		httpOpenPut:    "PUT:put",
+		httpOpenDelete: "DELETE:delete",
+	}
+
+	for method, expected := range methods {
+		p := openHttpTest(t, method, server.URL+"/log")
+		p.Write([]uint8(expected[strings.Index(expected, ":")+1:]))
+		p.Close()
+
+		select {
+		case received := <-logged:
+			if received != expected {
+				t.Errorf("Expected %s, got %s", expected, received)
+			}
+		case <-time.After(5 * time.Second):
+			t.Errorf("%s not sent on close", expected)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package fujinet
+
+import (
+	"errors"
+	"net"
+	"net/url"
+	"time"
+)
+
+/*
+TCP protocol of the Fujinet network device.
+
+See:
+	https://github.com/FujiNetWIFI/fujinet-platformio/blob/master/lib/network-protocol/TCP.cpp
+
+"TCP://host:port" connects to a server. "TCP://:port" listens for
+connections on the port, a waiting client is reported as connected on the
+status and it has to be accepted with the 'A' command. The 'c' command
+closes the client connection and keeps listening.
+*/
+
+const tcpDialTimeout = 5 * time.Second
+
+type protocolTcp struct {
+	conn     net.Conn
+	buffer   *streamBuffer
+	listener net.Listener
+	pending  chan net.Conn // Clients waiting to be accepted
+}
+
+func newProtocolTcp() *protocolTcp {
+	var p protocolTcp
+	return &p
+}
+
+func (p *protocolTcp) Open(urlParsed *url.URL) ErrorCode {
+	if urlParsed.Port() == "" {
+		return NetworkErrorInvalidDeviceSpec
+	}
+
+	if urlParsed.Hostname() == "" {
+		// Server mode
+		listener, err := net.Listen("tcp", urlParsed.Host)
+		if err != nil {
+			return NetworkErrorAddressInUse
+		}
+		p.listener = listener
+		p.pending = make(chan net.Conn, 8)
+		go p.acceptLoop(listener, p.pending)
+		return NoError
+	}
+
+	conn, err := net.DialTimeout("tcp", urlParsed.Host, tcpDialTimeout)
+	if err != nil {
+		return NetworkErrorConnectionRefused
+	}
+	p.startReading(conn)
+	return NoError
+}
+
+func (p *protocolTcp) acceptLoop(listener net.Listener, pending chan net.Conn) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			// The listener has been closed
+			return
+		}
+		select {
+		case pending <- conn:
+		default:
+			// Too many clients waiting
+			conn.Close()
+		}
+	}
+}
+
+func (p *protocolTcp) startReading(conn net.Conn) {
+	p.conn = conn
+	buffer := &streamBuffer{}
+	p.buffer = buffer
+	go func() {
+		data := make([]uint8, 1024)
+		for {
+			n, err := conn.Read(data)
+			if n > 0 {
+				buffer.append(data[:n])
+			}
+			if err != nil {
+				buffer.close()
+				return
+			}
+		}
+	}()
+}
+
+func (p *protocolTcp) closeClient() {
+	if p.conn != nil {
+		p.conn.Close()
+		p.conn = nil
+	}
+}
+
+func (p *protocolTcp) Close() {
+	p.closeClient()
+	if p.listener != nil {
+		p.listener.Close()
+		p.listener = nil
+		for len(p.pending) > 0 {
+			(<-p.pending).Close()
+		}
+	}
+}
+
+func (p *protocolTcp) Status() (int, bool, ErrorCode) {
+	if p.conn == nil {
+		if p.listener == nil {
+			return 0, false, NetworkErrorNotConnected
+		}
+		return 0, len(p.pending) > 0, NoError
+	}
+
+	waiting, closed := p.buffer.status()
+	if closed && waiting == 0 {
+		return 0, false, NetworkErrorEndOfFile
+	}
+	return waiting, !closed, NoError
+}
+
+func (p *protocolTcp) Read(length int) ([]uint8, ErrorCode) {
+	if p.conn == nil {
+		return nil, NetworkErrorNotConnected
+	}
+
+	data := p.buffer.read(length)
+	if len(data) == 0 {
+		if _, closed := p.buffer.status(); closed {
+			return nil, NetworkErrorEndOfFile
+		}
+	}
+	return data, NoError
+}
+
+func (p *protocolTcp) ReadAll() ([]uint8, ErrorCode) {
+	if p.conn == nil {
+		return nil, NetworkErrorNotConnected
+	}
+	return p.buffer.readAll(), NoError
+}
+
+func (p *protocolTcp) Write(data []uint8) error {
+	if p.conn == nil {
+		return errors.New("not connected")
+	}
+	_, err := p.conn.Write(data)
+	return err
+}
+
+func (p *protocolTcp) Control(code uint8, data []uint8) ErrorCode {
+	switch code {
+	case 'A':
+		// Accept a waiting client
+		if p.listener == nil {
+			return NetworkErrorServerNotRunning
+		}
+		select {
+		case conn := <-p.pending:
+			p.closeClient()
+			p.startReading(conn)
+			return NoError
+		default:
+			return NetworkErrorNoConnWaiting
+		}
+
+	case 'c':
+		// Close the client connection
+		p.closeClient()
+		return NoError
+	}
+
+	return NetworkErrorNotImplemented
+}
@@ -0,0 +1,157 @@
+package fujinet
+
+import (
+	"net"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func openProtocol(t *testing.T, rawUrl string) StreamProtocol {
+	urlParsed, err := url.Parse(rawUrl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, errorCode := InstantiateProtocol(urlParsed, 0)
+	if errorCode != NoError {
+		t.Fatalf("Error %v instantiating %s", errorCode, rawUrl)
+	}
+	errorCode = p.Open(urlParsed)
+	if errorCode != NoError {
+		t.Fatalf("Error %v opening %s", errorCode, rawUrl)
+	}
+	return p.(StreamProtocol)
+}
+
+func waitForBytes(t *testing.T, p StreamProtocol, count int) {
+	for i := 0; i < 200; i++ {
+		waiting, _, _ := p.Status()
+		if waiting >= count {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("Timeout waiting for %v bytes", count)
+}
+
+func TestTcpClient(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	p := openProtocol(t, "TCP://"+listener.Addr().String())
+	defer p.Close()
+
+	conn, err := listener.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn.Write([]uint8("HELLO"))
+	waitForBytes(t, p, 5)
+	data, errorCode := p.Read(3)
+	if errorCode != NoError || string(data) != "HEL" {
+		t.Errorf("Unexpected read '%s' with error %v", data, errorCode)
+	}
+	waiting, connected, _ := p.Status()
+	if waiting != 2 || !connected {
+		t.Errorf("Expected 2 bytes waiting and connected, got %v and %v", waiting, connected)
+	}
+
+	err = p.Write([]uint8("WORLD"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	received := make([]uint8, 5)
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, err = conn.Read(received)
+	if err != nil || string(received) != "WORLD" {
+		t.Errorf("Unexpected write '%s' with error %v", received, err)
+	}
+
+	conn.Close()
+	p.Read(2)
+	for i := 0; i < 200; i++ {
+		_, connected, _ = p.Status()
+		if !connected {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	_, connected, errorCode = p.Status()
+	if connected || errorCode != NetworkErrorEndOfFile {
+		t.Errorf("Expected disconnection, got %v with error %v", connected, errorCode)
+	}
+}
+
+func TestTcpServer(t *testing.T) {
+	// Find a free port
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+
+	p := openProtocol(t, "TCP://:"+strconv.Itoa(port))
+	defer p.Close()
+
+	if p.Control('A', nil) != NetworkErrorNoConnWaiting {
+		t.Error("No client expected")
+	}
+
+	conn, err := net.Dial("tcp", "127.0.0.1:"+strconv.Itoa(port))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	for i := 0; i < 200; i++ {
+		if _, connected, _ := p.Status(); connected {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if p.Control('A', nil) != NoError {
+		t.Fatal("Client expected")
+	}
+
+	conn.Write([]uint8("PING"))
+	waitForBytes(t, p, 4)
+	data, _ := p.Read(10)
+	if string(data) != "PING" {
+		t.Errorf("Unexpected read '%s'", data)
+	}
+}
+
+func TestUdp(t *testing.T) {
+	server, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	p := openProtocol(t, "UDP://"+server.LocalAddr().String())
+	defer p.Close()
+
+	err = p.Write([]uint8("ASK"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	received := make([]uint8, 100)
+	server.SetReadDeadline(time.Now().Add(2 * time.Second))
+	n, remote, err := server.ReadFromUDP(received)
+	if err != nil || string(received[:n]) != "ASK" {
+		t.Fatalf("Unexpected datagram '%s' with error %v", received[:n], err)
+	}
+
+	server.WriteToUDP([]uint8("ANSWER"), remote)
+	waitForBytes(t, p, 6)
+	data, _ := p.Read(100)
+	if string(data) != "ANSWER" {
+		t.Errorf("Unexpected read '%s'", data)
+	}
+}
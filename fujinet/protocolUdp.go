@@ -0,0 +1,147 @@
+package fujinet
+
+import (
+	"errors"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+/*
+UDP protocol of the Fujinet network device.
+
+See:
+	https://github.com/FujiNetWIFI/fujinet-platformio/blob/master/lib/network-protocol/UDP.cpp
+
+"UDP://host:port" sends the datagrams to that destination from any local
+port. "UDP://:port" listens on the port. The datagrams received are
+queued as a stream. Without a destination, the writes go to the sender of
+the last datagram received. The 'D' command sets a new destination as
+"host:port".
+*/
+
+type protocolUdp struct {
+	conn   *net.UDPConn
+	buffer *streamBuffer
+
+	mutex sync.Mutex // For dest, updated by the receiving goroutine
+	dest  *net.UDPAddr
+	fixed bool // The destination was set explicitly
+}
+
+func newProtocolUdp() *protocolUdp {
+	var p protocolUdp
+	return &p
+}
+
+func (p *protocolUdp) Open(urlParsed *url.URL) ErrorCode {
+	if urlParsed.Port() == "" {
+		return NetworkErrorInvalidDeviceSpec
+	}
+
+	local := &net.UDPAddr{}
+	if urlParsed.Hostname() == "" {
+		// Server mode
+		localAddress, err := net.ResolveUDPAddr("udp", urlParsed.Host)
+		if err != nil {
+			return NetworkErrorInvalidDeviceSpec
+		}
+		local = localAddress
+	} else {
+		errorCode := p.setDestination(urlParsed.Host)
+		if errorCode != NoError {
+			return errorCode
+		}
+	}
+
+	conn, err := net.ListenUDP("udp", local)
+	if err != nil {
+		return NetworkErrorAddressInUse
+	}
+	p.conn = conn
+	p.buffer = &streamBuffer{}
+	go p.receiveLoop(conn, p.buffer)
+	return NoError
+}
+
+func (p *protocolUdp) setDestination(address string) ErrorCode {
+	dest, err := net.ResolveUDPAddr("udp", address)
+	if err != nil {
+		return NetworkErrorInvalidDeviceSpec
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.dest = dest
+	p.fixed = true
+	return NoError
+}
+
+func (p *protocolUdp) receiveLoop(conn *net.UDPConn, buffer *streamBuffer) {
+	data := make([]uint8, 65536)
+	for {
+		n, remote, err := conn.ReadFromUDP(data)
+		if err != nil {
+			buffer.close()
+			return
+		}
+		buffer.append(data[:n])
+
+		p.mutex.Lock()
+		if !p.fixed {
+			p.dest = remote
+		}
+		p.mutex.Unlock()
+	}
+}
+
+func (p *protocolUdp) Close() {
+	if p.conn != nil {
+		p.conn.Close()
+		p.conn = nil
+	}
+}
+
+func (p *protocolUdp) Status() (int, bool, ErrorCode) {
+	if p.conn == nil {
+		return 0, false, NetworkErrorNotConnected
+	}
+	waiting, _ := p.buffer.status()
+	return waiting, true, NoError // Connectionless
+}
+
+func (p *protocolUdp) Read(length int) ([]uint8, ErrorCode) {
+	if p.conn == nil {
+		return nil, NetworkErrorNotConnected
+	}
+	return p.buffer.read(length), NoError
+}
+
+func (p *protocolUdp) ReadAll() ([]uint8, ErrorCode) {
+	if p.conn == nil {
+		return nil, NetworkErrorNotConnected
+	}
+	return p.buffer.readAll(), NoError
+}
+
+func (p *protocolUdp) Write(data []uint8) error {
+	p.mutex.Lock()
+	dest := p.dest
+	p.mutex.Unlock()
+
+	if p.conn == nil || dest == nil {
+		return errors.New("no destination")
+	}
+	_, err := p.conn.WriteToUDP(data, dest)
+	return err
+}
+
+func (p *protocolUdp) Control(code uint8, data []uint8) ErrorCode {
+	switch code {
+	case 'D':
+		// Set the destination
+		return p.setDestination(strings.TrimRight(string(data), "\x00"))
+	}
+
+	return NetworkErrorNotImplemented
+}
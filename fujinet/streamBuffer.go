@@ -0,0 +1,53 @@
+package fujinet
+
+import (
+	"sync"
+)
+
+/*
+Data received in the background from a connection, waiting to be read
+by the Apple II.
+*/
+type streamBuffer struct {
+	mutex  sync.Mutex
+	data   []uint8
+	closed bool // No more data will arrive
+}
+
+func (b *streamBuffer) append(data []uint8) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.data = append(b.data, data...)
+}
+
+func (b *streamBuffer) close() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.closed = true
+}
+
+func (b *streamBuffer) status() (int, bool) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return len(b.data), b.closed
+}
+
+func (b *streamBuffer) read(length int) []uint8 {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if length > len(b.data) {
+		length = len(b.data)
+	}
+	data := make([]uint8, length)
+	copy(data, b.data)
+	b.data = b.data[length:]
+	return data
+}
+
+func (b *streamBuffer) readAll() []uint8 {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	data := b.data
+	b.data = nil
+	return data
+}
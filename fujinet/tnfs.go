@@ -0,0 +1,252 @@
+package fujinet
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+/*
+TNFS client, the Trivial Network File System used by the Fujinet to
+access remote disk images.
+
+See:
+	https://github.com/FujiNetWIFI/spectranet/blob/master/tnfs/tnfs-protocol.md
+
+The messages are UDP datagrams with a header of connection id, sequence
+number and command. The responses repeat the header adding a status
+byte. Lost messages are retried. The calls block until the response or
+the timeouts, the fuji device makes them off the emulation thread.
+*/
+
+const (
+	TnfsDefaultPort = 16384
+
+	tnfsCommandMount    = uint8(0x00)
+	tnfsCommandUmount   = uint8(0x01)
+	tnfsCommandOpenDir  = uint8(0x10)
+	tnfsCommandReadDir  = uint8(0x11)
+	tnfsCommandCloseDir = uint8(0x12)
+	tnfsCommandRead     = uint8(0x21)
+	tnfsCommandClose    = uint8(0x23)
+	tnfsCommandStat     = uint8(0x24)
+	tnfsCommandOpenFile = uint8(0x29)
+
+	tnfsStatusSuccess = uint8(0x00)
+	tnfsStatusEOF     = uint8(0x21)
+
+	tnfsOpenReadOnly = uint16(0x0001)
+	tnfsModeDir      = uint16(0o040000)
+
+	tnfsVersion     = uint16(0x0102) // 1.2
+	tnfsMaxRead     = 512
+	tnfsTimeout     = 1 * time.Second
+	tnfsRetries     = 5
+	tnfsDialTimeout = 5 * time.Second
+	tnfsHeaderLen   = 4
+)
+
+// TnfsClient is a connection to a TNFS server
+type TnfsClient struct {
+	address string
+	conn    net.Conn
+	connID  uint16
+	seq     uint8
+}
+
+// TnfsStat is the information of a file on a TNFS server
+type TnfsStat struct {
+	IsDir    bool
+	Size     uint32
+	Modified time.Time
+}
+
+// TnfsError is an error status returned by the TNFS server
+type TnfsError uint8
+
+func (e TnfsError) Error() string {
+	return fmt.Sprintf("TNFS error $%02x", uint8(e))
+}
+
+// NewTnfsClient creates a client for the server on host or host:port
+func NewTnfsClient(host string) *TnfsClient {
+	var c TnfsClient
+	if strings.Contains(host, ":") {
+		c.address = host
+	} else {
+		c.address = fmt.Sprintf("%s:%v", host, TnfsDefaultPort)
+	}
+	return &c
+}
+
+// Mount opens a session on the server for the path provided
+func (c *TnfsClient) Mount(path string) error {
+	conn, err := net.DialTimeout("udp", c.address, tnfsDialTimeout)
+	if err != nil {
+		return err
+	}
+	c.conn = conn
+	c.connID = 0
+
+	request := binary.LittleEndian.AppendUint16(nil, tnfsVersion)
+	request = appendCString(request, path)
+	request = appendCString(request, "")       // User
+	request = appendCString(request, "")       // Password
+	_, err = c.exec(tnfsCommandMount, request) // Server version and retry time are ignored
+	if err != nil {
+		c.conn.Close()
+		c.conn = nil
+		return err
+	}
+	return nil
+}
+
+// Umount closes the session
+func (c *TnfsClient) Umount() {
+	if c.conn == nil {
+		return
+	}
+	c.exec(tnfsCommandUmount, nil)
+	c.conn.Close()
+	c.conn = nil
+}
+
+// ReadDir returns the names on a directory of the server
+func (c *TnfsClient) ReadDir(path string) ([]string, error) {
+	response, err := c.exec(tnfsCommandOpenDir, appendCString(nil, path))
+	if err != nil {
+		return nil, err
+	}
+	if len(response) < 1 {
+		return nil, errors.New("TNFS directory handle missing")
+	}
+	handle := response[0]
+	defer c.exec(tnfsCommandCloseDir, []uint8{handle})
+
+	var names []string
+	for {
+		response, err = c.exec(tnfsCommandReadDir, []uint8{handle})
+		if err == TnfsError(tnfsStatusEOF) {
+			return names, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		name := readCString(response)
+		if name != "." && name != ".." {
+			names = append(names, name)
+		}
+	}
+}
+
+// Stat returns the file information
+func (c *TnfsClient) Stat(path string) (*TnfsStat, error) {
+	response, err := c.exec(tnfsCommandStat, appendCString(nil, path))
+	if err != nil {
+		return nil, err
+	}
+	if len(response) < 18 {
+		return nil, errors.New("TNFS stat response too short")
+	}
+	var stat TnfsStat
+	mode := binary.LittleEndian.Uint16(response[0:])
+	stat.IsDir = mode&tnfsModeDir != 0
+	stat.Size = binary.LittleEndian.Uint32(response[6:])
+	stat.Modified = time.Unix(int64(binary.LittleEndian.Uint32(response[14:])), 0)
+	return &stat, nil
+}
+
+// ReadFile returns the full contents of a file on the server
+func (c *TnfsClient) ReadFile(path string) ([]uint8, error) {
+	request := binary.LittleEndian.AppendUint16(nil, tnfsOpenReadOnly)
+	request = binary.LittleEndian.AppendUint16(request, 0) // Mode, not used to read
+	request = appendCString(request, path)
+	response, err := c.exec(tnfsCommandOpenFile, request)
+	if err != nil {
+		return nil, err
+	}
+	if len(response) < 1 {
+		return nil, errors.New("TNFS file descriptor missing")
+	}
+	fd := response[0]
+	defer c.exec(tnfsCommandClose, []uint8{fd})
+
+	var data []uint8
+	for {
+		request = binary.LittleEndian.AppendUint16([]uint8{fd}, tnfsMaxRead)
+		response, err = c.exec(tnfsCommandRead, request)
+		if err == TnfsError(tnfsStatusEOF) {
+			return data, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(response) < 2 {
+			return nil, errors.New("TNFS read response too short")
+		}
+		size := int(binary.LittleEndian.Uint16(response))
+		if size == 0 || size > len(response)-2 {
+			return data, nil
+		}
+		data = append(data, response[2:2+size]...)
+	}
+}
+
+// exec sends a request and waits for the response, it returns the data after the status
+func (c *TnfsClient) exec(command uint8, data []uint8) ([]uint8, error) {
+	if c.conn == nil {
+		return nil, errors.New("TNFS not mounted")
+	}
+
+	c.seq++
+	request := binary.LittleEndian.AppendUint16(nil, c.connID)
+	request = append(request, c.seq, command)
+	request = append(request, data...)
+
+	response := make([]uint8, 1024)
+	for retry := 0; retry < tnfsRetries; retry++ {
+		_, err := c.conn.Write(request)
+		if err != nil {
+			return nil, err
+		}
+
+		// The wait is bounded even when unrelated datagrams arrive
+		c.conn.SetReadDeadline(time.Now().Add(tnfsTimeout))
+		for {
+			n, err := c.conn.Read(response)
+			if err != nil {
+				break // Timeout, retry
+			}
+			if n <= tnfsHeaderLen || response[2] != c.seq || response[3] != command {
+				continue // Not the response to this request
+			}
+
+			if command == tnfsCommandMount {
+				c.connID = binary.LittleEndian.Uint16(response)
+			}
+			status := response[tnfsHeaderLen]
+			if status != tnfsStatusSuccess {
+				return nil, TnfsError(status)
+			}
+			return response[tnfsHeaderLen+1 : n], nil
+		}
+	}
+	return nil, errors.New("TNFS server not responding")
+}
+
+func appendCString(data []uint8, s string) []uint8 {
+	data = append(data, s...)
+	return append(data, 0)
+}
+
+func readCString(data []uint8) string {
+	for i, v := range data {
+		if v == 0 {
+			return string(data[:i])
+		}
+	}
+	return string(data)
+}
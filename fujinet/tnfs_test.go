@@ -0,0 +1,166 @@
+package fujinet
+
+import (
+	"encoding/binary"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// tnfsTestServer serves a local directory with the subset of TNFS used by the client
+type tnfsTestServer struct {
+	conn  *net.UDPConn
+	root  string
+	dirs  map[uint8][]string
+	files map[uint8][]uint8
+	next  uint8
+}
+
+func startTnfsTestServer(t *testing.T, root string) *tnfsTestServer {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &tnfsTestServer{
+		conn:  conn,
+		root:  root,
+		dirs:  make(map[uint8][]string),
+		files: make(map[uint8][]uint8),
+	}
+	go s.serve()
+	return s
+}
+
+func (s *tnfsTestServer) serve() {
+	request := make([]uint8, 1024)
+	for {
+		n, remote, err := s.conn.ReadFromUDP(request)
+		if err != nil {
+			return
+		}
+		if n < tnfsHeaderLen {
+			continue
+		}
+		status, data := s.exec(request[3], request[tnfsHeaderLen:n])
+		response := []uint8{0x34, 0x12, request[2], request[3], status}
+		response = append(response, data...)
+		s.conn.WriteToUDP(response, remote)
+	}
+}
+
+func (s *tnfsTestServer) exec(command uint8, data []uint8) (uint8, []uint8) {
+	const notFound = 0x02
+	switch command {
+	case tnfsCommandMount:
+		return tnfsStatusSuccess, []uint8{0x02, 0x01, 0xe8, 0x03}
+	case tnfsCommandUmount:
+		return tnfsStatusSuccess, nil
+	case tnfsCommandOpenDir:
+		entries, err := os.ReadDir(filepath.Join(s.root, readCString(data)))
+		if err != nil {
+			return notFound, nil
+		}
+		names := []string{".", ".."}
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		s.next++
+		s.dirs[s.next] = names
+		return tnfsStatusSuccess, []uint8{s.next}
+	case tnfsCommandReadDir:
+		names := s.dirs[data[0]]
+		if len(names) == 0 {
+			return tnfsStatusEOF, nil
+		}
+		s.dirs[data[0]] = names[1:]
+		return tnfsStatusSuccess, appendCString(nil, names[0])
+	case tnfsCommandCloseDir:
+		delete(s.dirs, data[0])
+		return tnfsStatusSuccess, nil
+	case tnfsCommandStat:
+		info, err := os.Stat(filepath.Join(s.root, readCString(data)))
+		if err != nil {
+			return notFound, nil
+		}
+		stat := make([]uint8, 22)
+		mode := uint16(0o100644)
+		if info.IsDir() {
+			mode = tnfsModeDir | 0o755
+		}
+		binary.LittleEndian.PutUint16(stat[0:], mode)
+		binary.LittleEndian.PutUint32(stat[6:], uint32(info.Size()))
+		binary.LittleEndian.PutUint32(stat[14:], uint32(info.ModTime().Unix()))
+		return tnfsStatusSuccess, stat
+	case tnfsCommandOpenFile:
+		content, err := os.ReadFile(filepath.Join(s.root, readCString(data[4:])))
+		if err != nil {
+			return notFound, nil
+		}
+		s.next++
+		s.files[s.next] = content
+		return tnfsStatusSuccess, []uint8{s.next}
+	case tnfsCommandRead:
+		content := s.files[data[0]]
+		if len(content) == 0 {
+			return tnfsStatusEOF, nil
+		}
+		size := min(int(binary.LittleEndian.Uint16(data[1:])), len(content))
+		s.files[data[0]] = content[size:]
+		response := binary.LittleEndian.AppendUint16(nil, uint16(size))
+		return tnfsStatusSuccess, append(response, content[:size]...)
+	case tnfsCommandClose:
+		delete(s.files, data[0])
+		return tnfsStatusSuccess, nil
+	}
+	return 0x16, nil // Invalid argument
+}
+
+func TestTnfsClient(t *testing.T) {
+	root := t.TempDir()
+	content := make([]uint8, 1500)
+	for i := range content {
+		content[i] = uint8(i * 3)
+	}
+	err := os.WriteFile(filepath.Join(root, "disk.po"), content, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.Mkdir(filepath.Join(root, "games"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := startTnfsTestServer(t, root)
+	defer server.conn.Close()
+
+	host := NewHost(server.conn.LocalAddr().String(), "")
+	err = host.Mount()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer host.Unmount()
+
+	entries, err := host.ReadDir("/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 ||
+		entries[0].Name != "disk.po" || entries[0].IsDir || entries[0].Size != 1500 ||
+		entries[1].Name != "games" || !entries[1].IsDir {
+		t.Errorf("Unexpected directory: %+v", entries)
+	}
+
+	data, err := host.ReadFile("/disk.po")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != string(content) {
+		t.Errorf("File content mismatch, %v bytes read", len(data))
+	}
+
+	_, err = host.ReadFile("/missing.po")
+	if err != TnfsError(0x02) {
+		t.Errorf("Not found error expected, got %v", err)
+	}
+}
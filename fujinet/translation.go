@@ -0,0 +1,50 @@
+package fujinet
+
+import "bytes"
+
+/*
+Line ending translation of the network device. The translation mode,
+selected when opening the channel, is the line ending used on the
+network side. It is converted to and from CR, the end of line of the
+Apple II.
+
+See:
+	https://github.com/FujiNetWIFI/fujinet-platformio/blob/master/lib/network-protocol/Protocol.cpp
+*/
+
+const (
+	TranslationNone = uint8(0)
+	TranslationCR   = uint8(1)
+	TranslationLF   = uint8(2)
+	TranslationCRLF = uint8(3)
+)
+
+// TranslateReceive converts the line endings of the network to the Apple II ones
+func TranslateReceive(data []uint8, translation uint8) []uint8 {
+	switch translation {
+	case TranslationLF:
+		return bytes.ReplaceAll(data, []uint8("\n"), []uint8("\r"))
+	case TranslationCRLF:
+		return bytes.ReplaceAll(data, []uint8("\r\n"), []uint8("\r"))
+	}
+	return data
+}
+
+// TranslateTransmit converts the Apple II line endings to the network ones
+func TranslateTransmit(data []uint8, translation uint8) []uint8 {
+	switch translation {
+	case TranslationLF:
+		return bytes.ReplaceAll(data, []uint8("\r"), []uint8("\n"))
+	case TranslationCRLF:
+		return bytes.ReplaceAll(data, []uint8("\r"), []uint8("\r\n"))
+	}
+	return data
+}
+
+// JsonLineEnding returns the separator of the JSON values for a translation mode
+func JsonLineEnding(translation uint8) string {
+	if translation == TranslationNone {
+		return ""
+	}
+	return "\r"
+}
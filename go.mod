@@ -6,6 +6,7 @@ toolchain go1.23.2
 
 require (
 	fyne.io/fyne/v2 v2.5.3
+	github.com/ebitengine/oto/v3 v3.3.2
 	github.com/go-gl/glfw/v3.3/glfw v0.0.0-20240506104042-037f3cc74f2a
 	github.com/ivanizag/iz6502 v1.4.0
 	github.com/koron-go/z80 v0.10.1
@@ -20,7 +21,6 @@ require (
 	github.com/chzyer/readline v1.5.1 // indirect
 	github.com/ebitengine/gomobile v0.0.0-20241016134836-cc2e38a7c0ee // indirect
 	github.com/ebitengine/hideconsole v1.0.0 // indirect
-	github.com/ebitengine/oto/v3 v3.3.2 // indirect
 	github.com/ebitengine/purego v0.8.2 // indirect
 	github.com/fyne-io/gl-js v0.0.0-20230506162202-1fdaa286a934 // indirect
 	github.com/fyne-io/glfw-js v0.0.0-20241126112943-313d8a0fe1d0 // indirect
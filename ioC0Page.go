@@ -140,6 +140,9 @@ func (p *ioC0Page) isPanicNotImplemented(address uint16) bool {
 
 func (p *ioC0Page) peek(address uint16) uint8 {
 	pageAddress := uint8(address)
+	if p.apple2.accelerator != nil {
+		p.apple2.accelerator.ioAccess(pageAddress)
+	}
 	ss := p.softSwitchesR[pageAddress]
 	if ss == nil {
 		if p.isTraced(address) {
@@ -160,6 +163,9 @@ func (p *ioC0Page) peek(address uint16) uint8 {
 
 func (p *ioC0Page) poke(address uint16, value uint8) {
 	pageAddress := uint8(address)
+	if p.apple2.accelerator != nil {
+		p.apple2.accelerator.ioAccess(pageAddress)
+	}
 	ss := p.softSwitchesW[pageAddress]
 	if ss == nil {
 		if p.isTraced(address) {
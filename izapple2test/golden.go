@@ -0,0 +1,70 @@
+package izapple2test
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"strings"
+)
+
+/*
+CompareGolden compares the screen with a reference PNG file. When they are
+different, or the reference does not exist, the screen is saved next to it
+with the "_new.png" suffix to review it and replace the reference if right.
+*/
+func (m *Machine) CompareGolden(filename string, screenMode int) error {
+	actual := m.Snapshot(screenMode)
+
+	reference, err := loadPng(filename)
+	if err == nil && sameImage(reference, actual) {
+		return nil
+	}
+
+	newName := strings.TrimSuffix(filename, ".png") + "_new.png"
+	errSave := savePng(newName, actual)
+	if errSave != nil {
+		return errSave
+	}
+	if err != nil {
+		return fmt.Errorf("could not load %s, the screen is saved as %s: %w", filename, newName, err)
+	}
+	return fmt.Errorf("the screen is not as %s, it is saved as %s", filename, newName)
+}
+
+func sameImage(a image.Image, b image.Image) bool {
+	bounds := a.Bounds()
+	if bounds.Size() != b.Bounds().Size() {
+		return false
+	}
+	offset := b.Bounds().Min.Sub(bounds.Min)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			ca := color.RGBAModel.Convert(a.At(x, y))
+			cb := color.RGBAModel.Convert(b.At(x+offset.X, y+offset.Y))
+			if ca != cb {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func loadPng(filename string) (image.Image, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return png.Decode(f)
+}
+
+func savePng(filename string, img image.Image) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return png.Encode(f, img)
+}
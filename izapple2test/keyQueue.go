@@ -0,0 +1,40 @@
+package izapple2test
+
+import "sync"
+
+/*
+Keyboard for the tests. The keys are delivered one by one when the program
+reads the previous one, the queue has no limit to type while paused.
+*/
+type keyQueue struct {
+	mutex sync.Mutex
+	keys  []uint8
+}
+
+func (k *keyQueue) putText(text string) {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+	for _, ch := range text {
+		if ch == '\n' {
+			ch = '\r'
+		}
+		if ch < 0x80 {
+			k.keys = append(k.keys, uint8(ch))
+		}
+	}
+}
+
+// GetKey returns the next key when the previous one has been strobed
+func (k *keyQueue) GetKey(strobed bool) (key uint8, ok bool) {
+	if !strobed {
+		return 0, false
+	}
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+	if len(k.keys) == 0 {
+		return 0, false
+	}
+	key = k.keys[0]
+	k.keys = k.keys[1:]
+	return key, true
+}
@@ -0,0 +1,187 @@
+/*
+Package izapple2test runs emulated Apple II machines from Go tests. It is
+meant to check 6502 software: boot a model, insert a disk or load a binary,
+run until a text shows up or the program gets to an address, and check the
+screen and the memory.
+
+	m, err := izapple2test.New("2enh", map[string]string{"s6": "diskii,disk1=\"game.dsk\""})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	err = m.RunUntilText("PRESS START", 50_000_000)
+	if err != nil {
+		t.Fatalf("%v, the screen is:\n%s", err, m.Text())
+	}
+
+The machine runs at full speed, the cycle counts are emulated 6502 cycles.
+*/
+package izapple2test
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ivanizag/izapple2"
+	"github.com/ivanizag/izapple2/screen"
+)
+
+// ErrTimeout is returned when the cycles limit is reached before the condition
+var ErrTimeout = errors.New("cycles limit reached")
+
+// Machine is an emulated Apple II. It is paused while not running a method.
+type Machine struct {
+	a    *izapple2.Apple2
+	keys keyQueue
+	done chan struct{} // Closed when the emulation ends
+}
+
+/*
+New starts a machine for a model, like "2plus" or "2enh", with changes to its
+configuration. The keys of the overrides are the names of the command line
+options, like "s6" or "cpu". The speed is "full" if not overridden.
+*/
+func New(model string, overrides map[string]string) (*Machine, error) {
+	options := map[string]string{"speed": "full"}
+	for key, value := range overrides {
+		options[key] = value
+	}
+
+	a, err := izapple2.CreateApple(model, options)
+	if err != nil {
+		return nil, err
+	}
+
+	var m Machine
+	m.a = a
+	m.done = make(chan struct{})
+	a.SetKeyboardProvider(&m.keys)
+	go func() {
+		a.Start(true /*paused*/)
+		close(m.done)
+	}()
+	spinWait(func() bool { return a.IsPaused() })
+	return &m, nil
+}
+
+// Close stops the emulation and waits for it to end
+func (m *Machine) Close() {
+	m.a.SendCommand(izapple2.CommandKill)
+	<-m.done
+}
+
+// Apple2 returns the emulator, to use its methods while the machine is paused
+func (m *Machine) Apple2() *izapple2.Apple2 {
+	return m.a
+}
+
+// LoadDisk inserts a disk image on a removable media drive, 0 is the first one
+func (m *Machine) LoadDisk(drive int, filename string) error {
+	return m.a.LoadDisk(drive, filename)
+}
+
+// LoadBinary copies the data to memory starting on the address
+func (m *Machine) LoadBinary(address uint16, data []uint8) {
+	for i, value := range data {
+		m.a.Poke(address+uint16(i), value)
+	}
+}
+
+// LoadBinaryFile copies the content of a file to memory starting on the address
+func (m *Machine) LoadBinaryFile(address uint16, filename string) error {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	if len(data) > 0x10000-int(address) {
+		return fmt.Errorf("%s does not fit in memory at $%04x", filename, address)
+	}
+	m.LoadBinary(address, data)
+	return nil
+}
+
+// Jump sets the address of the next instruction to run
+func (m *Machine) Jump(pc uint16) {
+	m.a.SetPC(pc)
+}
+
+// Type queues the text to the keyboard, a line break is typed as return
+func (m *Machine) Type(text string) {
+	m.keys.putText(text)
+}
+
+// Run runs the machine for some cycles
+func (m *Machine) Run(cycles uint64) {
+	m.a.RunCycles(cycles)
+}
+
+// RunUntilText runs the machine until the text is on the screen. Returns
+// ErrTimeout if maxCycles are run before.
+func (m *Machine) RunUntilText(text string, maxCycles uint64) error {
+	found, err := m.a.RunUntil(func() bool {
+		return strings.Contains(m.Text(), text)
+	}, maxCycles)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("text '%s' not found: %w", text, ErrTimeout)
+	}
+	return nil
+}
+
+// RunUntilPC runs the machine until the 6502 is about to execute the
+// address. Returns ErrTimeout if maxCycles are run before.
+func (m *Machine) RunUntilPC(pc uint16, maxCycles uint64) error {
+	m.a.SetPCBreakpoint(pc)
+	m.a.RunCycles(maxCycles)
+	m.a.ClearPCBreakpoint()
+	if m.a.GetPC() != pc {
+		return fmt.Errorf("PC $%04x not reached: %w", pc, ErrTimeout)
+	}
+	return nil
+}
+
+// Text returns the text screen, including the 80 columns and Videx modes
+func (m *Machine) Text() string {
+	return izapple2.DumpTextMode(m.a)
+}
+
+// Peek returns a byte of memory as seen by the 6502
+func (m *Machine) Peek(address uint16) uint8 {
+	return m.a.Peek(address)
+}
+
+// Memory returns length bytes of memory as seen by the 6502
+func (m *Machine) Memory(address uint16, length int) []uint8 {
+	data := make([]uint8, length)
+	for i := range data {
+		data[i] = m.a.Peek(address + uint16(i))
+	}
+	return data
+}
+
+// PC returns the address of the next instruction to run
+func (m *Machine) PC() uint16 {
+	return m.a.GetPC()
+}
+
+// Cycles returns the count of cycles run since the machine was started
+func (m *Machine) Cycles() uint64 {
+	return m.a.GetCycles()
+}
+
+// Snapshot returns the screen with one of the screen.ScreenMode modes
+func (m *Machine) Snapshot(screenMode int) *image.RGBA {
+	return screen.Snapshot(m.a.GetVideoSource(), screenMode)
+}
+
+func spinWait(f func() bool) {
+	for !f() {
+		time.Sleep(time.Millisecond * 1)
+	}
+}
@@ -0,0 +1,149 @@
+package izapple2test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/ivanizag/izapple2/screen"
+)
+
+func newTestMachine(t *testing.T) *Machine {
+	m, err := New("2plus", map[string]string{"s6": "empty"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(m.Close)
+	return m
+}
+
+func TestBootAndType(t *testing.T) {
+	m := newTestMachine(t)
+
+	err := m.RunUntilText("\n]", 1_000_000)
+	if err != nil {
+		t.Fatalf("%v, got '%s'", err, m.Text())
+	}
+	if !strings.Contains(m.Text(), "APPLE ][") {
+		t.Errorf("Expected 'APPLE ][', got '%s'", m.Text())
+	}
+
+	m.Type("PRINT 6*7\n")
+	err = m.RunUntilText("\n42\n", 1_000_000)
+	if err != nil {
+		t.Fatalf("%v, got '%s'", err, m.Text())
+	}
+}
+
+func TestBinary(t *testing.T) {
+	m := newTestMachine(t)
+
+	m.LoadBinary(0x300, []uint8{
+		0xa9, 0x2a, // LDA #$2A
+		0x8d, 0x10, 0x03, // STA $0310
+		0xea,             // NOP
+		0x4c, 0x06, 0x03, // JMP $0306
+	})
+	m.Jump(0x300)
+
+	err := m.RunUntilPC(0x305, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Peek(0x310) != 0x2a {
+		t.Errorf("Expected $2a, got $%02x", m.Peek(0x310))
+	}
+	if mem := m.Memory(0x300, 2); mem[0] != 0xa9 || mem[1] != 0x2a {
+		t.Errorf("Unexpected memory % x", mem)
+	}
+
+	err = m.RunUntilPC(0x305, 1000)
+	if !errors.Is(err, ErrTimeout) {
+		t.Errorf("Expected a timeout, got %v", err)
+	}
+	if m.PC() != 0x306 {
+		t.Errorf("Expected PC $0306, got $%04x", m.PC())
+	}
+}
+
+func TestTextTimeout(t *testing.T) {
+	m := newTestMachine(t)
+
+	err := m.RunUntilText("NOT THERE", 200_000)
+	if !errors.Is(err, ErrTimeout) {
+		t.Errorf("Expected a timeout, got %v", err)
+	}
+	if m.Cycles() < 200_000 {
+		t.Errorf("Expected at least 200000 cycles, got %v", m.Cycles())
+	}
+}
+
+func TestCloseEndsTheEmulation(t *testing.T) {
+	before := runtime.NumGoroutine()
+	for i := 0; i < 5; i++ {
+		m, err := New("2plus", map[string]string{"s6": "empty"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		m.Run(10_000)
+		m.Close()
+	}
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("Expected %v goroutines after closing the machines, got %v", before, after)
+	}
+}
+
+func TestCompareGolden(t *testing.T) {
+	m := newTestMachine(t)
+	m.Run(1_000_000)
+
+	golden := filepath.Join(t.TempDir(), "boot.png")
+	err := m.CompareGolden(golden, screen.ScreenModePlain)
+	if err == nil {
+		t.Fatal("Expected an error without the reference")
+	}
+
+	err = os.Rename(strings.TrimSuffix(golden, ".png")+"_new.png", golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = m.CompareGolden(golden, screen.ScreenModePlain)
+	if err != nil {
+		t.Error(err)
+	}
+
+	m.Type("HOME\n")
+	m.Run(100_000)
+	err = m.CompareGolden(golden, screen.ScreenModePlain)
+	if err == nil {
+		t.Error("Expected an error with a different screen")
+	}
+}
+
+func TestUnknownOption(t *testing.T) {
+	_, err := New("2plus", map[string]string{"nonexistent": "1"})
+	if err == nil {
+		t.Error("Expected an error for an unknown option")
+	}
+}
+
+func TestFlashFollowsCycles(t *testing.T) {
+	// Two machines run the same cycles show the same flashing cursor
+	first := newTestMachine(t)
+	first.Run(1_000_000)
+	second := newTestMachine(t)
+	second.Run(1_000_000)
+	if !sameImage(first.Snapshot(screen.ScreenModePlain), second.Snapshot(screen.ScreenModePlain)) {
+		t.Error("Expected the same screen after the same cycles")
+	}
+
+	// The cursor flashes after 16 frames
+	before := first.Snapshot(screen.ScreenModePlain)
+	first.Run(16 * 17030)
+	if sameImage(before, first.Snapshot(screen.ScreenModePlain)) {
+		t.Error("Expected the cursor to flash")
+	}
+}
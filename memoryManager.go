@@ -285,6 +285,11 @@ func (mmu *memoryManager) pokeRange(address uint16, data []uint8) {
 func (mmu *memoryManager) Poke(address uint16, value uint8) {
 	mh := mmu.accessWrite(address)
 	if mh != nil {
+		if address >= shrPalettesAddress && address < shrPalettesAddress+shrPalettesSize &&
+			mmu.hasExtendedRAM() && mh == mmu.physicalExtRAM[0] {
+			// Changes on the SHR palettes during the frame
+			mmu.apple2.beforePaletteWrite()
+		}
 		mh.poke(address, value)
 	}
 
@@ -0,0 +1,245 @@
+package printer
+
+/*
+Epson FX-80 command set.
+
+See:
+	Epson FX Printer User's Manual, Appendix F Command summary
+
+The MSB is ignored on the text and the control codes, but not on the
+parameters or the graphics data. Italics, the user defined characters
+and the international character sets are not supported.
+*/
+
+type epson struct {
+	p         *Printer
+	sequence  []uint8 // Escape sequence being received
+	elite     bool
+	condensed bool
+	expanded  bool // Double width selected with ESC W
+	lineWidth bool // Double width selected with SO for a line
+}
+
+const (
+	asciiBS  = 0x08
+	asciiHT  = 0x09
+	asciiLF  = 0x0a
+	asciiVT  = 0x0b
+	asciiFF  = 0x0c
+	asciiCR  = 0x0d
+	asciiSO  = 0x0e
+	asciiSI  = 0x0f
+	asciiDC2 = 0x12
+	asciiDC4 = 0x14
+	asciiESC = 0x1b
+)
+
+var epsonGraphicsDensities = map[uint8]float64{'K': 60, 'L': 120, 'Y': 120, 'Z': 240}
+var epsonGraphicsModes = []float64{60, 120, 120, 240, 80, 72, 90}
+
+func newEpson(p *Printer) *epson {
+	var e epson
+	e.p = p
+	return &e
+}
+
+func (e *epson) reset() {
+	e.sequence = nil
+	e.elite = false
+	e.condensed = false
+	e.expanded = false
+	e.lineWidth = false
+	e.updatePitch()
+}
+
+func (e *epson) updatePitch() {
+	switch {
+	case e.condensed:
+		e.p.cpi = 17.16
+	case e.elite:
+		e.p.cpi = 12
+	default:
+		e.p.cpi = 10
+	}
+	e.p.doubleWidth = e.expanded || e.lineWidth
+}
+
+func (e *epson) process(value uint8) {
+	if len(e.sequence) > 0 {
+		e.sequence = append(e.sequence, value)
+		if e.escape(e.sequence) {
+			e.sequence = nil
+		}
+		return
+	}
+
+	value &= 0x7f
+	switch value {
+	case asciiESC:
+		e.sequence = []uint8{value}
+	case asciiCR:
+		e.p.carriageReturn()
+		e.lineWidth = false
+		e.updatePitch()
+	case asciiLF, asciiVT:
+		e.p.lineFeed()
+		e.lineWidth = false
+		e.updatePitch()
+	case asciiFF:
+		e.p.formFeed()
+	case asciiBS:
+		e.p.x = max(e.p.leftMargin, e.p.x-e.p.charWidth())
+	case asciiHT:
+		e.p.tab()
+	case asciiSO:
+		e.lineWidth = true
+		e.updatePitch()
+	case asciiDC4:
+		e.lineWidth = false
+		e.updatePitch()
+	case asciiSI:
+		e.condensed = true
+		e.updatePitch()
+	case asciiDC2:
+		e.condensed = false
+		e.updatePitch()
+	default:
+		if value >= 0x20 && value < 0x7f {
+			e.p.printChar(value)
+		}
+	}
+}
+
+// escape runs the sequence if it is complete
+func (e *epson) escape(s []uint8) bool {
+	if len(s) < 2 {
+		return false
+	}
+	param := func(i int) (uint8, bool) {
+		if len(s) < 2+i+1 {
+			return 0, false
+		}
+		return s[2+i], true
+	}
+
+	p := e.p
+	command := s[1] & 0x7f
+	switch command {
+	case '@':
+		p.reset()
+	case 'E', 'G':
+		p.bold = true
+	case 'F', 'H':
+		p.bold = false
+	case '-':
+		n, ok := param(0)
+		if !ok {
+			return false
+		}
+		p.underline = n&1 == 1 // Both 1 and '1'
+	case 'W':
+		n, ok := param(0)
+		if !ok {
+			return false
+		}
+		e.expanded = n&1 == 1
+		e.updatePitch()
+	case 'M':
+		e.elite = true
+		e.updatePitch()
+	case 'P':
+		e.elite = false
+		e.updatePitch()
+	case asciiSO:
+		e.lineWidth = true
+		e.updatePitch()
+	case asciiSI:
+		e.condensed = true
+		e.updatePitch()
+	case '0':
+		p.lineSpacing = dpi / 8
+	case '1':
+		p.lineSpacing = 7 * pinSpacing
+	case '2':
+		p.lineSpacing = dpi / 6
+	case '3':
+		n, ok := param(0)
+		if !ok {
+			return false
+		}
+		p.lineSpacing = float64(n) * dpi / 216
+	case 'A':
+		n, ok := param(0)
+		if !ok {
+			return false
+		}
+		p.lineSpacing = float64(n) * pinSpacing
+	case 'J':
+		n, ok := param(0)
+		if !ok {
+			return false
+		}
+		p.feed(float64(n) * dpi / 216)
+	case 'K', 'L', 'Y', 'Z':
+		return e.graphics(s, 2, epsonGraphicsDensities[command])
+	case '*':
+		mode, ok := param(0)
+		if !ok {
+			return false
+		}
+		density := float64(60)
+		if int(mode) < len(epsonGraphicsModes) {
+			density = epsonGraphicsModes[mode]
+		}
+		return e.graphics(s, 3, density)
+	case '!':
+		// Master select
+		n, ok := param(0)
+		if !ok {
+			return false
+		}
+		e.elite = n&0x01 != 0
+		e.condensed = n&0x04 != 0
+		p.bold = n&0x18 != 0 // Emphasized or double strike
+		e.expanded = n&0x20 != 0
+		p.underline = n&0x80 != 0
+		e.updatePitch()
+	case 'l':
+		n, ok := param(0)
+		if !ok {
+			return false
+		}
+		p.leftMargin = float64(n) * p.charWidth()
+		p.x = max(p.x, p.leftMargin)
+	case 'C':
+		// Page length, in lines or with a 0 and the inches. Always a Letter page.
+		n, ok := param(0)
+		if !ok || (n == 0 && len(s) < 4) {
+			return false
+		}
+	case 'D', 'B':
+		// Tab stops list, ended with 0. Not supported.
+		return len(s) > 2 && s[len(s)-1] == 0
+	case 'Q', 'N', 'R', 'S', 'U', 'x', 'k', 'p', 's', 'w', 'r', 'j', 'e', 'f', 'a':
+		// Commands with a parameter not supported
+		_, ok := param(0)
+		return ok
+	default:
+		// Commands without parameters not supported, like italics with
+		// ESC 4 and ESC 5
+	}
+	return true
+}
+
+// graphics prints the bit image of the sequence when all the data is received
+func (e *epson) graphics(s []uint8, header int, density float64) bool {
+	if len(s) < header+2 {
+		return false
+	}
+	length := int(s[header]) + int(s[header+1])<<8
+	if len(s) < header+2+length {
+		return false
+	}
+	e.p.graphics(s[header+2:], density, false)
+	return true
+}
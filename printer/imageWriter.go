@@ -0,0 +1,194 @@
+package printer
+
+/*
+Apple ImageWriter II command set.
+
+See:
+	ImageWriter II Technical Reference Manual, Appendix C
+
+The numeric parameters are sent as ASCII digits. The MSB is ignored
+on the text and the control codes, but not on the graphics data. The
+colors, the custom characters and the proportional spacing are not
+supported, the proportional pitches print as the fixed ones.
+*/
+
+type imageWriter struct {
+	p        *Printer
+	sequence []uint8 // Escape sequence being received
+	density  float64 // Graphics dots per inch, depends on the pitch
+}
+
+// Pitches in characters per inch and graphics densities in dots per inch
+var imageWriterPitches = map[uint8][2]float64{
+	'n': {9, 72},     // Extended
+	'N': {10, 80},    // Pica
+	'E': {12, 96},    // Elite
+	'e': {13.4, 107}, // Semicondensed
+	'q': {15, 120},   // Condensed
+	'Q': {17, 136},   // Ultracondensed
+	'p': {10, 144},   // Pica proportional
+	'P': {12, 160},   // Elite proportional
+}
+
+func newImageWriter(p *Printer) *imageWriter {
+	var iw imageWriter
+	iw.p = p
+	return &iw
+}
+
+func (iw *imageWriter) reset() {
+	iw.sequence = nil
+	iw.setPitch('N')
+}
+
+func (iw *imageWriter) setPitch(command uint8) {
+	pitch := imageWriterPitches[command]
+	iw.p.cpi = pitch[0]
+	iw.density = pitch[1]
+}
+
+func (iw *imageWriter) process(value uint8) {
+	if len(iw.sequence) > 0 {
+		iw.sequence = append(iw.sequence, value)
+		if iw.escape(iw.sequence) {
+			iw.sequence = nil
+		}
+		return
+	}
+
+	value &= 0x7f
+	switch value {
+	case asciiESC:
+		iw.sequence = []uint8{value}
+	case asciiCR:
+		iw.p.carriageReturn()
+	case asciiLF:
+		iw.p.lineFeed()
+	case asciiFF:
+		iw.p.formFeed()
+	case asciiBS:
+		iw.p.x = max(iw.p.leftMargin, iw.p.x-iw.p.charWidth())
+	case asciiHT:
+		iw.p.tab()
+	case asciiSO:
+		iw.p.doubleWidth = true
+	case asciiSI:
+		iw.p.doubleWidth = false
+	default:
+		if value >= 0x20 && value < 0x7f {
+			iw.p.printChar(value)
+		}
+	}
+}
+
+// decimal returns the value of the ASCII digits, if they are all received
+func decimal(s []uint8, start int, digits int) (int, bool) {
+	if len(s) < start+digits {
+		return 0, false
+	}
+	n := 0
+	for _, digit := range s[start : start+digits] {
+		n = n*10 + int(digit&0x0f)
+	}
+	return n, true
+}
+
+// escape runs the sequence if it is complete
+func (iw *imageWriter) escape(s []uint8) bool {
+	if len(s) < 2 {
+		return false
+	}
+
+	p := iw.p
+	command := s[1] & 0x7f
+	switch command {
+	case 'c':
+		p.reset()
+	case '!':
+		p.bold = true
+	case '"':
+		p.bold = false
+	case 'X':
+		p.underline = true
+	case 'Y':
+		p.underline = false
+	case 'n', 'N', 'E', 'e', 'q', 'Q', 'p', 'P':
+		iw.setPitch(command)
+	case 'A':
+		p.lineSpacing = dpi / 6
+	case 'B':
+		p.lineSpacing = dpi / 8
+	case 'T':
+		n, ok := decimal(s, 2, 2)
+		if !ok {
+			return false
+		}
+		p.lineSpacing = float64(n) * dpi / 144
+	case 'f':
+		p.reverseFeed = false
+	case 'r':
+		p.reverseFeed = true
+	case 'G', 'S':
+		n, ok := decimal(s, 2, 4)
+		if !ok || len(s) < 6+n {
+			return false
+		}
+		p.graphics(s[6:], iw.density, true)
+	case 'g':
+		n, ok := decimal(s, 2, 3)
+		if !ok || len(s) < 5+n*8 {
+			return false
+		}
+		p.graphics(s[5:], iw.density, true)
+	case 'V':
+		// Repeat a graphics column
+		n, ok := decimal(s, 2, 4)
+		if !ok || len(s) < 7 {
+			return false
+		}
+		column := make([]uint8, n)
+		for i := range column {
+			column[i] = s[6]
+		}
+		p.graphics(column, iw.density, true)
+	case 'R':
+		// Repeat a character
+		n, ok := decimal(s, 2, 3)
+		if !ok || len(s) < 6 {
+			return false
+		}
+		for i := 0; i < n; i++ {
+			p.printChar(s[5] & 0x7f)
+		}
+	case 'F':
+		// Move to a dot position
+		n, ok := decimal(s, 2, 4)
+		if !ok {
+			return false
+		}
+		p.x = p.leftMargin + float64(n)*dpi/iw.density
+	case 'L':
+		n, ok := decimal(s, 2, 3)
+		if !ok {
+			return false
+		}
+		p.leftMargin = float64(n) * p.charWidth()
+		p.x = max(p.x, p.leftMargin)
+	case 'H':
+		// Page length, always a Letter page
+		_, ok := decimal(s, 2, 4)
+		return ok
+	case 'Z', 'D':
+		// Switches, two bytes
+		return len(s) >= 4
+	case 'K', 'a', 'l', 's':
+		// Commands with a parameter not supported, like the color
+		return len(s) >= 3
+	case '(', ')':
+		// Tab stops list, ended with a period. Not supported.
+		return s[len(s)-1]&0x7f == '.'
+	default:
+		// Commands without parameters not supported
+	}
+	return true
+}
@@ -0,0 +1,147 @@
+package printer
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// pageWriter stores the pages. The last page written can be replaced
+// until it is completed.
+type pageWriter interface {
+	writePage(page *image.Gray, completed bool) error
+}
+
+// pngWriter writes a PNG file for each page
+type pngWriter struct {
+	base      string
+	extension string
+	pages     int // Pages completed
+}
+
+func newPngWriter(output string) *pngWriter {
+	var w pngWriter
+	w.extension = filepath.Ext(output)
+	w.base = strings.TrimSuffix(output, w.extension)
+	if w.extension == "" {
+		w.extension = ".png"
+	}
+	return &w
+}
+
+func (w *pngWriter) writePage(page *image.Gray, completed bool) error {
+	filename := fmt.Sprintf("%s-%d%s", w.base, w.pages+1, w.extension)
+	if completed {
+		w.pages++
+	}
+
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return png.Encode(f, page)
+}
+
+// pdfWriter writes all the pages on a PDF file. The file is rewritten
+// with each page.
+type pdfWriter struct {
+	filename string
+	pages    [][]uint8 // Compressed images of the completed pages
+}
+
+func newPdfWriter(output string) *pdfWriter {
+	var w pdfWriter
+	w.filename = output
+	return &w
+}
+
+func (w *pdfWriter) writePage(page *image.Gray, completed bool) error {
+	pages := append(w.pages, compressPage(page))
+	if completed {
+		w.pages = pages
+	}
+
+	data := buildPdf(pages, page.Bounds().Dx(), page.Bounds().Dy())
+	return os.WriteFile(w.filename, data, 0644)
+}
+
+// compressPage packs the page as one bit per pixel, with 1 for white
+func compressPage(page *image.Gray) []uint8 {
+	width := page.Bounds().Dx()
+	height := page.Bounds().Dy()
+	rowBytes := (width + 7) / 8
+
+	var buffer bytes.Buffer
+	compressor := zlib.NewWriter(&buffer)
+	row := make([]uint8, rowBytes)
+	for y := 0; y < height; y++ {
+		clear(row)
+		for x := 0; x < width; x++ {
+			if page.GrayAt(x, y).Y >= 0x80 {
+				row[x/8] |= 0x80 >> (x % 8)
+			}
+		}
+		compressor.Write(row)
+	}
+	compressor.Close()
+	return buffer.Bytes()
+}
+
+// buildPdf creates a PDF document with a Letter page for each image
+func buildPdf(pages [][]uint8, width int, height int) []uint8 {
+	var buffer bytes.Buffer
+	var offsets []int
+
+	beginObject := func() int {
+		offsets = append(offsets, buffer.Len())
+		id := len(offsets)
+		fmt.Fprintf(&buffer, "%d 0 obj\n", id)
+		return id
+	}
+
+	buffer.WriteString("%PDF-1.4\n")
+
+	// Objects 1 and 2 are the catalog and the page tree. Each page uses
+	// three objects: the page, the content and the image.
+	beginObject()
+	buffer.WriteString("<< /Type /Catalog /Pages 2 0 R >>\nendobj\n")
+	beginObject()
+	buffer.WriteString("<< /Type /Pages /Kids [")
+	for i := range pages {
+		fmt.Fprintf(&buffer, " %d 0 R", 3+i*3)
+	}
+	fmt.Fprintf(&buffer, " ] /Count %d >>\nendobj\n", len(pages))
+
+	content := "q 612 0 0 792 0 0 cm /Im0 Do Q"
+	for i, data := range pages {
+		page := 3 + i*3
+		beginObject()
+		fmt.Fprintf(&buffer, "<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792]"+
+			" /Resources << /XObject << /Im0 %d 0 R >> >> /Contents %d 0 R >>\nendobj\n",
+			page+2, page+1)
+		beginObject()
+		fmt.Fprintf(&buffer, "<< /Length %d >>\nstream\n%s\nendstream\nendobj\n",
+			len(content), content)
+		beginObject()
+		fmt.Fprintf(&buffer, "<< /Type /XObject /Subtype /Image /Width %d /Height %d"+
+			" /ColorSpace /DeviceGray /BitsPerComponent 1 /Filter /FlateDecode /Length %d >>\nstream\n",
+			width, height, len(data))
+		buffer.Write(data)
+		buffer.WriteString("\nendstream\nendobj\n")
+	}
+
+	xref := buffer.Len()
+	fmt.Fprintf(&buffer, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buffer, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buffer, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n",
+		len(offsets)+1, xref)
+	return buffer.Bytes()
+}
@@ -0,0 +1,256 @@
+package printer
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+Dot matrix printer that renders the pages to PNG files or to a PDF file.
+
+The page is a Letter sheet rendered at 240 dots per inch. The pins of
+the print head are separated 1/72 inch. The interpreters of the
+command sets of the printers move the print head and print dots,
+characters and graphics.
+
+The output is written when a page is completed and after some time
+without receiving data, to have the last page even if no form feed is
+sent.
+*/
+
+// Printer is a dot matrix printer
+type Printer struct {
+	mutex       sync.Mutex
+	interpreter interpreter
+	output      pageWriter
+	idleTimer   *time.Timer
+	autoLF      bool
+
+	page  *image.Gray
+	empty bool    // No dots on the current page
+	x, y  float64 // Position of the print head in dots
+
+	// Settings changed by the interpreters
+	cpi         float64 // Characters per inch
+	lineSpacing float64 // In dots
+	bold        bool
+	underline   bool
+	doubleWidth bool
+	leftMargin  float64
+	reverseFeed bool
+}
+
+type interpreter interface {
+	process(value uint8)
+	reset()
+}
+
+const (
+	// Emulations available
+	EmulationEpson       = "epson"
+	EmulationImageWriter = "imagewriter"
+
+	dpi            = 240
+	pageWidth      = 8.5 * dpi
+	pageHeight     = 11 * dpi
+	printableWidth = 8 * dpi
+	sideMargin     = (pageWidth - printableWidth) / 2
+	pinSpacing     = float64(dpi) / 72
+	dotSize        = 4
+	fontColumns    = 6 // 5 columns of the glyph and 1 of separation
+	idleFlushTime  = 2 * time.Second
+)
+
+// NewPrinter creates a printer with the emulation and the output file.
+// The output is a PDF if the name ends in ".pdf", if not, a PNG file is
+// written for each page adding the page number to the name.
+func NewPrinter(emulation string, output string, autoLF bool) (*Printer, error) {
+	var p Printer
+	p.autoLF = autoLF
+
+	switch emulation {
+	case EmulationEpson:
+		p.interpreter = newEpson(&p)
+	case EmulationImageWriter:
+		p.interpreter = newImageWriter(&p)
+	default:
+		return nil, fmt.Errorf("unknown printer emulation '%s'", emulation)
+	}
+
+	if strings.HasSuffix(strings.ToLower(output), ".pdf") {
+		p.output = newPdfWriter(output)
+	} else {
+		p.output = newPngWriter(output)
+	}
+
+	p.newPage()
+	p.reset()
+	return &p, nil
+}
+
+// Print sends a byte to the printer
+func (p *Printer) Print(value uint8) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.interpreter.process(value)
+
+	if p.idleTimer == nil {
+		p.idleTimer = time.AfterFunc(idleFlushTime, func() {
+			p.Flush()
+		})
+	} else {
+		p.idleTimer.Reset(idleFlushTime)
+	}
+}
+
+// Flush writes the output with the pages printed, including the current one
+func (p *Printer) Flush() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.empty {
+		return nil
+	}
+	return p.output.writePage(p.page, false)
+}
+
+func (p *Printer) reset() {
+	p.cpi = 10
+	p.lineSpacing = dpi / 6
+	p.bold = false
+	p.underline = false
+	p.doubleWidth = false
+	p.leftMargin = 0
+	p.reverseFeed = false
+	p.interpreter.reset()
+}
+
+func (p *Printer) newPage() {
+	p.page = image.NewGray(image.Rect(0, 0, pageWidth, pageHeight))
+	for i := range p.page.Pix {
+		p.page.Pix[i] = 0xff
+	}
+	p.empty = true
+	p.y = 0
+}
+
+func (p *Printer) formFeed() {
+	if !p.empty {
+		err := p.output.writePage(p.page, true)
+		if err != nil {
+			fmt.Printf("Error writing the printer output: %v\n", err)
+		}
+	}
+	p.newPage()
+	p.x = p.leftMargin
+}
+
+func (p *Printer) carriageReturn() {
+	p.x = p.leftMargin
+	if p.autoLF {
+		p.lineFeed()
+	}
+}
+
+func (p *Printer) lineFeed() {
+	p.feed(p.lineSpacing)
+}
+
+// feed moves the paper the distance in dots
+func (p *Printer) feed(distance float64) {
+	if p.reverseFeed {
+		p.y = max(0, p.y-distance)
+		return
+	}
+	p.y += distance
+	if p.y+8*pinSpacing > pageHeight {
+		p.formFeed()
+	}
+}
+
+func (p *Printer) charWidth() float64 {
+	width := dpi / p.cpi
+	if p.doubleWidth {
+		width *= 2
+	}
+	return width
+}
+
+// tab moves the print head to the next multiple of 8 characters
+func (p *Printer) tab() {
+	tabWidth := 8 * p.charWidth()
+	p.x = (math.Floor((p.x-p.leftMargin)/tabWidth)+1)*tabWidth + p.leftMargin
+}
+
+func (p *Printer) printChar(ch uint8) {
+	width := p.charWidth()
+	if p.x+width > printableWidth {
+		p.x = p.leftMargin
+		p.lineFeed()
+	}
+
+	step := width / fontColumns
+	glyph := glyphFor(ch)
+	for column, bits := range glyph {
+		x := p.x + float64(column)*step
+		for row := 0; row < 7; row++ {
+			if bits&(1<<row) != 0 {
+				y := p.y + float64(row)*pinSpacing
+				p.dot(x, y)
+				if p.doubleWidth {
+					p.dot(x+step/2, y)
+				}
+				if p.bold {
+					p.dot(x+step/3, y)
+				}
+			}
+		}
+	}
+
+	if p.underline {
+		y := p.y + 8*pinSpacing
+		for x := p.x; x < p.x+width; x += pinSpacing / 2 {
+			p.dot(x, y)
+		}
+	}
+
+	p.x += width
+}
+
+// graphics prints columns of 8 dots with the horizontal density
+func (p *Printer) graphics(data []uint8, density float64, topBitIsLSB bool) {
+	step := dpi / density
+	for _, column := range data {
+		if p.x >= printableWidth {
+			// The rest of the line is lost
+			break
+		}
+		for pin := 0; pin < 8; pin++ {
+			bit := column & (0x80 >> pin)
+			if topBitIsLSB {
+				bit = column & (1 << pin)
+			}
+			if bit != 0 {
+				p.dot(p.x, p.y+float64(pin)*pinSpacing)
+			}
+		}
+		p.x += step
+	}
+}
+
+func (p *Printer) dot(x float64, y float64) {
+	left := int(math.Round(x + sideMargin))
+	top := int(math.Round(y))
+	for i := left; i < left+dotSize && i < pageWidth; i++ {
+		for j := top; j < top+dotSize && j < pageHeight; j++ {
+			p.page.SetGray(i, j, color.Gray{0})
+		}
+	}
+	p.empty = false
+}
@@ -0,0 +1,136 @@
+package printer
+
+import (
+	"bytes"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newTestPrinter(t *testing.T, emulation string, output string) *Printer {
+	p, err := NewPrinter(emulation, filepath.Join(t.TempDir(), output), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if p.idleTimer != nil {
+			p.idleTimer.Stop()
+		}
+	})
+	return p
+}
+
+func printString(p *Printer, s string) {
+	for _, ch := range []uint8(s) {
+		p.Print(ch)
+	}
+}
+
+func isDot(p *Printer, x float64, y float64) bool {
+	return p.page.GrayAt(int(x+sideMargin)+1, int(y)+1).Y == 0
+}
+
+func TestEpsonText(t *testing.T) {
+	p := newTestPrinter(t, EmulationEpson, "page.png")
+
+	// The vertical bar is the third column of the glyph
+	printString(p, "|\r\n")
+	step := dpi / 10.0 / fontColumns
+	if !isDot(p, 2*step, 0) || !isDot(p, 2*step, 6*pinSpacing) || isDot(p, 0, 0) {
+		t.Error("Vertical bar not printed")
+	}
+	if p.y != dpi/6 {
+		t.Errorf("Line spacing of 1/6 inch expected, got %v dots", p.y)
+	}
+
+	// Underline and double width
+	printString(p, "\x1b-\x01\x0e_\x1b-\x00")
+	if !isDot(p, 23, p.y+8*pinSpacing) || !isDot(p, 44, p.y+8*pinSpacing) {
+		t.Error("Double width underline not printed")
+	}
+
+	// Escape sequences with parameters are not printed
+	x := p.x
+	printString(p, "\x1b3\x24\x1bA\x0c")
+	if p.x != x || p.lineSpacing != 12*pinSpacing {
+		t.Errorf("Unexpected line spacing %v", p.lineSpacing)
+	}
+}
+
+func TestEpsonGraphics(t *testing.T) {
+	p := newTestPrinter(t, EmulationEpson, "page.png")
+
+	// Two columns at 60 dpi, the first with the top pin, the second with the bottom one
+	printString(p, "\x1bK\x02\x00\x80\x01")
+	if !isDot(p, 0, 0) || isDot(p, 0, 7*pinSpacing) {
+		t.Error("Unexpected first graphics column")
+	}
+	if isDot(p, 4, 0) || !isDot(p, 4, 7*pinSpacing) {
+		t.Error("Unexpected second graphics column")
+	}
+	if p.x != 2*dpi/60 {
+		t.Errorf("Graphics at 60 dpi expected, head at %v", p.x)
+	}
+}
+
+func TestImageWriterGraphics(t *testing.T) {
+	p := newTestPrinter(t, EmulationImageWriter, "page.png")
+
+	// The top pin is on the LSB, the density is 80 dpi with the pica pitch
+	printString(p, "\x1bG0002\x01\x80")
+	if !isDot(p, 0, 0) || !isDot(p, 3, 7*pinSpacing) || isDot(p, 0, 7*pinSpacing) {
+		t.Error("Unexpected graphics")
+	}
+	if p.x != 2*dpi/80 {
+		t.Errorf("Graphics at 80 dpi expected, head at %v", p.x)
+	}
+
+	printString(p, "\x1bT16\n")
+	if p.y != 16.0*dpi/144 {
+		t.Errorf("Line spacing of 16/144 inch expected, got %v dots", p.y)
+	}
+}
+
+func TestPngOutput(t *testing.T) {
+	p := newTestPrinter(t, EmulationEpson, "page.png")
+	base := filepath.Dir(p.output.(*pngWriter).base)
+
+	printString(p, "PAGE 1\x0cPAGE 2")
+	err := p.Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"page-1.png", "page-2.png"} {
+		f, err := os.Open(filepath.Join(base, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		config, err := png.DecodeConfig(f)
+		f.Close()
+		if err != nil || config.Width != pageWidth || config.Height != pageHeight {
+			t.Errorf("Unexpected image %v: %+v, %v", name, config, err)
+		}
+	}
+}
+
+func TestPdfOutput(t *testing.T) {
+	p := newTestPrinter(t, EmulationImageWriter, "print.pdf")
+	filename := p.output.(*pdfWriter).filename
+
+	printString(p, "PAGE 1\x0cPAGE 2")
+	err := p.Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(data, []uint8("%PDF-1.4")) || !bytes.Contains(data, []uint8("/Count 2")) ||
+		!bytes.HasSuffix(data, []uint8("%%EOF\n")) {
+		t.Error("Unexpected PDF structure")
+	}
+}
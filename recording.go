@@ -0,0 +1,128 @@
+package izapple2
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/ivanizag/izapple2/screen"
+)
+
+/*
+Video recording of the emulation. A frame is captured every frame of the
+video timing, counting the emulated CPU cycles. The recordings are frame
+accurate at any emulation speed, including the headless runs at full
+speed.
+
+The Y4M videos are recorded with the speaker sound on a WAV file with
+the same name.
+*/
+
+type recording struct {
+	frames     screen.FrameWriter
+	audio      *screen.WavWriter
+	screenMode int
+	filename   string
+
+	frameCycles uint64
+	nextFrame   uint64
+
+	resampler *SpeakerResampler
+	samples   []int16
+}
+
+const recordingSampleRate = 48000
+
+func (a *Apple2) startRecording(filename string, screenMode int) error {
+	if a.recording != nil {
+		return fmt.Errorf("already recording to %s", a.recording.filename)
+	}
+
+	frames, err := screen.NewFrameWriter(filename, a.timing, a.palette)
+	if err != nil {
+		return err
+	}
+
+	var r recording
+	r.frames = frames
+	r.screenMode = screenMode
+	r.filename = filename
+	r.frameCycles = a.timing.FrameCycles()
+	r.nextFrame = a.cycles
+
+	if strings.ToLower(filepath.Ext(filename)) == ".y4m" {
+		audioFilename := strings.TrimSuffix(filename, filepath.Ext(filename)) + ".wav"
+		r.audio, err = screen.NewWavWriter(audioFilename, recordingSampleRate)
+		if err != nil {
+			frames.Close()
+			return err
+		}
+		r.resampler = NewSpeakerResampler(a.timing.ClockMhz, recordingSampleRate, a.cycles)
+	}
+
+	a.recording = &r
+	a.recordingActive.Store(true)
+	return nil
+}
+
+func (a *Apple2) stopRecording() error {
+	r := a.recording
+	if r == nil {
+		return fmt.Errorf("not recording")
+	}
+	err := r.frames.Close()
+	if r.audio != nil {
+		// The sound of the last frame
+		errAudio := r.writeAudio(r.nextFrame)
+		if errAudio == nil {
+			errAudio = r.audio.Close()
+		}
+		if err == nil {
+			err = errAudio
+		}
+	}
+
+	// Cleared after the files are complete, IsRecording() can be polled to wait for them
+	a.recording = nil
+	a.recordingActive.Store(false)
+	return err
+}
+
+// IsRecording returns true if a video is being recorded
+func (a *Apple2) IsRecording() bool {
+	return a.recordingActive.Load()
+}
+
+// click is called on every access to the speaker softswitch
+func (r *recording) click(cycle uint64) {
+	if r.audio != nil {
+		r.resampler.Click(cycle)
+	}
+}
+
+// update captures the frames completed up to the current cycle
+func (r *recording) update(a *Apple2) error {
+	for a.cycles >= r.nextFrame {
+		img := screen.Snapshot(a.video, r.screenMode)
+		err := r.frames.WriteFrame(img)
+		if err != nil {
+			return err
+		}
+
+		if r.audio != nil {
+			// The sound of the previous frame is complete
+			err = r.writeAudio(r.nextFrame)
+			if err != nil {
+				return err
+			}
+		}
+		r.nextFrame += r.frameCycles
+	}
+	return nil
+}
+
+// writeAudio generates the samples up to a cycle from the speaker clicks
+func (r *recording) writeAudio(untilCycle uint64) error {
+	r.samples = r.resampler.AppendSamples(r.samples[:0], untilCycle)
+	return r.audio.WriteSamples(r.samples)
+}
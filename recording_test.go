@@ -0,0 +1,79 @@
+package izapple2
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRecordingY4M(t *testing.T) {
+	at, err := makeApple2Tester("2plus", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	err = at.a.startRecording(filepath.Join(dir, "video.y4m"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	frameCycles := at.a.timing.FrameCycles()
+	at.terminateCondition = func(a *Apple2) bool {
+		return a.GetCycles() > 10*frameCycles
+	}
+	at.run() // Kill completes the recording
+
+	video, err := os.ReadFile(filepath.Join(dir, "video.y4m"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	frames := bytes.Count(video, []byte("FRAME\n"))
+	if frames < 10 || frames > 12 {
+		t.Errorf("Expected about 10 frames, got %v", frames)
+	}
+	if !bytes.HasPrefix(video, []byte("YUV4MPEG2 W560 H768 F1022714:17030 ")) {
+		t.Errorf("Unexpected Y4M header: %q", video[:40])
+	}
+
+	audio, err := os.ReadFile(filepath.Join(dir, "video.wav"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	samples := (len(audio) - 44) / 2
+	expected := frames * recordingSampleRate * 17030 / 1022714
+	if samples < expected-1 || samples > expected+1 {
+		t.Errorf("Expected %v audio samples for %v frames, got %v", expected, frames, samples)
+	}
+}
+
+func TestStartRecordingReportsErrors(t *testing.T) {
+	at, err := makeApple2Tester("2plus", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go at.a.Start(true /*paused*/)
+	defer at.a.SendCommand(CommandKill)
+
+	dir := t.TempDir()
+	err = at.a.StartRecording(filepath.Join(dir, "missing", "video.gif"), 0)
+	if err == nil {
+		t.Error("Recording to a missing folder should fail")
+	}
+	if at.a.IsRecording() {
+		t.Error("Should not be recording after a failure")
+	}
+
+	err = at.a.StartRecording(filepath.Join(dir, "video.gif"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !at.a.IsRecording() {
+		t.Error("Should be recording as soon as StartRecording returns")
+	}
+	at.a.SendCommand(CommandStopRecording)
+	for at.a.IsRecording() {
+		time.Sleep(time.Millisecond)
+	}
+}
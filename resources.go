@@ -152,7 +152,12 @@ func LoadBlockDisk(filename string) (storage.BlockDisk, error) {
 		file, _ = os.OpenFile(filename, os.O_RDONLY, 0)
 	}
 	if file != nil {
-		return storage.NewBlockDiskFile(file, readOnly)
+		bd, err := storage.NewBlockDiskFile(file, readOnly)
+		if err == nil {
+			return bd, nil
+		}
+		// Not a block image, it could be a 3.5 disk bitstream or compressed
+		file.Close()
 	}
 
 	// Load as a resource
@@ -163,3 +168,19 @@ func LoadBlockDisk(filename string) (storage.BlockDisk, error) {
 
 	return storage.NewBlockDiskMemory(data)
 }
+
+// LoadCdromDisk returns a read only BlockDisk for a CD-ROM image
+func LoadCdromDisk(filename string) (storage.BlockDisk, error) {
+	filename = normalizeFilename(filename)
+
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	bd, err := storage.NewBlockDiskCdrom(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return bd, nil
+}
@@ -0,0 +1,214 @@
+package screen
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"strconv"
+	"strings"
+)
+
+/*
+Composite signal simulation. Each pixel of the 560 pixels wide images is
+a sample of the video signal at 14.318 MHz, four samples per cycle of the
+3.58 MHz colorburst. The luminance bitstream is demodulated with the
+phase of the colorburst and filtered to get the Y, I and Q components.
+
+The filters are moving averages. A luma filter with a multiple of 4 taps
+removes the colorburst carrier, with fewer taps the dot patterns are
+visible. Longer chroma filters spread the colour fringes.
+
+See:
+	https://en.wikipedia.org/wiki/YIQ
+	https://mrob.com/pub/xapple2/colors.html
+*/
+
+// CompositeSettings has the parameters of the composite signal demodulation
+type CompositeSettings struct {
+	LumaTaps   int     // Length of the Y filter in 14 MHz samples
+	ChromaTaps int     // Length of the I and Q filters in 14 MHz samples
+	Saturation float64 // Gain of the chroma
+	Hue        float64 // Hue adjustment in degrees
+}
+
+// DefaultCompositeSettings are close to the colours of a typical NTSC monitor
+var DefaultCompositeSettings = CompositeSettings{
+	LumaTaps:   4,
+	ChromaTaps: 8,
+	Saturation: 0.8,
+	Hue:        0,
+}
+
+var compositeSettings = DefaultCompositeSettings
+
+// The phase of the pixels at positions multiple of 4 renders magenta
+const compositeHueOffset = 33.0
+
+// SetCompositeSettings changes the settings of the composite renderer
+func SetCompositeSettings(settings CompositeSettings) {
+	if settings.LumaTaps < 1 {
+		settings.LumaTaps = 1
+	}
+	if settings.ChromaTaps < 1 {
+		settings.ChromaTaps = 1
+	}
+	compositeSettings = settings
+}
+
+// ParseCompositeSettings reads the settings as "luma=4,chroma=8,saturation=0.8,hue=0".
+// The values not present are the defaults.
+func ParseCompositeSettings(value string) (CompositeSettings, error) {
+	settings := DefaultCompositeSettings
+	if value == "" || value == "default" {
+		return settings, nil
+	}
+
+	for _, item := range strings.Split(value, ",") {
+		name, number, found := strings.Cut(strings.TrimSpace(item), "=")
+		if !found {
+			return settings, fmt.Errorf("invalid composite setting '%s', it must be name=value", item)
+		}
+		var err error
+		switch name {
+		case "luma":
+			settings.LumaTaps, err = strconv.Atoi(number)
+		case "chroma":
+			settings.ChromaTaps, err = strconv.Atoi(number)
+		case "saturation":
+			settings.Saturation, err = strconv.ParseFloat(number, 64)
+		case "hue":
+			settings.Hue, err = strconv.ParseFloat(number, 64)
+		default:
+			return settings, fmt.Errorf("unknown composite setting '%s', must be luma, chroma, saturation or hue", name)
+		}
+		if err != nil {
+			return settings, fmt.Errorf("invalid value for the composite setting '%s': %w", name, err)
+		}
+	}
+	return settings, nil
+}
+
+func filterNTSCComposite(in *image.RGBA, mask *image.Alpha) *image.RGBA {
+	d := newCompositeDemodulator(in)
+	for y := 0; y < d.height; y++ {
+		d.demodulateLine(in, y)
+		for x := 0; x < d.outWidth; x++ {
+			if !d.copyMasked(in, mask, x, y) {
+				d.out.Set(x, y, yiqToRGBA(d.lumaY[x], d.chromaI[x], d.chromaQ[x]))
+			}
+		}
+	}
+	return d.out
+}
+
+type compositeDemodulator struct {
+	settings   CompositeSettings
+	width      int
+	height     int
+	outWidth   int
+	out        *image.RGBA
+	carrierCos [4]float64
+	carrierSin [4]float64
+
+	// Cumulative sums to apply the moving averages
+	sumY []float64
+	sumI []float64
+	sumQ []float64
+
+	lumaY   []float64
+	chromaI []float64
+	chromaQ []float64
+}
+
+func newCompositeDemodulator(in *image.RGBA) *compositeDemodulator {
+	var d compositeDemodulator
+	d.settings = compositeSettings
+
+	b := in.Bounds()
+	d.width = b.Dx()
+	d.height = b.Dy()
+	// As with filterNTSCColor, there are a few extra pixels for the fade out
+	d.outWidth = d.width + 4
+	d.out = image.NewRGBA(image.Rect(0, 0, d.outWidth, d.height))
+
+	hue := (compositeHueOffset + d.settings.Hue) * math.Pi / 180
+	for i := 0; i < 4; i++ {
+		phase := float64(i)*math.Pi/2 + hue
+		d.carrierCos[i] = math.Cos(phase)
+		d.carrierSin[i] = math.Sin(phase)
+	}
+
+	d.sumY = make([]float64, d.outWidth+1)
+	d.sumI = make([]float64, d.outWidth+1)
+	d.sumQ = make([]float64, d.outWidth+1)
+	d.lumaY = make([]float64, d.outWidth)
+	d.chromaI = make([]float64, d.outWidth)
+	d.chromaQ = make([]float64, d.outWidth)
+	return &d
+}
+
+func (d *compositeDemodulator) demodulateLine(in *image.RGBA, y int) {
+	for x := 0; x < d.outWidth; x++ {
+		signal := 0.0
+		if x < d.width {
+			r, _, _, _ := in.At(x, y).RGBA()
+			if r != 0 {
+				signal = 1.0
+			}
+		}
+		d.sumY[x+1] = d.sumY[x] + signal
+		d.sumI[x+1] = d.sumI[x] + signal*d.carrierCos[x%4]
+		d.sumQ[x+1] = d.sumQ[x] + signal*d.carrierSin[x%4]
+	}
+
+	gain := 2 * d.settings.Saturation
+	for x := 0; x < d.outWidth; x++ {
+		d.lumaY[x] = movingAverage(d.sumY, x, d.settings.LumaTaps)
+		d.chromaI[x] = gain * movingAverage(d.sumI, x, d.settings.ChromaTaps)
+		d.chromaQ[x] = gain * movingAverage(d.sumQ, x, d.settings.ChromaTaps)
+	}
+}
+
+// copyMasked copies the pixels of the RGB card mode7 as they are
+func (d *compositeDemodulator) copyMasked(in *image.RGBA, mask *image.Alpha, x int, y int) bool {
+	if mask == nil || x >= d.width {
+		return false
+	}
+	_, _, _, a := mask.At(x, y).RGBA()
+	if a == 0 {
+		return false
+	}
+	d.out.Set(x, y, in.At(x, y))
+	return true
+}
+
+// movingAverage of the window of taps samples centered on x
+func movingAverage(sums []float64, x int, taps int) float64 {
+	start := x - taps/2
+	end := start + taps
+	if start < 0 {
+		start = 0
+	}
+	if end > len(sums)-1 {
+		end = len(sums) - 1
+	}
+	return (sums[end] - sums[start]) / float64(taps)
+}
+
+func yiqToRGBA(y, i, q float64) color.RGBA {
+	r := y + 0.956*i + 0.621*q
+	g := y - 0.272*i - 0.647*q
+	b := y - 1.106*i + 1.703*q
+	return color.RGBA{clampColor(r), clampColor(g), clampColor(b), 255}
+}
+
+func clampColor(v float64) uint8 {
+	if v <= 0 {
+		return 0
+	}
+	if v >= 1 {
+		return 255
+	}
+	return uint8(v*255 + 0.5)
+}
@@ -0,0 +1,93 @@
+package screen
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestCompositeColors(t *testing.T) {
+	scenarios := map[int]color.Color{
+		0x0: ntscColorMap[0x0],
+		0x1: ntscColorMap[0x1],
+		0x2: ntscColorMap[0x2],
+		0x4: ntscColorMap[0x4],
+		0x8: ntscColorMap[0x8],
+		0x5: ntscColorMap[0x5],
+		0xf: ntscColorMap[0xf],
+	}
+
+	for pattern, want := range scenarios {
+		in := image.NewRGBA(image.Rect(0, 0, 2*hiResWidth, 1))
+		for x := 0; x < 2*hiResWidth; x++ {
+			if pattern&(1<<(x%4)) != 0 {
+				in.Set(x, 0, color.White)
+			}
+		}
+
+		out := filterNTSCComposite(in, nil)
+		got := out.RGBAAt(hiResWidth, 0)
+		if !isSameHue(got, want) {
+			t.Errorf("expected a color like %v but got %v for pattern %x", want, got, pattern)
+		}
+	}
+}
+
+// isSameHue compares the order of the components
+func isSameHue(a color.RGBA, b color.Color) bool {
+	r, g, bl, _ := b.RGBA()
+	br, bg, bb := int(r>>8), int(g>>8), int(bl>>8)
+	ar, ag, ab := int(a.R), int(a.G), int(a.B)
+	const margin = 40
+	same := func(x, y int) bool {
+		return x-y < margin && y-x < margin
+	}
+	order := func(x1, y1, x2, y2 int) bool {
+		return same(x1, y1) || same(x2, y2) || (x1 > y1) == (x2 > y2)
+	}
+	return order(ar, ag, br, bg) && order(ag, ab, bg, bb) && order(ar, ab, br, bb)
+}
+
+func TestCompositeText(t *testing.T) {
+	// The text has colour fringes only with the composite signal
+	hasColor := func(img *image.RGBA) bool {
+		for i := 0; i < len(img.Pix); i += 4 {
+			if img.Pix[i] != img.Pix[i+1] || img.Pix[i+1] != img.Pix[i+2] {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, name := range []string{"text40col_792589108.json", "text80col_474745391.json"} {
+		ts, err := loadTestScenario("./test_resources/" + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hasColor(snapshotByMode(ts, ts.VideoMode, ScreenModeNTSC)) {
+			t.Errorf("No colours expected on %s with the NTSC filter", name)
+		}
+		if !hasColor(snapshotByMode(ts, ts.VideoMode, ScreenModeComposite)) {
+			t.Errorf("Colour fringes expected on %s with the composite signal", name)
+		}
+	}
+}
+
+func TestParseCompositeSettings(t *testing.T) {
+	settings, err := ParseCompositeSettings("chroma=12, hue=-10.5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := DefaultCompositeSettings
+	expected.ChromaTaps = 12
+	expected.Hue = -10.5
+	if settings != expected {
+		t.Errorf("Unexpected settings %+v", settings)
+	}
+
+	for _, value := range []string{"luma", "luma=x", "gamma=2"} {
+		if _, err := ParseCompositeSettings(value); err == nil {
+			t.Errorf("Error expected for '%s'", value)
+		}
+	}
+}
@@ -31,8 +31,6 @@ var ntscColorMap = [16]color.Color{
 	color.RGBA{255, 255, 255, 255}, // White
 }
 
-var attenuatedColorMap = buildAttenuatedColorMap(ntscColorMap)
-
 func buildAttenuatedColorMap(colorMap [16]color.Color) [16]color.Color {
 	colors := [16]color.Color{}
 	for i := 0; i < len(colorMap); i++ {
@@ -45,32 +43,10 @@ func buildAttenuatedColorMap(colorMap [16]color.Color) [16]color.Color {
 	return colors
 }
 
-/*
-var rgbColorMap = [16]color.Color{
-	color.RGBA{0, 0, 0, 255},       // Black
-	color.RGBA{221, 0, 51, 255},    // Magenta
-	color.RGBA{0, 0, 153, 255},     // Dark Blue
-	color.RGBA{221, 34, 221, 255},  // Purple
-	color.RGBA{0, 119, 34, 255},    // Dark Green
-	color.RGBA{85, 85, 85, 255},    // Grey 1
-	color.RGBA{34, 34, 255, 255},   // Medium Blue
-	color.RGBA{102, 170, 255, 255}, // Light Blue
-	color.RGBA{136, 85, 0, 255},    // Brown
-	color.RGBA{255, 102, 0, 255},   // Orange
-	color.RGBA{170, 170, 170, 255}, // Grey 2
-	color.RGBA{255, 153, 136, 255}, // Pink
-	color.RGBA{17, 221, 0, 255},    // Green
-	color.RGBA{255, 255, 0, 255},   // Yellow
-	color.RGBA{68, 255, 153, 255},  // Aquamarine
-	color.RGBA{255, 255, 255, 255}, // White
-}
-*/
-
-func filterNTSCColor(in *image.RGBA, mask *image.Alpha, screenMode int) *image.RGBA {
-	colorMap := ntscColorMap // or rgbColorMap
-	colorMapLow := ntscColorMap
+func filterNTSCColor(in *image.RGBA, mask *image.Alpha, screenMode int, colorMap [16]color.Color) *image.RGBA {
+	colorMapLow := colorMap
 	if screenMode == ScreenModeNTSC {
-		colorMapLow = attenuatedColorMap
+		colorMapLow = buildAttenuatedColorMap(colorMap)
 	}
 
 	b := in.Bounds()
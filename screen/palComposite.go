@@ -0,0 +1,76 @@
+package screen
+
+import (
+	"image"
+	"math"
+)
+
+/*
+PAL colour TV. The european Apple II boards with a PAL colour encoder
+generate the colours with the same phases as the NTSC boards, switching
+the phase of the V component on alternate lines. The PAL decoders use a
+delay line to average the chroma of each line with the previous one. It
+cancels the hue errors, and blends the colours of consecutive lines on
+the horizontal edges.
+
+Here the chroma of each line is converted to the U and V components and
+V is switched on the odd lines as the encoder does. The delay line sums
+each line with the previous one after undoing the switch of each. The
+first line is averaged with itself.
+
+The Europlus without a colour card outputs only a monochrome signal, use
+the green or a plain monochrome screen mode for it.
+
+See:
+	https://en.wikipedia.org/wiki/PAL#PAL_vs._NTSC
+*/
+
+// The U and V axes are rotated 33 degrees from I and Q
+var palSin33, palCos33 = math.Sincos(33 * math.Pi / 180)
+
+// palSwitch returns the sign of the V component of the line
+func palSwitch(y int) float64 {
+	if y%2 == 1 {
+		return -1
+	}
+	return 1
+}
+
+func filterPALComposite(in *image.RGBA, mask *image.Alpha) *image.RGBA {
+	d := newCompositeDemodulator(in)
+	lineU := make([]float64, d.outWidth)
+	lineV := make([]float64, d.outWidth)
+	prevU := make([]float64, d.outWidth)
+	prevV := make([]float64, d.outWidth)
+
+	for y := 0; y < d.height; y++ {
+		d.demodulateLine(in, y)
+		sign := palSwitch(y)
+		for x := 0; x < d.outWidth; x++ {
+			// The chroma as transmitted, with the V switch
+			lineU[x] = -d.chromaI[x]*palSin33 + d.chromaQ[x]*palCos33
+			lineV[x] = (d.chromaI[x]*palCos33 + d.chromaQ[x]*palSin33) * sign
+		}
+		if y == 0 {
+			// There is no previous line, it is the same line with the other switch
+			copy(prevU, lineU)
+			for x := range prevV {
+				prevV[x] = -lineV[x]
+			}
+		}
+
+		for x := 0; x < d.outWidth; x++ {
+			// The delay line, undoing the switch of each line
+			u := (lineU[x] + prevU[x]) / 2
+			v := (lineV[x]*sign - prevV[x]*sign) / 2
+			if !d.copyMasked(in, mask, x, y) {
+				chromaI := -u*palSin33 + v*palCos33
+				chromaQ := u*palCos33 + v*palSin33
+				d.out.Set(x, y, yiqToRGBA(d.lumaY[x], chromaI, chromaQ))
+			}
+		}
+		copy(prevU, lineU)
+		copy(prevV, lineV)
+	}
+	return d.out
+}
@@ -0,0 +1,65 @@
+package screen
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestPALDelayLine(t *testing.T) {
+	// Magenta on the first line, green on the rest
+	in := image.NewRGBA(image.Rect(0, 0, 2*hiResWidth, 3))
+	for y := 0; y < 3; y++ {
+		pattern := 0xc
+		if y == 0 {
+			pattern = 0x3
+		}
+		for x := 0; x < 2*hiResWidth; x++ {
+			if pattern&(1<<(x%4)) != 0 {
+				in.Set(x, y, color.White)
+			}
+		}
+	}
+
+	out := filterPALComposite(in, nil)
+	blended := out.RGBAAt(hiResWidth, 1)
+	green := out.RGBAAt(hiResWidth, 2)
+	if blended == green {
+		t.Errorf("expected the chroma of line 1 blended with line 0, got %v", blended)
+	}
+	if !isSameHue(green, ntscColorMap[0xc]) {
+		t.Errorf("expected a color like %v but got %v", ntscColorMap[0xc], green)
+	}
+}
+
+func TestVideoTimingFrameRate(t *testing.T) {
+	ntsc := NTSCTiming.FrameRate()
+	if ntsc < 59.9 || ntsc > 60.1 {
+		t.Errorf("expected 60Hz for NTSC, got %v", ntsc)
+	}
+	pal := PALTiming.FrameRate()
+	if pal < 49.9 || pal > 50.2 {
+		t.Errorf("expected 50Hz for PAL, got %v", pal)
+	}
+	if PALTiming.VertBlankCycles() != 7800 {
+		t.Errorf("expected 120 lines of vertical blanking for PAL, got %v cycles", PALTiming.VertBlankCycles())
+	}
+}
+
+func TestPALFirstLine(t *testing.T) {
+	in := image.NewRGBA(image.Rect(0, 0, 2*hiResWidth, 3))
+	for y := 0; y < 3; y++ {
+		for x := 0; x < 2*hiResWidth; x++ {
+			if 0xc&(1<<(x%4)) != 0 {
+				in.Set(x, y, color.White)
+			}
+		}
+	}
+
+	out := filterPALComposite(in, nil)
+	first := out.RGBAAt(hiResWidth, 0)
+	other := out.RGBAAt(hiResWidth, 1)
+	if first != other {
+		t.Errorf("expected the first line like the rest, got %v and %v", first, other)
+	}
+}
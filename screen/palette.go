@@ -0,0 +1,151 @@
+package screen
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"image/color"
+	"strconv"
+	"strings"
+)
+
+/*
+Palettes with the colours of the video output. They can be loaded from
+text files with lines of "key: value" and comments starting with '#':
+
+	name: Apple IIgs
+	lores: 000000 dd0033 000099 dd22dd 007722 555555 2222ff 66aaff 885500 ff6600 aaaaaa ff9988 11dd00 ffff00 44ff99 ffffff
+	hires: 11dd00 dd22dd ff6600 2222ff
+	rgb: 000000 dd0033 000099 dd22dd 007722 555555 2222ff 66aaff 885500 ff6600 aaaaaa ff9988 11dd00 ffff00 44ff99 ffffff
+
+The "lores" colours are used for lo-res, double hi-res and the NTSC
+artifacts. The "hires" colours are green, violet, orange and blue, as
+HCOLOR 1, 2, 5 and 6. The "rgb" colours are used by the RGB cards. The
+"hires" and "rgb" lines are optional and default to the "lores" colours.
+*/
+
+// Palette has the colours to render the screen
+type Palette struct {
+	Name  string
+	LoRes [16]color.Color
+	HiRes [4]color.Color
+	RGB   [16]color.Color
+
+	attenuated [16]color.Color
+	artifacts  [16]color.Color
+}
+
+// Positions of the hi-res colours on the lo-res palette
+var hiResColorIndexes = [4]int{0xc, 0x3, 0x9, 0x6}
+
+// DefaultPalette is the built-in palette
+var DefaultPalette = newPalette("NTSC", ntscColorMap, nil, nil)
+
+func newPalette(name string, loRes [16]color.Color, hiRes *[4]color.Color, rgb *[16]color.Color) *Palette {
+	var p Palette
+	p.Name = name
+	p.LoRes = loRes
+
+	if hiRes != nil {
+		p.HiRes = *hiRes
+	} else {
+		for i, index := range hiResColorIndexes {
+			p.HiRes[i] = loRes[index]
+		}
+	}
+
+	if rgb != nil {
+		p.RGB = *rgb
+	} else {
+		p.RGB = loRes
+	}
+
+	p.attenuated = buildAttenuatedColorMap(p.LoRes)
+	p.artifacts = p.LoRes
+	for i, index := range hiResColorIndexes {
+		p.artifacts[index] = p.HiRes[i]
+	}
+	return &p
+}
+
+// ParsePalette reads a palette from the contents of a palette file
+func ParsePalette(data []uint8) (*Palette, error) {
+	name := ""
+	var loRes *[16]color.Color
+	var hiRes *[4]color.Color
+	var rgb *[16]color.Color
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, fmt.Errorf("line %v of the palette is not 'key: value'", lineNumber)
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		var err error
+		switch key {
+		case "name":
+			name = value
+		case "lores":
+			var colors [16]color.Color
+			err = parsePaletteColors(value, colors[:])
+			loRes = &colors
+		case "hires":
+			var colors [4]color.Color
+			err = parsePaletteColors(value, colors[:])
+			hiRes = &colors
+		case "rgb":
+			var colors [16]color.Color
+			err = parsePaletteColors(value, colors[:])
+			rgb = &colors
+		default:
+			err = fmt.Errorf("unknown key '%s'", key)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %v of the palette: %w", lineNumber, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if loRes == nil {
+		return nil, fmt.Errorf("the palette has no lores colours")
+	}
+	return newPalette(name, *loRes, hiRes, rgb), nil
+}
+
+func parsePaletteColors(value string, colors []color.Color) error {
+	fields := strings.Fields(value)
+	if len(fields) != len(colors) {
+		return fmt.Errorf("expected %v colours, got %v", len(colors), len(fields))
+	}
+	for i, field := range fields {
+		field = strings.TrimPrefix(field, "#")
+		rgb, err := strconv.ParseUint(field, 16, 32)
+		if err != nil || len(field) != 6 {
+			return fmt.Errorf("invalid colour '%s', it must be RRGGBB in hexadecimal", field)
+		}
+		colors[i] = color.RGBA{uint8(rgb >> 16), uint8(rgb >> 8), uint8(rgb), 255}
+	}
+	return nil
+}
+
+// paletteOf returns the palette of the video source, or the default one
+func paletteOf(vs VideoSource) *Palette {
+	ps, ok := vs.(PaletteSource)
+	if !ok {
+		return DefaultPalette
+	}
+	p := ps.GetPalette()
+	if p == nil {
+		return DefaultPalette
+	}
+	return p
+}
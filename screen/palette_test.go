@@ -0,0 +1,97 @@
+package screen
+
+import (
+	"image/color"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParsePalette(t *testing.T) {
+	data := []uint8(`# Comment
+name: Test
+lores: 000000 dd0033 000099 dd22dd 007722 555555 2222ff 66aaff 885500 ff6600 aaaaaa ff9988 11dd00 ffff00 44ff99 ffffff
+hires: 010203 040506 070809 0a0b0c
+`)
+	p, err := ParsePalette(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Name != "Test" {
+		t.Errorf("expected the name 'Test', got '%s'", p.Name)
+	}
+	if p.LoRes[1] != (color.RGBA{0xdd, 0x00, 0x33, 255}) {
+		t.Errorf("wrong lores colour 1: %v", p.LoRes[1])
+	}
+	if p.HiRes[3] != (color.RGBA{0x0a, 0x0b, 0x0c, 255}) {
+		t.Errorf("wrong hires blue: %v", p.HiRes[3])
+	}
+	if p.artifacts[0x6] != p.HiRes[3] {
+		t.Errorf("the hires blue is not used for the artifacts")
+	}
+	if p.RGB != p.LoRes {
+		t.Errorf("the rgb colours must default to the lores colours")
+	}
+}
+
+func TestParsePaletteErrors(t *testing.T) {
+	invalid := []string{
+		"name: No colours",
+		"lores: 000000",
+		"lores: 000000 dd0033 000099 dd22dd 007722 555555 2222ff 66aaff 885500 ff6600 aaaaaa ff9988 11dd00 ffff00 44ff99 fffffg",
+		"other: 000000",
+		"no key",
+	}
+	for _, data := range invalid {
+		_, err := ParsePalette([]uint8(data))
+		if err == nil {
+			t.Errorf("expected an error for '%s'", data)
+		}
+	}
+}
+
+func TestPaletteFiles(t *testing.T) {
+	files, err := filepath.Glob("../resources/palettes/*.pal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("no palette files found")
+	}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = ParsePalette(data)
+		if err != nil {
+			t.Errorf("%s: %v", file, err)
+		}
+	}
+}
+
+type testPaletteSource struct {
+	TestScenario
+	palette *Palette
+}
+
+func (ps *testPaletteSource) GetPalette() *Palette {
+	return ps.palette
+}
+
+func TestPaletteOf(t *testing.T) {
+	var ts TestScenario
+	if paletteOf(&ts) != DefaultPalette {
+		t.Errorf("a video source without palette must use the default one")
+	}
+
+	p := newPalette("Test", ntscColorMap, nil, nil)
+	ps := testPaletteSource{palette: p}
+	if paletteOf(&ps) != p {
+		t.Errorf("the palette of the video source is not used")
+	}
+	ps.palette = nil
+	if paletteOf(&ps) != DefaultPalette {
+		t.Errorf("a nil palette must use the default one")
+	}
+}
@@ -0,0 +1,310 @@
+package screen
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/gif"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+/*
+Video recording. The frames are captured by the emulator once per frame
+of the video timing, the file has the emulated frame rate regardless of
+the speed of the host.
+
+The supported formats are:
+  - GIF: the delays are in hundredths of second and the viewers need at
+    least 2, the frames closer than that are skipped.
+  - APNG: lossless and with the exact frame rate.
+  - Y4M: raw uncompressed YUV 4:4:4 frames, for video editors and encoders.
+
+GIF and APNG keep the frames in memory until the recording is closed. They
+are meant for short clips.
+
+The size of the video is the size of the first frame. The next frames are
+scaled if the video mode changes.
+
+See:
+	https://wiki.mozilla.org/APNG_Specification
+	https://wiki.multimedia.cx/index.php/YUV4MPEG2
+*/
+
+// FrameWriter receives the frames of a video recording
+type FrameWriter interface {
+	// WriteFrame adds a frame to the video
+	WriteFrame(img *image.RGBA) error
+	// Close completes the video file
+	Close() error
+}
+
+// NewFrameWriter creates a video file with the format given by the extension.
+// The palette is used for the GIF colours, nil for the default palette.
+func NewFrameWriter(filename string, timing VideoTiming, palette *Palette) (FrameWriter, error) {
+	if palette == nil {
+		palette = DefaultPalette
+	}
+
+	extension := strings.ToLower(filepath.Ext(filename))
+	switch extension {
+	case ".gif", ".png", ".apng", ".y4m":
+		// Supported
+	default:
+		return nil, fmt.Errorf("video format '%s' not supported, use .gif, .png, .apng or .y4m", extension)
+	}
+
+	f, err := os.Create(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	switch extension {
+	case ".gif":
+		return &gifFrameWriter{file: f, frameRate: timing.FrameRate(), palette: palette}, nil
+	case ".y4m":
+		return &y4mFrameWriter{file: f, w: bufio.NewWriter(f), timing: timing}, nil
+	default:
+		return &apngFrameWriter{file: f, frameRate: timing.FrameRate()}, nil
+	}
+}
+
+// FitFrame scales the image to a size, picking the nearest pixels
+func FitFrame(in *image.RGBA, width int, height int) *image.RGBA {
+	b := in.Bounds()
+	if b.Dx() == width && b.Dy() == height {
+		return in
+	}
+	out := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			out.SetRGBA(x, y, in.RGBAAt(b.Min.X+x*b.Dx()/width, b.Min.Y+y*b.Dy()/height))
+		}
+	}
+	return out
+}
+
+type gifFrameWriter struct {
+	file      *os.File
+	animation gif.GIF
+	frameRate float64
+	width     int
+	height    int
+	frames    int
+	lastStart int // Hundredths of second
+	palette   *Palette
+}
+
+const gifMinimalDelay = 2
+
+func (g *gifFrameWriter) WriteFrame(img *image.RGBA) error {
+	now := int(math.Round(float64(g.frames) * 100 / g.frameRate))
+	g.frames++
+
+	count := len(g.animation.Image)
+	if count == 0 {
+		g.width = img.Bounds().Dx()
+		g.height = img.Bounds().Dy()
+	} else {
+		if now-g.lastStart < gifMinimalDelay {
+			// Too close to the previous frame
+			return nil
+		}
+		g.animation.Delay[count-1] = now - g.lastStart
+	}
+
+	paletted := palletedFilter(FitFrame(img, g.width, g.height), g.palette)
+	g.animation.Image = append(g.animation.Image, paletted)
+	g.animation.Delay = append(g.animation.Delay, gifMinimalDelay)
+	g.lastStart = now
+	return nil
+}
+
+func (g *gifFrameWriter) Close() error {
+	defer g.file.Close()
+	count := len(g.animation.Image)
+	if count == 0 {
+		return fmt.Errorf("no frames recorded")
+	}
+	end := int(math.Round(float64(g.frames) * 100 / g.frameRate))
+	if end-g.lastStart > gifMinimalDelay {
+		g.animation.Delay[count-1] = end - g.lastStart
+	}
+	return gif.EncodeAll(g.file, &g.animation)
+}
+
+type apngFrameWriter struct {
+	file      *os.File
+	frameRate float64
+	width     int
+	height    int
+	header    []uint8   // IHDR chunk data
+	frames    [][]uint8 // IDAT data for each frame
+}
+
+func (a *apngFrameWriter) WriteFrame(img *image.RGBA) error {
+	if len(a.frames) == 0 {
+		a.width = img.Bounds().Dx()
+		a.height = img.Bounds().Dy()
+	}
+
+	var buffer bytes.Buffer
+	encoder := png.Encoder{CompressionLevel: png.BestSpeed}
+	err := encoder.Encode(&buffer, FitFrame(img, a.width, a.height))
+	if err != nil {
+		return err
+	}
+
+	// Extract the chunks of the png file
+	data := buffer.Bytes()[8:] // Skip the signature
+	var idat []uint8
+	for len(data) >= 12 {
+		length := binary.BigEndian.Uint32(data[0:4])
+		chunkType := string(data[4:8])
+		chunkData := data[8 : 8+length]
+		switch chunkType {
+		case "IHDR":
+			if a.header == nil {
+				a.header = bytes.Clone(chunkData)
+			}
+		case "IDAT":
+			idat = append(idat, chunkData...)
+		}
+		data = data[12+length:]
+	}
+	a.frames = append(a.frames, idat)
+	return nil
+}
+
+func (a *apngFrameWriter) Close() error {
+	defer a.file.Close()
+	if len(a.frames) == 0 {
+		return fmt.Errorf("no frames recorded")
+	}
+
+	w := bufio.NewWriter(a.file)
+	w.Write([]uint8{0x89, 'P', 'N', 'G', '\r', '\n', 0x1a, '\n'})
+	writePngChunk(w, "IHDR", a.header)
+
+	actl := make([]uint8, 8)
+	binary.BigEndian.PutUint32(actl[0:4], uint32(len(a.frames)))
+	binary.BigEndian.PutUint32(actl[4:8], 0) // Loop forever
+	writePngChunk(w, "acTL", actl)
+
+	// The delay is a fraction of 16 bits numbers
+	delayNum := uint16(100)
+	delayDen := uint16(math.Round(a.frameRate * 100))
+
+	sequence := uint32(0)
+	for i, frame := range a.frames {
+		fctl := make([]uint8, 26)
+		binary.BigEndian.PutUint32(fctl[0:4], sequence)
+		binary.BigEndian.PutUint32(fctl[4:8], uint32(a.width))
+		binary.BigEndian.PutUint32(fctl[8:12], uint32(a.height))
+		// The offsets are zero
+		binary.BigEndian.PutUint16(fctl[20:22], delayNum)
+		binary.BigEndian.PutUint16(fctl[22:24], delayDen)
+		// Dispose and blend ops are zero, replace the full frame
+		writePngChunk(w, "fcTL", fctl)
+		sequence++
+
+		if i == 0 {
+			writePngChunk(w, "IDAT", frame)
+		} else {
+			fdat := make([]uint8, 4, 4+len(frame))
+			binary.BigEndian.PutUint32(fdat[0:4], sequence)
+			fdat = append(fdat, frame...)
+			writePngChunk(w, "fdAT", fdat)
+			sequence++
+		}
+	}
+
+	writePngChunk(w, "IEND", nil)
+	return w.Flush()
+}
+
+func writePngChunk(w *bufio.Writer, chunkType string, data []uint8) {
+	header := make([]uint8, 8)
+	binary.BigEndian.PutUint32(header[0:4], uint32(len(data)))
+	copy(header[4:8], chunkType)
+	w.Write(header)
+	w.Write(data)
+
+	crc := crc32.NewIEEE()
+	crc.Write(header[4:8])
+	crc.Write(data)
+	binary.Write(w, binary.BigEndian, crc.Sum32())
+}
+
+type y4mFrameWriter struct {
+	file   *os.File
+	w      *bufio.Writer
+	timing VideoTiming
+	width  int
+	height int
+	planes [3][]uint8
+}
+
+func (y *y4mFrameWriter) WriteFrame(img *image.RGBA) error {
+	if y.width == 0 {
+		y.width = img.Bounds().Dx()
+		y.height = img.Bounds().Dy()
+		size := y.width * y.height
+		for i := range y.planes {
+			y.planes[i] = make([]uint8, size)
+		}
+		// The frame rate as an exact fraction, the clock in Hz by the cycles per frame
+		_, err := fmt.Fprintf(y.w, "YUV4MPEG2 W%v H%v F%v:%v Ip A1:1 C444\n",
+			y.width, y.height,
+			int(math.Round(y.timing.ClockMhz*1_000_000)), y.timing.FrameCycles())
+		if err != nil {
+			return err
+		}
+	}
+
+	frame := FitFrame(img, y.width, y.height)
+	b := frame.Bounds()
+	i := 0
+	for py := b.Min.Y; py < b.Max.Y; py++ {
+		for px := b.Min.X; px < b.Max.X; px++ {
+			c := frame.RGBAAt(px, py)
+			y.planes[0][i], y.planes[1][i], y.planes[2][i] = rgbToYCbCr601(c.R, c.G, c.B)
+			i++
+		}
+	}
+
+	_, err := y.w.WriteString("FRAME\n")
+	if err != nil {
+		return err
+	}
+	for _, plane := range y.planes {
+		_, err = y.w.Write(plane)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (y *y4mFrameWriter) Close() error {
+	defer y.file.Close()
+	if y.width == 0 {
+		return fmt.Errorf("no frames recorded")
+	}
+	return y.w.Flush()
+}
+
+// rgbToYCbCr601 converts to the studio range of BT.601
+func rgbToYCbCr601(r, g, b uint8) (uint8, uint8, uint8) {
+	ri, gi, bi := int(r), int(g), int(b)
+	yy := ((66*ri + 129*gi + 25*bi + 128) >> 8) + 16
+	cb := ((-38*ri - 74*gi + 112*bi + 128) >> 8) + 128
+	cr := ((112*ri - 94*gi - 18*bi + 128) >> 8) + 128
+	return uint8(yy), uint8(cb), uint8(cr)
+}
@@ -0,0 +1,80 @@
+package screen
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTestFrames(t *testing.T, filename string, count int) {
+	fw, err := NewFrameWriter(filename, NTSCTiming, nil /*default palette*/)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < count; i++ {
+		img := image.NewRGBA(image.Rect(0, 0, 40, 24))
+		img.Set(i, 0, color.White)
+		err = fw.WriteFrame(img)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = fw.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRecorderAPNG(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "video.png")
+	writeTestFrames(t, filename, 3)
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Count(data, []byte("fcTL")) != 3 || bytes.Count(data, []byte("fdAT")) != 2 {
+		t.Errorf("Expected 3 animation frames")
+	}
+
+	// The regular decoders show the first frame
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, _, _, _ := img.At(0, 0).RGBA()
+	if r == 0 {
+		t.Errorf("Expected the first frame")
+	}
+}
+
+func TestRecorderGIF(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "video.gif")
+	writeTestFrames(t, filename, 60)
+
+	f, err := os.Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	animation, err := gif.DecodeAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	total := 0
+	for _, delay := range animation.Delay {
+		if delay < gifMinimalDelay {
+			t.Errorf("Delay %v is too short", delay)
+		}
+		total += delay
+	}
+	if total != 100 {
+		t.Errorf("Expected one second for 60 frames, got %v hundredths", total)
+	}
+}
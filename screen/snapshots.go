@@ -5,6 +5,7 @@ import (
 	"image/color"
 	"image/png"
 	"os"
+	"strings"
 )
 
 /*
@@ -21,6 +22,10 @@ const (
 	ScreenModePlain
 	// ScreenModeNTSC shows spaces between pixels
 	ScreenModeNTSC
+	// ScreenModeComposite simulates the composite video signal
+	ScreenModeComposite
+	// ScreenModePAL simulates a PAL colour TV with a delay line
+	ScreenModePAL
 )
 
 func NextScreenMode(screenMode int) int {
@@ -29,11 +34,32 @@ func NextScreenMode(screenMode int) int {
 		return ScreenModePlain
 	case ScreenModePlain:
 		return ScreenModeNTSC
+	case ScreenModeNTSC:
+		return ScreenModeComposite
+	case ScreenModeComposite:
+		return ScreenModePAL
 	default:
 		return ScreenModeGreen
 	}
 }
 
+// ScreenModeByName returns the screen mode for a name like the ones used on the frontends
+func ScreenModeByName(name string) (int, bool) {
+	switch strings.ToLower(name) {
+	case "green":
+		return ScreenModeGreen, true
+	case "plain":
+		return ScreenModePlain, true
+	case "ntsc":
+		return ScreenModeNTSC, true
+	case "composite":
+		return ScreenModeComposite, true
+	case "pal":
+		return ScreenModePAL, true
+	}
+	return 0, false
+}
+
 // Snapshot the currently visible screen
 func Snapshot(vs VideoSource, screenMode int) *image.RGBA {
 	videoMode := vs.GetCurrentVideoMode()
@@ -50,7 +76,7 @@ func Snapshot(vs VideoSource, screenMode int) *image.RGBA {
 // SnapshotPaletted, snapshot of the currently visible screen as a paletted image
 func SnapshotPaletted(vs VideoSource, screenMode int) *image.Paletted {
 	img := Snapshot(vs, screenMode)
-	return palletedFilter(img)
+	return palletedFilter(img, paletteOf(vs))
 }
 
 // Color for typical Apple ][ period green P1 phosphor monitors
@@ -71,16 +97,21 @@ func snapshotByMode(vs VideoSource, videoMode uint32, screenMode int) *image.RGB
 		lightColor = greenPhosphorColor
 	}
 
+	// The colours of the NTSC filter
+	palette := paletteOf(vs)
+	colorMap := palette.LoRes
+
 	applyNTSCFilter := screenMode != ScreenModeGreen
 	var snap *image.RGBA
 	var ntscMask *image.Alpha
 	switch videoBase {
 	case VideoText40:
 		snap = snapshotText40(vs, isSecondPage, isAltText, lightColor)
-		applyNTSCFilter = false
+		// Only the composite signal shows the colour fringes of the text
+		applyNTSCFilter = screenMode == ScreenModeComposite
 	case VideoText80:
 		snap = snapshotText80(vs, isSecondPage, isAltText, hasAltOrder, lightColor)
-		applyNTSCFilter = false
+		applyNTSCFilter = screenMode == ScreenModeComposite
 	case VideoText40RGB:
 		snap = snapshotText40RGB(vs, isSecondPage, isAltText)
 		applyNTSCFilter = false
@@ -90,6 +121,7 @@ func snapshotByMode(vs VideoSource, videoMode uint32, screenMode int) *image.RGB
 		snap = snapshotMeRes(vs, isSecondPage, lightColor)
 	case VideoHGR:
 		snap = snapshotHiRes(vs, isSecondPage, lightColor, shiftSupported)
+		colorMap = palette.artifacts
 	case VideoDHGR:
 		snap, _ = snapshotDoubleHiRes(vs, isSecondPage, false /*isRGBMixMode*/, lightColor)
 	case VideoMono560:
@@ -99,6 +131,7 @@ func snapshotByMode(vs VideoSource, videoMode uint32, screenMode int) *image.RGB
 		snap, ntscMask = snapshotDoubleHiRes(vs, isSecondPage, true /*isRGBMixMode*/, lightColor)
 	case VideoRGB160:
 		snap = snapshotDoubleHiRes160(vs, isSecondPage, lightColor)
+		colorMap = palette.RGB
 	case VideoSHR:
 		snap = snapshotSuperHiRes(vs)
 		applyNTSCFilter = false
@@ -108,7 +141,7 @@ func snapshotByMode(vs VideoSource, videoMode uint32, screenMode int) *image.RGB
 	}
 
 	if applyNTSCFilter {
-		snap = filterNTSCColor(snap, ntscMask, screenMode)
+		snap = filterByMode(snap, ntscMask, screenMode, colorMap)
 	}
 
 	if mixMode != 0 {
@@ -124,7 +157,7 @@ func snapshotByMode(vs VideoSource, videoMode uint32, screenMode int) *image.RGB
 			applyNTSCFilter = false
 		}
 		if applyNTSCFilter {
-			bottom = filterNTSCColor(bottom, ntscMask, screenMode)
+			bottom = filterByMode(bottom, ntscMask, screenMode, colorMap)
 		}
 		snap = mixSnapshots(snap, bottom)
 	}
@@ -132,6 +165,16 @@ func snapshotByMode(vs VideoSource, videoMode uint32, screenMode int) *image.RGB
 	return snap
 }
 
+func filterByMode(in *image.RGBA, mask *image.Alpha, screenMode int, colorMap [16]color.Color) *image.RGBA {
+	switch screenMode {
+	case ScreenModeComposite:
+		return filterNTSCComposite(in, mask)
+	case ScreenModePAL:
+		return filterPALComposite(in, mask)
+	}
+	return filterNTSCColor(in, mask, screenMode, colorMap)
+}
+
 func mixSnapshots(top, bottom *image.RGBA) *image.RGBA {
 	bottomWidth := bottom.Bounds().Dx()
 
@@ -193,12 +236,14 @@ func linesSeparatedFilter(in *image.RGBA) *image.RGBA {
 	return out
 }
 
-func palletedFilter(in *image.RGBA) *image.Paletted {
+func palletedFilter(in *image.RGBA, p *Palette) *image.Paletted {
 	bounds := in.Bounds()
 	outBounds := image.Rect(0, 0, bounds.Dx()*2, bounds.Dy())
 	palette := []color.Color{color.Black, color.White, greenPhosphorColor}
-	palette = append(palette, ntscColorMap[:]...)
-	palette = append(palette, attenuatedColorMap[:]...)
+	palette = append(palette, p.LoRes[:]...)
+	palette = append(palette, p.attenuated[:]...)
+	palette = append(palette, p.HiRes[:]...)
+	palette = append(palette, p.RGB[:]...)
 	paletted := image.NewPaletted(outBounds, palette)
 
 	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
@@ -6,10 +6,10 @@ import (
 )
 
 const (
-	shrWidth      = 640
-	shrWidthBytes = 640 / 4
-	shrHeight     = 200
-	palettesCount = 256
+	shrWidth        = 640
+	shrWidthBytes   = 640 / 4
+	shrHeight       = 200
+	shrPalettesSize = 0x200 // 16 palettes of 16 colours
 
 	shrScanLineControlOffset = uint16(0x7d00)
 	shrColorPalettesOffset   = uint16(0x7e00)
@@ -17,31 +17,16 @@ const (
 
 func snapshotSuperHiRes(vs VideoSource) *image.RGBA {
 	data := vs.GetSuperVideoMemory()
-	return renderSuperHiRes(data)
+	return renderSuperHiRes(data, vs.GetSuperPalettes())
 }
 
-func renderSuperHiRes(data []uint8) *image.RGBA {
+// renderSuperHiRes builds the image, linePalettes can have the palettes memory of each line for 3200 colours images
+func renderSuperHiRes(data []uint8, linePalettes []uint8) *image.RGBA {
 	// See "Apple IIGS Hardware Reference", chapter 4, page 91
 	// http://www.applelogic.org/files/GSHARDWAREREF.pdf
 	size := image.Rect(0, 0, shrWidth, shrHeight)
 	img := image.NewRGBA(size)
 
-	// Load the palettes
-	colors := make([]color.Color, palettesCount)
-	iMem := uint16(0)
-	for i := 0; i < palettesCount; i++ {
-		b0 := data[iMem+shrColorPalettesOffset]
-		iMem++
-		b1 := data[iMem+shrColorPalettesOffset]
-		iMem++
-
-		red := (b1 & 0x0f) << 4
-		green := b0 & 0xf0
-		blue := (b0 & 0x0f) << 4
-
-		colors[i] = color.RGBA{red, green, blue, 255}
-	}
-
 	// See "Apple IIGS Hardware Reference", table 4-21
 	palettesSelectionTable := []uint8{0x4, 0x0, 0xc, 0x8}
 
@@ -50,7 +35,14 @@ func renderSuperHiRes(data []uint8) *image.RGBA {
 		controlByte := data[uint16(y)+shrScanLineControlOffset]
 		is640Wide := (controlByte & 0x80) != 0
 		isColorFill := (controlByte & 0x20) != 0
-		paletteIndex := (controlByte & 0x0f) << 4
+		paletteIndex := int(controlByte & 0x0f)
+
+		// Load the palette of the line
+		palettesMemory := data[shrColorPalettesOffset:]
+		if linePalettes != nil {
+			palettesMemory = linePalettes[y*shrPalettesSize:]
+		}
+		colors := loadSuperHiResPalette(palettesMemory, paletteIndex)
 
 		lineAddress := uint16(shrWidthBytes * y)
 		lineBytes := data[lineAddress : lineAddress+shrWidthBytes]
@@ -62,13 +54,14 @@ func renderSuperHiRes(data []uint8) *image.RGBA {
 				for j := 3; j >= 0; j-- {
 					p := (b >> (uint(j) * 2)) & 0x03
 					offset := palettesSelectionTable[j]
-					color := colors[paletteIndex+offset+p]
+					color := colors[offset+p]
 					img.Set(x, y, color)
 					x++
 				}
 			}
 		} else {
 			// Line is 320 pixels, two pixels per byte
+			// On fill mode, the colour 0 repeats the previous pixel
 			x := 0
 			previousColor := uint8(0)
 			for i := 0; i < shrWidthBytes; i++ {
@@ -84,10 +77,10 @@ func renderSuperHiRes(data []uint8) *image.RGBA {
 				} else {
 					previousColor = p1
 				}
-				img.Set(x, y, colors[paletteIndex+p0])
-				img.Set(x+1, y, colors[paletteIndex+p0])
-				img.Set(x+2, y, colors[paletteIndex+p1])
-				img.Set(x+3, y, colors[paletteIndex+p1])
+				img.Set(x, y, colors[p0])
+				img.Set(x+1, y, colors[p0])
+				img.Set(x+2, y, colors[p1])
+				img.Set(x+3, y, colors[p1])
 				x += 4
 			}
 		}
@@ -95,3 +88,20 @@ func renderSuperHiRes(data []uint8) *image.RGBA {
 
 	return img
 }
+
+// loadSuperHiResPalette decodes the 16 colours of a palette, two bytes per colour as 0RGB
+func loadSuperHiResPalette(palettesMemory []uint8, index int) [16]color.Color {
+	var colors [16]color.Color
+	for i := 0; i < 16; i++ {
+		address := (index*16 + i) * 2
+		b0 := palettesMemory[address]
+		b1 := palettesMemory[address+1]
+
+		red := (b1 & 0x0f) << 4
+		green := b0 & 0xf0
+		blue := (b0 & 0x0f) << 4
+
+		colors[i] = color.RGBA{red, green, blue, 255}
+	}
+	return colors
+}
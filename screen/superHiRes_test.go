@@ -0,0 +1,46 @@
+package screen
+
+import (
+	"image/color"
+	"testing"
+)
+
+func TestSuperHiResColorFill(t *testing.T) {
+	data := make([]uint8, 0x8000)
+	data[shrScanLineControlOffset] = 0x20 // Fill mode, palette 0
+	data[shrColorPalettesOffset+2] = 0x0f // Colour 1 is blue
+	data[0] = 0x10                        // Colour 1 followed by a colour 0
+
+	img := renderSuperHiRes(data, nil)
+	blue := color.RGBA{0, 0, 0xf0, 255}
+	for x := 0; x < 8; x++ {
+		if img.RGBAAt(x, 0) != blue {
+			t.Errorf("expected blue on pixel %v, got %v", x, img.RGBAAt(x, 0))
+		}
+	}
+
+	// Without fill mode the colour 0 is black
+	data[shrScanLineControlOffset] = 0x00
+	img = renderSuperHiRes(data, nil)
+	if img.RGBAAt(2, 0) != (color.RGBA{0, 0, 0, 255}) {
+		t.Errorf("expected black without fill mode, got %v", img.RGBAAt(2, 0))
+	}
+}
+
+func TestSuperHiResLinePalettes(t *testing.T) {
+	data := make([]uint8, 0x8000)
+	data[0] = 0x11 // Colour 1 on the first line
+	data[shrWidthBytes] = 0x11
+
+	linePalettes := make([]uint8, shrHeight*shrPalettesSize)
+	linePalettes[2] = 0x0f                 // Line 0, colour 1 is blue
+	linePalettes[shrPalettesSize+3] = 0x0f // Line 1, colour 1 is red
+
+	img := renderSuperHiRes(data, linePalettes)
+	if img.RGBAAt(0, 0) != (color.RGBA{0, 0, 0xf0, 255}) {
+		t.Errorf("expected blue on line 0, got %v", img.RGBAAt(0, 0))
+	}
+	if img.RGBAAt(0, 1) != (color.RGBA{0xf0, 0, 0, 255}) {
+		t.Errorf("expected red on line 1, got %v", img.RGBAAt(0, 1))
+	}
+}
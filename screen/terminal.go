@@ -0,0 +1,297 @@
+package screen
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/png"
+	"os"
+	"strings"
+)
+
+/*
+Graphics output for terminals, to use the emulator over SSH. The snapshots
+can be rendered as:
+  - Sixel: supported by xterm with "-ti vt340", mlterm, foot, WezTerm and others.
+  - Kitty graphics protocol: supported by kitty, WezTerm and Ghostty.
+  - Half blocks: two pixels per character with 24 bits colour, the fallback
+    for any terminal with Unicode.
+  - Braille: eight pixels per character with one colour.
+
+See:
+	https://vt100.net/docs/vt3xx-gp/chapter14.html
+	https://sw.kovidgoyal.net/kitty/graphics-protocol/
+*/
+
+const (
+	// TerminalText shows only the text modes as text
+	TerminalText = iota
+	// TerminalSixel uses the Sixel graphics
+	TerminalSixel
+	// TerminalKitty uses the kitty graphics protocol
+	TerminalKitty
+	// TerminalHalfBlocks uses the upper half block character with two colours
+	TerminalHalfBlocks
+	// TerminalBraille uses the Braille patterns with one colour
+	TerminalBraille
+)
+
+// Size of the images sent to terminals with pixel graphics
+const (
+	terminalPixelsWidth  = 560
+	terminalPixelsHeight = 384
+	kittyChunkSize       = 4096
+)
+
+// TerminalGraphicsByName returns the terminal graphics for a name, "auto" detects the best one
+func TerminalGraphicsByName(name string) (int, bool) {
+	switch strings.ToLower(name) {
+	case "text":
+		return TerminalText, true
+	case "sixel":
+		return TerminalSixel, true
+	case "kitty":
+		return TerminalKitty, true
+	case "halfblocks", "blocks":
+		return TerminalHalfBlocks, true
+	case "braille":
+		return TerminalBraille, true
+	case "auto":
+		return DetectTerminalGraphics(), true
+	}
+	return 0, false
+}
+
+// DetectTerminalGraphics guesses the graphics supported by the terminal from the environment
+func DetectTerminalGraphics() int {
+	term := os.Getenv("TERM")
+	program := os.Getenv("TERM_PROGRAM")
+	if os.Getenv("KITTY_WINDOW_ID") != "" || strings.Contains(term, "kitty") ||
+		strings.Contains(term, "ghostty") {
+		return TerminalKitty
+	}
+	if program == "WezTerm" || strings.HasPrefix(term, "mlterm") ||
+		strings.HasPrefix(term, "foot") || strings.Contains(term, "sixel") {
+		return TerminalSixel
+	}
+	return TerminalHalfBlocks
+}
+
+// RenderTerminalGraphics returns the escape sequences to show the screen, columns is used for the character modes
+func RenderTerminalGraphics(vs VideoSource, screenMode int, graphics int, columns int) string {
+	// No lines separation, the terminals don't have enough resolution
+	img := snapshotByMode(vs, vs.GetCurrentVideoMode(), screenMode)
+
+	switch graphics {
+	case TerminalSixel:
+		return RenderSixel(FitFrame(img, terminalPixelsWidth, terminalPixelsHeight))
+	case TerminalKitty:
+		return RenderKitty(FitFrame(img, terminalPixelsWidth, terminalPixelsHeight))
+	case TerminalBraille:
+		return RenderBraille(img, columns)
+	default:
+		return RenderHalfBlocks(img, columns)
+	}
+}
+
+// RenderSixel encodes the image as Sixel graphics, with up to 256 colours
+func RenderSixel(img *image.RGBA) string {
+	b := img.Bounds()
+	width := b.Dx()
+	height := b.Dy()
+
+	// Build the palette with the colours used, the web safe colours if there are too many
+	var colors color.Palette
+	indexes := make(map[color.RGBA]int)
+	for y := b.Min.Y; y < b.Max.Y && len(colors) <= 256; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := img.RGBAAt(x, y)
+			if _, ok := indexes[c]; !ok {
+				indexes[c] = len(colors)
+				colors = append(colors, c)
+			}
+		}
+	}
+	if len(colors) > 256 {
+		colors = palette.WebSafe
+	}
+	pixels := make([]uint8, width*height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			pixels[y*width+x] = uint8(colors.Index(img.RGBAAt(b.Min.X+x, b.Min.Y+y)))
+		}
+	}
+
+	var sb strings.Builder
+	sb.WriteString("\033Pq")
+	fmt.Fprintf(&sb, "\"1;1;%v;%v", width, height)
+	for i, c := range colors {
+		r, g, bl, _ := c.RGBA()
+		// The components are percentages
+		fmt.Fprintf(&sb, "#%v;2;%v;%v;%v", i, r*100/0xffff, g*100/0xffff, bl*100/0xffff)
+	}
+
+	// Bands of six lines, one pass for each colour on the band
+	band := make([]uint8, width)
+	for y := 0; y < height; y += 6 {
+		used := make(map[uint8]bool)
+		for dy := 0; dy < 6 && y+dy < height; dy++ {
+			for _, p := range pixels[(y+dy)*width : (y+dy+1)*width] {
+				used[p] = true
+			}
+		}
+		first := true
+		for i := range colors {
+			index := uint8(i)
+			if !used[index] {
+				continue
+			}
+			for x := 0; x < width; x++ {
+				bits := uint8(0)
+				for dy := 0; dy < 6 && y+dy < height; dy++ {
+					if pixels[(y+dy)*width+x] == index {
+						bits |= 1 << dy
+					}
+				}
+				band[x] = '?' + bits
+			}
+			if !first {
+				sb.WriteByte('$') // Back to the start of the band
+			}
+			first = false
+			fmt.Fprintf(&sb, "#%v", index)
+			writeSixelRuns(&sb, band)
+		}
+		sb.WriteByte('-') // Next band
+	}
+	sb.WriteString("\033\\")
+	return sb.String()
+}
+
+func writeSixelRuns(sb *strings.Builder, band []uint8) {
+	for i := 0; i < len(band); {
+		count := 1
+		for i+count < len(band) && band[i+count] == band[i] {
+			count++
+		}
+		if count > 3 {
+			fmt.Fprintf(sb, "!%v%c", count, band[i])
+		} else {
+			for j := 0; j < count; j++ {
+				sb.WriteByte(band[i])
+			}
+		}
+		i += count
+	}
+}
+
+// RenderKitty encodes the image with the kitty graphics protocol, replacing the previous image
+func RenderKitty(img *image.RGBA) string {
+	var buffer bytes.Buffer
+	encoder := png.Encoder{CompressionLevel: png.BestSpeed}
+	encoder.Encode(&buffer, img)
+	data := base64.StdEncoding.EncodeToString(buffer.Bytes())
+
+	var sb strings.Builder
+	// Delete the previous placements. q=2 avoids the responses of the terminal on the input
+	sb.WriteString("\033_Ga=d,q=2\033\\")
+	for i := 0; i < len(data); i += kittyChunkSize {
+		end := min(i+kittyChunkSize, len(data))
+		more := 0
+		if end < len(data) {
+			more = 1
+		}
+		if i == 0 {
+			fmt.Fprintf(&sb, "\033_Ga=T,f=100,q=2,m=%v;%s\033\\", more, data[i:end])
+		} else {
+			fmt.Fprintf(&sb, "\033_Gm=%v;%s\033\\", more, data[i:end])
+		}
+	}
+	sb.WriteString("\r\n")
+	return sb.String()
+}
+
+// RenderHalfBlocks renders the image with two pixels per character in 24 bits colour
+func RenderHalfBlocks(img *image.RGBA, columns int) string {
+	// Square pixels with the 4:3 aspect ratio of the screen
+	rows := columns * 3 / 8
+	small := resizeBoxFilter(img, columns, rows*2)
+
+	var sb strings.Builder
+	for y := 0; y < rows; y++ {
+		for x := 0; x < columns; x++ {
+			top := small.RGBAAt(x, 2*y)
+			bottom := small.RGBAAt(x, 2*y+1)
+			fmt.Fprintf(&sb, "\033[38;2;%v;%v;%vm\033[48;2;%v;%v;%vm▀",
+				top.R, top.G, top.B, bottom.R, bottom.G, bottom.B)
+		}
+		sb.WriteString("\033[0m\r\n")
+	}
+	return sb.String()
+}
+
+// RenderBraille renders the image with eight pixels per character and one colour
+func RenderBraille(img *image.RGBA, columns int) string {
+	// Braille cells are 2x4 dots, square dots with the 4:3 aspect ratio of the screen
+	rows := columns * 3 / 8
+	small := resizeBoxFilter(img, columns*2, rows*4)
+
+	// Bits of the dots of the Braille patterns by position
+	dots := [4][2]int{{0x01, 0x08}, {0x02, 0x10}, {0x04, 0x20}, {0x40, 0x80}}
+
+	var sb strings.Builder
+	for y := 0; y < rows; y++ {
+		for x := 0; x < columns; x++ {
+			pattern := 0
+			var r, g, b, count int
+			for dy := 0; dy < 4; dy++ {
+				for dx := 0; dx < 2; dx++ {
+					c := small.RGBAAt(2*x+dx, 4*y+dy)
+					if int(c.R)+int(c.G)+int(c.B) > 3*0x40 {
+						pattern |= dots[dy][dx]
+						r += int(c.R)
+						g += int(c.G)
+						b += int(c.B)
+						count++
+					}
+				}
+			}
+			if count == 0 {
+				sb.WriteByte(' ')
+				continue
+			}
+			fmt.Fprintf(&sb, "\033[38;2;%v;%v;%vm%c", r/count, g/count, b/count, rune(0x2800+pattern))
+		}
+		sb.WriteString("\033[0m\r\n")
+	}
+	return sb.String()
+}
+
+// resizeBoxFilter scales down the image averaging the pixels
+func resizeBoxFilter(in *image.RGBA, width int, height int) *image.RGBA {
+	b := in.Bounds()
+	out := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		y0 := b.Min.Y + y*b.Dy()/height
+		y1 := max(b.Min.Y+(y+1)*b.Dy()/height, y0+1)
+		for x := 0; x < width; x++ {
+			x0 := b.Min.X + x*b.Dx()/width
+			x1 := max(b.Min.X+(x+1)*b.Dx()/width, x0+1)
+			var r, g, bl, count int
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					c := in.RGBAAt(sx, sy)
+					r += int(c.R)
+					g += int(c.G)
+					bl += int(c.B)
+					count++
+				}
+			}
+			out.SetRGBA(x, y, color.RGBA{uint8(r / count), uint8(g / count), uint8(bl / count), 255})
+		}
+	}
+	return out
+}
@@ -0,0 +1,49 @@
+package screen
+
+import (
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+)
+
+func TestRenderSixel(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 8, 6))
+	for x := 0; x < 8; x++ {
+		for y := 0; y < 6; y++ {
+			img.Set(x, y, color.Black)
+		}
+	}
+	img.Set(0, 0, color.White)
+
+	got := RenderSixel(img)
+	want := "\033Pq\"1;1;8;6#0;2;100;100;100#1;2;0;0;0#0@!7?$#1}!7~-\033\\"
+	if got != want {
+		t.Errorf("unexpected sixel output %q", got)
+	}
+}
+
+func TestRenderHalfBlocks(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 280, 192))
+	out := RenderHalfBlocks(img, 40)
+	lines := strings.Split(strings.TrimSuffix(out, "\r\n"), "\r\n")
+	if len(lines) != 15 {
+		t.Errorf("expected 15 lines, got %v", len(lines))
+	}
+	if strings.Count(lines[0], "▀") != 40 {
+		t.Errorf("expected 40 characters per line, got %v", strings.Count(lines[0], "▀"))
+	}
+}
+
+func TestRenderBraille(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 280, 192))
+	for x := 0; x < 7; x++ {
+		for y := 0; y < 192; y++ {
+			img.Set(x, y, color.White)
+		}
+	}
+	out := RenderBraille(img, 40)
+	if !strings.Contains(out, "\033[38;2;255;255;255m⣿") {
+		t.Errorf("expected a full white Braille cell, got %q", out[:40])
+	}
+}
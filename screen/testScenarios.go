@@ -129,6 +129,11 @@ func (ts *TestScenario) GetSuperVideoMemory() []uint8 {
 	return ts.SVideoPage
 }
 
+// GetSuperPalettes returns the SHR palettes as seen by each scanline, nil if they didn't change on the frame
+func (ts *TestScenario) GetSuperPalettes() []uint8 {
+	return nil
+}
+
 // GetCardImage returns an image provided by a card, like the videx card
 func (ts *TestScenario) GetCardImage(light color.Color) *image.RGBA {
 	return nil
@@ -148,6 +153,10 @@ func buildImageName(name string, screenMode int, altSet bool) string {
 		screenName = "ntsc"
 	case ScreenModePlain:
 		screenName = "plain"
+	case ScreenModeComposite:
+		screenName = "composite"
+	case ScreenModePAL:
+		screenName = "pal"
 	default:
 		screenName = "unknown"
 	}
@@ -3,7 +3,6 @@ package screen
 import (
 	"image"
 	"image/color"
-	"time"
 )
 
 const (
@@ -73,18 +72,22 @@ func getTextCharOffset(col int, line int) uint16 {
 	return uint16(section*40 + eighth*0x80 + col)
 }
 
-func getRGBTextColor(pixel bool, colorKey uint8) color.Color {
+func getRGBTextColor(palette *Palette, pixel bool, colorKey uint8) color.Color {
 	if pixel {
 		colorKey >>= 4
 	}
 	colorKey &= 0x0f
-	return ntscColorMap[colorKey]
+	return palette.RGB[colorKey]
 
 }
 
 func renderText(vs VideoSource, text []uint8, isAltText bool, colorMap []uint8, light color.Color) *image.RGBA {
-	// Flash mode is 2Hz (host time)
-	isFlashedFrame := time.Now().Nanosecond() > (1 * 1000 * 1000 * 1000 / 2)
+	// Flash mode follows the emulated time, not flashed without it
+	isFlashedFrame := false
+	if fs, ok := vs.(FlashSource); ok {
+		isFlashedFrame = fs.IsFlashedFrame()
+	}
+	palette := paletteOf(vs)
 
 	columns := len(text) / textLines
 	if text == nil {
@@ -114,7 +117,7 @@ func renderText(vs VideoSource, text []uint8, isAltText bool, colorMap []uint8,
 
 			var colour color.Color
 			if colorMap != nil {
-				colour = getRGBTextColor(pixel, colorMap[charIndex])
+				colour = getRGBTextColor(palette, pixel, colorMap[charIndex])
 			} else if pixel {
 				colour = light
 			} else {
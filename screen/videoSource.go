@@ -52,8 +52,22 @@ type VideoSource interface {
 	GetCharacterPixel(char uint8, rowInChar int, colInChar int, isAltText bool, isFlashedFrame bool) bool
 	// GetSuperVideoMemory returns a slice to the SHR video memory
 	GetSuperVideoMemory() []uint8
+	// GetSuperPalettes returns the SHR palettes as seen by each scanline, nil if they didn't change on the frame
+	GetSuperPalettes() []uint8
 	// GetCardImage returns an image provided by a card, like the videx card
 	GetCardImage(light color.Color) *image.RGBA
 	// SupportsLowercase returns true if the video source supports lowercase
 	SupportsLowercase() bool
 }
+
+// FlashSource is implemented by the video sources with flashing characters
+type FlashSource interface {
+	// IsFlashedFrame returns true when the flashing characters are shown inverted
+	IsFlashedFrame() bool
+}
+
+// PaletteSource is implemented by the video sources with selectable colours
+type PaletteSource interface {
+	// GetPalette returns the colours to use, nil for the default palette
+	GetPalette() *Palette
+}
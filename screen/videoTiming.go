@@ -0,0 +1,65 @@
+package screen
+
+/*
+Frame timing of the video scanner. Each scanline takes 65 CPU cycles, 40
+for the visible bytes and 25 for the horizontal blanking. The NTSC frame
+has 262 lines and the PAL frame of the European models has 312 lines,
+both with 192 visible lines.
+
+See:
+	"Understanding the Apple II", chapter 3
+	"Understanding the Apple IIe", chapter 5
+*/
+
+// VideoTiming describes the frame of a video standard
+type VideoTiming struct {
+	Name          string
+	Lines         int     // Scanlines per frame
+	VisibleLines  int     // Scanlines with pixels
+	CyclesPerLine int     // CPU cycles per scanline
+	ClockMhz      float64 // CPU clock derived from the video crystal
+}
+
+// NTSCTiming is the frame timing of the american models
+var NTSCTiming = VideoTiming{"NTSC", 262, hiResHeight, 65, 14.318 / 14}
+
+// PALTiming is the frame timing of the european models, like the Europlus
+var PALTiming = VideoTiming{"PAL", 312, hiResHeight, 65, 14.238 / 14}
+
+// FrameCycles returns the CPU cycles to draw a full frame
+func (t VideoTiming) FrameCycles() uint64 {
+	return uint64(t.Lines * t.CyclesPerLine)
+}
+
+// VertBlankCycles returns the CPU cycles of the vertical blanking
+func (t VideoTiming) VertBlankCycles() uint64 {
+	return uint64((t.Lines - t.VisibleLines) * t.CyclesPerLine)
+}
+
+// BeamPosition returns the frame and the scanline drawn on a cycle. The frame
+// starts with the vertical blanking, the line is negative during the blanking
+// and counts from the first visible line after it.
+func (t VideoTiming) BeamPosition(cycles uint64) (uint64, int) {
+	frameCycles := t.FrameCycles()
+	line := int(cycles%frameCycles)/t.CyclesPerLine - (t.Lines - t.VisibleLines)
+	return cycles / frameCycles, line
+}
+
+// IsVertBlank returns true if the cycle is on the vertical blanking
+func (t VideoTiming) IsVertBlank(cycles uint64) bool {
+	_, line := t.BeamPosition(cycles)
+	return line < 0
+}
+
+// FrameRate returns the frames per second
+func (t VideoTiming) FrameRate() float64 {
+	return t.ClockMhz * 1_000_000 / float64(t.FrameCycles())
+}
+
+// DefaultScreenMode returns the colour TV screen mode for the video standard
+func (t VideoTiming) DefaultScreenMode() int {
+	if t == PALTiming {
+		return ScreenModePAL
+	}
+	return ScreenModeNTSC
+}
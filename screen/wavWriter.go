@@ -0,0 +1,78 @@
+package screen
+
+import (
+	"bufio"
+	"encoding/binary"
+	"os"
+)
+
+/*
+WAV file with 16 bits mono PCM samples, to record the sound along with
+the Y4M videos. The sizes on the header are updated when closed.
+
+See:
+	http://soundfile.sapp.org/doc/WaveFormat/
+*/
+
+// WavWriter writes the sound of a recording
+type WavWriter struct {
+	file       *os.File
+	w          *bufio.Writer
+	sampleRate int
+	samples    int
+}
+
+const wavHeaderSize = 44
+
+// NewWavWriter creates a WAV file
+func NewWavWriter(filename string, sampleRate int) (*WavWriter, error) {
+	f, err := os.Create(filename)
+	if err != nil {
+		return nil, err
+	}
+	var ww WavWriter
+	ww.file = f
+	ww.w = bufio.NewWriter(f)
+	ww.sampleRate = sampleRate
+
+	// Placeholder for the header
+	_, err = ww.w.Write(make([]uint8, wavHeaderSize))
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &ww, nil
+}
+
+// WriteSamples adds samples to the file
+func (ww *WavWriter) WriteSamples(samples []int16) error {
+	ww.samples += len(samples)
+	return binary.Write(ww.w, binary.LittleEndian, samples)
+}
+
+// Close completes the header and closes the file
+func (ww *WavWriter) Close() error {
+	defer ww.file.Close()
+	err := ww.w.Flush()
+	if err != nil {
+		return err
+	}
+
+	dataSize := uint32(ww.samples * 2)
+	header := make([]uint8, 0, wavHeaderSize)
+	header = append(header, "RIFF"...)
+	header = binary.LittleEndian.AppendUint32(header, 36+dataSize)
+	header = append(header, "WAVEfmt "...)
+	header = binary.LittleEndian.AppendUint32(header, 16) // Size of the fmt chunk
+	header = binary.LittleEndian.AppendUint16(header, 1)  // PCM
+	header = binary.LittleEndian.AppendUint16(header, 1)  // Mono
+	header = binary.LittleEndian.AppendUint32(header, uint32(ww.sampleRate))
+	header = binary.LittleEndian.AppendUint32(header, uint32(ww.sampleRate*2)) // Bytes per second
+	header = binary.LittleEndian.AppendUint16(header, 2)                       // Bytes per sample
+	header = binary.LittleEndian.AppendUint16(header, 16)                      // Bits per sample
+	header = append(header, "data"...)
+	header = binary.LittleEndian.AppendUint32(header, dataSize)
+
+	_, err = ww.file.WriteAt(header, 0)
+	return err
+}
@@ -7,6 +7,7 @@ import (
 	"strings"
 
 	"github.com/ivanizag/iz6502"
+	"github.com/ivanizag/izapple2/screen"
 )
 
 func configure(configuration *configuration) (*Apple2, error) {
@@ -20,8 +21,10 @@ func configure(configuration *configuration) (*Apple2, error) {
 	a.Name = configuration.get(confName)
 	a.mmu = newMemoryManager(&a)
 	a.video = newVideo(&a)
+	a.timing = screen.NTSCTiming
 	a.io = newIoC0Page(&a)
 	a.commandChannel = make(chan command, 100)
+	a.commandQueued = make(chan struct{}, 1)
 
 	// Configure the board
 	board := configuration.get(confBoard)
@@ -72,12 +75,29 @@ func configure(configuration *configuration) (*Apple2, error) {
 
 	a.setProfiling(configuration.getFlag(confProfile))
 	a.SetForceCaps(configuration.getFlag(confForceCaps))
+	a.SetDiskSounds(configuration.getFlag(confDiskSounds))
 
 	err = a.setClockSpeed(configuration.get(confSpeed))
 	if err != nil {
 		return nil, err
 	}
 
+	err = a.setVideoTiming(configuration.get(confVideo))
+	if err != nil {
+		return nil, err
+	}
+
+	err = a.loadPalette(configuration.get(confPalette))
+	if err != nil {
+		return nil, err
+	}
+
+	compositeSettings, err := screen.ParseCompositeSettings(configuration.get(confComposite))
+	if err != nil {
+		return nil, err
+	}
+	screen.SetCompositeSettings(compositeSettings)
+
 	// Add cards on the slots
 	for i := 0; i < 8; i++ {
 		cardConfig := configuration.get(fmt.Sprintf("s%v", i))
@@ -155,6 +175,34 @@ func (a *Apple2) setClockSpeed(speed string) error {
 	return nil
 }
 
+func (a *Apple2) setVideoTiming(video string) error {
+	switch video {
+	case "ntsc":
+		a.timing = screen.NTSCTiming
+	case "pal":
+		a.timing = screen.PALTiming
+	default:
+		return fmt.Errorf("invalid video standard: %s", video)
+	}
+	return nil
+}
+
+func (a *Apple2) loadPalette(filename string) error {
+	if filename == "" || filename == "default" {
+		a.palette = nil
+		return nil
+	}
+	data, _, err := LoadResource(filename)
+	if err != nil {
+		return fmt.Errorf("could not load the palette %s: %w", filename, err)
+	}
+	a.palette, err = screen.ParsePalette(data)
+	if err != nil {
+		return fmt.Errorf("invalid palette %s: %w", filename, err)
+	}
+	return nil
+}
+
 func (a *Apple2) setProfiling(value bool) {
 	a.profile = value
 }
@@ -164,6 +212,11 @@ func (a *Apple2) SetForceCaps(value bool) {
 	a.forceCaps = value
 }
 
+// SetDiskSounds allows the disk drives sounds to be toggled at runtime
+func (a *Apple2) SetDiskSounds(value bool) {
+	a.diskSoundsOn = value
+}
+
 func (a *Apple2) loadRom(filename string) error {
 	if filename == "<custom>" {
 		switch a.board {
@@ -239,6 +292,29 @@ func loadMultiPageRom(a *Apple2, filenames []string) error {
 	return nil
 }
 
+// CreateApple builds a machine for a model with changes to its configuration.
+// The keys of the overrides are the names of the command line options.
+func CreateApple(model string, overrides map[string]string) (*Apple2, error) {
+	models, defaultConfig, err := loadConfigurationModelsAndDefault()
+	if err != nil {
+		return nil, err
+	}
+
+	changes := newConfiguration()
+	for key, value := range overrides {
+		if !defaultConfig.has(key) {
+			return nil, fmt.Errorf("unknown configuration option %s", key)
+		}
+		changes.set(key, value)
+	}
+
+	configuration, err := models.getWithOverrides(model, changes)
+	if err != nil {
+		return nil, err
+	}
+	return configure(configuration)
+}
+
 // CreateConfiguredApple is a device independent main. Video, keyboard and speaker won't be defined
 func CreateConfiguredApple() (*Apple2, error) {
 	// Get configuration from defaults and the command line
@@ -47,6 +47,10 @@ const (
 	smartPortErrorIO             = uint8(0x27)
 	smartPortErrorNoDevice       = uint8(0x28)
 	smartPortErrorWriteProtected = uint8(0x2b)
+
+	// Not a SmartPort code, the firmware of the card repeats the call until
+	// the device has the result. Only the SmartPort entry supports it.
+	smartPortBusy = uint8(0x80)
 )
 
 type smartPortCall struct {
@@ -179,6 +183,8 @@ func smartPortErrorMessage(code uint8) string {
 		return "NO_DEVICE"
 	case smartPortErrorWriteProtected:
 		return "WRITE_PROTECT_ERROR"
+	case smartPortBusy:
+		return "BUSY"
 	default:
 		return string(code)
 
@@ -0,0 +1,164 @@
+package izapple2
+
+import (
+	"fmt"
+
+	"github.com/ivanizag/izapple2/storage"
+)
+
+/*
+A disk unit of the Fujinet. The images are mounted and unmounted at
+runtime by the fuji device. The unit is offline while empty.
+
+See:
+	https://github.com/FujiNetWIFI/fujinet-platformio/blob/master/lib/device/iwm/disk.cpp
+
+The images are loaded in memory and mounted read only.
+*/
+
+// SmartPortFujinetDisk represents a Fujinet disk unit
+type SmartPortFujinetDisk struct {
+	host     *CardSmartPort // For DMA
+	trace    bool
+	index    int
+	filename string
+	disk     storage.BlockDisk
+}
+
+// NewSmartPortFujinetDisk creates a new empty fujinet disk unit
+func NewSmartPortFujinetDisk(host *CardSmartPort, index int) *SmartPortFujinetDisk {
+	var d SmartPortFujinetDisk
+	d.host = host
+	d.index = index
+	return &d
+}
+
+func (d *SmartPortFujinetDisk) mount(filename string, data []uint8) error {
+	disk, err := storage.NewBlockDiskMemory(data)
+	if err != nil {
+		return err
+	}
+	d.filename = filename
+	d.disk = disk
+	return nil
+}
+
+func (d *SmartPortFujinetDisk) unmount() {
+	d.filename = ""
+	d.disk = nil
+}
+
+func (d *SmartPortFujinetDisk) exec(call *smartPortCall) uint8 {
+	var result uint8
+
+	switch call.command {
+
+	case smartPortCommandOpen:
+		result = smartPortNoError
+
+	case smartPortCommandClose:
+		result = smartPortNoError
+
+	case smartPortCommandStatus:
+		address := call.param16(2)
+		result = d.status(call.statusCode(), address)
+
+	case smartPortCommandReadBlock:
+		address := call.param16(2)
+		block := call.param24(4)
+		result = d.readBlock(block, address)
+
+	case smartPortCommandWriteBlock:
+		address := call.param16(2)
+		block := call.param24(4)
+		result = d.writeBlock(block, address)
+
+	default:
+		// Prodos device command not supported
+		result = smartPortErrorIO
+	}
+
+	if d.trace {
+		fmt.Printf("[SmartPortFujinetDisk] Command %v, return %s \n",
+			call, smartPortErrorMessage(result))
+	}
+
+	return result
+}
+
+func (d *SmartPortFujinetDisk) readBlock(block uint32, dest uint16) uint8 {
+	if d.disk == nil {
+		return smartPortErrorNoDevice
+	}
+
+	data, err := d.disk.Read(block)
+	if err != nil {
+		return smartPortErrorIO
+	}
+
+	// Byte by byte transfer to memory using the full Poke code path
+	for i := uint16(0); i < uint16(len(data)); i++ {
+		d.host.a.mmu.Poke(dest+i, data[i])
+	}
+
+	return smartPortNoError
+}
+
+func (d *SmartPortFujinetDisk) writeBlock(block uint32, source uint16) uint8 {
+	if d.disk == nil {
+		return smartPortErrorNoDevice
+	}
+	if d.disk.IsReadOnly() {
+		return smartPortErrorWriteProtected
+	}
+
+	// Byte by byte transfer from memory using the full Peek code path
+	buf := make([]uint8, storage.ProDosBlockSize)
+	for i := uint16(0); i < uint16(len(buf)); i++ {
+		buf[i] = d.host.a.mmu.Peek(source + i)
+	}
+
+	err := d.disk.Write(block, buf)
+	if err != nil {
+		return smartPortErrorIO
+	}
+
+	return smartPortNoError
+}
+
+func (d *SmartPortFujinetDisk) status(code uint8, dest uint16) uint8 {
+	statusByte := smartPortStatusCodeTypeBlock | smartPortStatusCodeTypeRead | smartPortStatusCodeTypeWrite
+	blocks := uint32(0)
+	if d.disk != nil {
+		statusByte |= smartPortStatusCodeTypeOnline
+		if d.disk.IsReadOnly() {
+			statusByte |= smartPortStatusCodeTypeProtected
+		}
+		blocks = d.disk.GetSizeInBlocks()
+	}
+
+	switch code {
+	case smartPortStatusCodeDevice:
+		d.host.a.mmu.pokeRange(dest, []uint8{
+			statusByte,
+			uint8(blocks), uint8(blocks >> 8), uint8(blocks >> 16),
+		})
+
+	case smartPortStatusCodeDeviceInfo:
+		name := fmt.Sprintf("FUJINET_DISK_%v", d.index)
+		dib := []uint8{
+			statusByte,
+			uint8(blocks), uint8(blocks >> 8), uint8(blocks >> 16),
+			uint8(len(name)),
+		}
+		dib = append(dib, fmt.Sprintf("%-16s", name)...)
+		dib = append(dib,
+			0x02,       // Type hard disk
+			0x00,       // Subtype
+			0x00, 0x01, // Firmware version
+		)
+		d.host.a.mmu.pokeRange(dest, dib)
+	}
+
+	return smartPortNoError // The return code is always success
+}
@@ -0,0 +1,576 @@
+package izapple2
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/ivanizag/izapple2/fujinet"
+)
+
+/*
+
+The fuji device of the Fujinet, used by the CONFIG program to manage the
+host slots and the device slots, browse directories and mount images on
+the disk units.
+
+See:
+	https://github.com/FujiNetWIFI/fujinet-platformio/blob/master/lib/device/iwm/fuji.cpp
+
+The commands sending data are CONTROL calls and the commands returning
+data are STATUS calls, both with the Fujinet command as code. To read a
+directory entry, a CONTROL call prepares it and a STATUS call returns it.
+
+The access to the hosts, that can be TNFS servers, runs in the background
+to not stop the emulation. Meanwhile the CONTROL call returns busy and the
+firmware repeats it until the result is ready.
+
+*/
+
+// SmartPortFujinetFuji represents the Fujinet control device
+type SmartPortFujinetFuji struct {
+	host   *CardSmartPort // For DMA
+	trace  bool
+	sdRoot string // Directory for the SD host
+
+	hostSlots   [fujiHostSlots]string
+	hosts       [fujiHostSlots]fujinet.Host // Hosts mounted
+	deviceSlots [fujiDeviceSlots]fujiDeviceSlot
+	disks       [fujiDeviceSlots]*SmartPortFujinetDisk
+
+	dirEntries  []fujinet.DirEntry
+	dirPosition int
+	dirEntry    []uint8 // Prepared for the next READ_DIR_ENTRY status
+
+	background *fujiBackground // Access to the hosts in progress
+}
+
+// fujiBackground is an access to the hosts running off the emulation thread
+type fujiBackground struct {
+	done   chan struct{}
+	result func() uint8 // Applies the result on the emulation thread
+}
+
+type fujiDeviceSlot struct {
+	hostSlot uint8
+	mode     uint8
+	filename string // Full path, the device slots show it truncated
+}
+
+const (
+	fujiHostSlots       = 8
+	fujiDeviceSlots     = 4
+	fujiHostNameLen     = 32
+	fujiFilenameLen     = 36
+	fujiDeviceSlotLen   = 2 + fujiFilenameLen
+	fujiNoHostSlot      = 0xff
+	fujiDirEntryDetails = 0x80 // Option to add the file details to the entry
+	fujiDirDetailsLen   = 10
+
+	fujiCmdReset             = 0xff
+	fujiCmdGetSsid           = 0xfe
+	fujiCmdGetWifiStatus     = 0xfa
+	fujiCmdMountHost         = 0xf9
+	fujiCmdMountImage        = 0xf8
+	fujiCmdOpenDirectory     = 0xf7
+	fujiCmdReadDirEntry      = 0xf6
+	fujiCmdCloseDirectory    = 0xf5
+	fujiCmdReadHostSlots     = 0xf4
+	fujiCmdWriteHostSlots    = 0xf3
+	fujiCmdReadDeviceSlots   = 0xf2
+	fujiCmdWriteDeviceSlots  = 0xf1
+	fujiCmdGetWifiEnabled    = 0xea
+	fujiCmdUnmountImage      = 0xe9
+	fujiCmdGetAdapterConfig  = 0xe8
+	fujiCmdUnmountHost       = 0xe6
+	fujiCmdGetDirPosition    = 0xe5
+	fujiCmdSetDirPosition    = 0xe4
+	fujiCmdSetDeviceFullpath = 0xe2
+	fujiCmdMountAll          = 0xd7
+)
+
+// NewSmartPortFujinetFuji creates a new fuji device managing the disk units provided
+func NewSmartPortFujinetFuji(host *CardSmartPort, disks []*SmartPortFujinetDisk) *SmartPortFujinetFuji {
+	var d SmartPortFujinetFuji
+	d.host = host
+	copy(d.disks[:], disks)
+	for i := range d.deviceSlots {
+		d.deviceSlots[i].hostSlot = fujiNoHostSlot
+	}
+	return &d
+}
+
+func (d *SmartPortFujinetFuji) exec(call *smartPortCall) uint8 {
+	var result uint8
+
+	switch call.command {
+
+	case smartPortCommandOpen:
+		result = smartPortNoError
+
+	case smartPortCommandClose:
+		result = smartPortNoError
+
+	case smartPortCommandStatus:
+		address := call.param16(2)
+		result = d.status(call.statusCode(), address)
+
+	case smartPortCommandControl:
+		data := call.paramData(2)
+		controlCode := call.param8(4)
+		result = d.control(data, controlCode)
+
+	default:
+		// Prodos device command not supported
+		result = smartPortErrorIO
+	}
+
+	if d.trace && result != smartPortBusy {
+		fmt.Printf("[SmartPortFujinetFuji] Command %v, return %s \n",
+			call, smartPortErrorMessage(result))
+	}
+
+	return result
+}
+
+func (d *SmartPortFujinetFuji) status(code uint8, dest uint16) uint8 {
+	var data []uint8
+
+	switch code {
+	case smartPortStatusCodeDevice:
+		data = []uint8{
+			smartPortStatusCodeTypeRead & smartPortStatusCodeTypeOnline,
+			0, 0, 0, // Block size is 0
+		}
+
+	case smartPortStatusCodeDeviceInfo:
+		data = []uint8{
+			smartPortStatusCodeTypeRead & smartPortStatusCodeTypeOnline,
+			0, 0, 0, // Block size is 0
+			8, 'T', 'H', 'E', '_', 'F', 'U', 'J', 'I', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ',
+			0x10,       // Type fujinet
+			0x00,       // Subtype
+			0x00, 0x01, // Firmware version
+		}
+
+	case fujiCmdReadHostSlots:
+		for _, name := range d.hostSlots {
+			data = append(data, fixedString(name, fujiHostNameLen)...)
+		}
+
+	case fujiCmdReadDeviceSlots:
+		for _, slot := range d.deviceSlots {
+			data = append(data, slot.hostSlot, slot.mode)
+			data = append(data, fixedString(slot.filename, fujiFilenameLen)...)
+		}
+
+	case fujiCmdReadDirEntry:
+		data = d.dirEntry
+
+	case fujiCmdGetDirPosition:
+		data = []uint8{uint8(d.dirPosition), uint8(d.dirPosition >> 8)}
+
+	case fujiCmdGetWifiStatus:
+		data = []uint8{3} // Connected
+
+	case fujiCmdGetWifiEnabled:
+		data = []uint8{1}
+
+	case fujiCmdGetSsid:
+		data = fixedString("izapple2", 33)
+		data = append(data, make([]uint8, 64)...) // No password
+
+	case fujiCmdGetAdapterConfig:
+		data = fixedString("izapple2", 33)                  // SSID
+		data = append(data, fixedString("fujinet", 64)...)  // Hostname
+		data = append(data, 127, 0, 0, 1)                   // IP address
+		data = append(data, 127, 0, 0, 1)                   // Gateway
+		data = append(data, 255, 0, 0, 0)                   // Netmask
+		data = append(data, 127, 0, 0, 1)                   // DNS
+		data = append(data, 0, 0, 0, 0, 0, 0)               // MAC
+		data = append(data, 0, 0, 0, 0, 0, 0)               // BSSID
+		data = append(data, fixedString("izapple2", 15)...) // Firmware version
+	}
+
+	if d.trace {
+		fmt.Printf("[SmartPortFujinetFuji] Status $%02x with %v bytes into $%x.\n",
+			code, len(data), dest)
+	}
+	d.host.a.mmu.pokeRange(dest, data)
+	return smartPortNoError // The return code is always success
+}
+
+func (d *SmartPortFujinetFuji) control(data []uint8, code uint8) uint8 {
+	if d.background != nil {
+		// The firmware repeats the call until the access to the host ends
+		return d.backgroundResult()
+	}
+
+	if d.trace {
+		fmt.Printf("[SmartPortFujinetFuji] Control $%02x with %v bytes.\n",
+			code, len(data))
+	}
+
+	arg := func(i int) uint8 {
+		if i < len(data) {
+			return data[i]
+		}
+		return 0
+	}
+
+	switch code {
+	case fujiCmdReset:
+		for i := range d.deviceSlots {
+			d.unmountImage(i)
+		}
+		for i := range d.hosts {
+			d.unmountHost(i)
+		}
+
+	case fujiCmdWriteHostSlots:
+		for i := range d.hostSlots {
+			start := i * fujiHostNameLen
+			if start+fujiHostNameLen <= len(data) {
+				name := readCString(data[start : start+fujiHostNameLen])
+				if name != d.hostSlots[i] {
+					d.unmountHost(i)
+					d.hostSlots[i] = name
+				}
+			}
+		}
+
+	case fujiCmdMountHost:
+		return d.mountHost(int(arg(0)))
+
+	case fujiCmdUnmountHost:
+		d.unmountHost(int(arg(0)))
+
+	case fujiCmdOpenDirectory:
+		if len(data) < 2 {
+			return smartPortErrorIO
+		}
+		parts := strings.SplitN(string(data[1:]), "\x00", 3)
+		filter := ""
+		if len(parts) > 1 {
+			filter = parts[1]
+		}
+		return d.openDirectory(int(data[0]), parts[0], filter)
+
+	case fujiCmdReadDirEntry:
+		d.readDirEntry(int(arg(0)), arg(1))
+
+	case fujiCmdCloseDirectory:
+		d.dirEntries = nil
+
+	case fujiCmdSetDirPosition:
+		d.dirPosition = int(arg(0)) + int(arg(1))<<8
+
+	case fujiCmdWriteDeviceSlots:
+		for i := range d.deviceSlots {
+			start := i * fujiDeviceSlotLen
+			if start+fujiDeviceSlotLen <= len(data) {
+				slot := &d.deviceSlots[i]
+				slot.hostSlot = data[start]
+				slot.mode = data[start+1]
+				filename := readCString(data[start+2 : start+fujiDeviceSlotLen])
+				if !strings.HasPrefix(slot.filename, filename) {
+					// Keep the full path if the truncated one is written back
+					slot.filename = filename
+				}
+			}
+		}
+
+	case fujiCmdSetDeviceFullpath:
+		slot := int(arg(0))
+		if slot >= fujiDeviceSlots || len(data) < 3 {
+			return smartPortErrorIO
+		}
+		d.deviceSlots[slot].hostSlot = data[1]
+		d.deviceSlots[slot].mode = data[2]
+		d.deviceSlots[slot].filename = readCString(data[3:])
+
+	case fujiCmdMountImage:
+		slot := int(arg(0))
+		if slot >= fujiDeviceSlots {
+			return smartPortErrorIO
+		}
+		d.deviceSlots[slot].mode = arg(1)
+		return d.mountImages([]int{slot})
+
+	case fujiCmdUnmountImage:
+		slot := int(arg(0))
+		if slot >= fujiDeviceSlots {
+			return smartPortErrorIO
+		}
+		d.unmountImage(slot)
+
+	case fujiCmdMountAll:
+		var slots []int
+		for i, slot := range d.deviceSlots {
+			if slot.hostSlot != fujiNoHostSlot && slot.filename != "" {
+				slots = append(slots, i)
+			}
+		}
+		return d.mountImages(slots)
+	}
+
+	return smartPortNoError
+}
+
+// SetHostSlot sets the name of a host slot, "SD" or a TNFS server
+func (d *SmartPortFujinetFuji) SetHostSlot(slot int, name string) {
+	d.unmountHost(slot)
+	d.hostSlots[slot] = name
+}
+
+// runInBackground starts an access to the hosts. The operation runs on its
+// own goroutine and returns a function to apply the result on the
+// emulation thread. Meanwhile the calls return busy.
+func (d *SmartPortFujinetFuji) runInBackground(operation func() func() uint8) uint8 {
+	background := &fujiBackground{done: make(chan struct{})}
+	go func() {
+		background.result = operation()
+		close(background.done)
+	}()
+	d.background = background
+	return smartPortBusy
+}
+
+// backgroundResult returns busy until the access to the hosts ends, then its result
+func (d *SmartPortFujinetFuji) backgroundResult() uint8 {
+	select {
+	case <-d.background.done:
+		result := d.background.result()
+		d.background = nil
+		return result
+	default:
+		return smartPortBusy
+	}
+}
+
+// fujiHostAccess is a host used in the background, mounted if needed
+type fujiHostAccess struct {
+	slot    int
+	host    fujinet.Host
+	mounted bool
+	err     error
+}
+
+func (d *SmartPortFujinetFuji) hostAccess(slot int) *fujiHostAccess {
+	if slot >= fujiHostSlots || d.hostSlots[slot] == "" {
+		return nil
+	}
+	if d.hosts[slot] != nil {
+		return &fujiHostAccess{slot: slot, host: d.hosts[slot], mounted: true}
+	}
+	return &fujiHostAccess{slot: slot, host: fujinet.NewHost(d.hostSlots[slot], d.sdRoot)}
+}
+
+// mount connects to the host, it runs in the background
+func (h *fujiHostAccess) mount() error {
+	if !h.mounted && h.err == nil {
+		h.err = h.host.Mount()
+		h.mounted = h.err == nil
+	}
+	return h.err
+}
+
+// keep stores the host once mounted, on the emulation thread
+func (d *SmartPortFujinetFuji) keep(h *fujiHostAccess) uint8 {
+	if h.err != nil {
+		if d.trace {
+			fmt.Printf("[SmartPortFujinetFuji] Error mounting host '%s': %v\n", d.hostSlots[h.slot], h.err)
+		}
+		return smartPortErrorIO
+	}
+	if h.mounted {
+		d.hosts[h.slot] = h.host
+	}
+	return smartPortNoError
+}
+
+func (d *SmartPortFujinetFuji) mountHost(slot int) uint8 {
+	h := d.hostAccess(slot)
+	if h == nil {
+		return smartPortErrorIO
+	}
+	if h.mounted {
+		return smartPortNoError // Already mounted
+	}
+
+	return d.runInBackground(func() func() uint8 {
+		h.mount()
+		return func() uint8 {
+			return d.keep(h)
+		}
+	})
+}
+
+func (d *SmartPortFujinetFuji) unmountHost(slot int) {
+	if slot >= fujiHostSlots || d.hosts[slot] == nil {
+		return
+	}
+	d.hosts[slot].Unmount()
+	d.hosts[slot] = nil
+}
+
+func (d *SmartPortFujinetFuji) openDirectory(slot int, dirPath string, filter string) uint8 {
+	h := d.hostAccess(slot)
+	if h == nil {
+		return smartPortErrorIO
+	}
+
+	return d.runInBackground(func() func() uint8 {
+		var entries []fujinet.DirEntry
+		err := h.mount()
+		if err == nil {
+			entries, err = h.host.ReadDir(dirPath)
+		}
+
+		return func() uint8 {
+			result := d.keep(h)
+			if result != smartPortNoError {
+				return result
+			}
+			if err != nil {
+				if d.trace {
+					fmt.Printf("[SmartPortFujinetFuji] Error reading directory '%s': %v\n", dirPath, err)
+				}
+				return smartPortErrorIO
+			}
+
+			d.dirEntries = nil
+			for _, entry := range entries {
+				if filter != "" && !entry.IsDir {
+					matched, _ := path.Match(strings.ToLower(filter), strings.ToLower(entry.Name))
+					if !matched {
+						continue
+					}
+				}
+				d.dirEntries = append(d.dirEntries, entry)
+			}
+			d.dirPosition = 0
+			return smartPortNoError
+		}
+	})
+}
+
+// readDirEntry prepares the next directory entry, see fujiDevice::read_directory_entry()
+func (d *SmartPortFujinetFuji) readDirEntry(maxLen int, options uint8) {
+	d.dirEntry = make([]uint8, maxLen)
+	name := d.dirEntry
+	if options&fujiDirEntryDetails != 0 && maxLen >= fujiDirDetailsLen {
+		name = d.dirEntry[fujiDirDetailsLen:]
+	}
+
+	if d.dirPosition >= len(d.dirEntries) {
+		// End of directory
+		copy(name, []uint8{0x7f, 0x7f})
+		return
+	}
+	entry := d.dirEntries[d.dirPosition]
+	d.dirPosition++
+
+	filename := entry.Name
+	if entry.IsDir {
+		filename += "/"
+	}
+	truncated := len(filename) >= len(name)
+	copy(name[:max(len(name)-1, 0)], filename)
+
+	if options&fujiDirEntryDetails != 0 && maxLen >= fujiDirDetailsLen {
+		modified := entry.Modified
+		details := d.dirEntry[:fujiDirDetailsLen]
+		details[0] = uint8(modified.Year() - 1970)
+		details[1] = uint8(modified.Month())
+		details[2] = uint8(modified.Day())
+		details[3] = uint8(modified.Hour())
+		details[4] = uint8(modified.Minute())
+		details[5] = uint8(modified.Second())
+		details[6] = uint8(entry.Size)
+		details[7] = uint8(entry.Size >> 8)
+		if entry.IsDir {
+			details[8] |= 0x01
+		}
+		if truncated {
+			details[8] |= 0x02
+		}
+		details[9] = 0 // Media type unknown
+	}
+}
+
+// mountImages reads the images of the device slots and mounts them on the disk units
+func (d *SmartPortFujinetFuji) mountImages(slots []int) uint8 {
+	if len(slots) == 0 {
+		return smartPortNoError
+	}
+
+	hosts := make(map[int]*fujiHostAccess)
+	imageHosts := make([]*fujiHostAccess, len(slots))
+	filenames := make([]string, len(slots))
+	for i, slot := range slots {
+		deviceSlot := &d.deviceSlots[slot]
+		hostSlot := int(deviceSlot.hostSlot)
+		if hosts[hostSlot] == nil {
+			hosts[hostSlot] = d.hostAccess(hostSlot)
+		}
+		if hosts[hostSlot] == nil || deviceSlot.filename == "" {
+			return smartPortErrorIO
+		}
+		imageHosts[i] = hosts[hostSlot]
+		filenames[i] = deviceSlot.filename
+	}
+
+	return d.runInBackground(func() func() uint8 {
+		images := make([][]uint8, len(slots))
+		errs := make([]error, len(slots))
+		for i, h := range imageHosts {
+			errs[i] = h.mount()
+			if errs[i] == nil {
+				images[i], errs[i] = h.host.ReadFile(filenames[i])
+			}
+		}
+
+		return func() uint8 {
+			for _, h := range hosts {
+				d.keep(h)
+			}
+			for i, slot := range slots {
+				err := errs[i]
+				if err == nil {
+					err = d.disks[slot].mount(filenames[i], images[i])
+				}
+				if err != nil {
+					if d.trace {
+						fmt.Printf("[SmartPortFujinetFuji] Error mounting image '%s': %v\n", filenames[i], err)
+					}
+					return smartPortErrorIO
+				}
+			}
+			return smartPortNoError
+		}
+	})
+}
+
+func (d *SmartPortFujinetFuji) unmountImage(slot int) {
+	d.disks[slot].unmount()
+	d.deviceSlots[slot].hostSlot = fujiNoHostSlot
+	d.deviceSlots[slot].mode = 0
+	d.deviceSlots[slot].filename = ""
+}
+
+// fixedString returns a string padded with zeros to a fixed length
+func fixedString(s string, length int) []uint8 {
+	data := make([]uint8, length)
+	copy(data[:length-1], s)
+	return data
+}
+
+func readCString(data []uint8) string {
+	for i, v := range data {
+		if v == 0 {
+			return string(data[:i])
+		}
+	}
+	return string(data)
+}
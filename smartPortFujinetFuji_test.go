@@ -0,0 +1,136 @@
+package izapple2
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// fujiControl repeats the call while the device is busy, as the firmware does
+func fujiControl(fuji *SmartPortFujinetFuji, data []uint8, code uint8) uint8 {
+	result := fuji.control(data, code)
+	for result == smartPortBusy {
+		time.Sleep(time.Millisecond)
+		result = fuji.control(data, code)
+	}
+	return result
+}
+
+func TestFujinetMountImageFromSD(t *testing.T) {
+	sd := t.TempDir()
+	image := make([]uint8, 280*512) // 140Kb ProDOS order
+	image[512+7] = 0x5a             // Marker on block 1
+	err := os.WriteFile(filepath.Join(sd, "test.po"), image, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	overrides := newConfiguration()
+	overrides.set(confS7, "fujinet,sd="+sd)
+	at, err := makeApple2Tester("2enh", overrides)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := at.a
+	card := a.GetCards()[7].(*CardSmartPort)
+	fuji := card.devices[2].(*SmartPortFujinetFuji)
+	disk := card.devices[3].(*SmartPortFujinetDisk)
+	buffer := uint16(0x2000)
+
+	// Browse the SD host on slot 0
+	if fuji.control([]uint8{0, '/', 0, 0}, fujiCmdOpenDirectory) != smartPortBusy {
+		t.Fatal("The directory is expected to be read in the background")
+	}
+	if fujiControl(fuji, nil, fujiCmdOpenDirectory) != smartPortNoError {
+		t.Fatal("Error opening the directory")
+	}
+	fuji.control([]uint8{32, 0}, fujiCmdReadDirEntry)
+	fuji.status(fujiCmdReadDirEntry, buffer)
+	if name := readCString(peekRange(a, buffer, 32)); name != "test.po" {
+		t.Errorf("Unexpected entry '%s'", name)
+	}
+	fuji.control([]uint8{32, 0}, fujiCmdReadDirEntry)
+	fuji.status(fujiCmdReadDirEntry, buffer)
+	if a.mmu.Peek(buffer) != 0x7f {
+		t.Error("End of directory expected")
+	}
+
+	// Mount the image on the first disk unit
+	fullPath := append([]uint8{0, 0, 1}, "test.po"...)
+	fuji.control(fullPath, fujiCmdSetDeviceFullpath)
+	if fujiControl(fuji, []uint8{0, 1}, fujiCmdMountImage) != smartPortNoError {
+		t.Fatal("Error mounting the image")
+	}
+	fuji.status(fujiCmdReadDeviceSlots, buffer)
+	if name := readCString(peekRange(a, buffer+2, fujiFilenameLen)); name != "test.po" {
+		t.Errorf("Unexpected device slot '%s'", name)
+	}
+
+	if disk.readBlock(1, buffer) != smartPortNoError || a.mmu.Peek(buffer+7) != 0x5a {
+		t.Error("Block 1 not read from the mounted image")
+	}
+
+	fuji.control([]uint8{0}, fujiCmdUnmountImage)
+	if disk.readBlock(1, buffer) != smartPortErrorNoDevice {
+		t.Error("Disk unit expected to be empty")
+	}
+}
+
+func TestFujinetHostInBackground(t *testing.T) {
+	// TNFS server that answers the mount only when released
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	release := make(chan struct{})
+	go func() {
+		request := make([]uint8, 1024)
+		for {
+			n, remote, err := conn.ReadFromUDP(request)
+			if err != nil {
+				return
+			}
+			if n >= 4 && request[3] == 0x00 { // Mount
+				<-release
+				conn.WriteToUDP([]uint8{0x34, 0x12, request[2], request[3], 0, 0x02, 0x01, 0xe8, 0x03}, remote)
+			}
+		}
+	}()
+
+	overrides := newConfiguration()
+	overrides.set(confS7, "fujinet,host="+conn.LocalAddr().String())
+	at, err := makeApple2Tester("2enh", overrides)
+	if err != nil {
+		t.Fatal(err)
+	}
+	card := at.a.GetCards()[7].(*CardSmartPort)
+	fuji := card.devices[2].(*SmartPortFujinetFuji)
+
+	if fuji.control([]uint8{1}, fujiCmdMountHost) != smartPortBusy {
+		t.Fatal("The host is expected to be mounted in the background")
+	}
+	if fuji.control([]uint8{1}, fujiCmdMountHost) != smartPortBusy {
+		t.Fatal("The device is expected to be busy until the server answers")
+	}
+	close(release)
+	if fujiControl(fuji, []uint8{1}, fujiCmdMountHost) != smartPortNoError {
+		t.Fatal("Error mounting the host")
+	}
+	if fuji.hosts[1] == nil {
+		t.Error("The mounted host is not kept")
+	}
+	if fuji.control([]uint8{1}, fujiCmdMountHost) != smartPortNoError {
+		t.Error("A mounted host is expected to answer immediately")
+	}
+}
+
+func peekRange(a *Apple2, address uint16, length int) []uint8 {
+	data := make([]uint8, length)
+	for i := range data {
+		data[i] = a.mmu.Peek(address + uint16(i))
+	}
+	return data
+}
@@ -3,6 +3,7 @@ package izapple2
 import (
 	"fmt"
 	"net/url"
+	"slices"
 	"strings"
 
 	"github.com/ivanizag/izapple2/fujinet"
@@ -23,12 +24,17 @@ type SmartPortFujinetNetwork struct {
 	trace bool
 
 	protocol        fujinet.Protocol
+	translation     uint8
 	jsonChannelMode bool
 	statusByte      uint8
 	errorCode       fujinet.ErrorCode
 
-	jsonData *fujinet.FnJson
-	data     []uint8
+	jsonData    *fujinet.FnJson
+	jsonBody    *fujinet.FnJson // Values written in JSON mode
+	jsonParsing bool            // Parse requested, the response is still arriving
+	jsonQuery   []uint8         // Query received while parsing
+	data        []uint8         // Result of the last JSON query
+	dataPos     int             // Read position on data
 	// connected    uint8
 }
 
@@ -67,6 +73,11 @@ func (d *SmartPortFujinetNetwork) exec(call *smartPortCall) uint8 {
 		pos := call.param24(6)
 		result = d.read(pos, len, address)
 
+	case smartPortCommandWrite:
+		address := call.param16(2)
+		len := call.param16(4)
+		result = d.write(len, address)
+
 	default:
 		// Prodos device command not supported
 		result = smartPortErrorIO
@@ -86,14 +97,94 @@ func (d *SmartPortFujinetNetwork) read(pos uint32, length uint16, dest uint16) u
 			length, pos, dest)
 	}
 
+	var data []uint8
+	if stream, ok := d.protocol.(fujinet.StreamProtocol); ok && !d.jsonChannelMode {
+		if seekable, ok := d.protocol.(fujinet.SeekableProtocol); ok && pos != 0 {
+			// Reads with position 0 continue after the previous read
+			seekable.Seek(int(pos))
+		}
+		data, d.errorCode = stream.Read(int(length))
+		data = fujinet.TranslateReceive(data, d.translation)
+	} else {
+		// Reads of the JSON query result, with the same position rules
+		d.continueJsonParse()
+		if pos != 0 {
+			d.dataPos = int(pos)
+		}
+		data = d.data[min(d.dataPos, len(d.data)):]
+		data = data[:min(int(length), len(data))]
+		d.dataPos += len(data)
+	}
+
 	// Byte by byte transfer to memory using the full Poke code path
-	for i := uint16(0); i < uint16(len(d.data)) && i < length; i++ {
-		d.host.a.mmu.Poke(dest+i, d.data[i])
+	for i := uint16(0); i < uint16(len(data)) && i < length; i++ {
+		d.host.a.mmu.Poke(dest+i, data[i])
 	}
 
 	return smartPortNoError
 }
 
+func (d *SmartPortFujinetNetwork) write(length uint16, source uint16) uint8 {
+	if d.trace {
+		fmt.Printf("[SmartPortFujinetNetwork] Write %v bytes from $%x.\n",
+			length, source)
+	}
+
+	if d.protocol == nil {
+		d.errorCode = fujinet.NetworkErrorNotConnected
+		return smartPortErrorIO
+	}
+
+	data := make([]uint8, length)
+	for i := range data {
+		data[i] = d.host.a.mmu.Peek(source + uint16(i))
+	}
+
+	if d.jsonChannelMode {
+		return d.writeJson(data)
+	}
+
+	err := d.protocol.Write(fujinet.TranslateTransmit(data, d.translation))
+	if err != nil {
+		d.errorCode = fujinet.NetworkErrorGeneral
+		return smartPortErrorIO
+	}
+
+	return smartPortNoError
+}
+
+// writeJson sets values to generate a JSON body, as "path=value" lines
+func (d *SmartPortFujinetNetwork) writeJson(data []uint8) uint8 {
+	if d.jsonBody == nil {
+		d.jsonBody = fujinet.NewFnJson()
+	}
+
+	lines := strings.FieldsFunc(string(data), func(r rune) bool {
+		return r == '\r' || r == '\n' || r == 0
+	})
+	for _, line := range lines {
+		path, value, found := strings.Cut(line, "=")
+		if !found {
+			d.errorCode = fujinet.NetworkErrorInvalidCommand
+			return smartPortErrorIO
+		}
+		d.errorCode = d.jsonBody.Set([]uint8(path), []uint8(value))
+		if d.errorCode != fujinet.NoError {
+			return smartPortErrorIO
+		}
+	}
+
+	return smartPortNoError
+}
+
+// flushJson sends the generated JSON body to the protocol
+func (d *SmartPortFujinetNetwork) flushJson() {
+	if d.jsonBody != nil && d.protocol != nil {
+		d.protocol.Write(d.jsonBody.Generate())
+	}
+	d.jsonBody = nil
+}
+
 func (d *SmartPortFujinetNetwork) control(data []uint8, code uint8) uint8 {
 	switch code {
 	case 'O':
@@ -113,9 +204,23 @@ func (d *SmartPortFujinetNetwork) control(data []uint8, code uint8) uint8 {
 			d.controlJsonQuery(data)
 		}
 
+	case 'C':
+		// Close
+		d.jsonParsing = false
+		if d.protocol != nil {
+			d.protocol.Close()
+			d.protocol = nil
+		}
+
 	case 0xfc:
 		mode := data[0]
 		d.controlChannelMode(mode)
+
+	default:
+		// Protocol specific commands, like accept on TCP servers
+		if stream, ok := d.protocol.(fujinet.StreamProtocol); ok {
+			d.errorCode = stream.Control(code, data)
+		}
 	}
 
 	return smartPortNoError
@@ -127,6 +232,32 @@ func (d *SmartPortFujinetNetwork) controlJsonParse() {
 		fmt.Printf("[SmartPortFujinetNetwork] control-parse()\n")
 	}
 
+	if d.protocol == nil {
+		d.errorCode = fujinet.NetworkErrorNotConnected
+		return
+	}
+
+	d.flushJson()
+	d.jsonData = nil
+	d.jsonQuery = nil
+	d.data = nil
+	d.dataPos = 0
+	d.jsonParsing = true
+	d.continueJsonParse()
+}
+
+// continueJsonParse parses the response once it is complete. The parse
+// control returns immediately and the status reports no data until then.
+func (d *SmartPortFujinetNetwork) continueJsonParse() {
+	if !d.jsonParsing || d.protocol == nil {
+		d.jsonParsing = false
+		return
+	}
+	if background, ok := d.protocol.(fujinet.BackgroundProtocol); ok && !background.Ready() {
+		return
+	}
+	d.jsonParsing = false
+
 	data, errorCode := d.protocol.ReadAll()
 	if errorCode != fujinet.NoError {
 		d.errorCode = errorCode
@@ -134,7 +265,12 @@ func (d *SmartPortFujinetNetwork) controlJsonParse() {
 	}
 
 	d.jsonData = fujinet.NewFnJson()
+	d.jsonData.LineEnding = fujinet.JsonLineEnding(d.translation)
 	d.errorCode = d.jsonData.Parse(data)
+	if d.jsonQuery != nil {
+		d.controlJsonQuery(d.jsonQuery)
+		d.jsonQuery = nil
+	}
 }
 
 func (d *SmartPortFujinetNetwork) controlJsonQuery(query []uint8) {
@@ -142,9 +278,17 @@ func (d *SmartPortFujinetNetwork) controlJsonQuery(query []uint8) {
 		fmt.Printf("[SmartPortFujinetNetwork] control-query('%s')\n", query)
 	}
 
+	d.continueJsonParse()
+	if d.jsonParsing {
+		// Applied when the parse ends
+		d.jsonQuery = slices.Clone(query)
+		return
+	}
+
 	if d.jsonData != nil {
 		d.jsonData.Query(query)
 		d.data = d.jsonData.Result
+		d.dataPos = 0
 	}
 }
 
@@ -155,6 +299,9 @@ func (d *SmartPortFujinetNetwork) controlChannelMode(mode uint8) {
 	}
 
 	if mode == 0 {
+		if d.jsonChannelMode {
+			d.flushJson()
+		}
 		d.jsonChannelMode = false
 	} else if mode == 1 {
 		d.jsonChannelMode = true
@@ -173,6 +320,9 @@ func (d *SmartPortFujinetNetwork) controlOpen(method uint8, translation uint8, r
 		d.protocol = nil
 	}
 	d.statusByte = 0
+	d.translation = translation
+	d.jsonBody = nil
+	d.jsonParsing = false
 
 	// Remove "N:" prefix
 	rawUrl = strings.TrimPrefix(rawUrl, "N:")
@@ -181,6 +331,7 @@ func (d *SmartPortFujinetNetwork) controlOpen(method uint8, translation uint8, r
 	if err != nil {
 		d.errorCode = fujinet.NetworkErrorInvalidDeviceSpec
 		d.statusByte = 4 // client_error
+		return
 	}
 
 	d.protocol, d.errorCode = fujinet.InstantiateProtocol(urlParsed, method)
@@ -189,7 +340,12 @@ func (d *SmartPortFujinetNetwork) controlOpen(method uint8, translation uint8, r
 		return
 	}
 
-	d.protocol.Open(urlParsed)
+	d.errorCode = d.protocol.Open(urlParsed)
+	if d.errorCode != fujinet.NoError {
+		d.protocol = nil
+		d.statusByte = 4 // client_error
+		return
+	}
 	d.jsonChannelMode = false
 }
 
@@ -219,8 +375,12 @@ func (d *SmartPortFujinetNetwork) status(code uint8, dest uint16) uint8 {
 
 	case 'S':
 		// Get connection status
-		len := len(d.data)
-		if d.jsonChannelMode {
+		d.continueJsonParse()
+		len := len(d.data) - min(d.dataPos, len(d.data))
+		if d.jsonChannelMode && d.jsonParsing {
+			// The response is still arriving, nothing waiting yet
+			d.host.a.mmu.pokeRange(dest, []uint8{0, 0, 1 /*True*/, 0})
+		} else if d.jsonChannelMode {
 			// See FNJSON
 			errorCode := 0
 			if len == 0 {
@@ -232,6 +392,18 @@ func (d *SmartPortFujinetNetwork) status(code uint8, dest uint16) uint8 {
 				1, /*True*/
 				uint8(errorCode),
 			})
+		} else if stream, ok := d.protocol.(fujinet.StreamProtocol); ok {
+			// See the status() of NetworkProtocolTCP, NetworkProtocolUDP and NetworkProtocolHTTP
+			waiting, connected, errorCode := stream.Status()
+			if waiting > 0xffff {
+				waiting = 0xffff
+			}
+			d.host.a.mmu.pokeRange(dest, []uint8{
+				uint8(waiting & 0xff),
+				uint8((waiting >> 8) & 0xff),
+				boolToConnected(connected),
+				uint8(errorCode),
+			})
 		} else {
 			// TODO
 			d.host.a.mmu.pokeRange(dest, []uint8{
@@ -245,3 +417,10 @@ func (d *SmartPortFujinetNetwork) status(code uint8, dest uint16) uint8 {
 
 	return smartPortNoError // The return code is always success
 }
+
+func boolToConnected(connected bool) uint8 {
+	if connected {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,62 @@
+package izapple2
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestFujinetNetworkJsonReadsInChunks(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		w.Write([]uint8(`{"name":"izapple2"}`))
+	}))
+	defer server.Close()
+
+	overrides := newConfiguration()
+	overrides.set(confS7, "fujinet")
+	at, err := makeApple2Tester("2enh", overrides)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := at.a
+	card := a.GetCards()[7].(*CardSmartPort)
+	net := card.devices[0].(*SmartPortFujinetNetwork)
+	buffer := uint16(0x2000)
+
+	net.control(append([]uint8{4, 0}, (server.URL+"/")...), 'O')
+	net.control([]uint8{1}, 0xfc) // JSON channel mode
+	net.control(nil, 'P')         // Returns with the request in progress
+	net.control([]uint8("/name"), 'Q')
+
+	net.status('S', buffer)
+	if a.mmu.Peek(buffer) != 0 || a.mmu.Peek(buffer+2) != 1 || a.mmu.Peek(buffer+3) != 0 {
+		t.Error("Connected without data expected while the response arrives")
+	}
+	close(release)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for net.status('S', buffer); a.mmu.Peek(buffer) == 0 && time.Now().Before(deadline); net.status('S', buffer) {
+		time.Sleep(time.Millisecond)
+	}
+	if waiting := a.mmu.Peek(buffer); waiting != 8 {
+		t.Errorf("8 bytes waiting expected, got %v", waiting)
+	}
+
+	net.read(0, 4, buffer)
+	net.read(0, 10, buffer+4)
+	if result := string(peekRange(a, buffer, 8)); result != "izapple2" {
+		t.Errorf("Unexpected result '%s'", result)
+	}
+	net.status('S', buffer)
+	if a.mmu.Peek(buffer) != 0 || a.mmu.Peek(buffer+3) != uint8(136) {
+		t.Error("End of file expected after reading the result")
+	}
+
+	net.read(2, 3, buffer)
+	if result := string(peekRange(a, buffer, 3)); result != "app" {
+		t.Errorf("Unexpected result '%s' reading from a position", result)
+	}
+}
@@ -0,0 +1,151 @@
+package izapple2
+
+import (
+	"fmt"
+)
+
+/*
+Sound samples for the frontends.
+
+The frontends pull the samples from the audio callbacks. SpeakerMixer
+converts the speaker clicks to a square wave at the sampling rate and
+mixes it with the sounds of the disk drives. The levels are from -1 to 1,
+the frontends convert them to the format of the audio library.
+*/
+
+const (
+	speakerMixerBufferSize   = 1000
+	speakerMixerOutOfSyncMs  = 2000
+	speakerMixerSilenceLevel = 0.0 // Mid position to avoid clicks when starting
+)
+
+// SpeakerMixer builds the sound samples from the speaker clicks and the drive sounds
+type SpeakerMixer struct {
+	clickChannel         chan uint64
+	pendingClicks        []uint64
+	lastCycle            uint64
+	lastState            bool
+	lastLevel            float32
+	sampleDurationCycles float64
+	driveSounds          *DriveSounds
+}
+
+// NewSpeakerMixer returns a mixer for the sampling rate provided
+func NewSpeakerMixer(samplingHz int) *SpeakerMixer {
+	var s SpeakerMixer
+	s.clickChannel = make(chan uint64, speakerMixerBufferSize)
+	s.pendingClicks = make([]uint64, 0, speakerMixerBufferSize)
+	s.lastLevel = speakerMixerSilenceLevel
+	// Each sample is 21.31 cpu cycles approx at 48Khz
+	s.sampleDurationCycles = 1000000 * CPUClockMhz / float64(samplingHz)
+	s.driveSounds = NewDriveSounds(samplingHz)
+	return &s
+}
+
+// Click receives a speaker click. The argument is the CPU cycle when it is generated
+func (s *SpeakerMixer) Click(cycle uint64) {
+	select {
+	case s.clickChannel <- cycle:
+		// Sent
+	default:
+		fmt.Printf("Speaker click dropped in channel.\n")
+		// The channel is full, the click is lost.
+	}
+}
+
+// DriveSounds returns the drive sounds mixed, to be set as the DriveSoundsProvider
+func (s *SpeakerMixer) DriveSounds() *DriveSounds {
+	return s.driveSounds
+}
+
+func speakerStateToLevel(state bool) float32 {
+	if state {
+		return 1.0
+	}
+	return -1.0
+}
+
+// FillSamples completes the buffer with the next samples, from -1 to 1
+func (s *SpeakerMixer) FillSamples(buf []float32) {
+	//Read queued clicks
+	done := false
+	for !done {
+		select {
+		case cycle := <-s.clickChannel:
+			s.pendingClicks = append(s.pendingClicks, cycle)
+		default:
+			done = true
+		}
+	}
+
+	// Verify that we are not too long behind
+	maxOutOfSyncCyclesFloat := 1000 * CPUClockMhz * speakerMixerOutOfSyncMs
+	maxOutOfSyncCycles := uint64(maxOutOfSyncCyclesFloat)
+	for _, pc := range s.pendingClicks {
+		if pc-s.lastCycle > maxOutOfSyncCycles {
+			// Fast forward
+			s.lastCycle = pc
+			fmt.Printf("Speaker fast forward.\n")
+		}
+	}
+
+	// Build wave
+	samples := len(buf)
+	var i, r int
+	level := s.lastLevel
+	for p := 0; p < len(s.pendingClicks); p++ {
+		cycle := s.pendingClicks[p]
+		if cycle < s.lastCycle {
+			// Too old, ignore
+			continue
+		}
+
+		// Fill with samples
+		level = speakerStateToLevel(s.lastState)
+		samplesNeeded := int(float64(cycle-s.lastCycle) / s.sampleDurationCycles)
+		if samplesNeeded+i > samples {
+			// Partial fill, to be completed on the next callback
+			samplesNeeded = samples - i
+			s.lastCycle = cycle - uint64(float64(samplesNeeded)*s.sampleDurationCycles)
+		} else {
+			s.lastCycle = cycle
+			s.lastState = !s.lastState
+			r++ // Remove this pending click
+		}
+
+		for j := 0; j < samplesNeeded; j++ {
+			buf[i] = level
+			i++
+		}
+
+		if i == samples {
+			// Buffer is complete
+			break
+		}
+	}
+
+	// If the buffer is empty lets stop the signal
+	if i == 0 {
+		level = speakerMixerSilenceLevel
+	}
+
+	// Complete the buffer if needed
+	for b := i; b < samples; b++ {
+		buf[b] = level
+	}
+	s.lastLevel = level
+
+	// Remove processed clicks, store the rest for later
+	s.pendingClicks = s.pendingClicks[r:]
+
+	// Mix the disk drives sounds
+	for b := range buf {
+		mixed := buf[b] + s.driveSounds.NextSample()
+		if mixed < -1 {
+			mixed = -1
+		} else if mixed > 1 {
+			mixed = 1
+		}
+		buf[b] = mixed
+	}
+}
@@ -0,0 +1,40 @@
+package izapple2
+
+import (
+	"testing"
+)
+
+func TestSpeakerMixerSquareWave(t *testing.T) {
+	s := NewSpeakerMixer(48000)
+	period := uint64(s.sampleDurationCycles * 10)
+	for i := uint64(1); i <= 10; i++ {
+		s.Click(i * period)
+	}
+
+	buf := make([]float32, 200)
+	s.FillSamples(buf)
+
+	changes := 0
+	for i := 1; i < len(buf); i++ {
+		if buf[i] != buf[i-1] {
+			changes++
+		}
+		if buf[i] < -1 || buf[i] > 1 {
+			t.Fatalf("sample %v out of range: %v", i, buf[i])
+		}
+	}
+	if changes < 8 {
+		t.Errorf("expected a square wave, got %v level changes", changes)
+	}
+}
+
+func TestSpeakerMixerSilence(t *testing.T) {
+	s := NewSpeakerMixer(48000)
+	buf := make([]float32, 100)
+	s.FillSamples(buf)
+	for i, v := range buf {
+		if v != speakerMixerSilenceLevel {
+			t.Fatalf("sample %v is %v with no clicks", i, v)
+		}
+	}
+}
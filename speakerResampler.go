@@ -0,0 +1,69 @@
+package izapple2
+
+/*
+Speaker sound resampled from the emulated cycles. SpeakerResampler
+converts the speaker clicks to samples at a fixed rate following the
+cycles of the emulation, not the real time. It is used by the video
+recordings and the streams, the samples are accurate at any emulation
+speed. A filter removes the DC component of the speaker square wave.
+*/
+
+const (
+	speakerResamplerAmplitude = 8000
+	speakerResamplerDCFilter  = 0.995 // To remove the DC component of the speaker square wave
+)
+
+// SpeakerResampler builds the samples at a fixed rate from the speaker clicks
+type SpeakerResampler struct {
+	cyclesPerSample float64
+	nextSample      float64 // Cycle of the next sample
+	clicks          []uint64
+	speakerState    bool
+	lastInput       float64
+	lastOutput      float64
+}
+
+// NewSpeakerResampler returns a resampler with the first sample on the cycle provided
+func NewSpeakerResampler(clockMhz float64, sampleRate int, cycle uint64) *SpeakerResampler {
+	var r SpeakerResampler
+	r.cyclesPerSample = clockMhz * 1_000_000 / float64(sampleRate)
+	r.nextSample = float64(cycle)
+	r.lastInput = -1 // The speaker starts off
+	return &r
+}
+
+// Click adds a speaker click. The argument is the CPU cycle when it is generated
+func (r *SpeakerResampler) Click(cycle uint64) {
+	r.clicks = append(r.clicks, cycle)
+}
+
+// Resync moves the next sample to the cycle if it is more than maxDelayMs away,
+// after a pause or when running at full speed
+func (r *SpeakerResampler) Resync(cycle uint64, sampleRate int, maxDelayMs int) {
+	maxDelayCycles := r.cyclesPerSample * float64(sampleRate*maxDelayMs) / 1000
+	if r.nextSample+maxDelayCycles < float64(cycle) || r.nextSample > float64(cycle)+maxDelayCycles {
+		r.nextSample = float64(cycle)
+	}
+}
+
+// AppendSamples adds to the slice the samples up to a cycle
+func (r *SpeakerResampler) AppendSamples(samples []int16, untilCycle uint64) []int16 {
+	for r.nextSample < float64(untilCycle) {
+		for len(r.clicks) > 0 && float64(r.clicks[0]) <= r.nextSample {
+			r.speakerState = !r.speakerState
+			r.clicks = r.clicks[1:]
+		}
+
+		input := -1.0
+		if r.speakerState {
+			input = 1.0
+		}
+		output := input - r.lastInput + speakerResamplerDCFilter*r.lastOutput
+		r.lastInput = input
+		r.lastOutput = output
+
+		samples = append(samples, int16(output*speakerResamplerAmplitude))
+		r.nextSample += r.cyclesPerSample
+	}
+	return samples
+}
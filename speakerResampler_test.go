@@ -0,0 +1,32 @@
+package izapple2
+
+import "testing"
+
+func TestSpeakerResamplerRemovesDC(t *testing.T) {
+	r := NewSpeakerResampler(1, 1000, 0) // A sample every 1000 cycles
+
+	// Speaker on after the first sample, the level decays to zero
+	r.Click(500)
+	samples := r.AppendSamples(nil, 1_000_000)
+	if len(samples) != 1000 {
+		t.Fatalf("Unexpected %v samples", len(samples))
+	}
+	if samples[0] != 0 || samples[1] != 2*speakerResamplerAmplitude {
+		t.Errorf("Unexpected first samples %v", samples[:2])
+	}
+	if samples[999] < 0 || samples[999] > speakerResamplerAmplitude/10 {
+		t.Errorf("The DC component was not removed, last sample is %v", samples[999])
+	}
+}
+
+func TestSpeakerResamplerResync(t *testing.T) {
+	r := NewSpeakerResampler(1, 1000, 0)
+	r.Resync(100_000, 1000, 500) // 100ms behind, kept
+	if samples := r.AppendSamples(nil, 100_000); len(samples) != 100 {
+		t.Errorf("Unexpected %v samples", len(samples))
+	}
+	r.Resync(10_000_000, 1000, 500) // Seconds behind, skipped
+	if samples := r.AppendSamples(nil, 10_000_000); len(samples) != 0 {
+		t.Errorf("Unexpected %v samples after the resync", len(samples))
+	}
+}
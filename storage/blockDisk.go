@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math"
 	"os"
 )
 
@@ -16,6 +17,8 @@ const (
 	// ProDosBlockSize is the size of the blocks on the ProDOS devices
 	ProDosBlockSize = uint32(512)
 	proDosMaxBlocks = uint32(65536)
+	// CdromSectorSize is the size of the sectors on the CD-ROM images
+	CdromSectorSize = uint32(2048)
 )
 
 // BlockDisk is any block device with 512 bytes blocks
@@ -62,7 +65,39 @@ func NewBlockDiskFile(file *os.File, readOnly bool) (BlockDisk, error) {
 	return &bd, nil
 }
 
+// NewBlockDiskCdrom creates a read only block device for a CD-ROM image.
+// The size is not limited to the size of a ProDOS volume.
+func NewBlockDiskCdrom(file *os.File) (BlockDisk, error) {
+	var bd blockDiskFile
+	bd.file = file
+	bd.readOnly = true
+
+	fileInfo, err := bd.file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	size := fileInfo.Size()
+	if size == 0 || size%int64(CdromSectorSize) != 0 || size > math.MaxUint32 {
+		return nil, fmt.Errorf("invalid size for a CD-ROM image")
+	}
+	bd.blocks = uint32(size / int64(ProDosBlockSize))
+	return &bd, nil
+}
+
 func NewBlockDiskMemory(data []uint8) (BlockDisk, error) {
+	if isFileWoz(data) || isFileA2r(data) {
+		// The sectors of 3.5 disks bitstreams are decoded as blocks
+		f, err := MakeFileWoz(data)
+		if err != nil {
+			return nil, err
+		}
+		data, err = f.disk35DecodeBlocks()
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	var bd blockDiskMemory
 	bd.data = data
 	bd.readOnly = true
@@ -9,13 +9,14 @@ type Diskette interface {
 	PowerOn(cycle uint64)
 	PowerOff(cycle uint64)
 	Read(quarterTrack int, cycle uint64) uint8
+	SenseWriteProtect(quarterTrack int, cycle uint64) uint8
 	Write(quarterTrack int, value uint8, cycle uint64)
 	Is13Sectors() bool
 }
 
 // IsDiskette returns true if the files looks like a 5 1/4 diskette
 func IsDiskette(data []byte) bool {
-	return isFileNib(data) || isFileDsk(data) || isFileWoz(data)
+	return isFileNib(data) || isFileDsk(data) || isFileWoz(data) || isFileA2r(data)
 }
 
 // MakeDiskette returns a Diskette by detecting the format
@@ -46,5 +47,14 @@ func MakeDiskette(data []byte, filename string, writeable bool) (Diskette, error
 		return newDisquetteWoz(f)
 	}
 
+	if isFileA2r(data) {
+		f, err := NewFileA2r(data)
+		if err != nil {
+			return nil, err
+		}
+
+		return newDisquetteWoz(f)
+	}
+
 	return nil, errors.New("diskette format not supported")
 }
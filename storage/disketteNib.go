@@ -26,6 +26,10 @@ func (d *disketteNib) Read(quarterTrack int, cycle uint64) uint8 {
 	return value
 }
 
+func (d *disketteNib) SenseWriteProtect(_ int, _ uint64) uint8 {
+	return 0 // Never write protected
+}
+
 func (d *disketteNib) Write(quarterTrack int, value uint8, _ uint64) {
 	track := quarterTrack / 4
 	d.nib.track[track][d.position] = value
@@ -33,6 +37,5 @@ func (d *disketteNib) Write(quarterTrack int, value uint8, _ uint64) {
 }
 
 func (d *disketteNib) Is13Sectors() bool {
-	// It may be 13 sectors but we don't know
-	return false
+	return d.nib.is13Sectors()
 }
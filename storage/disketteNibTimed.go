@@ -36,6 +36,10 @@ func (d *disketteNibTimed) Read(quarterTrack int, cycle uint64) uint8 {
 	return value
 }
 
+func (d *disketteNibTimed) SenseWriteProtect(_ int, _ uint64) uint8 {
+	return 0 // Never write protected
+}
+
 func (d *disketteNibTimed) Write(quarterTrack int, value uint8, _ uint64) {
 	panic("Write not implemented on time based disk implementation")
 }
@@ -29,6 +29,10 @@ func (d *disketteNibWritable) Read(quarterTrack int, cycle uint64) uint8 {
 	return value
 }
 
+func (d *disketteNibWritable) SenseWriteProtect(_ int, _ uint64) uint8 {
+	return 0 // Never write protected
+}
+
 func (d *disketteNibWritable) Write(quarterTrack int, value uint8, _ uint64) {
 	track := quarterTrack / 4
 
@@ -51,6 +55,5 @@ func (d *disketteNibWritable) commit() {
 }
 
 func (d *disketteNibWritable) Is13Sectors() bool {
-	// It amy be 13 sectors but we don't know
-	return false
+	return d.nib.is13Sectors()
 }
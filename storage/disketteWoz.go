@@ -8,22 +8,68 @@ import (
 /*
 See:
 	https://applesaucefdc.com/woz/
+
+To read the bits the data register of the Disk II card is updated as the
+Logic State Sequencer does in read mode. Only the read program of the
+sequencer is needed, it is taken from the P6 ROM 341-0028. It is indexed
+by the state, and then by bit 7 of the register and the read pulse:
+	[state][high0 no pulse, high0 pulse, high1 no pulse, high1 pulse]
+The high nibble of each entry is the next state, the low nibble is the
+operation on the register:
+	0-7: clear
+	8, A, C, E: nothing
+	9: shift left bringing a zero
+	D: shift left bringing a one
+
+While the write protection is sensed, the sequencer stays on state 0 and
+shifts the register right bringing the write protect bit. Copy protections
+use it to get out of sync with the nibbles on the disk.
 */
 
+const (
+	// The MC3470 outputs random bits after too many zeros. The WOZ reference
+	// suggests ones 30% of the time, as the sequencer Disk II does. Copy
+	// protections like the one of Congo Bongo don't load with fewer ones.
+	wozFakeBitOnesPercent = 30
+
+	wozStepPs         = 488_895 // The sequencer clock is the 14.318 Mhz clock divided by 7, 2 steps per CPU cycle
+	wozMaxCatchUpStep = 20_000  // Steps of the sequencer to emulate, older bits are skipped
+)
+
+var lssReadProgram = [16][4]uint8{
+	{0x18, 0x18, 0x18, 0x18},
+	{0x2d, 0x2d, 0x38, 0x38},
+	{0x38, 0xd8, 0x28, 0x08},
+	{0x48, 0xd8, 0x48, 0x48},
+	{0x58, 0xd8, 0x58, 0xd8},
+	{0x68, 0xd8, 0x68, 0xd8},
+	{0x78, 0xd8, 0x78, 0xd8},
+	{0x88, 0xd8, 0x88, 0xd8},
+	{0x98, 0xd8, 0x98, 0xd8},
+	{0x29, 0xd8, 0xa8, 0xd8},
+	{0xbd, 0xcd, 0xb8, 0xd8},
+	{0x59, 0xd9, 0xc8, 0xd8},
+	{0xd9, 0xd9, 0xa0, 0xd8},
+	{0x08, 0xd8, 0xe8, 0xe8},
+	{0xfd, 0xfd, 0xf8, 0xf8},
+	{0x4d, 0xdd, 0xe0, 0xe0},
+}
+
 type disketteWoz struct {
 	data    *FileWoz
 	cycleOn uint64 // Cycle when the disk was last turned on
 	turning bool
 
-	latch       uint8
 	position    uint32
 	positionMax uint32 // As tracks may have different lengths position is related of positionMax of the las track
 	cycle       uint64
+	elapsedPs   uint64 // Time since the last bit, in picoseconds
 
 	mc3470Buffer uint8 // Four bit buffer to detect weak bits and to add latency
 
-	visibleLatch          uint8
-	visibleLatchCountDown int8 // The visible latch stores a valid latch reading for 2 bit timings
+	register uint8 // Data register of the Disk II card
+	sequence uint8 // State of the logic state sequencer
+	sensing  bool  // The controller is sensing the write protection
 }
 
 func newDisquetteWoz(f *FileWoz) (*disketteWoz, error) {
@@ -47,49 +93,82 @@ func (d *disketteWoz) PowerOff(_ uint64) {
 }
 
 func (d *disketteWoz) Read(quarterTrack int, cycle uint64) uint8 {
-	// Count cycles to know how many bits have been read
-	cycles := cycle - d.cycle
-	deltaBits := cycles / cyclesPerBit // TODO: Use Woz optimal bit timing
-
-	// Process bits from woz
-	// TODO: avoid processing too many bits if delta is big
-	for i := uint64(0); i < deltaBits; i++ {
-		// Get next bit taking into account the MC3470 latency and weak bits
-		var fluxBit bool
-		fluxBit, d.position, d.positionMax = d.data.GetNextBitAndPosition(d.position, d.positionMax, quarterTrack)
-		d.mc3470Buffer = (d.mc3470Buffer << 1) & 0x0f
-		if fluxBit {
-			d.mc3470Buffer++
-		}
-		bit := (d.mc3470Buffer >> 1) & 0x1 // Use the previous to last bit to add latency
-		if d.mc3470Buffer == 0 && rand.Intn(100) < 3 {
-			// Four consecutive zeros. It'a a fake bit.
-			// Output a random value. 70% zero, 30% one
-			bit = 1
-		}
+	d.catchUp(quarterTrack, cycle)
+	d.sensing = false
+	return d.register
+}
+
+func (d *disketteWoz) SenseWriteProtect(quarterTrack int, cycle uint64) uint8 {
+	d.catchUp(quarterTrack, cycle)
+	d.sensing = true
+	return d.register
+}
 
-		d.latch = (d.latch << 1) + bit
-		if d.latch >= 0x80 {
-			// Valid byte, store value a bit longer and clear the internal latch
-			// fmt.Printf("Valid 0x%.2x\n", d.latch)
-			d.visibleLatch = d.latch
-			d.visibleLatchCountDown = 1
-			d.latch = 0
-		} else if d.visibleLatchCountDown > 0 {
-			// Continue showing the valid byte
-			d.visibleLatchCountDown--
-		} else {
-			// The valid byte is lost, show the internal latch
-			d.visibleLatch = d.latch
+// catchUp runs the sequencer up to the cycle provided
+func (d *disketteWoz) catchUp(quarterTrack int, cycle uint64) {
+	bitTimingPs := d.data.TrackBitTimingPs(quarterTrack)
+	steps := (cycle - d.cycle) * 2
+	d.cycle = cycle
+
+	if steps > wozMaxCatchUpStep {
+		// Too long since the last access, the bits passed but there is no
+		// need to run the sequencer for them.
+		d.elapsedPs += (steps - wozMaxCatchUpStep) * wozStepPs
+		for d.elapsedPs >= bitTimingPs {
+			d.nextBit(quarterTrack)
+			d.elapsedPs -= bitTimingPs
 		}
+		steps = wozMaxCatchUpStep
 	}
 
-	// fmt.Printf("Visible: 0x%.2x, latch: 0x%.2x, bits: %v, cycles: %v\n", d.visibleLatch, d.latch, deltaBits, cycle-d.cycle)
+	for i := uint64(0); i < steps; i++ {
+		pulse := false
+		d.elapsedPs += wozStepPs
+		if d.elapsedPs >= bitTimingPs {
+			pulse = d.nextBit(quarterTrack)
+			d.elapsedPs -= bitTimingPs
+		}
+
+		if d.sensing {
+			// Shift right bringing the write protect bit, never protected
+			d.sequence = 0
+			d.register >>= 1
+			continue
+		}
 
-	// Update the internal last cycle without losing the remainder not processed
-	d.cycle += deltaBits * cyclesPerBit
+		column := 0
+		if d.register >= 0x80 {
+			column = 2
+		}
+		if pulse {
+			column++
+		}
+		command := lssReadProgram[d.sequence][column]
+		d.sequence = command >> 4
+		operation := command & 0xf
+		if operation < 8 {
+			d.register = 0
+		} else if operation&0x3 == 1 {
+			d.register = (d.register << 1) | ((operation >> 2) & 1)
+		}
+	}
+}
 
-	return d.visibleLatch
+// nextBit gets next bit taking into account the MC3470 latency and weak bits
+func (d *disketteWoz) nextBit(quarterTrack int) bool {
+	var fluxBit bool
+	fluxBit, d.position, d.positionMax = d.data.GetNextBitAndPosition(d.position, d.positionMax, quarterTrack)
+	d.mc3470Buffer = (d.mc3470Buffer << 1) & 0x0f
+	if fluxBit {
+		d.mc3470Buffer++
+	}
+	bit := ((d.mc3470Buffer >> 1) & 0x1) != 0 // Use the previous to last bit to add latency
+	if d.mc3470Buffer == 0 && rand.Intn(100) < wozFakeBitOnesPercent {
+		// Four consecutive zeros. It'a a fake bit.
+		// Output a random value. 70% zero, 30% one
+		bit = true
+	}
+	return bit
 }
 
 func (d *disketteWoz) Write(quarterTrack int, value uint8, _ uint64) {
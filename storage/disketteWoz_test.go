@@ -0,0 +1,31 @@
+package storage
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWozFakeBits(t *testing.T) {
+	var f FileWoz
+	f.Info.DiskType = 1
+	f.trackMap = bytes.Repeat([]uint8{0xff}, wozMaxTrack)
+	f.trackMap[0] = 0
+	f.tracks[0].bitCount = 50_000 // No flux transitions
+	f.tracks[0].data = make([]uint8, 50_000/8)
+	d, err := newDisquetteWoz(&f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ones := 0
+	const bits = 100_000
+	for i := 0; i < bits; i++ {
+		if d.nextBit(0) {
+			ones++
+		}
+	}
+	percent := ones * 100 / bits
+	if percent < wozFakeBitOnesPercent-3 || percent > wozFakeBitOnesPercent+3 {
+		t.Errorf("Expected %v%% of fake ones, got %v%%", wozFakeBitOnesPercent, percent)
+	}
+}
@@ -0,0 +1,453 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"strings"
+)
+
+/*
+See:
+	https://applesaucefdc.com/a2r2-reference/
+	https://applesaucefdc.com/a2r/
+
+The A2R files store the raw flux transitions captured by the Applesauce.
+The timings are converted to a WOZ like bitstream with one bitstream per
+revolution captured. The revolutions are played one after the other to
+have the weak bits behave as on the real hardware.
+*/
+
+const (
+	a2rFirstChunkPos  = 8
+	a2rChunkHeaderLen = 8
+
+	a2r2TickNs         = 125         // Resolution of the A2R2 timings
+	a2rBitCellNs       = 4000        // 4 microseconds per bit on 5.25 disks
+	a2rRevolutionNs    = 200_000_000 // 200 ms per revolution at 300 rpm
+	a2rMaxRevolutions  = 16          // Avoid using too much memory with long captures
+	a2rLoopSearchRange = 30          // Look for the loop point on a +-3% window
+	a2rLoopCompareBits = 512         // Bits compared to validate a loop point
+	a2rLocationEnd     = 0xff        // End of the A2R2 STRM chunk
+	a2rCaptureMark     = uint8('C')
+	a2rEndMark         = uint8('X')
+
+	a2rCaptureTiming  = 1
+	a2rCaptureBits    = 2
+	a2rCaptureXTiming = 3
+)
+
+var headerA2r2 = []uint8{0x41, 0x32, 0x52, 0x32, 0xFF, 0x0A, 0x0D, 0x0A}
+var headerA2r3 = []uint8{0x41, 0x32, 0x52, 0x33, 0xFF, 0x0A, 0x0D, 0x0A}
+
+type a2rInfo struct {
+	Version        uint8
+	Creator        [32]byte
+	DriveType      uint8
+	WriteProtected uint8
+	Synchronized   uint8
+}
+
+type a2r2StreamHeader struct {
+	Location    uint8
+	CaptureType uint8
+	DataLength  uint32
+	LoopPoint   uint32
+}
+
+type a2r3CaptureHeader struct {
+	CaptureType uint8
+	Location    uint16
+	IndexCount  uint8
+}
+
+// a2rCapture is a flux capture with the timings already in nanoseconds
+type a2rCapture struct {
+	captureType uint8
+	location    int
+	fluxNs      []uint64 // Time of each flux transition since the start of the capture
+	indexNs     []uint64 // Time of each index pulse
+	loopNs      uint64   // A2R2 loop point, length of the first revolution from the start of the capture
+}
+
+func isFileA2r(data []uint8) bool {
+	if len(data) < len(headerA2r2) {
+		return false
+	}
+	header := data[:len(headerA2r2)]
+	return bytes.Equal(headerA2r2, header) || bytes.Equal(headerA2r3, header)
+}
+
+// MakeFileWoz returns a FileWoz by detecting the format, WOZ or A2R
+func MakeFileWoz(data []uint8) (*FileWoz, error) {
+	if isFileA2r(data) {
+		return NewFileA2r(data)
+	}
+	return NewFileWoz(data)
+}
+
+// NewFileA2r builds a WOZ like representation of an A2R flux capture
+func NewFileA2r(data []uint8) (*FileWoz, error) {
+	if !isFileA2r(data) {
+		return nil, errors.New("invalid A2R header")
+	}
+	version := 2
+	if bytes.Equal(headerA2r3, data[:len(headerA2r3)]) {
+		version = 3
+	}
+
+	// Extract the chunks
+	i := a2rFirstChunkPos
+	var chunkHeader wozChunkHeader
+	chunks := make(map[string][]uint8)
+	for i+a2rChunkHeaderLen <= len(data) {
+		binary.Read(bytes.NewReader(data[i:]), binary.LittleEndian, &chunkHeader)
+
+		i += a2rChunkHeaderLen
+		iNext := i + int(chunkHeader.Size)
+		if iNext > len(data) {
+			return nil, errors.New("invalid chunk in A2R file")
+		}
+
+		id := string(chunkHeader.ID[:])
+		if _, ok := chunks[id]; !ok {
+			chunks[id] = data[i:iNext]
+		}
+		i = iNext
+	}
+
+	var f FileWoz
+
+	// Read the INFO chunk
+	infoData, ok := chunks["INFO"]
+	if !ok {
+		return nil, errors.New("chunk INFO missing from A2R file")
+	}
+	var info a2rInfo
+	binary.Read(bytes.NewReader(infoData), binary.LittleEndian, &info)
+	switch info.DriveType {
+	case 1, 3, 4:
+		f.Info.DiskType = 1 // 5.25
+	case 2:
+		f.Info.DiskType = 2 // 3.5
+	default:
+		return nil, errors.New("only 5.25 and 3.5 A2R captures are supported")
+	}
+	f.Info.WriteProtected = info.WriteProtected
+	f.Info.Synchronized = info.Synchronized
+	f.Info.Creator = info.Creator
+	f.Info.OptimalBitTiming = a2rBitCellNs / a2r2TickNs
+	if f.Is35() {
+		f.Info.OptimalBitTiming = disk35BitCellNs / a2r2TickNs
+		f.Info.DiskSides = 2
+	}
+
+	// Read the optional META chunk
+	metaData, ok := chunks["META"]
+	if ok {
+		f.meta = make(map[string]string)
+		entries := strings.Split(string(metaData), "\n")
+		for _, entry := range entries {
+			parts := strings.Split(entry, "\t")
+			if len(parts) >= 2 {
+				f.meta[parts[0]] = parts[1]
+			}
+		}
+	}
+
+	// Read the captures
+	var captures []*a2rCapture
+	var err error
+	if version == 2 {
+		strmData, ok := chunks["STRM"]
+		if !ok {
+			return nil, errors.New("chunk STRM missing from A2R file")
+		}
+		captures, err = a2r2ReadStream(strmData)
+	} else {
+		rwcpData, ok := chunks["RWCP"]
+		if !ok {
+			return nil, errors.New("chunk RWCP missing from A2R file")
+		}
+		captures, err = a2r3ReadCaptures(rwcpData)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Choose a capture per location, xtiming captures have more than one revolution
+	selected := make(map[int]*a2rCapture)
+	for _, c := range captures {
+		if c.location < 0 || c.location >= wozMaxTrack || len(c.fluxNs) == 0 {
+			continue
+		}
+		previous, ok := selected[c.location]
+		if !ok || (previous.captureType != a2rCaptureXTiming && c.captureType == a2rCaptureXTiming) {
+			selected[c.location] = c
+		}
+	}
+	if len(selected) == 0 {
+		return nil, errors.New("no usable flux captures in A2R file")
+	}
+
+	// Build the tracks
+	f.trackMap = make([]uint8, wozMaxTrack)
+	for i := range f.trackMap {
+		f.trackMap[i] = 0xff
+	}
+	trackIndex := 0
+	for location := 0; location < wozMaxTrack; location++ {
+		c, ok := selected[location]
+		if !ok {
+			continue
+		}
+		revolutionNs := uint64(a2rRevolutionNs)
+		if f.Is35() {
+			// The locations are (track << 1) | side, the speed depends on the zone
+			revolutionNs = Disk35RevolutionNs(location >> 1)
+		}
+		revolutions := c.revolutions(f.BitTimingNs(), revolutionNs)
+		if len(revolutions) == 0 {
+			continue
+		}
+		f.tracks[trackIndex] = revolutions[0]
+		if len(revolutions) > 1 {
+			f.revolutions[trackIndex] = revolutions
+		}
+		f.trackMap[location] = uint8(trackIndex)
+		trackIndex++
+	}
+
+	// Like on WOZ files, the adjacent quarter tracks see the captured track
+	for location := 0; location < wozMaxTrack && !f.Is35(); location++ {
+		if _, ok := selected[location]; !ok {
+			continue
+		}
+		for _, adjacent := range []int{location - 1, location + 1} {
+			if adjacent >= 0 && adjacent < wozMaxTrack && f.trackMap[adjacent] == 0xff {
+				if _, captured := selected[adjacent]; !captured {
+					f.trackMap[adjacent] = f.trackMap[location]
+				}
+			}
+		}
+	}
+
+	return &f, nil
+}
+
+func a2r2ReadStream(data []uint8) ([]*a2rCapture, error) {
+	var captures []*a2rCapture
+	reader := bytes.NewReader(data)
+	for reader.Len() > 0 {
+		if data[len(data)-reader.Len()] == a2rLocationEnd {
+			break
+		}
+		var header a2r2StreamHeader
+		err := binary.Read(reader, binary.LittleEndian, &header)
+		if err != nil {
+			return nil, errors.New("invalid STRM chunk in A2R file")
+		}
+
+		start := len(data) - reader.Len()
+		end := start + int(header.DataLength)
+		if end > len(data) {
+			return nil, errors.New("invalid STRM chunk in A2R file")
+		}
+		reader.Seek(int64(header.DataLength), 1)
+
+		if header.CaptureType == a2rCaptureBits {
+			continue
+		}
+		c := &a2rCapture{
+			captureType: header.CaptureType,
+			location:    int(header.Location),
+			fluxNs:      a2rDecodeTimings(data[start:end], a2r2TickNs*1000),
+		}
+		c.loopNs = uint64(header.LoopPoint) * a2r2TickNs
+		captures = append(captures, c)
+	}
+	return captures, nil
+}
+
+func a2r3ReadCaptures(data []uint8) ([]*a2rCapture, error) {
+	const headerSize = 16 // version, resolution and 11 reserved bytes
+	if len(data) < headerSize {
+		return nil, errors.New("invalid RWCP chunk in A2R file")
+	}
+	resolutionPs := uint64(binary.LittleEndian.Uint32(data[1:5]))
+	if resolutionPs == 0 {
+		return nil, errors.New("invalid resolution on A2R file")
+	}
+
+	var captures []*a2rCapture
+	reader := bytes.NewReader(data[headerSize:])
+	for {
+		mark, err := reader.ReadByte()
+		if err != nil || mark == a2rEndMark {
+			break
+		}
+		if mark != a2rCaptureMark {
+			return nil, errors.New("invalid capture in A2R file")
+		}
+
+		var header a2r3CaptureHeader
+		err = binary.Read(reader, binary.LittleEndian, &header)
+		if err != nil {
+			return nil, errors.New("invalid capture in A2R file")
+		}
+		index := make([]uint32, header.IndexCount)
+		err = binary.Read(reader, binary.LittleEndian, index)
+		if err != nil {
+			return nil, errors.New("invalid capture in A2R file")
+		}
+		var size uint32
+		err = binary.Read(reader, binary.LittleEndian, &size)
+		if err != nil || int(size) > reader.Len() {
+			return nil, errors.New("invalid capture in A2R file")
+		}
+		timings := make([]uint8, size)
+		reader.Read(timings)
+
+		if header.CaptureType == a2rCaptureBits {
+			continue
+		}
+		c := &a2rCapture{
+			captureType: header.CaptureType,
+			location:    int(header.Location),
+			fluxNs:      a2rDecodeTimings(timings, resolutionPs),
+		}
+		for _, ticks := range index {
+			c.indexNs = append(c.indexNs, uint64(ticks)*resolutionPs/1000)
+		}
+		captures = append(captures, c)
+	}
+	return captures, nil
+}
+
+// a2rDecodeTimings returns the absolute time of each flux transition.
+// Each byte is the ticks since the previous transition, 255 means that
+// the next byte has to be added to the interval.
+func a2rDecodeTimings(data []uint8, resolutionPs uint64) []uint64 {
+	flux := make([]uint64, 0, len(data))
+	ticks := uint64(0)
+	for _, value := range data {
+		ticks += uint64(value)
+		if value != 0xff {
+			flux = append(flux, ticks*resolutionPs/1000)
+		}
+	}
+	return flux
+}
+
+// fluxToBits converts the flux transitions times to bits as seen by the
+// drive with one bit every bitCellNs. It returns the bit position for
+// each of the times in marksNs.
+func fluxToBits(fluxNs []uint64, marksNs []uint64, bitCellNs uint64) ([]bool, []int) {
+	bits := make([]bool, 0, len(fluxNs)*2)
+	marks := make([]int, 0, len(marksNs))
+	previous := uint64(0)
+	for _, t := range fluxNs {
+		for len(marks) < len(marksNs) && marksNs[len(marks)] < t {
+			marks = append(marks, len(bits))
+		}
+
+		cells := int((t - previous + bitCellNs/2) / bitCellNs)
+		if cells == 0 {
+			// Too short, it is merged with the previous transition
+			continue
+		}
+		for i := 1; i < cells; i++ {
+			bits = append(bits, false)
+		}
+		bits = append(bits, true)
+		previous = t
+	}
+	return bits, marks
+}
+
+// findLoop estimates the length of a revolution looking for the best match
+// of the start of the capture around the nominal length.
+func findLoop(bits []bool, nominal int) int {
+	window := nominal * a2rLoopSearchRange / 1000
+	best := 0
+	bestScore := -1
+	for length := nominal - window; length <= nominal+window; length++ {
+		if length <= 0 || length+a2rLoopCompareBits > len(bits) {
+			continue
+		}
+		score := 0
+		for i := 0; i < a2rLoopCompareBits; i++ {
+			if bits[i] == bits[length+i] {
+				score++
+			}
+		}
+		if score > bestScore {
+			best = length
+			bestScore = score
+		}
+	}
+	return best
+}
+
+// revolutions splits the capture on WOZ like tracks, one per revolution
+func (c *a2rCapture) revolutions(bitCellNs uint64, revolutionNs uint64) []disketteTrackWoz {
+	marksNs := c.indexNs
+	if c.loopNs != 0 {
+		// The A2R2 captures start with a revolution, the loop point is its end
+		marksNs = []uint64{c.loopNs}
+	}
+	bits, marks := fluxToBits(c.fluxNs, marksNs, bitCellNs)
+
+	// Get the start of each revolution
+	var starts []int
+	switch {
+	case c.loopNs != 0 && len(marks) == 1:
+		starts = splitRevolutions(0, marks[0], len(bits))
+	case c.loopNs == 0 && len(marks) > 1:
+		// Index pulses delimit the revolutions
+		starts = marks
+	default:
+		start := 0
+		if c.loopNs == 0 && len(marks) == 1 {
+			// The only index pulse is the start of a revolution, not its length
+			start = marks[0]
+		}
+		length := findLoop(bits[start:], int(revolutionNs/bitCellNs))
+		if length == 0 {
+			// No loop found, use the full capture
+			length = len(bits) - start
+		}
+		starts = splitRevolutions(start, length, len(bits))
+	}
+
+	var tracks []disketteTrackWoz
+	for i := 0; i+1 < len(starts) && len(tracks) < a2rMaxRevolutions; i++ {
+		tracks = append(tracks, packBits(bits[starts[i]:starts[i+1]]))
+	}
+	return tracks
+}
+
+// splitRevolutions returns the starts of the complete revolutions of a
+// length and the end of the last one
+func splitRevolutions(start int, length int, total int) []int {
+	if length == 0 {
+		return nil
+	}
+	var starts []int
+	for ; start+length <= total; start += length {
+		starts = append(starts, start)
+	}
+	return append(starts, start)
+}
+
+func packBits(bits []bool) disketteTrackWoz {
+	var track disketteTrackWoz
+	track.bitCount = uint32(len(bits))
+	track.data = make([]uint8, (len(bits)+7)/8)
+	for i, bit := range bits {
+		if bit {
+			track.data[i/8] |= 1 << (7 - i%8)
+		}
+	}
+	return track
+}
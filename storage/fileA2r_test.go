@@ -0,0 +1,218 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math/rand"
+	"testing"
+)
+
+func buildTestA2rRevolution(seed int) []bool {
+	bits := make([]bool, 0, 2000)
+	for i := 0; len(bits) < 2000; i++ {
+		// Ones separated by one or two zeros, ending with a one
+		bits = append(bits, false)
+		if (i*7+seed)%3 == 0 {
+			bits = append(bits, false)
+		}
+		bits = append(bits, true)
+	}
+	return bits
+}
+
+// buildTestRandomRevolution has ones separated by one or two zeros at random
+func buildTestRandomRevolution(seed int64, length int) []bool {
+	r := rand.New(rand.NewSource(seed))
+	bits := make([]bool, 0, length)
+	for len(bits) < length {
+		bits = append(bits, false)
+		if r.Intn(2) == 0 {
+			bits = append(bits, false)
+		}
+		bits = append(bits, true)
+	}
+	return bits
+}
+
+func buildTestA2r3(revolutions [][]bool) []uint8 {
+	var bits []bool
+	var index []int
+	for _, revolution := range revolutions {
+		index = append(index, len(bits))
+		bits = append(bits, revolution...)
+	}
+	return buildTestA2r3Capture(bits, index)
+}
+
+// buildTestA2r3Capture builds a capture with the index pulses at the bit positions
+func buildTestA2r3Capture(bits []bool, indexBits []int) []uint8 {
+	var timings []uint8
+	index := make([]uint32, len(indexBits))
+	for i, position := range indexBits {
+		index[i] = uint32(position * 32)
+	}
+	cells := 0
+	for _, bit := range bits {
+		cells++
+		if bit {
+			timings = append(timings, uint8(cells*32))
+			cells = 0
+		}
+	}
+
+	var rwcp bytes.Buffer
+	rwcp.WriteByte(1)                                        // Version
+	binary.Write(&rwcp, binary.LittleEndian, uint32(125000)) // 125ns resolution
+	rwcp.Write(make([]uint8, 11))
+	rwcp.WriteByte(a2rCaptureMark)
+	binary.Write(&rwcp, binary.LittleEndian, a2r3CaptureHeader{
+		CaptureType: a2rCaptureXTiming,
+		Location:    0,
+		IndexCount:  uint8(len(index)),
+	})
+	binary.Write(&rwcp, binary.LittleEndian, index)
+	binary.Write(&rwcp, binary.LittleEndian, uint32(len(timings)))
+	rwcp.Write(timings)
+	rwcp.WriteByte(a2rEndMark)
+
+	var info bytes.Buffer
+	binary.Write(&info, binary.LittleEndian, a2rInfo{Version: 1, DriveType: 1})
+	info.WriteByte(0) // Hard sector count
+
+	var data bytes.Buffer
+	data.Write(headerA2r3)
+	for _, chunk := range []struct {
+		id   string
+		data []uint8
+	}{{"INFO", info.Bytes()}, {"RWCP", rwcp.Bytes()}} {
+		data.WriteString(chunk.id)
+		binary.Write(&data, binary.LittleEndian, uint32(len(chunk.data)))
+		data.Write(chunk.data)
+	}
+	return data.Bytes()
+}
+
+func TestA2rRevolutions(t *testing.T) {
+	revolutions := [][]bool{
+		buildTestA2rRevolution(0),
+		buildTestA2rRevolution(1),
+		buildTestA2rRevolution(2),
+	}
+
+	data := buildTestA2r3(revolutions)
+	if !IsDiskette(data) {
+		t.Fatal("A2R file not detected")
+	}
+	f, err := MakeFileWoz(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The index pulse after the last revolution is missing, only two are complete
+	expected := append(append([]bool{}, revolutions[0]...), revolutions[1]...)
+	expected = append(expected, revolutions[0]...)
+	position := uint32(0)
+	positionMax := uint32(0)
+	for i, want := range expected {
+		var bit bool
+		bit, position, positionMax = f.GetNextBitAndPosition(position, positionMax, 1)
+		if bit != want {
+			t.Fatalf("Mismatch on bit %v at position %v", i, position)
+		}
+	}
+}
+
+func checkA2rTrack(t *testing.T, data []uint8, expected []bool) {
+	f, err := MakeFileWoz(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.tracks[0].bitCount != uint32(len(expected)) {
+		t.Fatalf("Expected a track of %v bits, got %v", len(expected), f.tracks[0].bitCount)
+	}
+	position := uint32(0)
+	positionMax := uint32(0)
+	for i, want := range expected {
+		var bit bool
+		bit, position, positionMax = f.GetNextBitAndPosition(position, positionMax, 1)
+		if bit != want {
+			t.Fatalf("Mismatch on bit %v at position %v", i, position)
+		}
+	}
+}
+
+func TestA2rSingleIndex(t *testing.T) {
+	// Some bits before the index pulse, then two and a half revolutions
+	revolution := buildTestRandomRevolution(1, 50_400)
+	bits := buildTestRandomRevolution(2, 1_000)
+	start := len(bits)
+	bits = append(bits, revolution...)
+	bits = append(bits, revolution...)
+	bits = append(bits, revolution[:len(revolution)/2]...)
+
+	checkA2rTrack(t, buildTestA2r3Capture(bits, []int{start}), revolution)
+}
+
+func TestA2rNoIndex(t *testing.T) {
+	// Two and a half revolutions without index pulses
+	revolution := buildTestRandomRevolution(3, 49_200)
+	var bits []bool
+	bits = append(bits, revolution...)
+	bits = append(bits, revolution...)
+	bits = append(bits, revolution[:len(revolution)/2]...)
+
+	checkA2rTrack(t, buildTestA2r3Capture(bits, nil), revolution)
+}
+
+// buildTestA2r2Capture builds an A2R2 timing capture with the loop point at a bit position
+func buildTestA2r2Capture(bits []bool, loopBits int) []uint8 {
+	var timings []uint8
+	cells := 0
+	for _, bit := range bits {
+		cells++
+		if bit {
+			timings = append(timings, uint8(cells*32)) // 32 ticks of 125ns per bit
+			cells = 0
+		}
+	}
+
+	var strm bytes.Buffer
+	binary.Write(&strm, binary.LittleEndian, a2r2StreamHeader{
+		Location:    0,
+		CaptureType: a2rCaptureXTiming,
+		DataLength:  uint32(len(timings)),
+		LoopPoint:   uint32(loopBits * 32),
+	})
+	strm.Write(timings)
+	strm.WriteByte(a2rLocationEnd)
+
+	var info bytes.Buffer
+	binary.Write(&info, binary.LittleEndian, a2rInfo{Version: 1, DriveType: 1})
+
+	var data bytes.Buffer
+	data.Write(headerA2r2)
+	for _, chunk := range []struct {
+		id   string
+		data []uint8
+	}{{"INFO", info.Bytes()}, {"STRM", strm.Bytes()}} {
+		data.WriteString(chunk.id)
+		binary.Write(&data, binary.LittleEndian, uint32(len(chunk.data)))
+		data.Write(chunk.data)
+	}
+	return data.Bytes()
+}
+
+func TestA2r2LoopPoint(t *testing.T) {
+	// Two and a half revolutions, shorter than the nominal 200 ms
+	revolution := buildTestRandomRevolution(4, 47_000)
+	var bits []bool
+	bits = append(bits, revolution...)
+	bits = append(bits, revolution...)
+	bits = append(bits, revolution[:len(revolution)/2]...)
+
+	data := buildTestA2r2Capture(bits, len(revolution))
+	if !IsDiskette(data) {
+		t.Fatal("A2R2 file not detected")
+	}
+	checkA2rTrack(t, data, revolution)
+}
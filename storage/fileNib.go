@@ -198,6 +198,8 @@ const (
 	diskPrologByte2        = uint8(0xaa)
 	diskPrologByte3Address = uint8(0x96)
 	diskPrologByte3Data    = uint8(0xad)
+
+	diskPrologByte3Address13Sectors = uint8(0xb5) // Used by DOS 3.2 and earlier
 )
 
 func nibEncodeTrack(data []byte, volume byte, track byte, logicalOrder *[16]int) []byte {
@@ -275,6 +277,12 @@ func findProlog(diskPrologByte3 uint8, data []byte, position int) int {
 	return -1
 }
 
+// is13Sectors detects 13 sectors disks looking for the DOS 3.2 address prolog on track 0
+func (f *fileNib) is13Sectors() bool {
+	return findProlog(diskPrologByte3Address13Sectors, f.track[0], 0) != -1 &&
+		findProlog(diskPrologByte3Address, f.track[0], 0) == -1
+}
+
 func nibDecodeTrack(data []byte, logicalOrder *[16]int) ([]byte, error) {
 	b := make([]byte, bytesPerTrack) // Buffer slice with enough capacity
 
@@ -5,6 +5,7 @@ import (
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"hash/crc32"
 	"strings"
 )
 
@@ -19,6 +20,10 @@ type FileWoz struct {
 	trackMap []uint8
 	tracks   [wozMaxTrack]disketteTrackWoz
 	meta     map[string]string
+
+	// Flux captures can have several revolutions per track, they are used in sequence
+	revolutions [wozMaxTrack][]disketteTrackWoz
+	revolution  [wozMaxTrack]int
 }
 
 type disketteTrackWoz struct {
@@ -49,6 +54,8 @@ type woz2Info struct {
 	CompatibleHardware uint16
 	RequiredRAM        uint16
 	LargestTrack       uint16
+	FluxBlock          uint16 // WOZ 2.1
+	LargestFluxTrack   uint16 // WOZ 2.1
 }
 
 type woz1TrackFooter struct {
@@ -75,6 +82,11 @@ const (
 	woz2TrackBlockSize    = 512
 	woz2FirstTrackBlock   = 3 // The bits on the TRKS block start on 3*512
 	woz2TrackBitsOffset   = 1280
+	woz2InfoSize          = 60
+	wozDefaultBitTiming   = 32 // 4 microseconds in 125 nanoseconds units
+	wozTickNs             = 125
+	wozRevolutionPs       = 200_000_000_000 // 300 rpm
+	wozMinRevolutionPs    = 190_000_000_000 // 5% faster
 )
 
 var headerWoz1 = []uint8{0x57, 0x4f, 0x5A, 0x31, 0xFF, 0x0A, 0x0D, 0x0A}
@@ -95,6 +107,11 @@ func (f *FileWoz) GetNextBitAndPosition(position uint32, positionMax uint32, qua
 		// TODO: return random value
 		return false, position, positionMax
 	}
+	if position == 0 && len(f.revolutions[trackIndex]) > 1 {
+		// Next revolution
+		f.revolution[trackIndex] = (f.revolution[trackIndex] + 1) % len(f.revolutions[trackIndex])
+		f.tracks[trackIndex] = f.revolutions[trackIndex][f.revolution[trackIndex]]
+	}
 	trackWoz := f.tracks[trackIndex]
 
 	if trackWoz.bitCount != positionMax {
@@ -127,7 +144,46 @@ func (f *FileWoz) SetBit(value bool, position uint32, positionMax uint32, quarte
 	fmt.Printf("Saving %v at %v\n", value, position)
 }
 
+// BitTiming returns the time used by each bit in units of 125 nanoseconds
+func (f *FileWoz) BitTiming() uint8 {
+	if f.Info.OptimalBitTiming == 0 {
+		return wozDefaultBitTiming
+	}
+	return f.Info.OptimalBitTiming
+}
+
+// BitTimingNs returns the time used by each bit in nanoseconds
+func (f *FileWoz) BitTimingNs() uint64 {
+	return uint64(f.BitTiming()) * wozTickNs
+}
+
+/*
+TrackBitTimingPs returns the time used by each bit of the track in picoseconds.
+The optimal bit timing of the INFO chunk is used when present. The WOZ 1 files
+don't have it, for those the bits of a track too short for 4 microseconds are
+spread on a revolution of the disk at 300 rpm.
+*/
+func (f *FileWoz) TrackBitTimingPs(quarterTrack int) uint64 {
+	bitTimingPs := f.BitTimingNs() * 1000
+	if f.Info.OptimalBitTiming != 0 || f.Info.DiskType != 1 ||
+		quarterTrack < 0 || quarterTrack >= len(f.trackMap) {
+		return bitTimingPs
+	}
+	trackIndex := f.trackMap[quarterTrack]
+	if trackIndex == 0xff || f.tracks[trackIndex].bitCount == 0 {
+		return bitTimingPs
+	}
+	bitCount := uint64(f.tracks[trackIndex].bitCount)
+	if bitCount*bitTimingPs < wozMinRevolutionPs {
+		return wozRevolutionPs / bitCount
+	}
+	return bitTimingPs
+}
+
 func isFileWoz(data []uint8) bool {
+	if len(data) < len(headerWoz2) {
+		return false
+	}
 	header := data[:len(headerWoz2)]
 	if bytes.Equal(headerWoz1, header) {
 		return true
@@ -203,7 +259,7 @@ func NewFileWoz(data []uint8) (*FileWoz, error) {
 	if !ok {
 		return nil, errors.New("chunk TMAP missing from WOZ file")
 	}
-	f.trackMap = trackMap
+	f.trackMap = append([]uint8(nil), trackMap...)
 
 	// Read the TRKS chunk
 	tracksData, ok := chunks["TRKS"]
@@ -222,17 +278,44 @@ func NewFileWoz(data []uint8) (*FileWoz, error) {
 			track++
 		}
 	} else if f.version == 2 {
+		// Read the optional FLUX chunk of WOZ 2.1
+		fluxMap, hasFlux := chunks["FLUX"]
+		isFluxTrack := make(map[uint8]bool)
+		if hasFlux && f.Info.Version >= 3 {
+			for i, track := range fluxMap {
+				if i < len(f.trackMap) && track != 0xff {
+					// The flux tracks have priority over the bitstream tracks
+					f.trackMap[i] = track
+					isFluxTrack[track] = true
+				}
+			}
+		}
+
 		reader := bytes.NewReader(tracksData)
 		for i := 0; i < wozMaxTrack; i++ {
 			var trackHeader woz2TrackHeader
 			binary.Read(reader, binary.LittleEndian, &trackHeader)
 			if trackHeader.BitCount != 0 {
-				f.tracks[i].bitCount = trackHeader.BitCount
-
 				dataPos := woz2TrackBlockSize*(int(trackHeader.StartingBlock)-woz2FirstTrackBlock) + woz2TrackBitsOffset
 				dataSize := woz2TrackBlockSize * int(trackHeader.BlockCount)
 				// fmt.Printf("@%v %v:%v (%v) of %v\n", trackHeader.StartingBlock, dataPos, dataPos+dataSize, dataSize, len(tracksData))
-				f.tracks[i].data = tracksData[dataPos : dataPos+dataSize]
+				if dataPos < 0 || dataPos+dataSize > len(tracksData) {
+					return nil, errors.New("invalid track in WOZ file")
+				}
+				data := tracksData[dataPos : dataPos+dataSize]
+
+				if isFluxTrack[uint8(i)] {
+					// For flux tracks, BitCount is the number of bytes with timings
+					if int(trackHeader.BitCount) > len(data) {
+						return nil, errors.New("invalid flux track in WOZ file")
+					}
+					fluxNs := a2rDecodeTimings(data[:trackHeader.BitCount], wozTickNs*1000)
+					bits, _ := fluxToBits(fluxNs, nil, f.BitTimingNs())
+					f.tracks[i] = packBits(bits)
+				} else {
+					f.tracks[i].bitCount = trackHeader.BitCount
+					f.tracks[i].data = data
+				}
 			}
 		}
 	} else {
@@ -242,6 +325,60 @@ func NewFileWoz(data []uint8) (*FileWoz, error) {
 	return &f, nil
 }
 
+// ToWoz2 returns the bitstreams as a WOZ 2 file, the flux tracks are
+// saved as the bitstreams decoded from them
+func (f *FileWoz) ToWoz2() []uint8 {
+	info := f.Info
+	info.Version = 2
+	info.FluxBlock = 0
+	info.LargestFluxTrack = 0
+
+	trackHeaders := make([]woz2TrackHeader, wozMaxTrack)
+	var tracksData bytes.Buffer
+	block := uint16(woz2FirstTrackBlock)
+	for i := range f.tracks {
+		track := f.tracks[i]
+		if track.bitCount == 0 {
+			continue
+		}
+		blockCount := (int(track.bitCount+7)/8 + woz2TrackBlockSize - 1) / woz2TrackBlockSize
+		data := make([]uint8, blockCount*woz2TrackBlockSize)
+		copy(data, track.data[:(track.bitCount+7)/8])
+		tracksData.Write(data)
+
+		trackHeaders[i] = woz2TrackHeader{block, uint16(blockCount), track.bitCount}
+		block += uint16(blockCount)
+		if uint16(blockCount) > info.LargestTrack {
+			info.LargestTrack = uint16(blockCount)
+		}
+	}
+
+	var chunks bytes.Buffer
+	writeChunk := func(id string, data []uint8) {
+		chunks.WriteString(id)
+		binary.Write(&chunks, binary.LittleEndian, uint32(len(data)))
+		chunks.Write(data)
+	}
+
+	var infoData bytes.Buffer
+	binary.Write(&infoData, binary.LittleEndian, info)
+	infoData.Write(make([]uint8, woz2InfoSize-infoData.Len()))
+	writeChunk("INFO", infoData.Bytes())
+
+	trackMap := make([]uint8, wozMaxTrack)
+	copy(trackMap, f.trackMap)
+	writeChunk("TMAP", trackMap)
+
+	var trks bytes.Buffer
+	binary.Write(&trks, binary.LittleEndian, trackHeaders)
+	trks.Write(tracksData.Bytes())
+	writeChunk("TRKS", trks.Bytes())
+
+	data := append([]uint8(nil), headerWoz2...)
+	data = binary.LittleEndian.AppendUint32(data, crc32.ChecksumIEEE(chunks.Bytes()))
+	return append(data, chunks.Bytes()...)
+}
+
 func (f *FileWoz) DumpTrackAsWoz(quarterTrack int) []uint8 {
 	trackWoz := f.tracks[f.trackMap[quarterTrack]]
 	return trackWoz.data
@@ -279,6 +416,10 @@ func (f *FileWoz) Dump() {
 		fmt.Printf("  Required RAM: %vKB\n", f.Info.RequiredRAM)
 		fmt.Printf("  Largest track: %v blocks\n", f.Info.LargestTrack)
 	}
+	if f.Info.Version >= 3 {
+		fmt.Printf("  Flux block: %v\n", f.Info.FluxBlock)
+		fmt.Printf("  Largest flux track: %v blocks\n", f.Info.LargestFluxTrack)
+	}
 	if f.meta != nil {
 		fmt.Printf("  Metadata:\n")
 		for k, v := range f.meta {
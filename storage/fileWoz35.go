@@ -0,0 +1,417 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+/*
+3.5 disks for the Apple 3.5 and UniDisk 3.5 drives.
+
+See:
+	https://applesaucefdc.com/woz/reference2/
+	"Inside Macintosh, volume III", chapter 2 "The Disk Driver"
+	https://github.com/TomHarte/CLK/wiki/Apple-GCR-disk-encoding
+
+The disks have 80 tracks per side, grouped in 5 zones of 16 tracks. The
+drive spins faster on the inner zones to keep the same 2 microseconds bit
+cell and the tracks have fewer sectors as they get shorter:
+	Zone  Tracks  Sectors  RPM
+	0     0-15    12       394
+	1     16-31   11       429
+	2     32-47   10       472
+	3     48-63   9        525
+	4     64-79   8        590
+
+On the WOZ files the TMAP entry for a track is (track << 1) | side.
+
+Each sector has 524 bytes, 12 tag bytes and 512 data bytes. The address
+field has the track, sector, side and format 6 and 2 encoded:
+	D5 AA 96 track sector side format checksum DE AA
+The data field has the sector followed by 699 nibbles for the 524 bytes
+and four nibbles with a 3 bytes checksum:
+	D5 AA AD sector data(699) checksum(4) DE AA
+The data bytes are grouped in three and scrambled with a running checksum.
+*/
+
+const (
+	woz35DiskType          = 2
+	disk35Tracks           = 80
+	disk35TracksPerZone    = 16
+	disk35BlocksPerSide    = 800
+	disk35BitCellNs        = 2000
+	disk35TagSize          = 12
+	disk35SectorSize       = disk35TagSize + int(ProDosBlockSize)
+	disk35DataNibbles      = 699
+	disk35FormatInterleave = 0x02 // 2:1 interleave
+	disk35FormatTwoSides   = 0x20 // Double sided
+	disk35AddressProlog3   = uint8(0x96)
+	disk35DataProlog3      = uint8(0xad)
+	disk35SectorGapSync    = 6
+	disk35SyncBits         = 10
+	disk35WriteProtection  = 1
+)
+
+var disk35ZoneSectors = [5]int{12, 11, 10, 9, 8}
+var disk35ZoneRPM = [5]uint64{394, 429, 472, 525, 590}
+
+// Disk35SectorsPerTrack returns the number of sectors for a track of a 3.5 disk
+func Disk35SectorsPerTrack(track int) int {
+	return disk35ZoneSectors[track/disk35TracksPerZone]
+}
+
+// Disk35RevolutionNs returns the time for a full revolution on a track of a 3.5 disk
+func Disk35RevolutionNs(track int) uint64 {
+	return 60 * 1000 * 1000 * 1000 / disk35ZoneRPM[track/disk35TracksPerZone]
+}
+
+// Is35 returns true for 3.5 disks
+func (f *FileWoz) Is35() bool {
+	return f.Info.DiskType == woz35DiskType
+}
+
+// Sides returns the number of sides of the disk
+func (f *FileWoz) Sides() int {
+	if f.Info.DiskSides == 2 {
+		return 2
+	}
+	return 1
+}
+
+// MakeFileWoz35 returns a 3.5 disk bitstream from a WOZ, A2R or a 400Kb or
+// 800Kb block image.
+func MakeFileWoz35(data []uint8) (*FileWoz, error) {
+	if isFileWoz(data) || isFileA2r(data) {
+		f, err := MakeFileWoz(data)
+		if err != nil {
+			return nil, err
+		}
+		if !f.Is35() {
+			return nil, errors.New("only 3.5 disks are supported")
+		}
+		return f, nil
+	}
+
+	bd, err := NewBlockDiskMemory(data)
+	if err != nil {
+		return nil, err
+	}
+	return newFileWoz35FromBlocks(bd)
+}
+
+func newFileWoz35FromBlocks(bd BlockDisk) (*FileWoz, error) {
+	blocks := bd.GetSizeInBlocks()
+	if blocks != disk35BlocksPerSide && blocks != 2*disk35BlocksPerSide {
+		return nil, fmt.Errorf("invalid size for a 3.5 disk: %v blocks", blocks)
+	}
+	sides := int(blocks / disk35BlocksPerSide)
+
+	var f FileWoz
+	f.version = 2
+	f.Info.Version = 2
+	f.Info.DiskType = woz35DiskType
+	f.Info.WriteProtected = disk35WriteProtection
+	f.Info.DiskSides = uint8(sides)
+	f.Info.OptimalBitTiming = disk35BitCellNs / wozTickNs
+	f.trackMap = make([]uint8, wozMaxTrack)
+	for i := range f.trackMap {
+		f.trackMap[i] = 0xff
+	}
+
+	block := uint32(0)
+	for track := 0; track < disk35Tracks; track++ {
+		for side := 0; side < sides; side++ {
+			sectors := make([][]uint8, Disk35SectorsPerTrack(track))
+			for i := range sectors {
+				data, err := bd.Read(block)
+				if err != nil {
+					return nil, err
+				}
+				sectors[i] = make([]uint8, disk35SectorSize)
+				copy(sectors[i][disk35TagSize:], data)
+				block++
+			}
+
+			index := track<<1 | side
+			f.tracks[index] = disk35EncodeTrack(track, side, sides, sectors)
+			f.trackMap[index] = uint8(index)
+		}
+	}
+
+	return &f, nil
+}
+
+// disk35EncodeTrack builds the bitstream for a track with 2:1 interleave
+func disk35EncodeTrack(track int, side int, sides int, sectors [][]uint8) disketteTrackWoz {
+	var bits []bool
+	appendSync := func(count int) {
+		for i := 0; i < count; i++ {
+			for j := 0; j < disk35SyncBits; j++ {
+				bits = append(bits, j < 8) // 0xff followed by two zeros
+			}
+		}
+	}
+	appendNibbles := func(nibbles ...uint8) {
+		for _, nibble := range nibbles {
+			for j := 7; j >= 0; j-- {
+				bits = append(bits, (nibble>>j)&1 == 1)
+			}
+		}
+	}
+
+	count := len(sectors)
+	sideByte := uint8(side<<5) | uint8(track>>6)
+	format := uint8(disk35FormatInterleave)
+	if sides == 2 {
+		format |= disk35FormatTwoSides
+	}
+	for physical := 0; physical < count; physical++ {
+		sector := physical/2 + (physical%2)*((count+1)/2)
+
+		appendSync(disk35SectorGapSync)
+		address := []uint8{uint8(track & 0x3f), uint8(sector), sideByte, format}
+		checksum := uint8(0)
+		appendNibbles(diskPrologByte1, diskPrologByte2, disk35AddressProlog3)
+		for _, v := range address {
+			appendNibbles(sixAndTwoTranslateTable[v])
+			checksum ^= v
+		}
+		appendNibbles(sixAndTwoTranslateTable[checksum&0x3f], 0xde, 0xaa)
+
+		appendSync(disk35SectorGapSync)
+		appendNibbles(diskPrologByte1, diskPrologByte2, disk35DataProlog3)
+		appendNibbles(sixAndTwoTranslateTable[sector])
+		for _, v := range disk35EncodeData(sectors[sector]) {
+			appendNibbles(sixAndTwoTranslateTable[v])
+		}
+		appendNibbles(0xde, 0xaa)
+	}
+
+	// Fill the rest of the track with sync bytes
+	length := int(Disk35RevolutionNs(track) / disk35BitCellNs)
+	for len(bits)+disk35SyncBits <= length {
+		appendSync(1)
+	}
+	return packBits(bits)
+}
+
+// disk35EncodeData returns the 6 bits values of the 524 bytes sector
+// followed by the checksum
+func disk35EncodeData(data []uint8) []uint8 {
+	var chk0, chk1, chk2 uint16
+	values := make([]uint8, 0, disk35DataNibbles+4)
+	for i := 0; i < len(data); i += 3 {
+		chk0 = (chk0 & 0xff) << 1
+		if chk0&0x100 != 0 {
+			chk0++
+		}
+
+		a := data[i]
+		chk2 += uint16(a)
+		if chk0&0x100 != 0 {
+			chk2++
+			chk0 &= 0xff
+		}
+		a ^= uint8(chk0)
+
+		b := data[i+1]
+		chk1 += uint16(b)
+		if chk2 > 0xff {
+			chk1++
+			chk2 &= 0xff
+		}
+		b ^= uint8(chk2)
+
+		if i+2 == len(data) {
+			values = append(values, (a&0xc0)>>2|(b&0xc0)>>4, a&0x3f, b&0x3f)
+			break
+		}
+
+		c := data[i+2]
+		chk0 += uint16(c)
+		if chk1 > 0xff {
+			chk0++
+			chk1 &= 0xff
+		}
+		c ^= uint8(chk1)
+
+		values = append(values, (a&0xc0)>>2|(b&0xc0)>>4|(c&0xc0)>>6, a&0x3f, b&0x3f, c&0x3f)
+	}
+
+	c0, c1, c2 := uint8(chk0), uint8(chk1), uint8(chk2)
+	values = append(values, (c0&0xc0)>>6|(c1&0xc0)>>4|(c2&0xc0)>>2, c2&0x3f, c1&0x3f, c0&0x3f)
+	return values
+}
+
+// disk35DecodeData gets the 524 bytes of a sector from the 6 bits values
+func disk35DecodeData(values []uint8) ([]uint8, error) {
+	if len(values) != disk35DataNibbles+4 {
+		return nil, errors.New("invalid length for a 3.5 sector")
+	}
+
+	var chk0, chk1, chk2 uint16
+	data := make([]uint8, 0, disk35SectorSize)
+	i := 0
+	for len(data) < disk35SectorSize {
+		twos := values[i]
+		a := values[i+1] | (twos<<2)&0xc0
+		b := values[i+2] | (twos<<4)&0xc0
+		i += 3
+
+		chk0 = (chk0 & 0xff) << 1
+		if chk0&0x100 != 0 {
+			chk0++
+		}
+
+		a ^= uint8(chk0)
+		chk2 += uint16(a)
+		if chk0&0x100 != 0 {
+			chk2++
+			chk0 &= 0xff
+		}
+
+		b ^= uint8(chk2)
+		chk1 += uint16(b)
+		if chk2 > 0xff {
+			chk1++
+			chk2 &= 0xff
+		}
+
+		data = append(data, a, b)
+		if len(data) == disk35SectorSize {
+			break
+		}
+
+		c := values[i] | (twos<<6)&0xc0
+		i++
+		c ^= uint8(chk1)
+		chk0 += uint16(c)
+		if chk1 > 0xff {
+			chk0++
+			chk1 &= 0xff
+		}
+		data = append(data, c)
+	}
+
+	twos := values[i]
+	if values[i+3]|(twos<<6)&0xc0 != uint8(chk0) ||
+		values[i+2]|(twos<<4)&0xc0 != uint8(chk1) ||
+		values[i+1]|(twos<<2)&0xc0 != uint8(chk2) {
+		return nil, errors.New("checksum error on 3.5 sector")
+	}
+	return data, nil
+}
+
+// disk35Nibbles reads the nibbles of a track. The track is read twice to
+// get the sectors crossing the end of the track.
+func (t *disketteTrackWoz) disk35Nibbles() []uint8 {
+	out := make([]uint8, 0, t.bitCount/4)
+	latch := uint8(0)
+	for i := uint32(0); i < 2*t.bitCount; i++ {
+		position := i % t.bitCount
+		bit := t.data[position/8] >> (7 - position%8) & 1
+		latch = (latch << 1) + bit
+		if latch >= 0x80 {
+			out = append(out, latch)
+			latch = 0
+		}
+	}
+	return out
+}
+
+func disk35Untranslate(nibbles []uint8) ([]uint8, error) {
+	values := make([]uint8, len(nibbles))
+	for i, nibble := range nibbles {
+		v := sixAndTwoUntranslateTable[nibble]
+		if v == -1 {
+			return nil, errors.New("invalid nibble on 3.5 sector")
+		}
+		values[i] = uint8(v)
+	}
+	return values, nil
+}
+
+// disk35FindProlog returns the position after the prolog. Unlike findProlog,
+// it does not wrap to the start, the nibbles already cover two revolutions.
+func disk35FindProlog(prologByte3 uint8, nibbles []uint8, position int) int {
+	if position >= len(nibbles) {
+		return -1
+	}
+	i := bytes.Index(nibbles[position:], []uint8{diskPrologByte1, diskPrologByte2, prologByte3})
+	if i == -1 {
+		return -1
+	}
+	return position + i + 3
+}
+
+// disk35DecodeTrack gets the 512 bytes of data of the sectors of a track
+func (t *disketteTrackWoz) disk35DecodeTrack(track int, side int) ([][]uint8, error) {
+	sectors := make([][]uint8, Disk35SectorsPerTrack(track))
+	nibbles := t.disk35Nibbles()
+	l := len(nibbles)
+	i := 0
+	for {
+		i = disk35FindProlog(disk35AddressProlog3, nibbles, i)
+		if i == -1 || i+5 > l {
+			break
+		}
+		address, err := disk35Untranslate(nibbles[i : i+5])
+		if err != nil {
+			continue
+		}
+		sector := int(address[1])
+		if address[0]^address[1]^address[2]^address[3] != address[4] ||
+			int(address[0]) != track&0x3f || int(address[2]>>5) != side ||
+			sector >= len(sectors) || sectors[sector] != nil {
+			continue
+		}
+
+		j := disk35FindProlog(disk35DataProlog3, nibbles, i+5)
+		if j == -1 || j+1+disk35DataNibbles+4 > l {
+			break
+		}
+		values, err := disk35Untranslate(nibbles[j+1 : j+1+disk35DataNibbles+4])
+		if err != nil {
+			return nil, err
+		}
+		data, err := disk35DecodeData(values)
+		if err != nil {
+			return nil, err
+		}
+		sectors[sector] = data[disk35TagSize:]
+	}
+
+	for sector, data := range sectors {
+		if data == nil {
+			return nil, fmt.Errorf("sector %v not found on track %v side %v", sector, track, side)
+		}
+	}
+	return sectors, nil
+}
+
+// disk35DecodeBlocks returns the ProDOS blocks of a 3.5 disk
+func (f *FileWoz) disk35DecodeBlocks() ([]uint8, error) {
+	if !f.Is35() {
+		return nil, errors.New("only 3.5 disks can be used as block devices")
+	}
+
+	sides := f.Sides()
+	data := make([]uint8, 0, sides*disk35BlocksPerSide*int(ProDosBlockSize))
+	for track := 0; track < disk35Tracks; track++ {
+		for side := 0; side < sides; side++ {
+			trackIndex := f.trackMap[track<<1|side]
+			if trackIndex == 0xff {
+				return nil, fmt.Errorf("track %v side %v missing", track, side)
+			}
+			sectors, err := f.tracks[trackIndex].disk35DecodeTrack(track, side)
+			if err != nil {
+				return nil, err
+			}
+			for _, sector := range sectors {
+				data = append(data, sector...)
+			}
+		}
+	}
+	return data, nil
+}
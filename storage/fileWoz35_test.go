@@ -0,0 +1,117 @@
+package storage
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestDisk35DataBackAndForth(t *testing.T) {
+	data := make([]uint8, disk35SectorSize)
+	for i := range data {
+		data[i] = uint8(i*7 + i/3)
+	}
+
+	values := disk35EncodeData(data)
+	data2, err := disk35DecodeData(values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range data {
+		if data[i] != data2[i] {
+			t.Errorf("Mismatch in %v: %02x -> %02x", i, data[i], data2[i])
+		}
+	}
+
+	values[100] ^= 0x01
+	_, err = disk35DecodeData(values)
+	if err == nil {
+		t.Error("Checksum error expected")
+	}
+}
+
+func TestDisk35BlocksBackAndForth(t *testing.T) {
+	data := make([]uint8, 2*disk35BlocksPerSide*ProDosBlockSize)
+	for i := range data {
+		data[i] = uint8(i/int(ProDosBlockSize) + i)
+	}
+
+	f, err := MakeFileWoz35(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !f.Is35() || f.Sides() != 2 || f.BitTimingNs() != disk35BitCellNs {
+		t.Fatalf("Unexpected 3.5 disk info: %+v", f.Info)
+	}
+
+	// The outer tracks are longer
+	if f.tracks[0].bitCount <= f.tracks[79<<1].bitCount {
+		t.Errorf("Track 0 with %v bits should be longer than track 79 with %v bits",
+			f.tracks[0].bitCount, f.tracks[79<<1].bitCount)
+	}
+
+	data2, err := f.disk35DecodeBlocks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data2) != len(data) {
+		t.Fatalf("Expected %v bytes, got %v", len(data), len(data2))
+	}
+	for i := range data {
+		if data[i] != data2[i] {
+			t.Fatalf("Mismatch in block %v byte %v", i/int(ProDosBlockSize), i%int(ProDosBlockSize))
+		}
+	}
+}
+
+func TestDisk35PrologOnTrackEnd(t *testing.T) {
+	// An address prolog crossing the end of the track
+	nibbles := []uint8{disk35AddressProlog3, 0xff, 0xff, 0xff, diskPrologByte1, diskPrologByte2}
+	track := disketteTrackWoz{bitCount: uint32(len(nibbles) * 8)}
+	track.data = nibbles
+
+	done := make(chan error)
+	go func() {
+		_, err := track.disk35DecodeTrack(0, 0)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("Missing sectors error expected")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("The decoding of the track does not end")
+	}
+}
+
+func TestDisk35ToWoz2(t *testing.T) {
+	data := make([]uint8, disk35BlocksPerSide*ProDosBlockSize)
+	for i := range data {
+		data[i] = uint8(i/int(ProDosBlockSize) ^ i)
+	}
+	f, err := MakeFileWoz35(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	woz := f.ToWoz2()
+	if !IsDiskette(woz) {
+		t.Fatal("WOZ file not detected")
+	}
+	f2, err := MakeFileWoz35(woz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f2.Sides() != 1 || f2.BitTimingNs() != disk35BitCellNs {
+		t.Fatalf("Unexpected 3.5 disk info: %+v", f2.Info)
+	}
+
+	data2, err := f2.disk35DecodeBlocks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, data2) {
+		t.Error("The blocks changed on the WOZ file")
+	}
+}
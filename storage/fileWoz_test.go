@@ -0,0 +1,110 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func TestWozFluxTrack(t *testing.T) {
+	// Bits to encode with a 3.5 microseconds bit timing
+	bits := []bool{true, true, false, true, false, false, true, true, true, false, true}
+	var flux []uint8
+	cells := 0
+	for _, bit := range bits {
+		cells++
+		if bit {
+			flux = append(flux, uint8(cells*28))
+			cells = 0
+		}
+	}
+
+	var info woz2Info
+	info.Version = 3
+	info.DiskType = 1
+	info.OptimalBitTiming = 28
+	info.FluxBlock = 3
+	var infoData bytes.Buffer
+	binary.Write(&infoData, binary.LittleEndian, info)
+	infoData.Write(make([]uint8, 60-infoData.Len()))
+
+	tmap := bytes.Repeat([]uint8{0xff}, wozMaxTrack)
+	fluxMap := bytes.Repeat([]uint8{0xff}, wozMaxTrack)
+	fluxMap[0] = 0
+
+	var trks bytes.Buffer
+	binary.Write(&trks, binary.LittleEndian, woz2TrackHeader{
+		StartingBlock: woz2FirstTrackBlock,
+		BlockCount:    1,
+		BitCount:      uint32(len(flux)),
+	})
+	trks.Write(make([]uint8, woz2TrackBitsOffset-trks.Len()))
+	trks.Write(flux)
+	trks.Write(make([]uint8, woz2TrackBlockSize-len(flux)))
+
+	var data bytes.Buffer
+	data.Write(headerWoz2)
+	data.Write([]uint8{0, 0, 0, 0}) // CRC
+	for _, chunk := range []struct {
+		id   string
+		data []uint8
+	}{{"INFO", infoData.Bytes()}, {"TMAP", tmap}, {"TRKS", trks.Bytes()}, {"FLUX", fluxMap}} {
+		data.WriteString(chunk.id)
+		binary.Write(&data, binary.LittleEndian, uint32(len(chunk.data)))
+		data.Write(chunk.data)
+	}
+
+	f, err := NewFileWoz(data.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.BitTiming() != 28 {
+		t.Errorf("Bit timing expected 28, got %v", f.BitTiming())
+	}
+
+	position := uint32(0)
+	positionMax := uint32(0)
+	for i, want := range bits {
+		var bit bool
+		bit, position, positionMax = f.GetNextBitAndPosition(position, positionMax, 0)
+		if bit != want {
+			t.Errorf("Mismatch on bit %v", i)
+		}
+	}
+	if positionMax != uint32(len(bits)) {
+		t.Errorf("Track length expected %v, got %v", len(bits), positionMax)
+	}
+}
+
+func TestWozTrackBitTiming(t *testing.T) {
+	var f FileWoz
+	f.Info.DiskType = 1
+	f.trackMap = bytes.Repeat([]uint8{0xff}, wozMaxTrack)
+	f.trackMap[0] = 0
+	f.trackMap[4] = 1
+	f.tracks[0].bitCount = 50_000 // A 300 rpm revolution at 4 microseconds
+	f.tracks[1].bitCount = 40_000 // Too short for 4 microseconds
+
+	testCases := []struct {
+		optimalBitTiming uint8
+		quarterTrack     int
+		expected         uint64
+	}{
+		// The optimal bit timing of the INFO chunk is always used
+		{28, 0, 3_500_000},
+		{28, 4, 3_500_000},
+		{28, 8, 3_500_000}, // No track
+		// Without it, the short tracks are spread on a revolution
+		{0, 0, 4_000_000},
+		{0, 4, 5_000_000},
+		{0, 8, 4_000_000},
+	}
+	for _, tc := range testCases {
+		f.Info.OptimalBitTiming = tc.optimalBitTiming
+		timing := f.TrackBitTimingPs(tc.quarterTrack)
+		if timing != tc.expected {
+			t.Errorf("Bit timing %v for quarter track %v expected %v ps, got %v",
+				tc.optimalBitTiming, tc.quarterTrack, tc.expected, timing)
+		}
+	}
+}
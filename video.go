@@ -16,6 +16,7 @@ const (
 	hiResPageSize     = uint16(0x2000)
 	shResPageAddress  = uint16(0x2000)
 	shResPageSize     = uint16(0x8000)
+	videoFlashFrames  = 16
 )
 
 type video struct {
@@ -135,6 +136,11 @@ func (v *video) GetSuperVideoMemory() []uint8 {
 	return mem.subRange(shResPageAddress, shResPageAddress+shResPageSize)
 }
 
+// GetSuperPalettes returns the SHR palettes as seen by each scanline, nil if they didn't change on the frame
+func (v *video) GetSuperPalettes() []uint8 {
+	return v.a.superPalettes()
+}
+
 // GetCharacterPixel returns the pixel as output by the character generator
 func (v *video) GetCharacterPixel(char uint8, rowInChar int, colInChar int, isAltText bool, isFlashedFrame bool) bool {
 	var pixel bool
@@ -170,6 +176,34 @@ func (v *video) SupportsLowercase() bool {
 	return v.a.hasLowerCase
 }
 
+// IsFlashedFrame returns true when the flashing characters are shown inverted.
+// They toggle every 16 frames of the emulated time, close to 2Hz.
+func (v *video) IsFlashedFrame() bool {
+	frame := v.a.GetPublishedCycles() / v.a.timing.FrameCycles()
+	return (frame/videoFlashFrames)%2 == 1
+}
+
+// GetPalette returns the colours to use, nil for the default palette
+func (v *video) GetPalette() *screen.Palette {
+	return v.a.palette
+}
+
+// DumpTextMode returns the text mode contents as plain text. It uses the
+// 80 columns mode when active and the text of the Videx cards when shown.
+func DumpTextMode(a *Apple2) string {
+	if a.isSoftVideoSwitchActive() {
+		switch videx := a.softVideoSwitch.(type) {
+		case *CardVidexVideoterm:
+			return videx.getText()
+		case *CardVidexUltraterm:
+			return videx.getText()
+		}
+	}
+
+	is80Columns := a.video.GetCurrentVideoMode()&screen.VideoBaseMask == screen.VideoText80
+	return screen.RenderTextModeString(a.video, is80Columns, false, false, a.hasLowerCase, false)
+}
+
 // DumpTextModeAnsi returns the text mode contents using ANSI escape codes for reverse and flash
 func DumpTextModeAnsi(a *Apple2) string {
 	is80Columns := a.io.isSoftSwitchActive(ioFlag80Col)
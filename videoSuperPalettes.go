@@ -0,0 +1,101 @@
+package izapple2
+
+/*
+Super Hi-Res palette changes during the frame. The 3200 colours images
+rewrite the palettes while the beam draws the screen, to have a different
+set of colours on each scanline. Before every write to the palettes
+memory, the palettes are copied for the scanlines drawn since the previous
+write. The snapshots use these copies for the lines already drawn with
+the old colours.
+
+The beam position is the same used by the VERTBLANK softswitch. The SHR
+frame has 200 lines ending with the 192 visible lines of the other modes,
+the first 8 lines are drawn at the end of the vertical blanking.
+
+See "Apple IIGS Hardware Reference", chapter 4, "Scan-line control byte"
+*/
+
+const (
+	shrPalettesAddress = shResPageAddress + 0x7e00
+	shrPalettesSize    = 0x200
+	shrLines           = 200
+)
+
+type shrPaletteTracker struct {
+	lines []uint8 // Copy of the palettes memory used on each scanline
+	frame uint64  // Frame of the last write
+	line  int     // Lines copied on the frame of the last write
+}
+
+// shrBeamPosition returns the frame and the number of SHR lines already drawn in that frame
+func (a *Apple2) shrBeamPosition() (uint64, int) {
+	frame, line := a.timing.BeamPosition(a.cycles)
+	line += shrLines - a.timing.VisibleLines
+	if line < 0 {
+		line = 0 // Vertical blanking
+	}
+	return frame, line
+}
+
+// beforePaletteWrite is called before a write to the SHR palettes memory
+func (a *Apple2) beforePaletteWrite() {
+	t := &a.shrPalettes
+	frame, line := a.shrBeamPosition()
+	palettes := a.video.GetSuperVideoMemory()[shrPalettesAddress-shResPageAddress:]
+
+	if t.lines == nil {
+		t.lines = make([]uint8, shrLines*shrPalettesSize)
+		t.frame = frame
+		t.line = 0
+	}
+	if frame != t.frame {
+		if frame > t.frame+1 {
+			// The frames after the last write had no changes
+			t.line = 0
+		}
+		// Complete the previous frame
+		t.copyLines(t.line, shrLines, palettes)
+		t.frame = frame
+		t.line = 0
+	}
+	t.copyLines(t.line, line, palettes)
+	if line > t.line {
+		t.line = line
+	}
+}
+
+func (t *shrPaletteTracker) copyLines(from int, to int, palettes []uint8) {
+	for y := from; y < to; y++ {
+		copy(t.lines[y*shrPalettesSize:(y+1)*shrPalettesSize], palettes[:shrPalettesSize])
+	}
+}
+
+// superPalettes returns the palettes as seen by each line of the screen, nil if they are not changing
+func (a *Apple2) superPalettes() []uint8 {
+	t := &a.shrPalettes
+	frame, line := a.shrBeamPosition()
+	if t.lines == nil || frame > t.frame+1 {
+		return nil
+	}
+	palettes := a.video.GetSuperVideoMemory()[shrPalettesAddress-shResPageAddress:]
+
+	out := make([]uint8, shrLines*shrPalettesSize)
+	for y := 0; y < shrLines; y++ {
+		var useCopy bool
+		if frame == t.frame {
+			// Lines drawn before the last write, or drawn on the previous frame
+			useCopy = y < t.line || y >= line
+		} else {
+			// Lines not drawn yet on this frame, but drawn before the last write
+			useCopy = y >= line && y < t.line
+		}
+
+		lineOut := out[y*shrPalettesSize : (y+1)*shrPalettesSize]
+		if useCopy {
+			copy(lineOut, t.lines[y*shrPalettesSize:])
+		} else {
+			copy(lineOut, palettes)
+		}
+	}
+	return out
+}
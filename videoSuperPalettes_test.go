@@ -0,0 +1,112 @@
+package izapple2
+
+import (
+	"testing"
+)
+
+func TestSuperPalettesChangeOnFrame(t *testing.T) {
+	at, err := makeApple2Tester("2enh", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := at.a
+	timing := a.timing
+	frameCycles := timing.FrameCycles()
+	lineCycles := func(frame uint64, line int) uint64 {
+		return frame*frameCycles + uint64((timing.Lines-shrLines+line)*timing.CyclesPerLine)
+	}
+
+	if a.superPalettes() != nil {
+		t.Error("expected no palette changes")
+	}
+
+	// Change the first colour while drawing the line 50
+	a.cycles = lineCycles(10, 50)
+	a.mmu.altMainRAMActiveWrite = true
+	a.mmu.Poke(shrPalettesAddress, 0x0f)
+	a.mmu.altMainRAMActiveWrite = false
+
+	a.cycles = lineCycles(10, 150)
+	expected := map[int]uint8{10: 0x00, 100: 0x0f, 180: 0x00}
+	palettes := a.superPalettes()
+	for line, value := range expected {
+		got := palettes[line*shrPalettesSize]
+		if got != value {
+			t.Errorf("expected colour %02x on line %v, got %02x", value, line, got)
+		}
+	}
+
+	// On the next frame all the lines have the new colour
+	a.cycles = lineCycles(11, 100)
+	palettes = a.superPalettes()
+	for line := range expected {
+		got := palettes[line*shrPalettesSize]
+		if got != 0x0f {
+			t.Errorf("expected colour 0f on line %v of the next frame, got %02x", line, got)
+		}
+	}
+
+	a.cycles = lineCycles(12, 100)
+	if a.superPalettes() != nil {
+		t.Error("expected no palette changes two frames later")
+	}
+}
+
+func TestSuperPalettesFollowVertBlank(t *testing.T) {
+	at, err := makeApple2Tester("2enh", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := at.a
+
+	// Write the first colour when the blanking ends and again about 100 lines later
+	program := []uint8{
+		0xad, 0x19, 0xc0, // w1: LDA $C019
+		0x10, 0xfb, // BPL w1 ; Wait for the start of the blanking
+		0xad, 0x19, 0xc0, // w2: LDA $C019
+		0x30, 0xfb, // BMI w2 ; Wait for the end of the blanking
+		0x8d, 0x05, 0xc0, // STA $C005 ; Write on aux memory
+		0xa9, 0x01, // LDA #$01
+		0x8d, 0x00, 0x9e, // STA $9E00 ; First colour of the first palette
+		0xa0, 0x64, // LDY #100
+		0xa2, 0x0c, // line: LDX #12
+		0xca,       // wait: DEX
+		0xd0, 0xfd, // BNE wait
+		0x88,       // DEY
+		0xd0, 0xf8, // BNE line ; 66 cycles per iteration
+		0xa9, 0x02, // LDA #$02
+		0x8d, 0x00, 0x9e, // STA $9E00
+		0x8d, 0x04, 0xc0, // STA $C004
+	}
+	for i, value := range program {
+		a.mmu.Poke(0x300+uint16(i), value)
+	}
+	a.cpu.SetPC(0x300)
+	end := 0x300 + uint16(len(program))
+	for pc, _ := a.cpu.GetPCAndSP(); pc != end; pc, _ = a.cpu.GetPCAndSP() {
+		a.executeInstruction()
+	}
+
+	// Let the beam complete the frame
+	frame, _ := a.timing.BeamPosition(a.cycles)
+	a.cycles = (frame + 1) * a.timing.FrameCycles()
+
+	expected := map[int]uint8{
+		0:   0x00, // Drawn during the blanking, before the first write
+		7:   0x00,
+		8:   0x01, // First line after the blanking
+		100: 0x01,
+		112: 0x02,
+		199: 0x02,
+	}
+	palettes := a.superPalettes()
+	if palettes == nil {
+		t.Fatal("expected palette changes")
+	}
+	for line, value := range expected {
+		got := palettes[line*shrPalettesSize]
+		if got != value {
+			t.Errorf("expected colour %02x on line %v, got %02x", value, line, got)
+		}
+	}
+}